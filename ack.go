@@ -0,0 +1,38 @@
+package runstats
+
+import (
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// WriteAck confirms a batch of points written to the backend.
+type WriteAck struct {
+	// Points is the number of points written.
+	Points int
+	// Timestamps of the points in the order they were written.
+	Timestamps []time.Time
+	// Oldest and Newest are the earliest and latest point timestamps.
+	Oldest, Newest time.Time
+	// Written is when the backend confirmed the write, so Written.Sub(Oldest) is
+	// the worst end-to-end latency of the batch.
+	Written time.Time
+}
+
+func newWriteAck(points []*point.Point, written time.Time) WriteAck {
+	ack := WriteAck{
+		Points:     len(points),
+		Timestamps: make([]time.Time, len(points)),
+		Written:    written,
+	}
+	for i, p := range points {
+		ack.Timestamps[i] = p.Time
+		if ack.Oldest.IsZero() || p.Time.Before(ack.Oldest) {
+			ack.Oldest = p.Time
+		}
+		if p.Time.After(ack.Newest) {
+			ack.Newest = p.Time
+		}
+	}
+	return ack
+}
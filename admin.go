@@ -0,0 +1,158 @@
+package runstats
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// adminDuration accepts a duration as a string ("30s") or in nanoseconds.
+type adminDuration time.Duration
+
+func (d *adminDuration) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch v := v.(type) {
+	case string:
+		_d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = adminDuration(_d)
+	case float64:
+		*d = adminDuration(v)
+	default:
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	return nil
+}
+
+// configPatch are the settings that can be changed at runtime with ConfigHandler.
+type configPatch struct {
+	CollectionInterval *adminDuration  `json:"collection_interval"`
+	ExpvarExclude      *[]string       `json:"expvar_exclude"`
+	Groups             map[string]bool `json:"groups"`
+}
+
+// effectiveConfig returns a copy of the configuration as currently applied, with
+// the credentials removed.
+func (r *RunStats) effectiveConfig() Config {
+	r.configMu.RLock()
+	config := *r.config
+	r.configMu.RUnlock()
+
+	config.CollectionInterval = r.collector.Interval()
+	config.Token = ""
+	config.Password = ""
+	config.AdminToken = ""
+	config.TagSalt = ""
+	// Headers may carry API keys
+	if len(config.Headers) > 0 {
+		config.Headers = make(map[string]string, len(r.config.Headers))
+		for k := range r.config.Headers {
+			config.Headers[k] = "redacted"
+		}
+	}
+	// URLs may carry a password as user info
+	config.Host = redactURL(config.Host)
+	config.PushgatewayURL = redactURL(config.PushgatewayURL)
+	if hosts := config.FailoverHosts; len(hosts) > 0 {
+		config.FailoverHosts = make([]string, len(hosts))
+		for i, host := range hosts {
+			config.FailoverHosts[i] = redactURL(host)
+		}
+	}
+	return config
+}
+
+// redactURL replaces the password of the URL s, if any, with "xxxxx".
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+	return u.Redacted()
+}
+
+// ConfigHandler returns the admin http.Handler conventionally mounted at
+// /runstats/config. Requests must carry the configured AdminToken as a bearer
+// token; without an AdminToken all requests are rejected.
+//
+// GET returns the effective configuration, without credentials, plus the metric
+// groups as a JSON object. PATCH changes collection_interval (e.g. "30s"),
+// expvar_exclude and groups (e.g. {"groups": {"gc": false}}) without restarting
+// the collector; the patch is validated as a whole and rejected with 400 if any
+// part is invalid or names another setting.
+func (r *RunStats) ConfigHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !r.authorizeAdmin(req) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPatch:
+			var patch configPatch
+			d := json.NewDecoder(req.Body)
+			d.DisallowUnknownFields()
+			if err := d.Decode(&patch); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := r.applyConfigPatch(&patch); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, PATCH")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Config
+			Groups map[string]bool `json:"groups"`
+		}{r.effectiveConfig(), r.collector.Groups()})
+	})
+}
+
+func (r *RunStats) authorizeAdmin(req *http.Request) bool {
+	if r.config.AdminToken == "" {
+		return false
+	}
+	expected := "Bearer " + r.config.AdminToken
+	return subtle.ConstantTimeCompare([]byte(req.Header.Get("Authorization")), []byte(expected)) == 1
+}
+
+func (r *RunStats) applyConfigPatch(patch *configPatch) error {
+	if patch.CollectionInterval != nil && *patch.CollectionInterval <= 0 {
+		return fmt.Errorf("%w: collection_interval must be positive", ErrInvalidConfig)
+	}
+	groups := r.collector.Groups()
+	for name := range patch.Groups {
+		if _, ok := groups[name]; !ok {
+			return fmt.Errorf("%w: unknown metric group %s", ErrInvalidConfig, name)
+		}
+	}
+
+	r.configMu.Lock()
+	if patch.CollectionInterval != nil {
+		r.setIntervals(time.Duration(*patch.CollectionInterval), r.config.FleetReducedInterval)
+	}
+	if patch.ExpvarExclude != nil {
+		r.config.ExpvarExclude = *patch.ExpvarExclude
+	}
+	r.configMu.Unlock()
+	for name, enabled := range patch.Groups {
+		r.collector.SetGroup(name, enabled)
+	}
+	return nil
+}
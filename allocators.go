@@ -0,0 +1,30 @@
+package runstats
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+)
+
+// writeTopAllocators writes the HeapTopN allocation sites with the most bytes in
+// use to the AllocMeasurement, at most once per HeapProfileInterval. Points are
+// tagged with the site hash and its first non-runtime frame.
+func (r *RunStats) writeTopAllocators(ts time.Time) {
+	if !r.lastHeapProfile.IsZero() && ts.Sub(r.lastHeapProfile) < r.config.HeapProfileInterval {
+		return
+	}
+	r.lastHeapProfile = ts
+
+	for i, site := range collector.TopAllocators(r.config.HeapTopN) {
+		r.writer.WritePoint(r.config.AllocMeasurement, r.pointTags(map[string]string{
+			"site":     site.Hash,
+			"function": site.Function,
+			"location": site.File + ":" + strconv.Itoa(site.Line),
+		}), map[string]interface{}{
+			"rank":          i + 1,
+			"inuse.bytes":   site.InUseBytes,
+			"inuse.objects": site.InUseObjects,
+		}, ts)
+	}
+}
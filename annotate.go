@@ -0,0 +1,31 @@
+package runstats
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Annotate writes an event-style point (deploy, config change, incident, ...) to
+// the configured AnnotationMeasurement. The point carries "title" and "text"
+// fields plus a comma separated "tags" field, which maps directly onto the
+// Text/Tags columns of a Grafana InfluxDB annotation query. The given tags are
+// also attached to the point as regular InfluxDB tags, together with the static
+// tags and an "instance" tag holding the current Identity.
+func (r *RunStats) Annotate(title, text string, tags map[string]string) {
+	_tags := r.pointTags(tags)
+	_tags["instance"] = r.instance()
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+":"+v)
+	}
+	sort.Strings(pairs)
+
+	fields := map[string]interface{}{
+		"title": title,
+		"text":  text,
+		"tags":  strings.Join(pairs, ","),
+	}
+
+	r.writer.WritePoint(r.config.AnnotationMeasurement, _tags, fields, time.Now())
+}
@@ -0,0 +1,122 @@
+package runstats
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// appMetrics holds the application metrics of a RunStats, see RegisterGauge.
+type appMetrics struct {
+	mu      sync.Mutex
+	metrics map[string]func() interface{}
+}
+
+// Counter is an application counter written every collection, see
+// RunStats.NewCounter. It is safe for concurrent use.
+type Counter struct {
+	v int64 // accessed atomically
+}
+
+// Inc adds 1 to the counter.
+func (c *Counter) Inc() {
+	atomic.AddInt64(&c.v, 1)
+}
+
+// Add adds n to the counter.
+func (c *Counter) Add(n int64) {
+	atomic.AddInt64(&c.v, n)
+}
+
+// Value returns the count.
+func (c *Counter) Value() int64 {
+	return atomic.LoadInt64(&c.v)
+}
+
+// Gauge is an application gauge written every collection, see RunStats.NewGauge.
+// It is safe for concurrent use.
+type Gauge struct {
+	bits uint64 // float64 bits, accessed atomically
+}
+
+// Set sets the gauge to v.
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Add adds delta, which may be negative, to the gauge.
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := atomic.LoadUint64(&g.bits)
+		v := math.Float64bits(math.Float64frombits(old) + delta)
+		if atomic.CompareAndSwapUint64(&g.bits, old, v) {
+			return
+		}
+	}
+}
+
+// Value returns the value of the gauge.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// NewCounter returns a Counter written as the field name of the AppMeasurement
+// every collection.
+func (r *RunStats) NewCounter(name string) *Counter {
+	c := &Counter{}
+	r.registerMetric(name, func() interface{} { return c.Value() })
+	return c
+}
+
+// NewGauge returns a Gauge written as the field name of the AppMeasurement every
+// collection.
+func (r *RunStats) NewGauge(name string) *Gauge {
+	g := &Gauge{}
+	r.registerMetric(name, func() interface{} { return g.Value() })
+	return g
+}
+
+// RegisterGauge writes the value returned by fn as the field name of the
+// AppMeasurement every collection, e.g. the length of a queue. fn is called from
+// the collector goroutine and must not block.
+func (r *RunStats) RegisterGauge(name string, fn func() float64) {
+	r.registerMetric(name, func() interface{} { return fn() })
+}
+
+// Unregister stops writing the metric registered under name.
+func (r *RunStats) Unregister(name string) {
+	r.app.mu.Lock()
+	defer r.app.mu.Unlock()
+	delete(r.app.metrics, name)
+}
+
+// registerMetric registers value under name, replacing any metric of that name.
+func (r *RunStats) registerMetric(name string, value func() interface{}) {
+	r.app.mu.Lock()
+	defer r.app.mu.Unlock()
+	if r.app.metrics == nil {
+		r.app.metrics = map[string]func() interface{}{}
+	}
+	r.app.metrics[name] = value
+}
+
+// writeAppMetrics writes the application metrics as the fields of a single point
+// to the AppMeasurement.
+func (r *RunStats) writeAppMetrics(ts time.Time) {
+	r.app.mu.Lock()
+	metrics := make(map[string]func() interface{}, len(r.app.metrics))
+	for name, value := range r.app.metrics {
+		metrics[name] = value
+	}
+	r.app.mu.Unlock()
+
+	if len(metrics) == 0 {
+		return
+	}
+	fields := make(map[string]interface{}, len(metrics))
+	for name, value := range metrics {
+		fields[name] = value()
+	}
+	r.writer.WritePoint(r.config.AppMeasurement, r.pointTags(nil), fields, ts)
+}
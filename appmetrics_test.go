@@ -0,0 +1,46 @@
+package runstats
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAppMetrics(t *testing.T) {
+	stats, tr := newTestRunStats(nil)
+
+	orders := stats.NewCounter("orders")
+	inflight := stats.NewGauge("inflight")
+	depth := 3.0
+	stats.RegisterGauge("queue.depth", func() float64 { return depth })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			orders.Inc()
+			inflight.Add(0.5)
+		}()
+	}
+	wg.Wait()
+	orders.Add(5)
+
+	stats.writeAppMetrics(time.Now())
+	points := tr.Points(stats)
+	if len(points) != 1 || points[0].Measurement != defaultAppMeasurement {
+		t.Fatalf("expected an app point got %v", points)
+	}
+	fields := points[0].Fields
+	if fields["orders"] != int64(15) || fields["inflight"] != 5.0 || fields["queue.depth"] != 3.0 {
+		t.Errorf("unexpected app fields %v", fields)
+	}
+
+	stats.Unregister("orders")
+	stats.Unregister("inflight")
+	stats.Unregister("queue.depth")
+	stats.writeAppMetrics(time.Now())
+	if points := tr.Points(stats); len(points) != 1 {
+		t.Errorf("expected no point without metrics got %d", len(points)-1)
+	}
+}
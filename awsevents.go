@@ -0,0 +1,363 @@
+package runstats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSummaryInterval  = time.Minute
+	defaultEventSource      = "go-runtime-metrics"
+	defaultEventDetailType  = "Go Runtime Summary"
+	defaultEventBusName     = "default"
+	defaultAWSClientTimeout = 10 * time.Second
+)
+
+// RuntimeSummary summarizes the points written during an interval. It is the
+// detail of the events published by EventBridgeSink and the message published
+// by SNSSink.
+type RuntimeSummary struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	// Tags shared by all the points of the interval, e.g. host and env.
+	Tags   map[string]string `json:"tags"`
+	Points int               `json:"points"`
+	// Summaries of the numeric fields by measurement and field.
+	Measurements map[string]map[string]*FieldSummary `json:"measurements"`
+}
+
+// FieldSummary summarizes the values of a field over an interval.
+type FieldSummary struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	Last  float64 `json:"last"`
+}
+
+// summarizer aggregates the points of every interval into a RuntimeSummary,
+// published once a point of a later interval is written. Intervals are aligned
+// to multiples of their length since the Unix epoch.
+type summarizer struct {
+	mu      sync.Mutex
+	current *RuntimeSummary
+	lastAt  map[string]time.Time // time of the Last value by measurement and field
+}
+
+// add adds a point to the summary of its interval, publishing the summary of
+// the previous interval first. Points of intervals already published, e.g.
+// retried ones, are ignored. A summary that fails to publish is dropped and
+// the error returned, without adding the point.
+func (s *summarizer) add(interval time.Duration, measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time, publish func(*RuntimeSummary) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current != nil && ts.Before(s.current.Start) {
+		return nil
+	}
+	if s.current == nil || !ts.Before(s.current.End) {
+		prev := s.current
+		start := ts.Truncate(interval)
+		s.current = &RuntimeSummary{Start: start, End: start.Add(interval), Measurements: map[string]map[string]*FieldSummary{}}
+		s.lastAt = map[string]time.Time{}
+		if prev != nil {
+			if err := publish(prev); err != nil {
+				return err
+			}
+		}
+	}
+
+	sum := s.current
+	if sum.Points == 0 {
+		sum.Tags = make(map[string]string, len(tags))
+		for k, v := range tags {
+			sum.Tags[k] = v
+		}
+	} else {
+		for k, v := range sum.Tags {
+			if tags[k] != v {
+				delete(sum.Tags, k)
+			}
+		}
+	}
+	sum.Points++
+
+	for name, v := range fields {
+		f, ok := promValue(v)
+		if !ok {
+			continue
+		}
+		m := sum.Measurements[measurement]
+		if m == nil {
+			m = map[string]*FieldSummary{}
+			sum.Measurements[measurement] = m
+		}
+		fs := m[name]
+		if fs == nil {
+			fs = &FieldSummary{Min: f, Max: f}
+			m[name] = fs
+		}
+		if f < fs.Min {
+			fs.Min = f
+		}
+		if f > fs.Max {
+			fs.Max = f
+		}
+		fs.Mean += (f - fs.Mean) / float64(fs.Count+1)
+		fs.Count++
+		if key := measurement + "\x00" + name; !ts.Before(s.lastAt[key]) {
+			fs.Last = f
+			s.lastAt[key] = ts
+		}
+	}
+	return nil
+}
+
+// flush publishes the summary of the current interval, if any.
+func (s *summarizer) flush(publish func(*RuntimeSummary) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sum := s.current
+	s.current = nil
+	if sum == nil || sum.Points == 0 {
+		return nil
+	}
+	return publish(sum)
+}
+
+// EventBridgeSink is a Sink publishing a RuntimeSummary of the points written
+// every Interval as an event to Amazon EventBridge, e.g. for rules triggering
+// scale-ups or alarms from the runtime health of the application. Use it as
+// Config.Sink or, next to another backend, in Config.Sinks. The summary of the
+// last interval is published by Close.
+type EventBridgeSink struct {
+	// AWS region. Default is $AWS_REGION
+	Region string
+	// Default is "default"
+	EventBusName string
+	// Source of the events. Default is "go-runtime-metrics"
+	Source string
+	// DetailType of the events. Default is "Go Runtime Summary"
+	DetailType string
+	// Interval summarized per event. Default is 1 minute
+	Interval time.Duration
+
+	// Credentials signing the requests. A *SigV4Credentials without Region or
+	// Service gets those of the sink. Default is a *SigV4Credentials from
+	// $AWS_ACCESS_KEY_ID, $AWS_SECRET_ACCESS_KEY and $AWS_SESSION_TOKEN, as set
+	// in AWS Lambda.
+	Credentials CredentialsProvider
+	// Default is "https://events.<Region>.amazonaws.com"
+	Endpoint string
+	// Default is a client with a 10 seconds timeout
+	HTTPClient *http.Client
+
+	summaries summarizer
+}
+
+func (s *EventBridgeSink) WritePoint(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	return s.summaries.add(summaryInterval(s.Interval), measurement, tags, fields, ts, s.publish)
+}
+
+// Close publishes the summary of the current interval.
+func (s *EventBridgeSink) Close() error {
+	return s.summaries.flush(s.publish)
+}
+
+func (s *EventBridgeSink) publish(sum *RuntimeSummary) error {
+	detail, err := json.Marshal(sum)
+	if err != nil {
+		return err
+	}
+
+	entry := map[string]interface{}{
+		"Source":       orDefault(s.Source, defaultEventSource),
+		"DetailType":   orDefault(s.DetailType, defaultEventDetailType),
+		"Detail":       string(detail),
+		"EventBusName": orDefault(s.EventBusName, defaultEventBusName),
+		"Time":         sum.End.Unix(),
+	}
+	body, err := json.Marshal(map[string]interface{}{"Entries": []interface{}{entry}})
+	if err != nil {
+		return err
+	}
+
+	region := awsRegion(s.Region)
+	endpoint := orDefault(s.Endpoint, "https://events."+region+".amazonaws.com")
+	resp, err := awsPost(s.HTTPClient, awsCredentials(s.Credentials, region, "events"), endpoint, "application/x-amz-json-1.1", "AWSEvents.PutEvents", body)
+	if err != nil {
+		return fmt.Errorf("eventbridge: %w", err)
+	}
+
+	var result struct {
+		FailedEntryCount int
+		Entries          []struct{ ErrorCode, ErrorMessage string }
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return fmt.Errorf("eventbridge: %w", err)
+	}
+	if result.FailedEntryCount > 0 && len(result.Entries) > 0 {
+		return fmt.Errorf("eventbridge: %s: %s", result.Entries[0].ErrorCode, result.Entries[0].ErrorMessage)
+	}
+	return nil
+}
+
+// SNSSink is a Sink publishing a RuntimeSummary of the points written every
+// Interval as a JSON message to an Amazon SNS topic, like EventBridgeSink.
+type SNSSink struct {
+	// ARN of the topic, required.
+	TopicArn string
+	// AWS region. Default is the region of TopicArn
+	Region string
+	// Subject of the messages, for email subscriptions. Default is ""
+	Subject string
+	// Interval summarized per message. Default is 1 minute
+	Interval time.Duration
+
+	// Credentials signing the requests, see EventBridgeSink.Credentials.
+	Credentials CredentialsProvider
+	// Default is "https://sns.<Region>.amazonaws.com"
+	Endpoint string
+	// Default is a client with a 10 seconds timeout
+	HTTPClient *http.Client
+
+	summaries summarizer
+}
+
+func (s *SNSSink) WritePoint(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	return s.summaries.add(summaryInterval(s.Interval), measurement, tags, fields, ts, s.publish)
+}
+
+// Close publishes the summary of the current interval.
+func (s *SNSSink) Close() error {
+	return s.summaries.flush(s.publish)
+}
+
+func (s *SNSSink) publish(sum *RuntimeSummary) error {
+	if s.TopicArn == "" {
+		return withKind(ErrInvalidConfig, fmt.Errorf("sns: topic arn is required"))
+	}
+	message, err := json.Marshal(sum)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{
+		"Action":   {"Publish"},
+		"Version":  {"2010-03-31"},
+		"TopicArn": {s.TopicArn},
+		"Message":  {string(message)},
+	}
+	if s.Subject != "" {
+		form.Set("Subject", s.Subject)
+	}
+
+	// arn:aws:sns:<region>:<account>:<topic>
+	region := s.Region
+	if region == "" {
+		if parts := strings.Split(s.TopicArn, ":"); len(parts) > 3 {
+			region = parts[3]
+		}
+	}
+	region = awsRegion(region)
+	endpoint := orDefault(s.Endpoint, "https://sns."+region+".amazonaws.com")
+	if _, err := awsPost(s.HTTPClient, awsCredentials(s.Credentials, region, "sns"), endpoint, "application/x-www-form-urlencoded", "", []byte(form.Encode())); err != nil {
+		return fmt.Errorf("sns: %w", err)
+	}
+	return nil
+}
+
+func summaryInterval(interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return defaultSummaryInterval
+	}
+	return interval
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+func awsRegion(region string) string {
+	if region == "" {
+		return os.Getenv("AWS_REGION")
+	}
+	return region
+}
+
+// awsCredentials returns creds completed with region and service, or the
+// credentials of the environment if creds is nil.
+func awsCredentials(creds CredentialsProvider, region, service string) CredentialsProvider {
+	if creds == nil {
+		return &SigV4Credentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			Region:          region,
+			Service:         service,
+		}
+	}
+	if sigv4, ok := creds.(*SigV4Credentials); ok && (sigv4.Region == "" || sigv4.Service == "") {
+		c := *sigv4
+		c.Region = orDefault(c.Region, region)
+		c.Service = orDefault(c.Service, service)
+		return &c
+	}
+	return creds
+}
+
+// awsPost posts body to an AWS API endpoint, signed by creds, and returns the
+// response body. target, if set, is the X-Amz-Target of JSON protocol APIs.
+func awsPost(client *http.Client, creds CredentialsProvider, endpoint, contentType, target string, body []byte) ([]byte, error) {
+	if client == nil {
+		client = &http.Client{Timeout: defaultAWSClientTimeout}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), defaultAWSClientTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", contentType)
+	if target != "" {
+		req.Header.Set("X-Amz-Target", target)
+	}
+	if err := creds.Authorize(req); err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, withKind(ErrBackendUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode/100 != 2 {
+		if len(b) > 512 {
+			b = b[:512]
+		}
+		err := &statusError{resp.StatusCode, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(b))}
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return nil, withKind(ErrBackendUnavailable, err)
+		}
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,133 @@
+package runstats
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventBridgeSink(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*http.Request
+	var bodies [][]byte
+	failed := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests, bodies = append(requests, req), append(bodies, b)
+		if failed {
+			w.Write([]byte(`{"FailedEntryCount":1,"Entries":[{"ErrorCode":"InternalFailure","ErrorMessage":"try again"}]}`))
+			return
+		}
+		w.Write([]byte(`{"FailedEntryCount":0,"Entries":[{"EventId":"1"}]}`))
+	}))
+	defer srv.Close()
+
+	sink := &EventBridgeSink{
+		Region:      "eu-west-1",
+		Credentials: &SigV4Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		Endpoint:    srv.URL,
+	}
+	start := time.Unix(600, 0)
+	write := func(offset time.Duration, host string, alloc int64) error {
+		return sink.WritePoint("go.runtime", map[string]string{"env": "prod", "host": host}, map[string]interface{}{"mem.alloc": alloc, "go.version": "go1.16"}, start.Add(offset))
+	}
+	write(0, "a", 10)
+	write(30*time.Second, "b", 30)
+	write(10*time.Second, "a", 20)
+	if len(requests) != 0 {
+		t.Fatalf("expected nothing published within the interval got %d", len(requests))
+	}
+	if err := write(time.Minute, "a", 5); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 {
+		t.Fatalf("expected the first interval published got %d", len(requests))
+	}
+
+	req := requests[0]
+	if req.Header.Get("X-Amz-Target") != "AWSEvents.PutEvents" || !strings.Contains(req.Header.Get("Authorization"), "/eu-west-1/events/aws4_request") {
+		t.Errorf("unexpected request headers %v", req.Header)
+	}
+	var input struct {
+		Entries []struct{ Source, DetailType, Detail, EventBusName string }
+	}
+	if err := json.Unmarshal(bodies[0], &input); err != nil || len(input.Entries) != 1 {
+		t.Fatalf("unexpected body %s", bodies[0])
+	}
+	entry := input.Entries[0]
+	if entry.Source != "go-runtime-metrics" || entry.DetailType != "Go Runtime Summary" || entry.EventBusName != "default" {
+		t.Errorf("unexpected entry %+v", entry)
+	}
+	var sum RuntimeSummary
+	if err := json.Unmarshal([]byte(entry.Detail), &sum); err != nil {
+		t.Fatal(err)
+	}
+	fs := sum.Measurements["go.runtime"]["mem.alloc"]
+	if sum.Points != 3 || !sum.Start.Equal(start) || !sum.End.Equal(start.Add(time.Minute)) || len(sum.Tags) != 1 || sum.Tags["env"] != "prod" {
+		t.Errorf("unexpected summary %+v", sum)
+	}
+	if fs == nil || fs.Count != 3 || fs.Min != 10 || fs.Max != 30 || fs.Mean != 20 || fs.Last != 30 || len(sum.Measurements["go.runtime"]) != 1 {
+		t.Errorf("unexpected field summary %+v", sum.Measurements)
+	}
+
+	// A failed publish drops the summary, retried points of it are ignored
+	failed = true
+	if err := write(2*time.Minute, "a", 1); err == nil || !strings.Contains(err.Error(), "InternalFailure") {
+		t.Errorf("expected the entry error got %v", err)
+	}
+	failed = false
+	write(time.Minute, "a", 5)
+	write(2*time.Minute, "a", 1)
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 3 {
+		t.Fatalf("expected the last interval published on close got %d", len(requests))
+	}
+	json.Unmarshal(bodies[2], &input)
+	json.Unmarshal([]byte(input.Entries[0].Detail), &sum)
+	if sum.Points != 1 || !sum.Start.Equal(start.Add(2*time.Minute)) {
+		t.Errorf("unexpected last summary %+v", sum)
+	}
+}
+
+func TestSNSSink(t *testing.T) {
+	var form url.Values
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		req.ParseForm()
+		form, auth = req.PostForm, req.Header.Get("Authorization")
+		w.Write([]byte(`<PublishResponse><PublishResult><MessageId>1</MessageId></PublishResult></PublishResponse>`))
+	}))
+	defer srv.Close()
+
+	sink := &SNSSink{
+		TopicArn:    "arn:aws:sns:us-east-2:123456789012:runtime",
+		Subject:     "runtime health",
+		Credentials: &SigV4Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"},
+		Endpoint:    srv.URL,
+	}
+	sink.WritePoint("go.runtime", nil, map[string]interface{}{"cpu.goroutines": int64(12)}, time.Now())
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var sum RuntimeSummary
+	if form.Get("Action") != "Publish" || form.Get("TopicArn") != sink.TopicArn || form.Get("Subject") != "runtime health" {
+		t.Errorf("unexpected form %v", form)
+	}
+	if err := json.Unmarshal([]byte(form.Get("Message")), &sum); err != nil || sum.Measurements["go.runtime"]["cpu.goroutines"].Last != 12 {
+		t.Errorf("unexpected message %s", form.Get("Message"))
+	}
+	if !strings.Contains(auth, "/us-east-2/sns/aws4_request") {
+		t.Errorf("expected the region of the topic got %s", auth)
+	}
+}
@@ -0,0 +1,88 @@
+package runstats
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// errBudgetExceeded is reported for points dropped by the bandwidth budget.
+var errBudgetExceeded = errors.New("runstats: bandwidth budget exceeded")
+
+// budget caps the bytes written per window. The size of a point is estimated by
+// its line protocol encoding. Once a point doesn't fit the remaining budget it is
+// reduced to its critical fields, and dropped if it has none or still doesn't fit.
+type budget struct {
+	limit    int
+	window   time.Duration
+	critical map[string]bool
+	now      func() time.Time
+
+	// start and used are only accessed by the writer while flushing.
+	start time.Time
+	used  int
+	buf   []byte
+
+	droppedFields int64 // accessed atomically
+}
+
+func newBudget(limit int, window time.Duration, critical []string) *budget {
+	b := &budget{
+		limit:    limit,
+		window:   window,
+		critical: make(map[string]bool, len(critical)),
+		now:      time.Now,
+	}
+	for _, f := range critical {
+		b.critical[f] = true
+	}
+	return b
+}
+
+// apply returns the points of batch that fit the budget and the number of points
+// dropped entirely.
+func (b *budget) apply(batch []*point.Point) ([]*point.Point, int) {
+	if now := b.now(); now.Sub(b.start) >= b.window {
+		b.start, b.used = now, 0
+	}
+
+	kept := batch[:0:0]
+	dropped := 0
+	for _, p := range batch {
+		if size := b.size(p); b.used+size <= b.limit {
+			b.used += size
+			kept = append(kept, p)
+			continue
+		}
+
+		reduced := &point.Point{Measurement: p.Measurement, Tags: p.Tags, Time: p.Time, Fields: map[string]interface{}{}, Units: map[string]string{}}
+		for k, v := range p.Fields {
+			if b.critical[k] {
+				reduced.Fields[k] = v
+				if unit, ok := p.Units[k]; ok {
+					reduced.Units[k] = unit
+				}
+			}
+		}
+		if size := b.size(reduced); len(reduced.Fields) > 0 && b.used+size <= b.limit {
+			b.used += size
+			kept = append(kept, reduced)
+			atomic.AddInt64(&b.droppedFields, int64(len(p.Fields)-len(reduced.Fields)))
+			continue
+		}
+
+		atomic.AddInt64(&b.droppedFields, int64(len(p.Fields)))
+		dropped++
+	}
+	return kept, dropped
+}
+
+func (b *budget) size(p *point.Point) int {
+	var err error
+	if b.buf, err = point.AppendLineProtocol(b.buf[:0], p); err != nil {
+		return 0
+	}
+	return len(b.buf)
+}
@@ -0,0 +1,30 @@
+package runstats
+
+import (
+	"runtime/debug"
+)
+
+// buildInfo describes the main module of the running binary.
+type buildInfo struct {
+	Path     string
+	Version  string
+	Revision string // short VCS revision, empty when unknown
+}
+
+func readBuildInfo() buildInfo {
+	info := buildInfo{Path: "unknown", Version: "unknown"}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	if bi.Main.Path != "" {
+		info.Path = bi.Main.Path
+	}
+	if bi.Main.Version != "" {
+		info.Version = bi.Main.Version
+	}
+	info.Revision = vcsRevision(bi)
+
+	return info
+}
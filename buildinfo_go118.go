@@ -0,0 +1,21 @@
+//go:build go1.18
+// +build go1.18
+
+package runstats
+
+import (
+	"runtime/debug"
+)
+
+// vcsRevision returns the short VCS revision stamped into the binary.
+func vcsRevision(bi *debug.BuildInfo) string {
+	for _, s := range bi.Settings {
+		if s.Key == "vcs.revision" {
+			if len(s.Value) > 7 {
+				return s.Value[:7]
+			}
+			return s.Value
+		}
+	}
+	return ""
+}
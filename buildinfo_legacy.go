@@ -0,0 +1,13 @@
+//go:build !go1.18
+// +build !go1.18
+
+package runstats
+
+import (
+	"runtime/debug"
+)
+
+// vcsRevision returns an empty revision as VCS stamping requires Go 1.18.
+func vcsRevision(bi *debug.BuildInfo) string {
+	return ""
+}
@@ -0,0 +1,121 @@
+package runstats
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+// certificates holds the watched certificates of a RunStats, see
+// WatchCertificate. A watched certificate is loaded anew every collection.
+type certificates struct {
+	mu      sync.Mutex
+	watched map[string]func() (*x509.Certificate, error)
+}
+
+// WatchCertificate writes the expiry of the leaf of cert to the CertMeasurement
+// every collection, tagged cert=name, until UnwatchCertificate is called with
+// name. Watching another certificate under the same name replaces it.
+func (r *RunStats) WatchCertificate(name string, cert *tls.Certificate) error {
+	leaf, err := certificateLeaf(cert)
+	if err != nil {
+		return err
+	}
+	r.watchCertificate(name, func() (*x509.Certificate, error) { return leaf, nil })
+	return nil
+}
+
+// WatchCertificateFile is like WatchCertificate for the first certificate of a
+// PEM file, which is read again every collection so that rotated certificates
+// are picked up.
+func (r *RunStats) WatchCertificateFile(name, path string) error {
+	load := func() (*x509.Certificate, error) {
+		return loadCertificateFile(path)
+	}
+	if _, err := load(); err != nil {
+		return err
+	}
+	r.watchCertificate(name, load)
+	return nil
+}
+
+// UnwatchCertificate stops writing the expiry of the certificate watched under
+// name.
+func (r *RunStats) UnwatchCertificate(name string) {
+	r.certs.mu.Lock()
+	defer r.certs.mu.Unlock()
+	delete(r.certs.watched, name)
+}
+
+func (r *RunStats) watchCertificate(name string, load func() (*x509.Certificate, error)) {
+	r.certs.mu.Lock()
+	defer r.certs.mu.Unlock()
+	if r.certs.watched == nil {
+		r.certs.watched = map[string]func() (*x509.Certificate, error){}
+	}
+	r.certs.watched[name] = load
+}
+
+// writeCertificates writes a point per watched certificate with days_until_expiry
+// (negative once expired), expires_at in unix seconds and expired, tagged with
+// its name, subject and issuer common names.
+func (r *RunStats) writeCertificates(ts time.Time) {
+	r.certs.mu.Lock()
+	watched := make(map[string]func() (*x509.Certificate, error), len(r.certs.watched))
+	for name, load := range r.certs.watched {
+		watched[name] = load
+	}
+	r.certs.mu.Unlock()
+
+	for name, load := range watched {
+		cert, err := load()
+		if err != nil {
+			r.logError("runstats: failed to load certificate "+name+":", err)
+			continue
+		}
+		tags := r.pointTags(map[string]string{
+			"cert":    name,
+			"subject": cert.Subject.CommonName,
+			"issuer":  cert.Issuer.CommonName,
+		})
+		r.writer.WritePoint(r.config.CertMeasurement, tags, map[string]interface{}{
+			"days_until_expiry": cert.NotAfter.Sub(ts).Hours() / 24,
+			"expires_at":        cert.NotAfter.Unix(),
+			"expired":           !ts.Before(cert.NotAfter),
+		}, ts)
+	}
+}
+
+// certificateLeaf returns the parsed leaf of cert.
+func certificateLeaf(cert *tls.Certificate) (*x509.Certificate, error) {
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, errors.New("runstats: empty certificate")
+	}
+	return x509.ParseCertificate(cert.Certificate[0])
+}
+
+// loadCertificateFile parses the first certificate of a PEM file.
+func loadCertificateFile(path string) (*x509.Certificate, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("runstats: no certificate in %s", path)
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
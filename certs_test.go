@@ -0,0 +1,90 @@
+package runstats
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func testCertificate(t *testing.T, cn string, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestWatchCertificate(t *testing.T) {
+	stats, tr := newTestRunStats(nil)
+	// Certificates store their validity in seconds
+	now := time.Now().Truncate(time.Second)
+
+	server := testCertificate(t, "api.example.com", now.Add(36*time.Hour))
+	if err := stats.WatchCertificate("server", &tls.Certificate{Certificate: [][]byte{server}}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "client.pem")
+	client := testCertificate(t, "client", now.Add(-time.Hour))
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: client}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := stats.WatchCertificateFile("client", path); err != nil {
+		t.Fatal(err)
+	}
+	if err := stats.WatchCertificateFile("missing", filepath.Join(dir, "missing.pem")); err == nil {
+		t.Error("expected an error for a missing certificate file")
+	}
+
+	stats.writeCertificates(now)
+	points := tr.Points(stats)
+	if len(points) != 2 {
+		t.Fatalf("expected a point per certificate got %d", len(points))
+	}
+	for _, p := range points {
+		switch p.Tags["cert"] {
+		case "server":
+			if p.Fields["days_until_expiry"].(float64) != 1.5 || p.Fields["expired"] != false || p.Tags["subject"] != "api.example.com" {
+				t.Errorf("unexpected server certificate point %v %v", p.Tags, p.Fields)
+			}
+		case "client":
+			if p.Fields["days_until_expiry"].(float64) >= 0 || p.Fields["expired"] != true {
+				t.Errorf("unexpected client certificate point %v %v", p.Tags, p.Fields)
+			}
+		default:
+			t.Errorf("unexpected certificate %v", p.Tags)
+		}
+	}
+
+	stats.UnwatchCertificate("server")
+	stats.UnwatchCertificate("client")
+	stats.writeCertificates(now)
+	if points := tr.Points(stats); len(points) != 2 {
+		t.Errorf("expected no points for unwatched certificates got %d", len(points)-2)
+	}
+}
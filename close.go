@@ -0,0 +1,115 @@
+package runstats
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DroppedError is returned by Close when points could not be written before the
+// deadline of its context (or because the backend failed).
+type DroppedError struct {
+	// Dropped is the number of points dropped while closing.
+	Dropped int64
+	// Err is the first error encountered, e.g. context.DeadlineExceeded.
+	Err error
+}
+
+func (e *DroppedError) Error() string {
+	return fmt.Sprintf("runstats: %d points dropped on close: %v", e.Dropped, e.Err)
+}
+
+func (e *DroppedError) Unwrap() error {
+	return e.Err
+}
+
+// Close stops collecting, writes the Middleware statistics of the requests served
+// since the last collection, the final values of the application metrics and the
+// stop marker, and flushes all pending points, giving up when ctx is done. Points
+// that couldn't be written in time are reported by a *DroppedError. Close is also
+// called with a ShutdownTimeout deadline when the context passed to RunCollector
+// is done; only the first call has any effect.
+func (r *RunStats) Close(ctx context.Context) error {
+	r.closeOnce.Do(func() {
+		close(r.done)
+		<-r.stopped
+
+		if r.tracer != nil {
+			r.tracer.recorder.stop()
+		}
+
+		now := time.Now()
+		r.drainHTTPStats(now)
+		r.writeAppMetrics(now)
+
+		if !r.config.DisableMarkers {
+			r.marker(markerStop)
+		}
+		r.closeErr = r.writer.Close(ctx)
+	})
+	return r.closeErr
+}
+
+// Flush writes the pending points now rather than at the next FlushInterval,
+// e.g. before a checkpoint. Points of failed writes are kept for retry as with
+// the periodic flushes; the first write error is returned.
+func (r *RunStats) Flush(ctx context.Context) error {
+	return r.writer.Flush(ctx)
+}
+
+// Dropped returns the total number of points dropped because they couldn't be
+// written to the backend.
+func (r *RunStats) Dropped() int64 {
+	return r.writer.Dropped()
+}
+
+// DroppedFields returns the total number of fields dropped by the bandwidth
+// budget, see Config.MaxBytesPerInterval. It includes the fields of points that
+// were dropped entirely.
+func (r *RunStats) DroppedFields() int64 {
+	if r.writer.budget == nil {
+		return 0
+	}
+	return atomic.LoadInt64(&r.writer.budget.droppedFields)
+}
+
+// WriteErrors returns the number of failed writes so far by class: "auth",
+// "rejected", "throttled", "timeout" and "unavailable". Points of auth and
+// rejected failures are dropped right away, the others are kept for retry up to
+// Config.RetryBufferSize, throttled ones after an exponential backoff.
+func (r *RunStats) WriteErrors() map[string]int64 {
+	counts := map[string]int64{}
+	for class, n := range r.writer.Errors() {
+		counts[string(class)] = n
+	}
+	return counts
+}
+
+// writeWriterStats writes the failed writes by class and the dropped points,
+// once a write failed, and the latency histogram of the points written since the
+// last collection to the WriterMeasurement.
+func (r *RunStats) writeWriterStats(ts time.Time) {
+	fields := map[string]interface{}{"dropped": r.writer.Dropped()}
+	failed := false
+	for class, n := range r.writer.Errors() {
+		fields["errors."+string(class)] = n
+		failed = failed || n > 0
+	}
+	written := r.latency != nil && r.latency.fields(fields)
+	if failed || written {
+		r.writer.WritePoint(r.config.WriterMeasurement, r.pointTags(nil), fields, ts)
+	}
+}
+
+// writeError reports points dropped by a failed write to the logger and
+// Config.OnError.
+func (r *RunStats) writeError(err error, points int) {
+	r.log("runstats: dropped", points, "points:", err)
+	r.onError(fmt.Errorf("runstats: dropped %d points: %w", points, err))
+}
+
+// retryError reports a failed write of points kept for retry.
+func (r *RunStats) retryError(err error, points int) {
+	r.onError(fmt.Errorf("runstats: write of %d points failed, kept for retry: %w", points, err))
+}
@@ -0,0 +1,159 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClose(t *testing.T) {
+	stats, tr := newTestRunStats(nil)
+	stats.WritePoint("app.orders", nil, map[string]interface{}{"count": 1})
+
+	if err := stats.Close(context.Background()); err != nil {
+		t.Fatalf("expected a clean close got %v", err)
+	}
+	if err := stats.Close(context.Background()); err != nil {
+		t.Errorf("expected a second close to be a no-op got %v", err)
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if !tr.closed {
+		t.Error("expected the transport to be closed")
+	}
+	// The pending point and the stop marker
+	if len(tr.points) != 2 || tr.points[1].Fields["title"] != markerStop {
+		t.Errorf("expected the pending point and the stop marker flushed got %v", tr.points)
+	}
+}
+
+func TestCloseDrainsHTTPStats(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{DisableMarkers: true})
+	h := stats.Middleware("jobs", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+
+	if err := stats.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if len(tr.points) != 1 || tr.points[0].Measurement != stats.config.HTTPMeasurement || tr.points[0].Fields["requests"] != int64(2) {
+		t.Errorf("expected the requests since the last collection flushed got %v", tr.points)
+	}
+}
+
+func TestCloseWritesAppMetrics(t *testing.T) {
+	stats, tr := newTestRunStats(nil)
+	stats.NewCounter("jobs.done").Add(3)
+
+	if err := stats.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if len(tr.points) != 2 || tr.points[0].Measurement != stats.config.AppMeasurement || tr.points[0].Fields["jobs.done"] != int64(3) || tr.points[1].Fields["title"] != markerStop {
+		t.Errorf("expected the app metrics before the stop marker got %v", tr.points)
+	}
+}
+
+func TestCloseRecordingRace(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{DisableMarkers: true})
+	h := stats.Middleware("jobs", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+	for i := 0; i < 10; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+	}
+	served := int64(10)
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/", nil))
+				atomic.AddInt64(&served, 1)
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	before := atomic.LoadInt64(&served)
+	if err := stats.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	close(stop)
+	wg.Wait()
+
+	// Every request served before Close is in the final batch
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	var written int64
+	for _, p := range tr.points {
+		written += p.Fields["requests"].(int64)
+	}
+	if written < before || written > served {
+		t.Errorf("expected between %d and %d requests written got %d", before, served, written)
+	}
+}
+
+func TestCloseDeadline(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{DisableMarkers: true})
+	tr.block = true
+	stats.WritePoint("app.orders", nil, map[string]interface{}{"count": 1})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var dropped *DroppedError
+	if err := stats.Close(ctx); !errors.As(err, &dropped) || dropped.Dropped != 1 {
+		t.Errorf("expected 1 dropped point got %v", err)
+	}
+}
+
+func TestWriteErrors(t *testing.T) {
+	stats, tr := newTestRunStats(nil)
+	stats.onNewPoint(stats.collector.OneOff())
+	for _, p := range tr.Points(stats) {
+		if p.Measurement == stats.config.WriterMeasurement {
+			t.Fatal("expected no writer point before a write failed")
+		}
+	}
+
+	tr.mu.Lock()
+	tr.err = &statusError{http.StatusBadRequest, errors.New("bad points")}
+	tr.mu.Unlock()
+	stats.WritePoint("app.orders", nil, map[string]interface{}{"count": 1})
+	tr.Points(stats)
+	if errs := stats.WriteErrors(); errs["rejected"] != 1 {
+		t.Fatalf("expected 1 rejected write got %v", errs)
+	}
+
+	tr.mu.Lock()
+	tr.err = nil
+	tr.mu.Unlock()
+	stats.onNewPoint(stats.collector.OneOff())
+	var found bool
+	for _, p := range tr.Points(stats) {
+		if p.Measurement == stats.config.WriterMeasurement {
+			found = p.Fields["errors.rejected"] == int64(1) && p.Fields["dropped"] == int64(1)
+		}
+	}
+	if !found {
+		t.Error("expected the failed write on the writer point")
+	}
+}
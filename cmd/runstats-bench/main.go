@@ -0,0 +1,152 @@
+// Command runstats-bench writes synthetic points at a given rate and
+// cardinality through the pipeline of RunStats (batching, budget, retries and
+// the configured backend) to size InfluxDB or other backends and validate the
+// batching settings before a rollout.
+//
+// The backend is configured like the application would: with -config, see
+// runstats.LoadConfig, or with the GRM_ environment variables of
+// runstats.ConfigFromEnv.
+//
+//	GRM_HOST=influx:8086 GRM_TOKEN=... runstats-bench -rate 5000 -series 1000 -duration 5m
+//
+// Every second it prints the points written and dropped, and the worst write
+// latency; a summary follows at the end.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strconv"
+	"sync"
+	"time"
+
+	runstats "github.com/nzlov/go-runtime-metrics"
+)
+
+func main() {
+	configPath := flag.String("config", "", "config file, see runstats.LoadConfig (default from the GRM_ environment variables)")
+	rate := flag.Float64("rate", 1000, "points written per second")
+	series := flag.Int("series", 100, "number of distinct series, i.e. tag sets")
+	fields := flag.Int("fields", 10, "fields per point")
+	measurement := flag.String("measurement", "runstats.bench", "measurement of the synthetic points")
+	duration := flag.Duration("duration", time.Minute, "how long to write")
+	flag.Parse()
+
+	if err := run(*configPath, *measurement, *rate, *series, *fields, *duration); err != nil {
+		fmt.Fprintln(os.Stderr, "runstats-bench:", err)
+		os.Exit(1)
+	}
+}
+
+// stats accumulates the write acknowledgements.
+type stats struct {
+	mu         sync.Mutex
+	written    int64
+	maxLatency time.Duration
+}
+
+func (s *stats) ack(ack runstats.WriteAck) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.written += int64(ack.Points)
+	if latency := ack.Written.Sub(ack.Oldest); latency > s.maxLatency {
+		s.maxLatency = latency
+	}
+}
+
+// take returns the points written and the worst latency since the last call.
+func (s *stats) take() (int64, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	written, latency := s.written, s.maxLatency
+	s.written, s.maxLatency = 0, 0
+	return written, latency
+}
+
+func run(configPath, measurement string, rate float64, series, fields int, duration time.Duration) error {
+	if rate <= 0 || series <= 0 || fields <= 0 {
+		return fmt.Errorf("rate, series and fields must be positive")
+	}
+
+	var config *runstats.Config
+	var err error
+	if configPath != "" {
+		config, err = runstats.LoadConfig(configPath)
+	} else {
+		config, err = runstats.ConfigFromEnv()
+	}
+	if err != nil {
+		return err
+	}
+	var acks stats
+	onWritten := config.OnWritten
+	config.OnWritten = func(ack runstats.WriteAck) {
+		acks.ack(ack)
+		if onWritten != nil {
+			onWritten(ack)
+		}
+	}
+	config.DisableMarkers = true
+
+	ctx, cancel := context.WithTimeout(context.Background(), duration)
+	defer cancel()
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	r, err := runstats.RunCollector(ctx, config)
+	if err != nil {
+		return err
+	}
+
+	tags := make([]map[string]string, series)
+	for i := range tags {
+		tags[i] = map[string]string{"series": strconv.Itoa(i)}
+	}
+	names := make([]string, fields)
+	for i := range names {
+		names[i] = "field" + strconv.Itoa(i)
+	}
+
+	const tick = 10 * time.Millisecond
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	report := time.NewTicker(time.Second)
+	defer report.Stop()
+
+	start := time.Now()
+	var sent, total int64
+	var due float64
+	for ctx.Err() == nil {
+		select {
+		case <-ctx.Done():
+		case <-report.C:
+			written, latency := acks.take()
+			total += written
+			fmt.Printf("%s sent %d written %d/s dropped %d max latency %s\n",
+				time.Since(start).Truncate(time.Second), sent, written, r.Dropped(), latency.Truncate(time.Millisecond))
+		case <-ticker.C:
+			for due += rate * tick.Seconds(); due >= 1; due-- {
+				values := make(map[string]interface{}, fields)
+				for _, name := range names {
+					values[name] = rand.Float64()
+				}
+				r.WritePoint(measurement, tags[sent%int64(series)], values)
+				sent++
+			}
+		}
+	}
+
+	closeCtx, cancelClose := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancelClose()
+	closeErr := r.Close(closeCtx)
+	written, _ := acks.take()
+	total += written
+	elapsed := time.Since(start)
+	fmt.Printf("sent %d points in %s (%.0f/s), written %d, dropped %d, write errors %v\n",
+		sent, elapsed.Truncate(time.Millisecond), float64(sent)/elapsed.Seconds(), total, r.Dropped(), r.WriteErrors())
+	return closeErr
+}
@@ -0,0 +1,106 @@
+package collector
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// AllocSite is an allocation call stack with its bytes and objects in use as of
+// the last GC.
+type AllocSite struct {
+	// Hash identifies the call stack by its functions and lines, so it is stable
+	// across restarts of the same build.
+	Hash string
+	// Function, File and Line locate the first non-runtime frame.
+	Function string
+	File     string
+	Line     int
+
+	InUseBytes   int64
+	InUseObjects int64
+}
+
+// TopAllocators returns the n allocation sites with the most bytes in use,
+// largest first. Only sampled allocations are recorded, see
+// runtime.MemProfileRate; like in heap profiles they are scaled up to estimates
+// of all the allocations of their site.
+func TopAllocators(n int) []AllocSite {
+	var records []runtime.MemProfileRecord
+	count, _ := runtime.MemProfile(nil, false)
+	for {
+		// Leave room for sites added between the two calls.
+		records = make([]runtime.MemProfileRecord, count+50)
+		var ok bool
+		if count, ok = runtime.MemProfile(records, false); ok {
+			records = records[:count]
+			break
+		}
+	}
+
+	rate := int64(runtime.MemProfileRate)
+	sites := make([]AllocSite, len(records))
+	for i := range records {
+		sites[i].InUseObjects, sites[i].InUseBytes = scaleHeapSample(records[i].InUseObjects(), records[i].InUseBytes(), rate)
+	}
+	sort.Sort(allocSitesByBytes{sites, records})
+	if len(sites) > n {
+		sites, records = sites[:n], records[:n]
+	}
+
+	for i := range sites {
+		sites[i].Hash, sites[i].Function, sites[i].File, sites[i].Line = hashStack(records[i].Stack())
+	}
+	return sites
+}
+
+// allocSitesByBytes sorts sites and their records by bytes in use, largest
+// first.
+type allocSitesByBytes struct {
+	sites   []AllocSite
+	records []runtime.MemProfileRecord
+}
+
+func (s allocSitesByBytes) Len() int           { return len(s.sites) }
+func (s allocSitesByBytes) Less(i, j int) bool { return s.sites[i].InUseBytes > s.sites[j].InUseBytes }
+func (s allocSitesByBytes) Swap(i, j int) {
+	s.sites[i], s.sites[j] = s.sites[j], s.sites[i]
+	s.records[i], s.records[j] = s.records[j], s.records[i]
+}
+
+// scaleHeapSample estimates the objects and bytes allocated at a site from those
+// sampled at rate, like scaleHeapSample of runtime/pprof: an allocation of size
+// bytes is sampled with probability 1-exp(-size/rate).
+func scaleHeapSample(count, size, rate int64) (int64, int64) {
+	if count == 0 || size == 0 {
+		return 0, 0
+	}
+	if rate <= 1 {
+		// Every allocation is sampled
+		return count, size
+	}
+	avgSize := float64(size) / float64(count)
+	scale := 1 / (1 - math.Exp(-avgSize/float64(rate)))
+	return int64(float64(count) * scale), int64(float64(size) * scale)
+}
+
+// hashStack hashes the functions and lines of the call stack pcs and locates its
+// first non-runtime frame.
+func hashStack(pcs []uintptr) (hash, function, file string, line int) {
+	h := fnv.New64a()
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		fmt.Fprintf(h, "%s:%d\n", frame.Function, frame.Line)
+		if function == "" && !strings.HasPrefix(frame.Function, "runtime.") {
+			function, file, line = frame.Function, frame.File, frame.Line
+		}
+		if !more {
+			break
+		}
+	}
+	return fmt.Sprintf("%016x", h.Sum64()), function, file, line
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+var allocSink [][]byte
+
+func TestTopAllocators(t *testing.T) {
+	rate := runtime.MemProfileRate
+	runtime.MemProfileRate = 1
+	defer func() { runtime.MemProfileRate = rate }()
+
+	for i := 0; i < 64; i++ {
+		allocSink = append(allocSink, make([]byte, 1<<20))
+	}
+	defer func() { allocSink = nil }()
+	runtime.GC()
+	runtime.GC()
+
+	sites := TopAllocators(5)
+	if len(sites) == 0 || len(sites) > 5 {
+		t.Fatalf("expected 1 to 5 sites got %d", len(sites))
+	}
+	for i := 1; i < len(sites); i++ {
+		if sites[i].InUseBytes > sites[i-1].InUseBytes {
+			t.Errorf("expected sites sorted by in-use bytes got %v", sites)
+		}
+	}
+	if !strings.HasSuffix(sites[0].Function, "TestTopAllocators") || sites[0].InUseBytes < 64<<20 {
+		t.Errorf("expected the test to be the top allocator got %+v", sites[0])
+	}
+	if len(sites[0].Hash) != 16 {
+		t.Errorf("unexpected hash %q", sites[0].Hash)
+	}
+}
+
+func TestScaleHeapSample(t *testing.T) {
+	// Objects as large as the rate are sampled with a probability of 1-1/e
+	count, size := scaleHeapSample(100, 100*512<<10, 512<<10)
+	if count != 158 || size != 82941140 {
+		t.Errorf("unexpected scaled sample %d objects of %d bytes", count, size)
+	}
+	if count, size := scaleHeapSample(3, 300, 1); count != 3 || size != 300 {
+		t.Errorf("expected samples at rate 1 to be left alone got %d and %d", count, size)
+	}
+}
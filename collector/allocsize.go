@@ -0,0 +1,66 @@
+package collector
+
+import (
+	"math"
+	"runtime/metrics"
+	"sync"
+)
+
+const allocsBySizeMetric = "/gc/heap/allocs-by-size:bytes"
+
+// allocSizeTracker computes allocation size quantiles over the allocations made
+// between two collections.
+type allocSizeTracker struct {
+	mu     sync.Mutex
+	counts []uint64
+}
+
+// observe reads the cumulative allocation size histogram and returns the median
+// and 99th percentile size in bytes of the allocations since the previous call,
+// 0 if there were none. The first call covers all allocations so far.
+func (t *allocSizeTracker) observe() (p50, p99 float64) {
+	sample := []metrics.Sample{{Name: allocsBySizeMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64Histogram {
+		return 0, 0
+	}
+	h := sample[0].Value.Float64Histogram()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delta := make([]uint64, len(h.Counts))
+	var total uint64
+	for i, n := range h.Counts {
+		if i < len(t.counts) && n >= t.counts[i] {
+			n -= t.counts[i]
+		}
+		delta[i] = n
+		total += n
+	}
+	t.counts = append(t.counts[:0], h.Counts...)
+
+	if total == 0 {
+		return 0, 0
+	}
+	return histogramQuantile(delta, h.Buckets, total, 0.5), histogramQuantile(delta, h.Buckets, total, 0.99)
+}
+
+// histogramQuantile returns the upper bound of the bucket holding quantile q of
+// counts, or its lower bound for the unbounded last bucket. buckets holds the
+// len(counts)+1 bucket boundaries.
+func histogramQuantile(counts []uint64, buckets []float64, total uint64, q float64) float64 {
+	rank := uint64(math.Ceil(q * float64(total)))
+	var seen uint64
+	for i, n := range counts {
+		seen += n
+		if seen < rank {
+			continue
+		}
+		if math.IsInf(buckets[i+1], 1) {
+			return buckets[i]
+		}
+		return buckets[i+1]
+	}
+	return buckets[len(buckets)-1]
+}
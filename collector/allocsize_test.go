@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	buckets := []float64{0, 8, 16, 32, math.Inf(1)}
+	counts := []uint64{50, 40, 9, 1}
+
+	if q := histogramQuantile(counts, buckets, 100, 0.5); q != 8 {
+		t.Errorf("expected a median of 8 got %v", q)
+	}
+	if q := histogramQuantile(counts, buckets, 100, 0.99); q != 32 {
+		t.Errorf("expected a p99 of 32 got %v", q)
+	}
+	if q := histogramQuantile(counts, buckets, 100, 1); q != 32 {
+		t.Errorf("expected the lower bound of the last bucket got %v", q)
+	}
+}
+
+var allocSizeSink [][]byte
+
+func TestAllocSizeTracker(t *testing.T) {
+	var tracker allocSizeTracker
+	tracker.observe()
+
+	for i := 0; i < 10000; i++ {
+		allocSizeSink = append(allocSizeSink, make([]byte, 4096))
+	}
+	defer func() { allocSizeSink = nil }()
+
+	p50, p99 := tracker.observe()
+	if p50 < 4096 || p99 < p50 {
+		t.Errorf("expected 4KiB allocations to dominate got p50 %v p99 %v", p50, p99)
+	}
+}
@@ -0,0 +1,216 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const cgroupRoot = "/sys/fs/cgroup"
+
+// cgroup locates the control files of the cgroup(s) the process belongs to.
+type cgroup struct {
+	// unified is the cgroup v2 path, when running under the unified hierarchy.
+	unified string
+	// controllers maps cgroup v1 controller names to their paths.
+	controllers map[string]string
+}
+
+var (
+	selfCgroup     *cgroup
+	selfCgroupOnce sync.Once
+)
+
+func loadSelfCgroup() *cgroup {
+	selfCgroupOnce.Do(func() {
+		f, err := os.Open("/proc/self/cgroup")
+		if err != nil {
+			return
+		}
+		defer f.Close()
+		selfCgroup = parseCgroup(bufio.NewScanner(f))
+	})
+	return selfCgroup
+}
+
+// parseCgroup parses the "hierarchy-ID:controller-list:cgroup-path" lines of
+// /proc/<pid>/cgroup.
+func parseCgroup(s *bufio.Scanner) *cgroup {
+	cg := &cgroup{controllers: map[string]string{}}
+	for s.Scan() {
+		parts := strings.SplitN(s.Text(), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			cg.unified = parts[2]
+			continue
+		}
+		for _, controller := range strings.Split(parts[1], ",") {
+			cg.controllers[controller] = parts[2]
+		}
+	}
+	return cg
+}
+
+// read returns the contents of a control file, preferring cgroup v2 (v2File) and
+// falling back to cgroup v1 (v1File of controller). Inside a container the cgroup
+// path usually isn't visible, in which case the control file at the root of the
+// mount is used.
+func (cg *cgroup) read(v2File, controller, v1File string) ([]byte, error) {
+	var candidates []string
+	if v2File != "" && len(cg.controllers) == 0 {
+		candidates = append(candidates,
+			filepath.Join(cgroupRoot, cg.unified, v2File),
+			filepath.Join(cgroupRoot, v2File),
+		)
+	}
+	if v1File != "" {
+		if path, ok := cg.controllers[controller]; ok {
+			candidates = append(candidates, filepath.Join(cgroupRoot, controller, path, v1File))
+		}
+		candidates = append(candidates, filepath.Join(cgroupRoot, controller, v1File))
+	}
+
+	err := os.ErrNotExist
+	for _, c := range candidates {
+		var b []byte
+		if b, err = ioutil.ReadFile(c); err == nil {
+			return b, nil
+		}
+	}
+	return nil, err
+}
+
+// cpuQuota returns the cgroup CPU quota in cores, or false if there is no limit.
+func cpuQuota() (float64, bool) {
+	cg := loadSelfCgroup()
+	if cg == nil {
+		return 0, false
+	}
+
+	if b, err := cg.read("cpu.max", "", ""); err == nil {
+		return parseCPUMax(b)
+	}
+
+	quota, err := cg.read("", "cpu", "cpu.cfs_quota_us")
+	if err != nil {
+		return 0, false
+	}
+	period, err := cg.read("", "cpu", "cpu.cfs_period_us")
+	if err != nil {
+		return 0, false
+	}
+	return quotaCores(string(bytes.TrimSpace(quota)), string(bytes.TrimSpace(period)))
+}
+
+// memoryLimit returns the cgroup memory limit in bytes, or false if there is no limit.
+func memoryLimit() (int64, bool) {
+	cg := loadSelfCgroup()
+	if cg == nil {
+		return 0, false
+	}
+
+	b, err := cg.read("memory.max", "memory", "memory.limit_in_bytes")
+	if err != nil {
+		return 0, false
+	}
+	return parseMemoryLimit(b)
+}
+
+// memoryUsage returns the memory usage of the cgroup in bytes, page cache
+// included, or false outside of a cgroup.
+func memoryUsage() (int64, bool) {
+	cg := loadSelfCgroup()
+	if cg == nil {
+		return 0, false
+	}
+
+	b, err := cg.read("memory.current", "memory", "memory.usage_in_bytes")
+	if err != nil {
+		return 0, false
+	}
+	usage, err := strconv.ParseInt(string(bytes.TrimSpace(b)), 10, 64)
+	return usage, err == nil
+}
+
+// cpuThrottling returns the CFS enforcement periods of the cgroup, the ones in
+// which it was throttled and the total time it was throttled, or false if there
+// is none.
+func cpuThrottling() (throttling, bool) {
+	cg := loadSelfCgroup()
+	if cg == nil {
+		return throttling{}, false
+	}
+
+	b, err := cg.read("cpu.stat", "cpu", "cpu.stat")
+	if err != nil {
+		return throttling{}, false
+	}
+	return parseCPUStat(b)
+}
+
+// parseCPUStat parses the nr_periods, nr_throttled and throttled_usec (v2) or
+// throttled_time (v1, in ns) keys of cpu.stat.
+func parseCPUStat(b []byte) (throttling, bool) {
+	var t throttling
+	found := false
+	for _, line := range strings.Split(string(b), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		v, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		switch fields[0] {
+		case "nr_periods":
+			t.Periods, found = v, true
+		case "nr_throttled":
+			t.Throttled = v
+		case "throttled_usec":
+			t.ThrottledTime = v * 1000
+		case "throttled_time":
+			t.ThrottledTime = v
+		}
+	}
+	return t, found
+}
+
+// parseMemoryLimit parses memory.max (v2) or memory.limit_in_bytes (v1).
+func parseMemoryLimit(b []byte) (int64, bool) {
+	limit, err := strconv.ParseInt(string(bytes.TrimSpace(b)), 10, 64)
+	// "max" (v2) or a page aligned math.MaxInt64 (v1) means unlimited
+	if err != nil || limit <= 0 || limit >= 1<<62 {
+		return 0, false
+	}
+	return limit, true
+}
+
+// parseCPUMax parses the cgroup v2 cpu.max "$MAX $PERIOD" format.
+func parseCPUMax(b []byte) (float64, bool) {
+	fields := strings.Fields(string(b))
+	if len(fields) != 2 {
+		return 0, false
+	}
+	return quotaCores(fields[0], fields[1])
+}
+
+func quotaCores(quota, period string) (float64, bool) {
+	q, err := strconv.ParseFloat(quota, 64)
+	if err != nil || q <= 0 {
+		// "max" (v2) or -1 (v1) means unlimited
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(period, 64)
+	if err != nil || p <= 0 {
+		return 0, false
+	}
+	return q / p, true
+}
@@ -0,0 +1,79 @@
+package collector
+
+import (
+	"bufio"
+	"strings"
+	"testing"
+)
+
+func TestParseCgroup(t *testing.T) {
+	v1 := parseCgroup(bufio.NewScanner(strings.NewReader(
+		"12:memory:/docker/abc\n4:cpu,cpuacct:/docker/abc\n1:name=systemd:/docker/abc\n")))
+	if v1.unified != "" || v1.controllers["cpu"] != "/docker/abc" || v1.controllers["memory"] != "/docker/abc" {
+		t.Errorf("unexpected cgroup v1 parse %+v", v1)
+	}
+
+	v2 := parseCgroup(bufio.NewScanner(strings.NewReader("0::/system.slice/app.service\n")))
+	if v2.unified != "/system.slice/app.service" || len(v2.controllers) != 0 {
+		t.Errorf("unexpected cgroup v2 parse %+v", v2)
+	}
+}
+
+func TestParseCPUMax(t *testing.T) {
+	tests := []struct {
+		in    string
+		cores float64
+		ok    bool
+	}{
+		{"max 100000\n", 0, false},
+		{"250000 100000\n", 2.5, true},
+		{"50000 100000", 0.5, true},
+		{"garbage", 0, false},
+	}
+
+	for _, test := range tests {
+		cores, ok := parseCPUMax([]byte(test.in))
+		if cores != test.cores || ok != test.ok {
+			t.Errorf("parseCPUMax(%q): expected (%v, %v) got (%v, %v)", test.in, test.cores, test.ok, cores, ok)
+		}
+	}
+
+	if _, ok := quotaCores("-1", "100000"); ok {
+		t.Error("expected cgroup v1 quota of -1 to be unlimited")
+	}
+}
+
+func TestParseMemoryLimit(t *testing.T) {
+	tests := []struct {
+		in    string
+		limit int64
+		ok    bool
+	}{
+		{"max\n", 0, false},
+		{"536870912\n", 512 << 20, true},
+		{"9223372036854771712\n", 0, false},
+	}
+
+	for _, test := range tests {
+		limit, ok := parseMemoryLimit([]byte(test.in))
+		if limit != test.limit || ok != test.ok {
+			t.Errorf("parseMemoryLimit(%q): expected (%v, %v) got (%v, %v)", test.in, test.limit, test.ok, limit, ok)
+		}
+	}
+}
+
+func TestParseCPUStat(t *testing.T) {
+	v2, ok := parseCPUStat([]byte("usage_usec 1000\nnr_periods 120\nnr_throttled 30\nthrottled_usec 4500\n"))
+	if !ok || v2 != (throttling{Periods: 120, Throttled: 30, ThrottledTime: 4500000}) {
+		t.Errorf("unexpected cgroup v2 cpu.stat parse %+v", v2)
+	}
+
+	v1, ok := parseCPUStat([]byte("nr_periods 10\nnr_throttled 2\nthrottled_time 7000\n"))
+	if !ok || v1 != (throttling{Periods: 10, Throttled: 2, ThrottledTime: 7000}) {
+		t.Errorf("unexpected cgroup v1 cpu.stat parse %+v", v1)
+	}
+
+	if _, ok := parseCPUStat([]byte("usage_usec 1000\n")); ok {
+		t.Error("expected no throttling without a CPU limit controller")
+	}
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+// +build !linux
+
+package collector
+
+// cpuQuota returns false as cgroups only exist on Linux.
+func cpuQuota() (float64, bool) {
+	return 0, false
+}
+
+// memoryUsage returns false as cgroups only exist on Linux.
+func memoryUsage() (int64, bool) {
+	return 0, false
+}
+
+// cpuThrottling returns false as cgroups only exist on Linux.
+func cpuThrottling() (throttling, bool) {
+	return throttling{}, false
+}
+
+// memoryLimit returns false as cgroups only exist on Linux.
+func memoryLimit() (int64, bool) {
+	return 0, false
+}
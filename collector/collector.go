@@ -1,7 +1,10 @@
 package collector
 
 import (
+	"os"
 	"runtime"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -15,6 +18,25 @@ type Collector struct {
 	// Defaults to 10 seconds.
 	PauseDur time.Duration
 
+	// Delay postpones the first collection, e.g. to skip the allocation bursts of
+	// process initialization. Defaults to 0.
+	Delay time.Duration
+
+	// Schedule, if set, determines the collection times instead of PauseDur, see
+	// ParseCron.
+	Schedule Schedule
+
+	// Align aligns collections to multiples of PauseDur since the Unix epoch, e.g.
+	// exactly on :00, :10, :20 seconds past the minute for 10 seconds, so that the
+	// points of many processes share timestamps (see AlignTime). The first
+	// collection waits for the next boundary. Ignored when a Schedule is set.
+	// Defaults to false.
+	Align bool
+
+	// OnError, if set, is called with the errors encountered while collecting,
+	// besides reporting them to the logger. Defaults to nil.
+	OnError func(err error)
+
 	// EnableCPU determines whether CPU statistics will be output. Defaults to true.
 	EnableCPU bool
 
@@ -25,11 +47,75 @@ type Collector struct {
 	// must also be set to true for this to take affect. Defaults to true.
 	EnableGC bool
 
+	// LeakWindow is the number of GC cycles the live heap trend (mem.heap.live.slope
+	// and mem.heap.leak_score) is computed over. EnableGC must also be set to true.
+	// Defaults to 30.
+	LeakWindow int
+
+	// LeakThreshold is the mem.heap.leak_score at or above which OnLeak is called.
+	// Defaults to 0.9.
+	LeakThreshold float64
+
+	// OnLeak, if set, is called after each collection whose leak score reached
+	// LeakThreshold.
+	OnLeak LeakFunc
+
+	// PauseSLO, if set, enables GC pause budget tracking (mem.gc.slo.*). EnableGC must
+	// also be set to true.
+	PauseSLO *PauseSLO
+
+	// MemoryLimitRatio is the share of the container memory limit reported as the
+	// recommended GOMEMLIMIT (mem.limit.recommended). Defaults to DefaultMemoryLimitRatio.
+	MemoryLimitRatio float64
+
+	// EnableProcess determines whether process statistics (CPU time, RSS, open file
+	// descriptors/handles and threads) will be output. Supported on Linux, Darwin and
+	// Windows. Defaults to true.
+	EnableProcess bool
+
+	// EnableRuntimeMetrics replaces the runtime.MemStats based memory and GC
+	// statistics by every metric of the runtime/metrics package (RuntimeMetrics),
+	// which doesn't stop the world. EnableMem must also be set to true. Defaults to
+	// false.
+	EnableRuntimeMetrics bool
+
+	// EnableRunQueue determines whether scheduler run queue statistics will be output.
+	// They need a Go version whose runtime/metrics expose the runnable goroutine
+	// count, or RunQueueStackFallback. Defaults to false.
+	EnableRunQueue bool
+
+	// RunQueueStackFallback counts the runnable goroutines in a full goroutine
+	// stack dump, which stops the world, when runtime/metrics don't expose them.
+	// Defaults to false.
+	RunQueueStackFallback bool
+
+	// EnableScheduler determines whether scheduler thread, goroutine state and
+	// latency statistics (sched.threads, sched.goroutines.*, sched.latency.*) will
+	// be output. Defaults to true.
+	EnableScheduler bool
+
+	// EnableContention determines whether the totals of the block and mutex
+	// profiles (contention.*) will be output. They only record contention once
+	// enabled with runtime.SetBlockProfileRate and runtime.SetMutexProfileFraction.
+	// Defaults to false.
+	EnableContention bool
+
 	// Done, when closed, is used to signal Collector that is should stop collecting
 	// statistics and the Run function should return.
 	Done <-chan struct{}
 
 	fieldsFunc FieldsFunc
+	mu         sync.RWMutex // guards the Enable* fields and PauseDur, see SetGroup and SetInterval
+	reset      chan struct{}
+	leak       leakDetector
+	slo        sloTracker
+	pauses     pauseTracker
+	latency    latencyTracker
+	cpuUtil    utilizationTracker
+	allocSize  allocSizeTracker
+	runtime    runtimeMetricsReader
+	logger     atomic.Value // holds a loggerBox, see SetLogger
+	forcedGC   uint32       // accessed atomically, see collectGCStats
 }
 
 // New creates a new Collector that will periodically output statistics to fieldsFunc. It
@@ -41,32 +127,80 @@ func New(fieldsFunc FieldsFunc) *Collector {
 	}
 
 	return &Collector{
-		PauseDur:   10 * time.Second,
-		EnableCPU:  true,
-		EnableMem:  true,
-		EnableGC:   true,
-		fieldsFunc: fieldsFunc,
+		PauseDur:         10 * time.Second,
+		EnableCPU:        true,
+		EnableMem:        true,
+		MemoryLimitRatio: DefaultMemoryLimitRatio,
+		LeakWindow:       30,
+		LeakThreshold:    0.9,
+		EnableGC:         true,
+		EnableProcess:    true,
+		EnableScheduler:  true,
+		fieldsFunc:       fieldsFunc,
+		reset:            make(chan struct{}, 1),
 	}
 }
 
 // Run gathers statistics then outputs them to the configured PointFunc every
-// PauseDur. Unlike OneOff, this function will return until Done has been closed
-// (or never if Done is nil), therefore it should be called in its own go routine.
+// PauseDur, or at the times determined by Schedule. Unlike OneOff, this function
+// will return until Done has been closed (or never if Done is nil), therefore it
+// should be called in its own go routine.
 func (c *Collector) Run() {
+	if c.Delay > 0 {
+		timer := time.NewTimer(c.Delay)
+		select {
+		case <-c.Done:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+
+	if c.Schedule != nil {
+		c.fieldsFunc(c.collectStats())
+		c.runSchedule()
+		return
+	}
+	if c.Align {
+		c.runAligned()
+		return
+	}
+
 	c.fieldsFunc(c.collectStats())
 
-	tick := time.NewTicker(c.PauseDur)
+	tick := time.NewTicker(c.Interval())
 	defer tick.Stop()
 	for {
 		select {
 		case <-c.Done:
 			return
+		case <-c.reset:
+			tick.Reset(c.Interval())
 		case <-tick.C:
 			c.fieldsFunc(c.collectStats())
 		}
 	}
 }
 
+func (c *Collector) runSchedule() {
+	for {
+		next := c.Schedule.Next(time.Now())
+		if next.IsZero() {
+			<-c.Done
+			return
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-c.Done:
+			timer.Stop()
+			return
+		case <-timer.C:
+			c.fieldsFunc(c.collectStats())
+		}
+	}
+}
+
 // OneOff gathers returns a map containing all statistics. It is safe for use from
 // multiple go routines
 func (c *Collector) OneOff() Fields {
@@ -76,37 +210,108 @@ func (c *Collector) OneOff() Fields {
 func (c *Collector) collectStats() Fields {
 	fields := Fields{}
 
-	if c.EnableCPU {
+	c.mu.RLock()
+	enabled := c.groups()
+	c.mu.RUnlock()
+
+	if enabled.cpu {
 		cStats := cpuStats{
-			NumGoroutine: int64(runtime.NumGoroutine()),
-			NumCgoCall:   int64(runtime.NumCgoCall()),
-			NumCpu:       int64(runtime.NumCPU()),
+			NumGoroutine:         int64(runtime.NumGoroutine()),
+			NumCgoCall:           int64(runtime.NumCgoCall()),
+			NumCpu:               int64(runtime.NumCPU()),
+			GoMaxProcs:           int64(runtime.GOMAXPROCS(0)),
+			GoMaxProcsConfigured: int64(configuredMaxProcs),
 		}
+		var hasQuota, hasThrottling bool
+		cStats.CPUQuota, hasQuota = cpuQuota()
+		cStats.Throttling, hasThrottling = cpuThrottling()
 		c.collectCPUStats(&fields, &cStats)
+		// An unlimited quota is 0, left out only outside of a cgroup
+		if hasQuota || hasThrottling {
+			fields.sections |= sectionCPUQuota
+		}
+		if hasThrottling {
+			fields.sections |= sectionThrottling
+		}
 	}
-	if c.EnableMem {
+	if enabled.mem && c.EnableRuntimeMetrics {
+		fields.RuntimeMetrics, fields.runtimeUnits = c.runtime.read()
+	} else if enabled.mem {
 		m := &runtime.MemStats{}
 		runtime.ReadMemStats(m)
 		c.collectMemStats(&fields, m)
-		if c.EnableGC {
+		c.collectMemLimitStats(&fields)
+		c.collectAllocSizeStats(&fields)
+		if enabled.gc {
 			c.collectGCStats(&fields, m)
+			gcCPU := gcCPUStats{}
+			readGCCPUStats(&gcCPU)
+			c.collectGCCPUStats(&fields, &gcCPU)
+			pacer := pacerStats{}
+			readPacerStats(&pacer)
+			c.collectPacerStats(&fields, &pacer)
+			c.collectLeakStats(&fields, m)
+			if c.PauseSLO != nil {
+				c.collectSLOStats(&fields, m)
+			}
+		}
+	}
+	if enabled.runQueue {
+		rStats := runQueueStats{}
+		if readRunQueueStats(&rStats, c.RunQueueStackFallback) {
+			c.collectRunQueueStats(&fields, &rStats)
+		}
+	}
+	if enabled.scheduler {
+		sStats := schedStats{}
+		readSchedStats(&sStats)
+		c.collectSchedStats(&fields, &sStats)
+		c.collectLatencyStats(&fields)
+	}
+	if enabled.contention {
+		cStats := contentionStats{}
+		readContentionStats(&cStats)
+		c.collectContentionStats(&fields, &cStats)
+	}
+	if enabled.process {
+		pStats := procStats{}
+		if err := readProcStats(&pStats); err == nil {
+			c.collectProcStats(&fields, &pStats)
+			if enabled.mem && pStats.RSS > 0 {
+				c.collectOffHeapStats(&fields)
+			}
+			if enabled.cpu {
+				c.collectUtilizationStats(&fields, &pStats)
+			}
+		} else if err != errProcessUnsupported {
+			c.logError("collector: failed to read process stats:", err)
 		}
 	}
 
 	fields.Goos = runtime.GOOS
 	fields.Goarch = runtime.GOARCH
 	fields.Version = runtime.Version()
+	fields.GoDebug = os.Getenv("GODEBUG")
+	fields.sections |= sectionGo
 
 	return fields
 }
 
 func (_ *Collector) collectCPUStats(fields *Fields, s *cpuStats) {
+	fields.sections |= sectionCPU
 	fields.NumCpu = s.NumCpu
 	fields.NumGoroutine = s.NumGoroutine
 	fields.NumCgoCall = s.NumCgoCall
+	fields.GoMaxProcs = s.GoMaxProcs
+	fields.GoMaxProcsConfigured = s.GoMaxProcsConfigured
+	fields.CPUQuota = s.CPUQuota
+	fields.CPUPeriods = s.Throttling.Periods
+	fields.CPUThrottledPeriods = s.Throttling.Throttled
+	fields.CPUThrottledTime = s.Throttling.ThrottledTime
 }
 
 func (_ *Collector) collectMemStats(fields *Fields, m *runtime.MemStats) {
+	fields.sections |= sectionMem
 	// General
 	fields.Alloc = int64(m.Alloc)
 	fields.TotalAlloc = int64(m.TotalAlloc)
@@ -114,6 +319,10 @@ func (_ *Collector) collectMemStats(fields *Fields, m *runtime.MemStats) {
 	fields.Lookups = int64(m.Lookups)
 	fields.Mallocs = int64(m.Mallocs)
 	fields.Frees = int64(m.Frees)
+	fields.LiveObjects = int64(m.Mallocs - m.Frees)
+	if fields.LiveObjects > 0 {
+		fields.AvgObjectSize = float64(m.HeapAlloc) / float64(fields.LiveObjects)
+	}
 
 	// Heap
 	fields.HeapAlloc = int64(m.HeapAlloc)
@@ -134,20 +343,163 @@ func (_ *Collector) collectMemStats(fields *Fields, m *runtime.MemStats) {
 	fields.OtherSys = int64(m.OtherSys)
 }
 
-func (_ *Collector) collectGCStats(fields *Fields, m *runtime.MemStats) {
+func (c *Collector) collectMemLimitStats(fields *Fields) {
+	fields.CgroupMemLimit, _ = memoryLimit()
+	var inCgroup bool
+	if fields.CgroupMemUsage, inCgroup = memoryUsage(); inCgroup {
+		fields.sections |= sectionCgroupMem
+	}
+	if fields.CgroupMemLimit > 0 {
+		fields.CgroupMemUsageRatio = float64(fields.CgroupMemUsage) / float64(fields.CgroupMemLimit)
+	}
+	fields.MemLimitRecommended, _ = RecommendedMemoryLimit(c.MemoryLimitRatio)
+	fields.MemLimitApplied = currentMemoryLimit()
+}
+
+func (c *Collector) collectAllocSizeStats(fields *Fields) {
+	fields.AllocSizeP50, fields.AllocSizeP99 = c.allocSize.observe()
+}
+
+func (c *Collector) collectLeakStats(fields *Fields, m *runtime.MemStats) {
+	fields.sections |= sectionLeak
+	fields.HeapLive = liveHeap(m)
+	fields.HeapLiveSlope, fields.LeakScore = c.leak.observe(time.Now(), m.NumGC, fields.HeapLive, c.LeakWindow)
+
+	if c.OnLeak != nil && fields.LeakScore > 0 && fields.LeakScore >= c.LeakThreshold {
+		c.OnLeak(fields.HeapLiveSlope, fields.LeakScore)
+	}
+}
+
+func (c *Collector) collectSLOStats(fields *Fields, m *runtime.MemStats) {
+	fields.sections |= sectionSLO
+	fields.SLOPauses, fields.SLOViolations, fields.SLOBudgetUsed, fields.SLOBurnRate = c.slo.observe(time.Now(), m, *c.PauseSLO)
+}
+
+func (c *Collector) collectGCStats(fields *Fields, m *runtime.MemStats) {
+	fields.sections |= sectionGC
 	fields.GCSys = int64(m.GCSys)
 	fields.NextGC = int64(m.NextGC)
 	fields.LastGC = int64(m.LastGC)
 	fields.PauseTotalNs = int64(m.PauseTotalNs)
 	fields.PauseNs = int64(m.PauseNs[(m.NumGC+255)%256])
 	fields.NumGC = int64(m.NumGC)
+	pauses := c.pauses.observe(m)
+	fields.PauseCount = pauses.Count
+	fields.PauseP50 = pauses.P50
+	fields.PauseP90 = pauses.P90
+	fields.PauseP99 = pauses.P99
+	fields.PauseMax = pauses.Max
 	fields.GCCPUFraction = float64(m.GCCPUFraction)
+	fields.GCForced = m.NumForcedGC > atomic.SwapUint32(&c.forcedGC, m.NumForcedGC)
+	if m.NextGC > 0 {
+		fields.HeapGoalRatio = float64(m.HeapAlloc) / float64(m.NextGC)
+	}
+}
+
+func (_ *Collector) collectGCCPUStats(fields *Fields, s *gcCPUStats) {
+	fields.GCAssistCPU = s.Assist
+	fields.GCMarkDedicatedCPU = s.MarkDedicated
+	fields.GCMarkIdleCPU = s.MarkIdle
+	fields.GCPauseCPU = s.Pause
+}
+
+func (_ *Collector) collectPacerStats(fields *Fields, s *pacerStats) {
+	fields.GOGC = s.GOGC
+	fields.GCScanHeap = s.ScanHeap
+	fields.GCScanStack = s.ScanStack
+	fields.GCScanGlobals = s.ScanGlobals
+	fields.NumForcedGC = s.Forced
+}
+
+func (_ *Collector) collectProcStats(fields *Fields, s *procStats) {
+	fields.sections |= sectionProc
+	fields.ProcCPUUser = s.CPUUser
+	fields.ProcCPUSystem = s.CPUSystem
+	fields.ProcRSS = s.RSS
+	fields.ProcPeakRSS = s.PeakRSS
+	fields.ProcVSZ = s.VSZ
+	fields.ProcFDs = s.FDs
+	fields.ProcFDLimit = s.FDLimit
+	fields.ProcThreads = s.Threads
+	fields.ProcThreadsCreated = s.ThreadsCreated
+	fields.ProcCtxSwitches = s.CtxSwitches
+	fields.ProcCtxSwitchesInvol = s.CtxSwitchesInvol
+	if s.StartTime > 0 {
+		fields.ProcUptime = time.Now().UnixNano() - s.StartTime
+	}
+}
+
+// collectOffHeapStats estimates the memory not managed by the Go runtime, e.g.
+// allocated by C libraries through cgo, as the RSS minus the memory obtained by
+// the runtime that wasn't returned to the OS. Since not all of the latter is
+// resident the estimate is a lower bound. It requires the runtime.MemStats based
+// fields.
+func (_ *Collector) collectOffHeapStats(fields *Fields) {
+	if fields.Sys == 0 {
+		return
+	}
+	fields.sections |= sectionOffHeap
+	if offHeap := fields.ProcRSS - (fields.Sys - fields.HeapReleased); offHeap > 0 {
+		fields.OffHeap = offHeap
+	}
+}
+
+func (_ *Collector) collectRunQueueStats(fields *Fields, s *runQueueStats) {
+	fields.sections |= sectionRunQueue
+	fields.RunQueue = s.Runnable
+	if s.GoMaxProcs > 0 {
+		fields.RunQueueRatio = float64(s.Runnable) / float64(s.GoMaxProcs)
+	}
+}
+
+func (_ *Collector) collectSchedStats(fields *Fields, s *schedStats) {
+	fields.sections |= sectionSched
+	fields.Threads = s.Threads
+	fields.GoroutinesCreated = s.GoroutinesCreated
+	fields.GoroutinesRunning = s.GoroutinesRunning
+	fields.GoroutinesWaiting = s.GoroutinesWaiting
+	fields.GoroutinesNotInGo = s.GoroutinesNotInGo
+}
+
+func (_ *Collector) collectContentionStats(fields *Fields, s *contentionStats) {
+	fields.sections |= sectionContention
+	fields.BlockCount = s.BlockCount
+	fields.BlockDelay = s.BlockDelay
+	fields.MutexCount = s.MutexCount
+	fields.MutexDelay = s.MutexDelay
+}
+
+func (c *Collector) collectLatencyStats(fields *Fields) {
+	fields.sections |= sectionSchedLatency
+	latency := c.latency.observe()
+	fields.SchedLatencyCount = latency.Count
+	fields.SchedLatencyP50 = latency.P50
+	fields.SchedLatencyP90 = latency.P90
+	fields.SchedLatencyP99 = latency.P99
+	fields.SchedLatencyMax = latency.Max
+}
+
+func (c *Collector) collectUtilizationStats(fields *Fields, s *procStats) {
+	fields.sections |= sectionUtilization
+	cores := availableCores(fields.GoMaxProcs, fields.CPUQuota)
+	fields.CPUUtilization = c.cpuUtil.observe(time.Now(), s.CPUUser+s.CPUSystem, cores)
 }
 
 type cpuStats struct {
-	NumCpu       int64
-	NumGoroutine int64
-	NumCgoCall   int64
+	NumCpu               int64
+	NumGoroutine         int64
+	NumCgoCall           int64
+	GoMaxProcs           int64
+	GoMaxProcsConfigured int64
+	CPUQuota             float64
+	Throttling           throttling
+}
+
+// throttling holds the CFS bandwidth statistics of the cgroup.
+type throttling struct {
+	Periods       int64
+	Throttled     int64
+	ThrottledTime int64 // nanoseconds
 }
 
 // NOTE: uint64 is not supported by influxDB client due to potential overflows
@@ -157,6 +509,15 @@ type Fields struct {
 	NumGoroutine int64 `json:"cpu.goroutines"`
 	NumCgoCall   int64 `json:"cpu.cgo_calls"`
 
+	GoMaxProcs           int64   `json:"cpu.gomaxprocs"`
+	GoMaxProcsConfigured int64   `json:"cpu.gomaxprocs.configured"`
+	CPUQuota             float64 `json:"cgroup.cpu.quota"`
+	CPUUtilization       float64 `json:"cpu.utilization"`
+
+	CPUPeriods          int64 `json:"cgroup.cpu.periods"`
+	CPUThrottledPeriods int64 `json:"cgroup.cpu.throttled_periods"`
+	CPUThrottledTime    int64 `json:"cgroup.cpu.throttled_time"`
+
 	// General
 	Alloc      int64 `json:"mem.alloc"`
 	TotalAlloc int64 `json:"mem.total"`
@@ -165,6 +526,10 @@ type Fields struct {
 	Mallocs    int64 `json:"mem.malloc"`
 	Frees      int64 `json:"mem.frees"`
 
+	// Live objects, derived from the cumulative counters
+	LiveObjects   int64   `json:"mem.objects.live"`
+	AvgObjectSize float64 `json:"mem.objects.avg_size"`
+
 	// Heap
 	HeapAlloc    int64 `json:"mem.heap.alloc"`
 	HeapSys      int64 `json:"mem.heap.sys"`
@@ -183,6 +548,17 @@ type Fields struct {
 
 	OtherSys int64 `json:"mem.othersys"`
 
+	// Allocation sizes since the previous collection
+	AllocSizeP50 float64 `json:"mem.alloc_size.p50"`
+	AllocSizeP99 float64 `json:"mem.alloc_size.p99"`
+
+	// Limits
+	CgroupMemLimit      int64   `json:"cgroup.mem.limit"`
+	CgroupMemUsage      int64   `json:"cgroup.mem.usage"`
+	CgroupMemUsageRatio float64 `json:"cgroup.mem.usage_ratio"`
+	MemLimitRecommended int64   `json:"mem.limit.recommended"`
+	MemLimitApplied     int64   `json:"mem.limit.applied"`
+
 	// GC
 	GCSys         int64   `json:"mem.gc.sys"`
 	NextGC        int64   `json:"mem.gc.next"`
@@ -191,6 +567,94 @@ type Fields struct {
 	PauseNs       int64   `json:"mem.gc.pause"`
 	NumGC         int64   `json:"mem.gc.count"`
 	GCCPUFraction float64 `json:"mem.gc.cpu_fraction"`
+	GCForced      bool    `json:"mem.gc.forced"`
+
+	// GC pauses since the previous collection
+	PauseCount int64 `json:"mem.gc.pause.count"`
+	PauseP50   int64 `json:"mem.gc.pause.p50"`
+	PauseP90   int64 `json:"mem.gc.pause.p90"`
+	PauseP99   int64 `json:"mem.gc.pause.p99"`
+	PauseMax   int64 `json:"mem.gc.pause.max"`
+
+	// GC CPU time
+	GCAssistCPU        int64 `json:"mem.gc.cpu.assist"`
+	GCMarkDedicatedCPU int64 `json:"mem.gc.cpu.mark_dedicated"`
+	GCMarkIdleCPU      int64 `json:"mem.gc.cpu.mark_idle"`
+	GCPauseCPU         int64 `json:"mem.gc.cpu.pause"`
+
+	// GC pacer
+	HeapGoalRatio float64 `json:"mem.gc.goal_ratio"`
+	GOGC          int64   `json:"mem.gc.gogc"`
+	GCScanHeap    int64   `json:"mem.gc.scan.heap"`
+	GCScanStack   int64   `json:"mem.gc.scan.stack"`
+	GCScanGlobals int64   `json:"mem.gc.scan.globals"`
+	NumForcedGC   int64   `json:"mem.gc.count.forced"`
+
+	// GC pause SLO
+	SLOPauses     int64   `json:"mem.gc.slo.pauses"`
+	SLOViolations int64   `json:"mem.gc.slo.violations"`
+	SLOBudgetUsed float64 `json:"mem.gc.slo.budget_used"`
+	SLOBurnRate   float64 `json:"mem.gc.slo.burn_rate"`
+
+	// Live heap trend
+	HeapLive      int64   `json:"mem.heap.live"`
+	HeapLiveSlope float64 `json:"mem.heap.live.slope"`
+	LeakScore     float64 `json:"mem.heap.leak_score"`
+
+	// Scheduler
+	RunQueue      int64   `json:"sched.runqueue"`
+	RunQueueRatio float64 `json:"sched.runqueue.ratio"`
+
+	Threads           int64 `json:"sched.threads"`
+	GoroutinesCreated int64 `json:"sched.goroutines.created"`
+	GoroutinesRunning int64 `json:"sched.goroutines.running"`
+	GoroutinesWaiting int64 `json:"sched.goroutines.waiting"`
+	GoroutinesNotInGo int64 `json:"sched.goroutines.not_in_go"`
+
+	// Scheduler latencies since the previous collection
+	SchedLatencyCount int64 `json:"sched.latency.count"`
+	SchedLatencyP50   int64 `json:"sched.latency.p50"`
+	SchedLatencyP90   int64 `json:"sched.latency.p90"`
+	SchedLatencyP99   int64 `json:"sched.latency.p99"`
+	SchedLatencyMax   int64 `json:"sched.latency.max"`
+
+	// Contention
+	BlockCount int64 `json:"contention.block.count"`
+	BlockDelay int64 `json:"contention.block.delay"`
+	MutexCount int64 `json:"contention.mutex.count"`
+	MutexDelay int64 `json:"contention.mutex.delay"`
+
+	// Runtime settings
+	GoDebug string `json:"go.godebug"`
+
+	// Process
+	ProcCPUUser   int64 `json:"proc.cpu.user"`
+	ProcCPUSystem int64 `json:"proc.cpu.system"`
+	ProcRSS       int64 `json:"proc.mem.rss"`
+	ProcVSZ       int64 `json:"proc.mem.vsz"`
+	OffHeap       int64 `json:"mem.offheap"`
+	ProcFDs       int64 `json:"proc.fds"`
+	ProcFDLimit   int64 `json:"proc.fds.limit"`
+	ProcThreads   int64 `json:"proc.threads"`
+	ProcUptime    int64 `json:"proc.uptime"`
+
+	// Darwin reports these in place of proc.mem.rss and proc.threads
+	ProcPeakRSS        int64 `json:"proc.mem.rss.peak"`
+	ProcThreadsCreated int64 `json:"proc.threads.created"`
+
+	ProcCtxSwitches      int64 `json:"proc.ctx_switches.voluntary"`
+	ProcCtxSwitchesInvol int64 `json:"proc.ctx_switches.involuntary"`
+
+	// RuntimeMetrics holds every metric of the runtime/metrics package with
+	// EnableRuntimeMetrics, keyed by its name with dots for slashes and the unit as
+	// last element, e.g. "sched.goroutines.goroutines". Histograms such as
+	// /sched/latencies:seconds are written as the ".count" of observations and the
+	// ".p50", ".p90" and ".p99" quantiles since the previous collection.
+	RuntimeMetrics map[string]interface{} `json:"-"`
+	runtimeUnits   map[string]string
+
+	// sections are the sets of fields collected.
+	sections section
 
 	Goarch  string `json:"-"`
 	Goos    string `json:"-"`
@@ -205,40 +669,189 @@ func (f *Fields) Tags() map[string]string {
 	}
 }
 
+// Values returns the fields by name. Only the fields of the groups collected
+// are included, e.g. no cgroup fields outside of a cgroup or mem.gc.slo.* without
+// a PauseSLO. Fields not returned by a Collector have all of them.
 func (f *Fields) Values() map[string]interface{} {
-	return map[string]interface{}{
-		"cpu.count":      f.NumCpu,
-		"cpu.goroutines": f.NumGoroutine,
-		"cpu.cgo_calls":  f.NumCgoCall,
-
-		"mem.alloc":   f.Alloc,
-		"mem.total":   f.TotalAlloc,
-		"mem.sys":     f.Sys,
-		"mem.lookups": f.Lookups,
-		"mem.malloc":  f.Mallocs,
-		"mem.frees":   f.Frees,
-
-		"mem.heap.alloc":    f.HeapAlloc,
-		"mem.heap.sys":      f.HeapSys,
-		"mem.heap.idle":     f.HeapIdle,
-		"mem.heap.inuse":    f.HeapInuse,
-		"mem.heap.released": f.HeapReleased,
-		"mem.heap.objects":  f.HeapObjects,
-
-		"mem.stack.inuse":        f.StackInuse,
-		"mem.stack.sys":          f.StackSys,
-		"mem.stack.mspan_inuse":  f.MSpanInuse,
-		"mem.stack.mspan_sys":    f.MSpanSys,
-		"mem.stack.mcache_inuse": f.MCacheInuse,
-		"mem.stack.mcache_sys":   f.MCacheSys,
-		"mem.othersys":           f.OtherSys,
-
-		"mem.gc.sys":          f.GCSys,
-		"mem.gc.next":         f.NextGC,
-		"mem.gc.last":         f.LastGC,
-		"mem.gc.pause_total":  f.PauseTotalNs,
-		"mem.gc.pause":        f.PauseNs,
-		"mem.gc.count":        f.NumGC,
-		"mem.gc.cpu_fraction": float64(f.GCCPUFraction),
+	values := make(map[string]interface{}, 128)
+	if f.has(sectionCPU) {
+		values["cpu.count"] = f.NumCpu
+		values["cpu.goroutines"] = f.NumGoroutine
+		values["cpu.cgo_calls"] = f.NumCgoCall
+		values["cpu.gomaxprocs"] = f.GoMaxProcs
+		values["cpu.gomaxprocs.configured"] = f.GoMaxProcsConfigured
+	}
+	if f.has(sectionCPUQuota) {
+		values["cgroup.cpu.quota"] = f.CPUQuota
+	}
+	if f.has(sectionUtilization) {
+		values["cpu.utilization"] = f.CPUUtilization
 	}
+	if f.has(sectionThrottling) {
+		values["cgroup.cpu.periods"] = f.CPUPeriods
+		values["cgroup.cpu.throttled_periods"] = f.CPUThrottledPeriods
+		values["cgroup.cpu.throttled_time"] = f.CPUThrottledTime
+	}
+
+	if f.has(sectionMem) {
+		values["mem.alloc"] = f.Alloc
+		values["mem.total"] = f.TotalAlloc
+		values["mem.sys"] = f.Sys
+		values["mem.lookups"] = f.Lookups
+		values["mem.malloc"] = f.Mallocs
+		values["mem.frees"] = f.Frees
+
+		values["mem.objects.live"] = f.LiveObjects
+		values["mem.objects.avg_size"] = f.AvgObjectSize
+
+		values["mem.heap.alloc"] = f.HeapAlloc
+		values["mem.heap.sys"] = f.HeapSys
+		values["mem.heap.idle"] = f.HeapIdle
+		values["mem.heap.inuse"] = f.HeapInuse
+		values["mem.heap.released"] = f.HeapReleased
+		values["mem.heap.objects"] = f.HeapObjects
+
+		values["mem.stack.inuse"] = f.StackInuse
+		values["mem.stack.sys"] = f.StackSys
+		values["mem.stack.mspan_inuse"] = f.MSpanInuse
+		values["mem.stack.mspan_sys"] = f.MSpanSys
+		values["mem.stack.mcache_inuse"] = f.MCacheInuse
+		values["mem.stack.mcache_sys"] = f.MCacheSys
+		values["mem.othersys"] = f.OtherSys
+
+		values["mem.alloc_size.p50"] = f.AllocSizeP50
+		values["mem.alloc_size.p99"] = f.AllocSizeP99
+
+		values["mem.limit.recommended"] = f.MemLimitRecommended
+		values["mem.limit.applied"] = f.MemLimitApplied
+	}
+	if f.has(sectionCgroupMem) {
+		values["cgroup.mem.limit"] = f.CgroupMemLimit
+		values["cgroup.mem.usage"] = f.CgroupMemUsage
+		values["cgroup.mem.usage_ratio"] = f.CgroupMemUsageRatio
+	}
+
+	if f.has(sectionGC) {
+		values["mem.gc.sys"] = f.GCSys
+		values["mem.gc.next"] = f.NextGC
+		values["mem.gc.last"] = f.LastGC
+		values["mem.gc.pause_total"] = f.PauseTotalNs
+		values["mem.gc.pause"] = f.PauseNs
+		values["mem.gc.count"] = f.NumGC
+		values["mem.gc.cpu_fraction"] = float64(f.GCCPUFraction)
+		values["mem.gc.forced"] = f.GCForced
+
+		values["mem.gc.pause.count"] = f.PauseCount
+		values["mem.gc.pause.p50"] = f.PauseP50
+		values["mem.gc.pause.p90"] = f.PauseP90
+		values["mem.gc.pause.p99"] = f.PauseP99
+		values["mem.gc.pause.max"] = f.PauseMax
+
+		values["mem.gc.cpu.assist"] = f.GCAssistCPU
+		values["mem.gc.cpu.mark_dedicated"] = f.GCMarkDedicatedCPU
+		values["mem.gc.cpu.mark_idle"] = f.GCMarkIdleCPU
+		values["mem.gc.cpu.pause"] = f.GCPauseCPU
+
+		values["mem.gc.goal_ratio"] = f.HeapGoalRatio
+		values["mem.gc.gogc"] = f.GOGC
+		values["mem.gc.scan.heap"] = f.GCScanHeap
+		values["mem.gc.scan.stack"] = f.GCScanStack
+		values["mem.gc.scan.globals"] = f.GCScanGlobals
+		values["mem.gc.count.forced"] = f.NumForcedGC
+	}
+	if f.has(sectionSLO) {
+		values["mem.gc.slo.pauses"] = f.SLOPauses
+		values["mem.gc.slo.violations"] = f.SLOViolations
+		values["mem.gc.slo.budget_used"] = f.SLOBudgetUsed
+		values["mem.gc.slo.burn_rate"] = f.SLOBurnRate
+	}
+	if f.has(sectionLeak) {
+		values["mem.heap.live"] = f.HeapLive
+		values["mem.heap.live.slope"] = f.HeapLiveSlope
+		values["mem.heap.leak_score"] = f.LeakScore
+	}
+
+	if f.has(sectionRunQueue) {
+		values["sched.runqueue"] = f.RunQueue
+		values["sched.runqueue.ratio"] = f.RunQueueRatio
+	}
+	if f.has(sectionSched) {
+		values["sched.threads"] = f.Threads
+		values["sched.goroutines.created"] = f.GoroutinesCreated
+		values["sched.goroutines.running"] = f.GoroutinesRunning
+		values["sched.goroutines.waiting"] = f.GoroutinesWaiting
+		values["sched.goroutines.not_in_go"] = f.GoroutinesNotInGo
+	}
+	if f.has(sectionSchedLatency) {
+		values["sched.latency.count"] = f.SchedLatencyCount
+		values["sched.latency.p50"] = f.SchedLatencyP50
+		values["sched.latency.p90"] = f.SchedLatencyP90
+		values["sched.latency.p99"] = f.SchedLatencyP99
+		values["sched.latency.max"] = f.SchedLatencyMax
+	}
+	if f.has(sectionContention) {
+		values["contention.block.count"] = f.BlockCount
+		values["contention.block.delay"] = f.BlockDelay
+		values["contention.mutex.count"] = f.MutexCount
+		values["contention.mutex.delay"] = f.MutexDelay
+	}
+
+	if f.has(sectionGo) {
+		values["go.godebug"] = f.GoDebug
+	}
+
+	if f.has(sectionProc) {
+		values["proc.cpu.user"] = f.ProcCPUUser
+		values["proc.cpu.system"] = f.ProcCPUSystem
+		values["proc.mem.vsz"] = f.ProcVSZ
+		values["proc.fds"] = f.ProcFDs
+		values["proc.fds.limit"] = f.ProcFDLimit
+		values["proc.uptime"] = f.ProcUptime
+		if f.ProcPeakRSS > 0 || f.ProcThreadsCreated > 0 {
+			values["proc.mem.rss.peak"] = f.ProcPeakRSS
+			values["proc.threads.created"] = f.ProcThreadsCreated
+		} else {
+			values["proc.mem.rss"] = f.ProcRSS
+			values["proc.threads"] = f.ProcThreads
+		}
+
+		values["proc.ctx_switches.voluntary"] = f.ProcCtxSwitches
+		values["proc.ctx_switches.involuntary"] = f.ProcCtxSwitchesInvol
+	}
+	if f.has(sectionOffHeap) {
+		values["mem.offheap"] = f.OffHeap
+	}
+
+	for k, v := range f.RuntimeMetrics {
+		values[k] = v
+	}
+	return values
+}
+
+// section is a set of fields collected together, see Fields.Values.
+type section uint32
+
+const (
+	sectionGo section = 1 << iota
+	sectionCPU
+	sectionCPUQuota
+	sectionThrottling
+	sectionUtilization
+	sectionMem
+	sectionCgroupMem
+	sectionGC
+	sectionSLO
+	sectionLeak
+	sectionRunQueue
+	sectionSched
+	sectionSchedLatency
+	sectionContention
+	sectionProc
+	sectionOffHeap
+)
+
+// has reports whether the fields of s were collected. Fields built by hand,
+// without any section, have all of them.
+func (f *Fields) has(s section) bool {
+	return f.sections == 0 || f.sections&s != 0
 }
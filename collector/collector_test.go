@@ -1,6 +1,7 @@
 package collector
 
 import (
+	"runtime"
 	"testing"
 	"time"
 )
@@ -49,3 +50,80 @@ func TestCollector(t *testing.T) {
 	}
 
 }
+
+func TestCollectMemStatsLiveObjects(t *testing.T) {
+	fields := Fields{}
+	(&Collector{}).collectMemStats(&fields, &runtime.MemStats{Mallocs: 1500, Frees: 500, HeapAlloc: 64000})
+
+	if fields.LiveObjects != 1000 || fields.AvgObjectSize != 64 {
+		t.Errorf("expected 1000 live objects of 64 bytes got %d of %v", fields.LiveObjects, fields.AvgObjectSize)
+	}
+}
+
+func TestCollectGCStatsForced(t *testing.T) {
+	c := &Collector{}
+	fields := Fields{}
+	c.collectGCStats(&fields, &runtime.MemStats{NumForcedGC: 2})
+	if !fields.GCForced {
+		t.Error("expected a forced GC")
+	}
+
+	c.collectGCStats(&fields, &runtime.MemStats{NumForcedGC: 2})
+	if fields.GCForced {
+		t.Error("expected no forced GC since the previous collection")
+	}
+	if v, ok := fields.Values()["mem.gc.forced"].(bool); !ok || v {
+		t.Errorf("expected a bool field got %v", fields.Values()["mem.gc.forced"])
+	}
+}
+
+func TestCollectOffHeapStats(t *testing.T) {
+	fields := Fields{Sys: 100 << 20, HeapReleased: 20 << 20, ProcRSS: 130 << 20}
+	(&Collector{}).collectOffHeapStats(&fields)
+	if fields.OffHeap != 50<<20 {
+		t.Errorf("expected 50MiB off heap got %d", fields.OffHeap)
+	}
+
+	fields = Fields{Sys: 100 << 20, ProcRSS: 60 << 20}
+	(&Collector{}).collectOffHeapStats(&fields)
+	if fields.OffHeap != 0 {
+		t.Errorf("expected no off heap memory when the Go memory isn't all resident got %d", fields.OffHeap)
+	}
+}
+
+func TestFieldsValuesPeakRSS(t *testing.T) {
+	peak := Fields{ProcPeakRSS: 64 << 20, ProcThreadsCreated: 8}
+	values := peak.Values()
+	if values["proc.mem.rss.peak"] != int64(64<<20) || values["proc.threads.created"] != int64(8) {
+		t.Errorf("expected the peak RSS and created threads got %v", values)
+	}
+	if _, ok := values["proc.mem.rss"]; ok {
+		t.Error("expected no current RSS next to the peak")
+	}
+	current := Fields{ProcRSS: 64 << 20}
+	if _, ok := current.Values()["proc.mem.rss.peak"]; ok {
+		t.Error("expected no peak RSS next to the current one")
+	}
+}
+
+func TestFieldsValuesOfCollectedGroups(t *testing.T) {
+	c := New(func(Fields) {})
+	c.SetGroup(GroupGC, false)
+	fields := c.collectStats()
+	values := fields.Values()
+
+	for _, key := range []string{"cpu.goroutines", "mem.alloc", "sched.threads", "go.godebug"} {
+		if _, ok := values[key]; !ok {
+			t.Errorf("expected the enabled field %s", key)
+		}
+	}
+	for _, key := range []string{"mem.gc.count", "mem.heap.live", "mem.gc.slo.pauses", "sched.runqueue", "contention.block.count"} {
+		if _, ok := values[key]; ok {
+			t.Errorf("unexpected field %s of a disabled or opt-in group", key)
+		}
+	}
+
+	if _, ok := (&Fields{}).Values()["mem.gc.count"]; !ok {
+		t.Error("expected all the fields of Fields not returned by a Collector")
+	}
+}
@@ -0,0 +1,82 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"runtime"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// contentionStats holds the contention events recorded by the block and mutex
+// profiles since the process started, with their delays in nanoseconds.
+type contentionStats struct {
+	BlockCount, BlockDelay int64
+	MutexCount, MutexDelay int64
+}
+
+var (
+	cyclesPerSecond     float64
+	cyclesPerSecondOnce sync.Once
+)
+
+// readContentionStats sums the records of the block and mutex profiles. The
+// profiles only record anything once enabled with runtime.SetBlockProfileRate
+// and runtime.SetMutexProfileFraction, and their records are already scaled by
+// the sampling rates.
+func readContentionStats(s *contentionStats) {
+	cyclesPerSecondOnce.Do(func() {
+		cyclesPerSecond = readCyclesPerSecond()
+	})
+
+	var cycles int64
+	s.BlockCount, cycles = sumProfile(runtime.BlockProfile)
+	s.BlockDelay = cyclesToNanoseconds(cycles)
+	s.MutexCount, cycles = sumProfile(runtime.MutexProfile)
+	s.MutexDelay = cyclesToNanoseconds(cycles)
+}
+
+func sumProfile(profile func([]runtime.BlockProfileRecord) (int, bool)) (count, cycles int64) {
+	n, _ := profile(nil)
+	var records []runtime.BlockProfileRecord
+	for {
+		records = make([]runtime.BlockProfileRecord, n+50)
+		var ok bool
+		if n, ok = profile(records); ok {
+			records = records[:n]
+			break
+		}
+	}
+	for _, r := range records {
+		count += r.Count
+		cycles += r.Cycles
+	}
+	return count, cycles
+}
+
+func cyclesToNanoseconds(cycles int64) int64 {
+	if cyclesPerSecond <= 0 {
+		return 0
+	}
+	return int64(float64(cycles) / cyclesPerSecond * 1e9)
+}
+
+// readCyclesPerSecond reads the rate of the clock the profile delays are
+// measured with from the header of the text mutex profile, as the runtime
+// doesn't export it.
+func readCyclesPerSecond() float64 {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("mutex").WriteTo(&buf, 1); err != nil {
+		return 0
+	}
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		if v := strings.TrimPrefix(s.Text(), "cycles/second="); v != s.Text() {
+			rate, _ := strconv.ParseFloat(v, 64)
+			return rate
+		}
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestReadContentionStats(t *testing.T) {
+	defer runtime.SetBlockProfileRate(0)
+	defer runtime.SetMutexProfileFraction(runtime.SetMutexProfileFraction(1))
+	runtime.SetBlockProfileRate(1)
+
+	var mu sync.Mutex
+	mu.Lock()
+	done := make(chan struct{})
+	go func() {
+		mu.Lock()
+		mu.Unlock()
+		close(done)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	mu.Unlock()
+	<-done
+
+	s := contentionStats{}
+	readContentionStats(&s)
+	if s.BlockCount == 0 || s.BlockDelay < int64(time.Millisecond) {
+		t.Errorf("expected the blocked lock in the block profile got %+v", s)
+	}
+	if s.MutexCount == 0 || s.MutexDelay <= 0 {
+		t.Errorf("expected the contended lock in the mutex profile got %+v", s)
+	}
+}
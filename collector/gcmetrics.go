@@ -0,0 +1,87 @@
+package collector
+
+import (
+	"runtime/metrics"
+)
+
+// gcCPUMetrics are the runtime/metrics CPU classes of the GC, in gcCPUStats
+// order. They are only available since Go 1.20 and read 0 before.
+var gcCPUMetrics = []string{
+	"/cpu/classes/gc/mark/assist:cpu-seconds",
+	"/cpu/classes/gc/mark/dedicated:cpu-seconds",
+	"/cpu/classes/gc/mark/idle:cpu-seconds",
+	"/cpu/classes/gc/pause:cpu-seconds",
+}
+
+// gcCPUStats holds the CPU time spent by the GC since the program started, in
+// nanoseconds.
+type gcCPUStats struct {
+	// Assist is the time goroutines spent assisting the GC while allocating.
+	Assist int64
+	// MarkDedicated and MarkIdle are the time of the background mark workers,
+	// on dedicated Ps and on otherwise idle Ps.
+	MarkDedicated int64
+	MarkIdle      int64
+	// Pause is the time of the stop-the-world pauses, multiplied by GOMAXPROCS.
+	Pause int64
+}
+
+func readGCCPUStats(s *gcCPUStats) {
+	samples := make([]metrics.Sample, len(gcCPUMetrics))
+	for i, name := range gcCPUMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+
+	s.Assist = cpuNanoseconds(samples[0])
+	s.MarkDedicated = cpuNanoseconds(samples[1])
+	s.MarkIdle = cpuNanoseconds(samples[2])
+	s.Pause = cpuNanoseconds(samples[3])
+}
+
+// cpuNanoseconds returns the cpu-seconds sample in nanoseconds, 0 if the runtime
+// doesn't support it.
+func cpuNanoseconds(s metrics.Sample) int64 {
+	if s.Value.Kind() != metrics.KindFloat64 {
+		return 0
+	}
+	return int64(s.Value.Float64() * 1e9)
+}
+
+// pacerMetrics are the runtime/metrics inputs of the GC pacer, in pacerStats
+// order. They are only available since Go 1.21 and read 0 before.
+var pacerMetrics = []string{
+	"/gc/gogc:percent",
+	"/gc/scan/heap:bytes",
+	"/gc/scan/stack:bytes",
+	"/gc/scan/globals:bytes",
+	"/gc/cycles/forced:gc-cycles",
+}
+
+// pacerStats holds the GC pacer inputs.
+type pacerStats struct {
+	// GOGC is the current GOGC percentage, -1 when the GC is off.
+	GOGC int64
+	// ScanHeap, ScanStack and ScanGlobals are the bytes the next GC cycle is
+	// expected to scan, which the pacer budgets the mark work against.
+	ScanHeap    int64
+	ScanStack   int64
+	ScanGlobals int64
+	// Forced is the number of GC cycles forced by the application.
+	Forced int64
+}
+
+func readPacerStats(s *pacerStats) {
+	samples := make([]metrics.Sample, len(pacerMetrics))
+	for i, name := range pacerMetrics {
+		samples[i].Name = name
+	}
+	metrics.Read(samples)
+
+	// GOGC=off reads as -1 once converted
+	s.GOGC = liteValue(samples[0])
+	s.ScanHeap = liteValue(samples[1])
+	s.ScanStack = liteValue(samples[2])
+	s.ScanGlobals = liteValue(samples[3])
+	s.Forced = liteValue(samples[4])
+}
@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"runtime"
+	"runtime/debug"
+	"testing"
+)
+
+var gcSink []byte
+
+func TestReadGCCPUStats(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		gcSink = make([]byte, 64<<10)
+	}
+	runtime.GC()
+
+	s := gcCPUStats{}
+	readGCCPUStats(&s)
+	if s.Assist < 0 || s.MarkDedicated < 0 || s.MarkIdle < 0 {
+		t.Errorf("expected non-negative GC CPU time got %+v", s)
+	}
+	if hasMetric(gcCPUMetrics[3]) && s.Pause <= 0 {
+		t.Errorf("expected GC pause CPU time after a GC got %+v", s)
+	}
+}
+
+func TestReadPacerStats(t *testing.T) {
+	defer debug.SetGCPercent(debug.SetGCPercent(150))
+	runtime.GC()
+
+	s := pacerStats{}
+	readPacerStats(&s)
+	if hasMetric(pacerMetrics[0]) && s.GOGC != 150 {
+		t.Errorf("expected GOGC 150 got %d", s.GOGC)
+	}
+	if hasMetric(pacerMetrics[4]) && s.Forced < 1 {
+		t.Errorf("expected the forced GC to be counted got %+v", s)
+	}
+}
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"fmt"
+)
+
+// Metric groups that can be toggled with SetGroup.
+const (
+	GroupCPU        = "cpu"
+	GroupMem        = "mem"
+	GroupGC         = "gc"
+	GroupProcess    = "process"
+	GroupRunQueue   = "runqueue"
+	GroupScheduler  = "scheduler"
+	GroupContention = "contention"
+)
+
+// groups are the enabled states of the metric groups, see Collector.SetGroup.
+type groups struct {
+	cpu, mem, gc, process, runQueue, scheduler, contention bool
+}
+
+func (c *Collector) groupFlag(name string) (*bool, bool) {
+	switch name {
+	case GroupCPU:
+		return &c.EnableCPU, true
+	case GroupMem:
+		return &c.EnableMem, true
+	case GroupGC:
+		return &c.EnableGC, true
+	case GroupProcess:
+		return &c.EnableProcess, true
+	case GroupRunQueue:
+		return &c.EnableRunQueue, true
+	case GroupScheduler:
+		return &c.EnableScheduler, true
+	case GroupContention:
+		return &c.EnableContention, true
+	}
+	return nil, false
+}
+
+// SetGroup enables or disables the named metric group. Unlike setting the Enable*
+// fields directly it is safe to call while Run is executing; the change applies
+// from the next collection on.
+func (c *Collector) SetGroup(name string, enabled bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	flag, ok := c.groupFlag(name)
+	if !ok {
+		return fmt.Errorf("collector: unknown metric group %q", name)
+	}
+	*flag = enabled
+	return nil
+}
+
+// Groups returns whether each metric group is enabled.
+func (c *Collector) Groups() map[string]bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	g := c.groups()
+	return map[string]bool{
+		GroupCPU:        g.cpu,
+		GroupMem:        g.mem,
+		GroupGC:         g.gc,
+		GroupProcess:    g.process,
+		GroupRunQueue:   g.runQueue,
+		GroupScheduler:  g.scheduler,
+		GroupContention: g.contention,
+	}
+}
+
+// groups returns a snapshot of the group flags; c.mu must be held.
+func (c *Collector) groups() groups {
+	return groups{
+		cpu:        c.EnableCPU,
+		mem:        c.EnableMem,
+		gc:         c.EnableGC,
+		process:    c.EnableProcess,
+		runQueue:   c.EnableRunQueue,
+		scheduler:  c.EnableScheduler,
+		contention: c.EnableContention,
+	}
+}
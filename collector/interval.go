@@ -0,0 +1,69 @@
+package collector
+
+import (
+	"fmt"
+	"time"
+)
+
+// SetInterval changes PauseDur. Unlike setting the field directly it is safe to
+// call while Run is executing; the next collection is d after the call. It has no
+// effect when a Schedule is set.
+func (c *Collector) SetInterval(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("collector: interval must be positive, got %v", d)
+	}
+
+	c.mu.Lock()
+	c.PauseDur = d
+	c.mu.Unlock()
+
+	select {
+	case c.reset <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Interval returns PauseDur.
+func (c *Collector) Interval() time.Duration {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.PauseDur
+}
+
+// AlignTime returns the multiple of d since the Unix epoch closest to t, the
+// timestamp of a collection made with Align.
+func AlignTime(t time.Time, d time.Duration) time.Time {
+	ns := t.UnixNano() + int64(d)/2
+	return time.Unix(0, ns-ns%int64(d))
+}
+
+// nextBoundary returns the first multiple of d since the Unix epoch after t.
+func nextBoundary(t time.Time, d time.Duration) time.Time {
+	ns := t.UnixNano()
+	return time.Unix(0, ns-ns%int64(d)+int64(d))
+}
+
+func (c *Collector) runAligned() {
+	var last time.Time
+	for {
+		d := c.Interval()
+		next := nextBoundary(time.Now(), d)
+		// The timer may fire slightly early if the wall clock is slewed
+		if !next.After(last) {
+			next = next.Add(d)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-c.Done:
+			timer.Stop()
+			return
+		case <-c.reset:
+			timer.Stop()
+		case <-timer.C:
+			last = next
+			c.fieldsFunc(c.collectStats())
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetInterval(t *testing.T) {
+	collected := make(chan struct{}, 10)
+	c := New(func(Fields) { collected <- struct{}{} })
+	c.PauseDur = time.Hour
+	c.EnableProcess = false
+	done := make(chan struct{})
+	c.Done = done
+	defer close(done)
+
+	go c.Run()
+	<-collected
+
+	if err := c.SetInterval(0); err == nil {
+		t.Error("expected error for a zero interval")
+	}
+	if err := c.SetInterval(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-collected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected a collection at the new interval")
+	}
+	if d := c.Interval(); d != 10*time.Millisecond {
+		t.Errorf("expected interval 10ms got %v", d)
+	}
+}
+
+func TestAlignTime(t *testing.T) {
+	base := time.Date(2021, 6, 7, 12, 0, 10, 0, time.UTC)
+	for _, offset := range []time.Duration{0, time.Millisecond, -time.Millisecond, 4 * time.Second} {
+		if got := AlignTime(base.Add(offset), 10*time.Second); !got.Equal(base) {
+			t.Errorf("AlignTime(%v): expected %v got %v", offset, base, got)
+		}
+	}
+	if next := nextBoundary(base, 10*time.Second); !next.Equal(base.Add(10 * time.Second)) {
+		t.Errorf("expected next boundary after a boundary to be the following one got %v", next)
+	}
+}
+
+func TestAlignedRun(t *testing.T) {
+	collected := make(chan time.Time, 10)
+	c := New(func(Fields) { collected <- time.Now() })
+	c.PauseDur = 100 * time.Millisecond
+	c.Align = true
+	c.EnableProcess = false
+	done := make(chan struct{})
+	c.Done = done
+	defer close(done)
+
+	go c.Run()
+	for i := 0; i < 3; i++ {
+		at := <-collected
+		if offset := at.Sub(AlignTime(at, c.PauseDur)); offset < 0 || offset > 40*time.Millisecond {
+			t.Errorf("collection %d is %v off the boundary", i, offset)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+)
+
+// GoroutineLabels counts the goroutines per value of each of the given pprof label
+// keys, e.g. {"subsystem": {"db": 12, "http": 40}}. Goroutines without a key
+// aren't counted for it. The counts are read from the goroutine profile, so labels
+// must be set with pprof.Do or pprof.SetGoroutineLabels.
+func GoroutineLabels(keys []string) (map[string]map[string]int64, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return nil, err
+	}
+	return parseGoroutineLabels(&buf, keys), nil
+}
+
+// parseGoroutineLabels parses the debug=1 goroutine profile, where each stack
+// starts with "<count> @ <pcs>" optionally followed by "# labels: {...}".
+func parseGoroutineLabels(buf *bytes.Buffer, keys []string) map[string]map[string]int64 {
+	counts := make(map[string]map[string]int64, len(keys))
+	for _, k := range keys {
+		counts[k] = map[string]int64{}
+	}
+
+	var n int64
+	scanner := bufio.NewScanner(buf)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, " @ "); i > 0 {
+			n, _ = strconv.ParseInt(line[:i], 10, 64)
+			continue
+		}
+		if !strings.HasPrefix(line, "# labels: ") {
+			continue
+		}
+
+		var labels map[string]string
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "# labels: ")), &labels); err != nil {
+			continue
+		}
+		for _, k := range keys {
+			if v, ok := labels[k]; ok {
+				counts[k][v] += n
+			}
+		}
+	}
+	return counts
+}
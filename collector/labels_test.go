@@ -0,0 +1,56 @@
+package collector
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"sync"
+	"testing"
+)
+
+func TestParseGoroutineLabels(t *testing.T) {
+	profile := `goroutine profile: total 6
+3 @ 0x1 0x2
+# labels: {"subsystem":"db", "tenant":"a"}
+#	0x1	main.worker+0x1	main.go:1
+
+2 @ 0x1 0x3
+# labels: {"subsystem":"http"}
+#	0x1	main.handler+0x1	main.go:2
+
+1 @ 0x1 0x4
+#	0x1	main.main+0x1	main.go:3
+`
+	counts := parseGoroutineLabels(bytes.NewBufferString(profile), []string{"subsystem", "tenant"})
+
+	if counts["subsystem"]["db"] != 3 || counts["subsystem"]["http"] != 2 {
+		t.Errorf("unexpected subsystem counts %v", counts["subsystem"])
+	}
+	if len(counts["tenant"]) != 1 || counts["tenant"]["a"] != 3 {
+		t.Errorf("unexpected tenant counts %v", counts["tenant"])
+	}
+}
+
+func TestGoroutineLabels(t *testing.T) {
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	pprof.Do(context.Background(), pprof.Labels("subsystem", "labels_test"), func(context.Context) {
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func() {
+				wg.Done()
+				<-stop
+			}()
+		}
+	})
+	wg.Wait()
+	defer close(stop)
+
+	counts, err := GoroutineLabels([]string{"subsystem"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := counts["subsystem"]["labels_test"]; got != 3 {
+		t.Errorf("expected 3 labelled goroutines got %d", got)
+	}
+}
@@ -0,0 +1,98 @@
+package collector
+
+import (
+	"os"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// liveHeapMetric is only available in newer Go versions.
+const liveHeapMetric = "/gc/heap/live:bytes"
+
+var hasLiveHeapMetric = hasMetric(liveHeapMetric)
+
+// LeakFunc is called after a collection whose leak score reached the configured
+// threshold, with the live heap slope in bytes per second and the score in [0, 1].
+type LeakFunc func(slope, score float64)
+
+// leakDetector tracks the post-GC live heap over a sliding window of GC cycles.
+type leakDetector struct {
+	mu     sync.Mutex
+	numGC  uint32
+	times  []float64 // seconds
+	values []float64 // bytes
+}
+
+// observe records the live heap if a GC cycle completed since the previous call, then
+// returns the least squares slope of the window (bytes per second) and a leak score:
+// the coefficient of determination of the fit when the slope is positive, 0 otherwise.
+// A steadily growing live heap scores close to 1, a noisy or flat one close to 0.
+func (d *leakDetector) observe(now time.Time, numGC uint32, live int64, window int) (slope, score float64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if numGC != d.numGC {
+		d.numGC = numGC
+		d.times = append(d.times, float64(now.UnixNano())/1e9)
+		d.values = append(d.values, float64(live))
+		if n := len(d.times); n > window {
+			d.times = append(d.times[:0], d.times[n-window:]...)
+			d.values = append(d.values[:0], d.values[n-window:]...)
+		}
+	}
+
+	// Don't judge before half the window is filled
+	n := float64(len(d.times))
+	if len(d.times) < 3 || len(d.times) < window/2 {
+		return 0, 0
+	}
+
+	var sumX, sumY, sumXY, sumXX, sumYY float64
+	x0 := d.times[0]
+	for i := range d.times {
+		x, y := d.times[i]-x0, d.values[i]
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+		sumYY += y * y
+	}
+
+	varX := n*sumXX - sumX*sumX
+	varY := n*sumYY - sumY*sumY
+	if varX == 0 {
+		return 0, 0
+	}
+	cov := n*sumXY - sumX*sumY
+	slope = cov / varX
+	if slope <= 0 || varY == 0 {
+		return slope, 0
+	}
+	return slope, cov * cov / (varX * varY)
+}
+
+// liveHeap returns the heap marked live by the last GC. Without the runtime metric
+// it is derived from the heap goal and GOGC.
+func liveHeap(m *runtime.MemStats) int64 {
+	if hasLiveHeapMetric {
+		sample := []metrics.Sample{{Name: liveHeapMetric}}
+		metrics.Read(sample)
+		if sample[0].Value.Kind() == metrics.KindUint64 {
+			return int64(sample[0].Value.Uint64())
+		}
+	}
+
+	gogc := 100
+	if v := os.Getenv("GOGC"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			// GOGC=off has no heap goal to derive the live heap from
+			return int64(m.HeapAlloc)
+		}
+		gogc = n
+	}
+	return int64(float64(m.NextGC) / (1 + float64(gogc)/100))
+}
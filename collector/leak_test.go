@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLeakDetector(t *testing.T) {
+	start := time.Now()
+
+	growing := leakDetector{}
+	var slope, score float64
+	for i := 1; i <= 10; i++ {
+		slope, score = growing.observe(start.Add(time.Duration(i)*time.Second), uint32(i), int64(i)*1024, 10)
+	}
+	if slope < 1023 || slope > 1025 || score < 0.99 {
+		t.Errorf("expected growing heap to score ~1 with slope ~1024 got (%v, %v)", slope, score)
+	}
+
+	flat := leakDetector{}
+	for i := 1; i <= 10; i++ {
+		slope, score = flat.observe(start.Add(time.Duration(i)*time.Second), uint32(i), int64(1024+(i%2)*512), 10)
+	}
+	if score > 0.1 {
+		t.Errorf("expected oscillating heap to score ~0 got (%v, %v)", slope, score)
+	}
+
+	// Without new GC cycles no samples are added
+	idle := leakDetector{}
+	for i := 1; i <= 10; i++ {
+		slope, score = idle.observe(start.Add(time.Duration(i)*time.Second), 1, int64(i)*1024, 10)
+	}
+	if len(idle.times) != 1 || score != 0 {
+		t.Errorf("expected a single sample and no score got (%d, %v)", len(idle.times), score)
+	}
+}
@@ -0,0 +1,145 @@
+package collector
+
+import (
+	"encoding/binary"
+	"errors"
+	"runtime/metrics"
+	"time"
+)
+
+// liteVersion is the first byte of every binary LiteFields payload.
+const liteVersion = 1
+
+// liteMetrics are the runtime/metrics read by LiteCollector, in LiteFields order.
+// None of them require stopping the world, unlike runtime.ReadMemStats.
+var liteMetrics = []string{
+	"/sched/goroutines:goroutines",
+	"/memory/classes/heap/objects:bytes",
+	"/memory/classes/total:bytes",
+	"/gc/heap/goal:bytes",
+	"/gc/cycles/total:gc-cycles",
+}
+
+// LiteFieldsFunc represents a callback after successfully gathering lite statistics.
+// The LiteFields value is reused between calls and must not be retained.
+type LiteFieldsFunc func(*LiteFields)
+
+// LiteFields is the handful of cheap gauges gathered by LiteCollector.
+type LiteFields struct {
+	Time         int64 // unix nanoseconds
+	NumGoroutine int64
+	HeapAlloc    int64
+	Sys          int64
+	NextGC       int64
+	NumGC        int64
+}
+
+// LiteCollector is a low-overhead alternative to Collector for long-running agents
+// on small devices. It only reads a few runtime/metrics values into a reused
+// LiteFields, so a collection doesn't allocate.
+type LiteCollector struct {
+	// PauseDur represents the interval in-between each set of stats output.
+	// Defaults to 10 seconds.
+	PauseDur time.Duration
+
+	// Done, when closed, is used to signal LiteCollector that is should stop collecting
+	// statistics and the Run function should return.
+	Done <-chan struct{}
+
+	fieldsFunc LiteFieldsFunc
+	samples    []metrics.Sample
+	fields     LiteFields
+}
+
+// NewLite creates a new LiteCollector that will periodically output statistics to fieldsFunc.
+func NewLite(fieldsFunc LiteFieldsFunc) *LiteCollector {
+	if fieldsFunc == nil {
+		fieldsFunc = func(*LiteFields) {}
+	}
+
+	samples := make([]metrics.Sample, len(liteMetrics))
+	for i, name := range liteMetrics {
+		samples[i].Name = name
+	}
+
+	return &LiteCollector{
+		PauseDur:   10 * time.Second,
+		fieldsFunc: fieldsFunc,
+		samples:    samples,
+	}
+}
+
+// Run gathers statistics then outputs them to the configured LiteFieldsFunc every
+// PauseDur. It returns once Done has been closed (or never if Done is nil), therefore
+// it should be called in its own go routine.
+func (c *LiteCollector) Run() {
+	c.OneOff(&c.fields)
+	c.fieldsFunc(&c.fields)
+
+	tick := time.NewTicker(c.PauseDur)
+	defer tick.Stop()
+	for {
+		select {
+		case <-c.Done:
+			return
+		case <-tick.C:
+			c.OneOff(&c.fields)
+			c.fieldsFunc(&c.fields)
+		}
+	}
+}
+
+// OneOff gathers statistics into fields. Unlike Collector.OneOff it is not safe for
+// use from multiple go routines.
+func (c *LiteCollector) OneOff(fields *LiteFields) {
+	metrics.Read(c.samples)
+
+	fields.Time = time.Now().UnixNano()
+	fields.NumGoroutine = liteValue(c.samples[0])
+	fields.HeapAlloc = liteValue(c.samples[1])
+	fields.Sys = liteValue(c.samples[2])
+	fields.NextGC = liteValue(c.samples[3])
+	fields.NumGC = liteValue(c.samples[4])
+}
+
+func liteValue(s metrics.Sample) int64 {
+	if s.Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return int64(s.Value.Uint64())
+}
+
+// AppendBinary appends the compact binary encoding of f to b: a version byte
+// followed by one varint per field.
+func (f *LiteFields) AppendBinary(b []byte) []byte {
+	var buf [binary.MaxVarintLen64]byte
+
+	b = append(b, liteVersion)
+	for _, v := range [...]int64{f.Time, f.NumGoroutine, f.HeapAlloc, f.Sys, f.NextGC, f.NumGC} {
+		b = append(b, buf[:binary.PutVarint(buf[:], v)]...)
+	}
+	return b
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (f *LiteFields) MarshalBinary() ([]byte, error) {
+	return f.AppendBinary(make([]byte, 0, 1+6*binary.MaxVarintLen64)), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (f *LiteFields) UnmarshalBinary(b []byte) error {
+	if len(b) == 0 || b[0] != liteVersion {
+		return errors.New("collector: unsupported lite payload version")
+	}
+	b = b[1:]
+
+	for _, v := range [...]*int64{&f.Time, &f.NumGoroutine, &f.HeapAlloc, &f.Sys, &f.NextGC, &f.NumGC} {
+		x, n := binary.Varint(b)
+		if n <= 0 {
+			return errors.New("collector: truncated lite payload")
+		}
+		*v = x
+		b = b[n:]
+	}
+	return nil
+}
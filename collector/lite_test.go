@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestLiteCollector(t *testing.T) {
+	c := NewLite(nil)
+
+	fields := &LiteFields{}
+	c.OneOff(fields)
+	if fields.NumGoroutine == 0 || fields.HeapAlloc == 0 || fields.Sys == 0 {
+		t.Errorf("expected non-zero gauges got %+v", fields)
+	}
+
+	buf := make([]byte, 0, 128)
+	if allocs := testing.AllocsPerRun(100, func() {
+		c.OneOff(fields)
+		buf = fields.AppendBinary(buf[:0])
+	}); allocs != 0 {
+		t.Errorf("expected no allocations got %v", allocs)
+	}
+}
+
+func TestLiteFieldsBinary(t *testing.T) {
+	exp := LiteFields{Time: 1600000000000000000, NumGoroutine: 12, HeapAlloc: 1 << 20, Sys: 1 << 24, NextGC: 4 << 20, NumGC: 7}
+
+	b, _ := exp.MarshalBinary()
+	got := LiteFields{}
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+	if got != exp {
+		t.Errorf("round trip mismatch:\ngot: %+v\nexp: %+v", got, exp)
+	}
+
+	if err := got.UnmarshalBinary(b[:len(b)-1]); err == nil {
+		t.Error("expected error for truncated payload")
+	}
+}
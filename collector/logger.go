@@ -0,0 +1,32 @@
+package collector
+
+import "fmt"
+
+// Logger receives the errors encountered while collecting.
+type Logger interface {
+	Println(v ...interface{})
+}
+
+// loggerBox lets an atomic.Value hold any Logger implementation, and nil.
+type loggerBox struct{ Logger }
+
+// SetLogger sets the logger collection errors are reported to, nil to discard
+// them. It is safe to call while Run is executing.
+func (c *Collector) SetLogger(l Logger) {
+	c.logger.Store(loggerBox{l})
+}
+
+// log reports v to the logger, if any.
+func (c *Collector) log(v ...interface{}) {
+	if box, _ := c.logger.Load().(loggerBox); box.Logger != nil {
+		box.Println(v...)
+	}
+}
+
+// logError reports err, prefixed by msg, to the logger and to OnError, if any.
+func (c *Collector) logError(msg string, err error) {
+	c.log(msg, err)
+	if c.OnError != nil {
+		c.OnError(fmt.Errorf("%s %w", msg, err))
+	}
+}
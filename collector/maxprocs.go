@@ -0,0 +1,38 @@
+package collector
+
+import (
+	"math"
+	"os"
+	"runtime"
+	"strconv"
+)
+
+// configuredMaxProcs is the GOMAXPROCS the process was started with: the
+// GOMAXPROCS environment variable, or the number of CPUs when it's unset.
+var configuredMaxProcs = func() int {
+	if n, err := strconv.Atoi(os.Getenv("GOMAXPROCS")); err == nil && n > 0 {
+		return n
+	}
+	return runtime.NumCPU()
+}()
+
+// AdjustMaxProcs sets GOMAXPROCS to the container CPU quota, rounded down to at
+// least 1, unless the GOMAXPROCS environment variable is set or no quota applies.
+// It returns the resulting GOMAXPROCS.
+func AdjustMaxProcs() int {
+	if os.Getenv("GOMAXPROCS") != "" {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	quota, ok := cpuQuota()
+	if !ok {
+		return runtime.GOMAXPROCS(0)
+	}
+
+	procs := int(math.Floor(quota))
+	if procs < 1 {
+		procs = 1
+	}
+	runtime.GOMAXPROCS(procs)
+	return procs
+}
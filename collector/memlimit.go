@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"os"
+)
+
+// DefaultMemoryLimitRatio is the share of the container memory limit recommended
+// as GOMEMLIMIT, leaving headroom for non-heap memory.
+const DefaultMemoryLimitRatio = 0.9
+
+// RecommendedMemoryLimit returns ratio of the container memory limit, or false
+// when no memory limit applies. A ratio <= 0 uses DefaultMemoryLimitRatio.
+func RecommendedMemoryLimit(ratio float64) (int64, bool) {
+	if ratio <= 0 {
+		ratio = DefaultMemoryLimitRatio
+	}
+
+	limit, ok := memoryLimit()
+	if !ok {
+		return 0, false
+	}
+	return int64(float64(limit) * ratio), true
+}
+
+// ApplyMemoryLimit sets the runtime memory limit (GOMEMLIMIT) to the value from
+// RecommendedMemoryLimit, unless the GOMEMLIMIT environment variable is set, no
+// container memory limit applies or the Go version doesn't support memory limits.
+// It returns the resulting memory limit, 0 meaning no limit.
+func ApplyMemoryLimit(ratio float64) int64 {
+	if os.Getenv("GOMEMLIMIT") == "" {
+		if limit, ok := RecommendedMemoryLimit(ratio); ok {
+			setMemoryLimit(limit)
+		}
+	}
+	return currentMemoryLimit()
+}
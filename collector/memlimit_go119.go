@@ -0,0 +1,21 @@
+//go:build go1.19
+// +build go1.19
+
+package collector
+
+import (
+	"math"
+	"runtime/debug"
+)
+
+// currentMemoryLimit returns the runtime memory limit, 0 meaning no limit.
+func currentMemoryLimit() int64 {
+	if limit := debug.SetMemoryLimit(-1); limit != math.MaxInt64 {
+		return limit
+	}
+	return 0
+}
+
+func setMemoryLimit(limit int64) {
+	debug.SetMemoryLimit(limit)
+}
@@ -0,0 +1,11 @@
+//go:build !go1.19
+// +build !go1.19
+
+package collector
+
+// currentMemoryLimit returns 0 as memory limits require Go 1.19.
+func currentMemoryLimit() int64 {
+	return 0
+}
+
+func setMemoryLimit(limit int64) {}
@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"math"
+	"runtime"
+	"sort"
+	"sync"
+)
+
+// pauseTracker computes the GC pause distribution over the GC cycles completed
+// between two collections.
+type pauseTracker struct {
+	mu     sync.Mutex
+	numGC  uint32
+	pauses []uint64
+}
+
+// pauseStats holds the GC pauses of an interval, in nanoseconds.
+type pauseStats struct {
+	Count, P50, P90, P99, Max int64
+}
+
+// observe returns the distribution of the pauses of the GC cycles completed since
+// the previous call, all 0 if there were none. The first call covers the pauses
+// still in the MemStats ring buffer, which only keeps the last 256.
+func (t *pauseTracker) observe(m *runtime.MemStats) pauseStats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	from := t.numGC
+	if m.NumGC-from > 256 {
+		from = m.NumGC - 256
+	}
+	t.pauses = t.pauses[:0]
+	for i := from + 1; i <= m.NumGC; i++ {
+		t.pauses = append(t.pauses, m.PauseNs[(i+255)%256])
+	}
+	t.numGC = m.NumGC
+
+	if len(t.pauses) == 0 {
+		return pauseStats{}
+	}
+	sort.Slice(t.pauses, func(i, j int) bool { return t.pauses[i] < t.pauses[j] })
+	return pauseStats{
+		Count: int64(len(t.pauses)),
+		P50:   pauseQuantile(t.pauses, 0.5),
+		P90:   pauseQuantile(t.pauses, 0.9),
+		P99:   pauseQuantile(t.pauses, 0.99),
+		Max:   int64(t.pauses[len(t.pauses)-1]),
+	}
+}
+
+// pauseQuantile returns the nearest-rank quantile q of the sorted pauses.
+func pauseQuantile(sorted []uint64, q float64) int64 {
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return int64(sorted[rank])
+}
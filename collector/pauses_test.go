@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestPauseTracker(t *testing.T) {
+	m := &runtime.MemStats{}
+	for i := uint32(1); i <= 100; i++ {
+		m.PauseNs[(i+255)%256] = uint64(i) * 1000
+	}
+	m.NumGC = 100
+
+	var tracker pauseTracker
+	s := tracker.observe(m)
+	if s != (pauseStats{Count: 100, P50: 50000, P90: 90000, P99: 99000, Max: 100000}) {
+		t.Errorf("unexpected pause distribution %+v", s)
+	}
+
+	if s := tracker.observe(m); s != (pauseStats{}) {
+		t.Errorf("expected no pauses without GC cycles got %+v", s)
+	}
+
+	// Only the cycles since the previous collection count
+	m.PauseNs[(101+255)%256] = 7000
+	m.PauseNs[(102+255)%256] = 3000
+	m.NumGC = 102
+	if s := tracker.observe(m); s != (pauseStats{Count: 2, P50: 3000, P90: 7000, P99: 7000, Max: 7000}) {
+		t.Errorf("unexpected pause distribution %+v", s)
+	}
+}
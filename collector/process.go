@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"errors"
+	"time"
+)
+
+var errProcessUnsupported = errors.New("collector: process statistics are not supported on this platform")
+
+// processStart approximates the process start time where the OS doesn't report it.
+var processStart = time.Now()
+
+// procStats holds process level statistics. Field semantics are identical on every
+// supported platform; FDs counts open handles on Windows. Statistics a platform
+// doesn't provide are left 0.
+type procStats struct {
+	CPUUser   int64 // nanoseconds
+	CPUSystem int64 // nanoseconds
+	RSS       int64 // bytes
+	PeakRSS   int64 // bytes, where the current RSS isn't known
+	VSZ       int64 // bytes
+	FDs       int64
+	FDLimit   int64
+	Threads   int64
+	// ThreadsCreated counts the threads created by the Go runtime, where the
+	// current thread count isn't known.
+	ThreadsCreated int64
+	// Voluntary and involuntary context switches
+	CtxSwitches      int64
+	CtxSwitchesInvol int64
+	StartTime        int64 // unix nanoseconds
+}
@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"os"
+	"runtime/pprof"
+	"syscall"
+)
+
+// Without cgo the current RSS, VSZ, thread count and start time aren't reachable
+// on Darwin, so only PeakRSS and ThreadsCreated are set in their place and
+// StartTime reports the initialization of the package.
+func readProcStats(s *procStats) error {
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return err
+	}
+
+	s.CPUUser = ru.Utime.Nano()
+	s.CPUSystem = ru.Stime.Nano()
+	// ru_maxrss is in bytes on Darwin
+	s.PeakRSS = ru.Maxrss
+	s.ThreadsCreated = int64(pprof.Lookup("threadcreate").Count())
+	s.CtxSwitches = ru.Nvcsw
+	s.CtxSwitchesInvol = ru.Nivcsw
+	s.StartTime = processStart.UnixNano()
+
+	if err := readFDLimit(s); err != nil {
+		return err
+	}
+
+	d, err := os.Open("/dev/fd")
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	names, err := d.Readdirnames(-1)
+	if err != nil {
+		return err
+	}
+	// Don't count the descriptor used to read the directory
+	s.FDs = int64(len(names)) - 1
+
+	return nil
+}
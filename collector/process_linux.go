@@ -0,0 +1,95 @@
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+// clockTicks is USER_HZ, which is 100 on all mainstream Linux architectures.
+const clockTicks = 100
+
+func readProcStats(s *procStats) error {
+	stat, err := ioutil.ReadFile("/proc/self/stat")
+	if err != nil {
+		return err
+	}
+
+	// The command name is enclosed in parentheses and may contain spaces
+	i := bytes.LastIndexByte(stat, ')')
+	if i < 0 {
+		return errors.New("collector: malformed /proc/self/stat")
+	}
+	// Fields after the command name, starting with field 3 (state)
+	fields := bytes.Fields(stat[i+1:])
+	if len(fields) < 22 {
+		return errors.New("collector: malformed /proc/self/stat")
+	}
+
+	utime, _ := strconv.ParseInt(string(fields[11]), 10, 64)
+	stime, _ := strconv.ParseInt(string(fields[12]), 10, 64)
+	threads, _ := strconv.ParseInt(string(fields[17]), 10, 64)
+	starttime, _ := strconv.ParseInt(string(fields[19]), 10, 64)
+	vsize, _ := strconv.ParseInt(string(fields[20]), 10, 64)
+	rss, _ := strconv.ParseInt(string(fields[21]), 10, 64)
+
+	s.CPUUser = utime * (1e9 / clockTicks)
+	s.CPUSystem = stime * (1e9 / clockTicks)
+	s.Threads = threads
+	s.VSZ = vsize
+	s.RSS = rss * int64(os.Getpagesize())
+
+	// The start time is in clock ticks since boot
+	s.StartTime = processStart.UnixNano()
+	if btime, err := bootTime(); err == nil {
+		s.StartTime = btime*1e9 + starttime*(1e9/clockTicks)
+	}
+
+	var ru syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+		return err
+	}
+	s.CtxSwitches = int64(ru.Nvcsw)
+	s.CtxSwitchesInvol = int64(ru.Nivcsw)
+
+	if err := readFDLimit(s); err != nil {
+		return err
+	}
+
+	fds, err := countDir("/proc/self/fd")
+	if err != nil {
+		return err
+	}
+	// Don't count the descriptor used to read the directory
+	s.FDs = fds - 1
+
+	return nil
+}
+
+// bootTime returns the boot time in unix seconds from /proc/stat.
+func bootTime() (int64, error) {
+	stat, err := ioutil.ReadFile("/proc/stat")
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range bytes.Split(stat, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("btime ")) {
+			return strconv.ParseInt(string(bytes.TrimSpace(line[len("btime "):])), 10, 64)
+		}
+	}
+	return 0, errors.New("collector: no btime in /proc/stat")
+}
+
+func countDir(path string) (int64, error) {
+	d, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer d.Close()
+
+	names, err := d.Readdirnames(-1)
+	return int64(len(names)), err
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+// +build !linux,!darwin,!windows
+
+package collector
+
+func readProcStats(s *procStats) error {
+	return errProcessUnsupported
+}
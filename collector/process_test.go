@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestReadProcStats(t *testing.T) {
+	s := procStats{}
+	err := readProcStats(&s)
+	switch runtime.GOOS {
+	case "linux", "darwin", "windows":
+	default:
+		if err != errProcessUnsupported {
+			t.Errorf("expected unsupported error got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rss, threads := s.RSS, s.Threads
+	if runtime.GOOS == "darwin" {
+		if s.RSS != 0 || s.Threads != 0 {
+			t.Errorf("expected no current RSS and thread count on Darwin got %+v", s)
+		}
+		rss, threads = s.PeakRSS, s.ThreadsCreated
+	}
+	if rss <= 0 || threads <= 0 || s.FDs <= 0 || s.StartTime <= 0 || s.StartTime > time.Now().UnixNano() {
+		t.Errorf("expected positive process stats got %+v", s)
+	}
+	if runtime.GOOS != "windows" && (s.FDLimit < s.FDs || s.CtxSwitches+s.CtxSwitchesInvol <= 0) {
+		t.Errorf("expected descriptor limit and context switches got %+v", s)
+	}
+	if runtime.GOOS == "linux" && s.VSZ < s.RSS {
+		t.Errorf("expected VSZ above RSS got %+v", s)
+	}
+}
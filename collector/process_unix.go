@@ -0,0 +1,23 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package collector
+
+import (
+	"math"
+	"syscall"
+)
+
+// readFDLimit reads the soft limit of open file descriptors, reported as
+// math.MaxInt64 when unlimited.
+func readFDLimit(s *procStats) error {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return err
+	}
+	s.FDLimit = math.MaxInt64
+	if rl.Cur < math.MaxInt64 {
+		s.FDLimit = int64(rl.Cur)
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package collector
+
+import (
+	"math"
+	"runtime/metrics"
+	"strings"
+	"sync"
+)
+
+// runtimeQuantiles are the quantiles written for every runtime/metrics histogram.
+var runtimeQuantiles = []struct {
+	suffix string
+	q      float64
+}{
+	{".p50", 0.5},
+	{".p90", 0.9},
+	{".p99", 0.99},
+}
+
+// runtimeMetricsReader reads every metric supported by the runtime/metrics
+// package. Histograms are summarized over the observations made between two
+// reads.
+type runtimeMetricsReader struct {
+	mu      sync.Mutex
+	samples []metrics.Sample
+	names   []string // field names, in samples order
+	units   []string // units, in samples order
+	counts  map[string][]uint64
+}
+
+// read returns the metric values and their units, keyed by field name.
+func (r *runtimeMetricsReader) read() (values map[string]interface{}, units map[string]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.samples == nil {
+		for _, d := range metrics.All() {
+			name, unit := runtimeMetricField(d.Name)
+			r.samples = append(r.samples, metrics.Sample{Name: d.Name})
+			r.names = append(r.names, name)
+			r.units = append(r.units, unit)
+		}
+		r.counts = map[string][]uint64{}
+	}
+	metrics.Read(r.samples)
+
+	values = make(map[string]interface{}, len(r.samples))
+	units = make(map[string]string, len(r.samples))
+	for i, s := range r.samples {
+		name, unit := r.names[i], r.units[i]
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			values[name], units[name] = int64(s.Value.Uint64()), unit
+		case metrics.KindFloat64:
+			values[name], units[name] = finite(s.Value.Float64()), unit
+		case metrics.KindFloat64Histogram:
+			h := s.Value.Float64Histogram()
+			delta, total := r.delta(name, h.Counts)
+			values[name+".count"], units[name+".count"] = int64(total), UnitCount
+			for _, rq := range runtimeQuantiles {
+				var v float64
+				if total > 0 {
+					v = finite(histogramQuantile(delta, h.Buckets, total, rq.q))
+				}
+				values[name+rq.suffix], units[name+rq.suffix] = v, unit
+			}
+		}
+	}
+	return values, units
+}
+
+// delta returns the histogram counts of name since the previous read and their
+// sum. The first read covers all observations so far.
+func (r *runtimeMetricsReader) delta(name string, counts []uint64) ([]uint64, uint64) {
+	last := r.counts[name]
+	delta := make([]uint64, len(counts))
+	var total uint64
+	for i, n := range counts {
+		if i < len(last) && n >= last[i] {
+			n -= last[i]
+		}
+		delta[i] = n
+		total += n
+	}
+	r.counts[name] = append(last[:0], counts...)
+	return delta, total
+}
+
+// runtimeMetricField maps a runtime/metrics name to a field name and unit, e.g.
+// "/gc/pauses:seconds" to "gc.pauses.seconds" and UnitSeconds. The metric unit is
+// kept in the name since some metrics only differ by it.
+func runtimeMetricField(name string) (field, unit string) {
+	path, metricUnit := name, ""
+	if i := strings.LastIndexByte(name, ':'); i >= 0 {
+		path, metricUnit = name[:i], name[i+1:]
+	}
+	field = strings.Replace(strings.TrimPrefix(path, "/"), "/", ".", -1)
+	if metricUnit != "" {
+		field += "." + strings.Replace(metricUnit, "-", "_", -1)
+	}
+
+	switch metricUnit {
+	case "bytes":
+		unit = UnitBytes
+	case "seconds", "cpu-seconds":
+		unit = UnitSeconds
+	case "percent":
+		unit = UnitPercent
+	default:
+		unit = UnitCount
+	}
+	return field, unit
+}
+
+// finite returns v, or 0 if it is NaN or infinite, which line protocol rejects.
+func finite(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0
+	}
+	return v
+}
@@ -0,0 +1,48 @@
+package collector
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestRuntimeMetricField(t *testing.T) {
+	cases := []struct{ name, field, unit string }{
+		{"/gc/pauses:seconds", "gc.pauses.seconds", UnitSeconds},
+		{"/gc/heap/allocs:bytes", "gc.heap.allocs.bytes", UnitBytes},
+		{"/gc/heap/allocs:objects", "gc.heap.allocs.objects", UnitCount},
+		{"/cpu/classes/gc/total:cpu-seconds", "cpu.classes.gc.total.cpu_seconds", UnitSeconds},
+		{"/gc/gogc:percent", "gc.gogc.percent", UnitPercent},
+	}
+	for _, c := range cases {
+		if field, unit := runtimeMetricField(c.name); field != c.field || unit != c.unit {
+			t.Errorf("%s: expected %s (%s) got %s (%s)", c.name, c.field, c.unit, field, unit)
+		}
+	}
+}
+
+func TestRuntimeMetrics(t *testing.T) {
+	c := New(nil)
+	c.EnableRuntimeMetrics = true
+	c.OneOff()
+	runtime.GC()
+	fields := c.OneOff()
+
+	values, units := fields.Values(), fields.Units()
+	if n, ok := values["sched.goroutines.goroutines"].(int64); !ok || n < 1 {
+		t.Errorf("expected the goroutine count got %v", values["sched.goroutines.goroutines"])
+	}
+	if n, ok := values["gc.cycles.total.gc_cycles"].(int64); !ok || n < 1 {
+		t.Errorf("expected the GC cycle count got %v", values["gc.cycles.total.gc_cycles"])
+	}
+	if hasMetric("/gc/pauses:seconds") {
+		if n, ok := values["gc.pauses.seconds.count"].(int64); !ok || n < 1 {
+			t.Errorf("expected the pauses of the last GC got %v", values["gc.pauses.seconds.count"])
+		}
+		if units["gc.pauses.seconds.p99"] != UnitSeconds {
+			t.Errorf("expected seconds for the pause quantiles got %q", units["gc.pauses.seconds.p99"])
+		}
+	}
+	if fields.HeapAlloc != 0 {
+		t.Errorf("expected no MemStats fields got heap alloc %d", fields.HeapAlloc)
+	}
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"bytes"
+	"runtime"
+	"runtime/metrics"
+)
+
+// runnableMetric is only available in newer Go versions.
+const runnableMetric = "/sched/goroutines/runnable:goroutines"
+
+var hasRunnableMetric = hasMetric(runnableMetric)
+
+// hasMetric reports whether the runtime supports the runtime/metrics name.
+func hasMetric(name string) bool {
+	for _, d := range metrics.All() {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// runQueueStats holds scheduler run queue statistics.
+type runQueueStats struct {
+	Runnable   int64
+	GoMaxProcs int64
+}
+
+// readRunQueueStats reads the number of runnable goroutines waiting for a P. The
+// runtime doesn't expose the per-P queues. When runtime/metrics lack the
+// runnable goroutine count, it is counted in a full goroutine stack dump if
+// stackFallback is set and false is returned otherwise.
+func readRunQueueStats(s *runQueueStats, stackFallback bool) bool {
+	s.GoMaxProcs = int64(runtime.GOMAXPROCS(0))
+
+	if hasRunnableMetric {
+		sample := []metrics.Sample{{Name: runnableMetric}}
+		metrics.Read(sample)
+		if sample[0].Value.Kind() == metrics.KindUint64 {
+			s.Runnable = int64(sample[0].Value.Uint64())
+			return true
+		}
+	}
+	if !stackFallback {
+		return false
+	}
+	s.Runnable = countRunnableStacks()
+	return true
+}
+
+// countRunnableStacks counts the runnable goroutines in a dump of all the
+// goroutine stacks, which stops the world.
+func countRunnableStacks() int64 {
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	return int64(bytes.Count(buf, []byte(" [runnable")))
+}
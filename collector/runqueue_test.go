@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// startRunnable starts n goroutines blocking until stop is closed. With a single
+// P they stay runnable until the caller yields.
+func startRunnable(n int, stop chan struct{}) {
+	for i := 0; i < n; i++ {
+		go func() { <-stop }()
+	}
+}
+
+func TestReadRunQueueStats(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(1))
+	const n = 20
+
+	// Blocked goroutines aren't runnable
+	blocked := make(chan struct{})
+	startRunnable(n, blocked)
+	time.Sleep(10 * time.Millisecond)
+	s := runQueueStats{}
+	if !readRunQueueStats(&s, true) {
+		t.Fatal("expected run queue stats with the stack fallback")
+	}
+	if s.GoMaxProcs != 1 || s.Runnable >= n {
+		t.Errorf("expected fewer than %d runnable goroutines with GOMAXPROCS 1 got %+v", n, s)
+	}
+	close(blocked)
+
+	// New goroutines wait for the only P until the test yields
+	stop := make(chan struct{})
+	defer close(stop)
+	startRunnable(n, stop)
+	s = runQueueStats{}
+	readRunQueueStats(&s, true)
+	if s.Runnable < n {
+		t.Errorf("expected at least %d runnable goroutines got %d", n, s.Runnable)
+	}
+	if stacks := countRunnableStacks(); stacks < n {
+		t.Errorf("expected at least %d runnable goroutine stacks got %d", n, stacks)
+	}
+}
+
+func TestReadRunQueueStatsWithoutFallback(t *testing.T) {
+	s := runQueueStats{}
+	if ok := readRunQueueStats(&s, false); ok != hasRunnableMetric {
+		t.Errorf("expected stats only with the runnable metric (%v) got %v", hasRunnableMetric, ok)
+	}
+}
@@ -0,0 +1,102 @@
+package collector
+
+import (
+	"runtime/metrics"
+	"runtime/pprof"
+	"sync"
+)
+
+// Scheduler metrics, the goroutine states and thread count are only available
+// in newer Go versions.
+const (
+	threadsMetric           = "/sched/threads/total:threads"
+	goroutinesCreatedMetric = "/sched/goroutines-created:goroutines"
+	goroutinesRunningMetric = "/sched/goroutines/running:goroutines"
+	goroutinesWaitingMetric = "/sched/goroutines/waiting:goroutines"
+	goroutinesNotInGoMetric = "/sched/goroutines/not-in-go:goroutines"
+	schedLatenciesMetric    = "/sched/latencies:seconds"
+)
+
+var hasThreadsMetric = hasMetric(threadsMetric)
+
+// schedStats holds scheduler thread and goroutine state statistics.
+type schedStats struct {
+	Threads           int64
+	GoroutinesCreated int64
+	GoroutinesRunning int64
+	GoroutinesWaiting int64
+	GoroutinesNotInGo int64
+}
+
+// readSchedStats reads the number of OS threads owned by the runtime and of
+// goroutines per state. When runtime/metrics lacks the thread count it falls back
+// to the number of threads created, which the runtime rarely exits; the goroutine
+// states are left 0 when unsupported.
+func readSchedStats(s *schedStats) {
+	samples := []metrics.Sample{
+		{Name: threadsMetric},
+		{Name: goroutinesCreatedMetric},
+		{Name: goroutinesRunningMetric},
+		{Name: goroutinesWaitingMetric},
+		{Name: goroutinesNotInGoMetric},
+	}
+	metrics.Read(samples)
+
+	values := [...]*int64{&s.Threads, &s.GoroutinesCreated, &s.GoroutinesRunning, &s.GoroutinesWaiting, &s.GoroutinesNotInGo}
+	for i, sample := range samples {
+		if sample.Value.Kind() == metrics.KindUint64 {
+			*values[i] = int64(sample.Value.Uint64())
+		}
+	}
+	if !hasThreadsMetric {
+		s.Threads = int64(pprof.Lookup("threadcreate").Count())
+	}
+}
+
+// latencyStats holds the scheduler latencies of an interval, in nanoseconds.
+type latencyStats struct {
+	Count, P50, P90, P99, Max int64
+}
+
+// latencyTracker computes the scheduler latency distribution, the time
+// goroutines spent runnable before running, over the scheduling events between
+// two collections.
+type latencyTracker struct {
+	mu     sync.Mutex
+	counts []uint64
+}
+
+// observe reads the cumulative scheduler latency histogram and returns the
+// distribution of the latencies since the previous call, all 0 if there were
+// none. Values are the upper bounds of the histogram buckets. The first call
+// covers all scheduling events so far.
+func (t *latencyTracker) observe() latencyStats {
+	sample := []metrics.Sample{{Name: schedLatenciesMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindFloat64Histogram {
+		return latencyStats{}
+	}
+	h := sample[0].Value.Float64Histogram()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delta := make([]uint64, len(h.Counts))
+	var total uint64
+	for i, n := range h.Counts {
+		if i < len(t.counts) && n >= t.counts[i] {
+			n -= t.counts[i]
+		}
+		delta[i] = n
+		total += n
+	}
+	t.counts = append(t.counts[:0], h.Counts...)
+
+	if total == 0 {
+		return latencyStats{}
+	}
+	ns := func(q float64) int64 {
+		return int64(histogramQuantile(delta, h.Buckets, total, q) * 1e9)
+	}
+	return latencyStats{Count: int64(total), P50: ns(0.5), P90: ns(0.9), P99: ns(0.99), Max: ns(1)}
+}
@@ -0,0 +1,36 @@
+package collector
+
+import (
+	"runtime"
+	"testing"
+)
+
+func TestReadSchedStats(t *testing.T) {
+	s := schedStats{}
+	readSchedStats(&s)
+	if s.Threads < 1 {
+		t.Errorf("expected at least a thread got (%d)", s.Threads)
+	}
+	if hasMetric(goroutinesCreatedMetric) && s.GoroutinesCreated < int64(runtime.NumGoroutine()) {
+		t.Errorf("expected at least the %d live goroutines created got (%d)", runtime.NumGoroutine(), s.GoroutinesCreated)
+	}
+	if hasMetric(goroutinesRunningMetric) && s.GoroutinesRunning < 1 {
+		t.Errorf("expected the test goroutine running got (%d)", s.GoroutinesRunning)
+	}
+}
+
+func TestLatencyTracker(t *testing.T) {
+	var tracker latencyTracker
+	tracker.observe()
+
+	done := make(chan struct{})
+	for i := 0; i < 100; i++ {
+		go func() { done <- struct{}{} }()
+		<-done
+	}
+
+	s := tracker.observe()
+	if s.Count == 0 || s.P50 > s.P99 || s.P99 > s.Max {
+		t.Errorf("expected ordered latencies of the scheduled goroutines got %+v", s)
+	}
+}
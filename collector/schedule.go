@@ -0,0 +1,186 @@
+package collector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule determines when Collector gathers statistics, as an alternative to the
+// fixed PauseDur interval.
+type Schedule interface {
+	// Next returns the next collection time after t, or the zero time if there is none.
+	Next(t time.Time) time.Time
+}
+
+// cronSchedule is a parsed five field cron expression, each field being a bit set
+// of the matching values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record unrestricted day fields, those starting with * or
+	// ?: when both day fields are restricted a day matches if either does, as in
+	// cron.
+	domStar, dowStar bool
+}
+
+type cronField struct {
+	min, max int
+}
+
+var cronFields = [5]cronField{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+
+// ParseCron parses a standard five field cron expression ("minute hour
+// day-of-month month day-of-week") supporting *, lists, ranges and steps, e.g.
+// "*/5 9-17 * * 1-5". As in cron, a day matches if either day field does when
+// both are restricted, and if both do otherwise; a day field starting with *,
+// e.g. "*/2", counts as unrestricted. Several expressions separated by ";" are
+// combined, the collection running whenever any of them matches:
+//
+//	ParseCron("* 9-17 * * 1-5; */10 0-8,18-23 * * *")
+func ParseCron(expr string) (Schedule, error) {
+	var schedules multiSchedule
+	for _, e := range strings.Split(expr, ";") {
+		if strings.TrimSpace(e) == "" {
+			continue
+		}
+		s, err := parseCronExpr(e)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, s)
+	}
+
+	switch len(schedules) {
+	case 0:
+		return nil, fmt.Errorf("collector: empty cron expression")
+	case 1:
+		return schedules[0], nil
+	}
+	return schedules, nil
+}
+
+func parseCronExpr(expr string) (*cronSchedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("collector: cron expression %q must have 5 fields", expr)
+	}
+
+	var sets [5]uint64
+	for i, part := range parts {
+		set, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("collector: cron expression %q: %v", expr, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday is both 0 and 7
+	if sets[4]&(1<<7) != 0 {
+		sets[4] |= 1
+	}
+
+	return &cronSchedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: strings.HasPrefix(parts[2], "*") || parts[2] == "?",
+		dowStar: strings.HasPrefix(parts[4], "*") || parts[4] == "?",
+	}, nil
+}
+
+func parseCronField(field string, r cronField) (uint64, error) {
+	var set uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if i := strings.IndexByte(item, '/'); i >= 0 {
+			var err error
+			if step, err = strconv.Atoi(item[i+1:]); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %q", item)
+			}
+			rangePart = item[:i]
+		}
+
+		lo, hi := r.min, r.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", item)
+			}
+		default:
+			v, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", item)
+			}
+			lo, hi = v, v
+			if step > 1 {
+				hi = r.max
+			}
+		}
+
+		if lo < r.min || hi > r.max || lo > hi {
+			return 0, fmt.Errorf("%q out of range %d-%d", item, r.min, r.max)
+		}
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+// Next returns the start of the first minute after t matching the expression.
+func (s *cronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	// Give up after five years, e.g. for "0 0 30 2 *"
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (s *cronSchedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// multiSchedule runs whenever any of its schedules does.
+type multiSchedule []Schedule
+
+func (m multiSchedule) Next(t time.Time) time.Time {
+	var next time.Time
+	for _, s := range m {
+		if n := s.Next(t); !n.IsZero() && (next.IsZero() || n.Before(next)) {
+			next = n
+		}
+	}
+	return next
+}
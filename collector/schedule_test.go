@@ -0,0 +1,45 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	// Monday 2021-06-07 08:59
+	start := time.Date(2021, 6, 7, 8, 59, 30, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		next time.Time
+	}{
+		{"* * * * *", time.Date(2021, 6, 7, 9, 0, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2021, 6, 7, 9, 0, 0, 0, time.UTC)},
+		{"30 12 * * *", time.Date(2021, 6, 7, 12, 30, 0, 0, time.UTC)},
+		{"0 0 1 1 *", time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 9 * * 0", time.Date(2021, 6, 13, 9, 0, 0, 0, time.UTC)},
+		{"0 9 * * 7", time.Date(2021, 6, 13, 9, 0, 0, 0, time.UTC)},
+		{"0 9 15 * 6", time.Date(2021, 6, 12, 9, 0, 0, 0, time.UTC)},
+		{"0 9 */2 * 2", time.Date(2021, 6, 15, 9, 0, 0, 0, time.UTC)},
+		{"5,10 18-20/2 * * 1-5", time.Date(2021, 6, 7, 18, 5, 0, 0, time.UTC)},
+		{"0 12 * * 1-5; */10 0-8,18-23 * * *", time.Date(2021, 6, 7, 12, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+
+	for _, test := range tests {
+		s, err := ParseCron(test.expr)
+		if err != nil {
+			t.Errorf("ParseCron(%q): %v", test.expr, err)
+			continue
+		}
+		if next := s.Next(start); !next.Equal(test.next) {
+			t.Errorf("ParseCron(%q).Next: expected %v got %v", test.expr, test.next, next)
+		}
+	}
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q): expected error", expr)
+		}
+	}
+}
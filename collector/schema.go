@@ -0,0 +1,68 @@
+package collector
+
+// SchemaVersion is the version of the field names of Fields. It is increased
+// whenever a field is renamed or removed, with the change recorded in Migrations,
+// and written as the "schema.version" tag so that dashboards can tell which
+// names a point uses.
+const SchemaVersion = 1
+
+// Migration records a field renamed or removed by a schema version.
+type Migration struct {
+	// Version is the schema version introducing the change.
+	Version int
+	// Field is the name up to Version-1.
+	Field string
+	// NewField is the name from Version on, "" if the field was removed.
+	NewField string
+	// Note explains the change and how to update queries.
+	Note string
+}
+
+// Migrations lists the field changes by increasing Version. Version 1 is the
+// baseline and has none.
+var Migrations = []Migration{}
+
+// MigrateField returns the current name of the field called name in schema
+// version from, and false if the field has been removed since.
+func MigrateField(name string, from int) (string, bool) {
+	for _, m := range Migrations {
+		if m.Version <= from || m.Field != name {
+			continue
+		}
+		if m.NewField == "" {
+			return "", false
+		}
+		name = m.NewField
+	}
+	return name, true
+}
+
+// FieldAt returns the name of the field, as currently called, in schema version.
+func FieldAt(field string, version int) string {
+	for i := len(Migrations) - 1; i >= 0; i-- {
+		if m := Migrations[i]; m.Version > version && m.NewField == field {
+			field = m.Field
+		}
+	}
+	return field
+}
+
+// FieldsAt renames values and units, keyed by the current field names, to the
+// names of schema version, so that points keep matching dashboards that haven't
+// been migrated yet. Fields introduced after version keep their name; removed
+// fields can't be brought back.
+func FieldsAt(version int, values map[string]interface{}, units map[string]string) (map[string]interface{}, map[string]string) {
+	if version >= SchemaVersion || len(Migrations) == 0 {
+		return values, units
+	}
+
+	oldValues := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		oldValues[FieldAt(k, version)] = v
+	}
+	oldUnits := make(map[string]string, len(units))
+	for k, v := range units {
+		oldUnits[FieldAt(k, version)] = v
+	}
+	return oldValues, oldUnits
+}
@@ -0,0 +1,28 @@
+package collector
+
+import "testing"
+
+func TestMigrateField(t *testing.T) {
+	defer func(m []Migration) { Migrations = m }(Migrations)
+	Migrations = []Migration{
+		{Version: 2, Field: "mem.gc.pause", NewField: "mem.gc.pause.last"},
+		{Version: 3, Field: "mem.gc.pause.last", NewField: "gc.pause.last"},
+		{Version: 3, Field: "mem.lookups"},
+	}
+
+	if name, ok := MigrateField("mem.gc.pause", 1); !ok || name != "gc.pause.last" {
+		t.Errorf("expected the renames applied in order got %q", name)
+	}
+	if name, ok := MigrateField("mem.gc.pause.last", 2); !ok || name != "gc.pause.last" {
+		t.Errorf("expected the later rename only got %q", name)
+	}
+	if _, ok := MigrateField("mem.lookups", 2); ok {
+		t.Error("expected the removed field to be reported")
+	}
+	if name := FieldAt("gc.pause.last", 1); name != "mem.gc.pause" {
+		t.Errorf("expected the version 1 name got %q", name)
+	}
+	if name := FieldAt("mem.heap.alloc", 1); name != "mem.heap.alloc" {
+		t.Errorf("expected an unchanged name got %q", name)
+	}
+}
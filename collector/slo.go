@@ -0,0 +1,71 @@
+package collector
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// PauseSLO declares a GC pause budget: Quantile of the GC pauses in every Window
+// must not exceed Target, e.g. {5 * time.Millisecond, 0.99, time.Minute} for
+// "p99 <= 5ms per minute". The remaining 1-Quantile of pauses is the error budget.
+type PauseSLO struct {
+	Target   time.Duration
+	Quantile float64
+	Window   time.Duration
+}
+
+// sloTracker counts GC pauses exceeding the PauseSLO target per window.
+type sloTracker struct {
+	mu          sync.Mutex
+	numGC       uint32
+	windowStart time.Time
+	pauses      int64
+	violations  int64
+}
+
+// observe accounts the pauses of the GC cycles completed since the previous call and
+// returns the pauses and violations of the current window, the share of the window's
+// error budget used so far and the burn rate of the new pauses (1 meaning the budget
+// is consumed exactly as fast as the SLO allows).
+func (t *sloTracker) observe(now time.Time, m *runtime.MemStats, slo PauseSLO) (pauses, violations int64, used, burnRate float64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.windowStart.IsZero() {
+		t.windowStart = now
+		t.numGC = m.NumGC
+	}
+	if now.Sub(t.windowStart) >= slo.Window {
+		t.windowStart = now
+		t.pauses, t.violations = 0, 0
+	}
+
+	// Only the last 256 pauses are kept in the MemStats ring buffer
+	from := t.numGC
+	if m.NumGC-from > 256 {
+		from = m.NumGC - 256
+	}
+	var newPauses, newViolations int64
+	for i := from + 1; i <= m.NumGC; i++ {
+		newPauses++
+		if time.Duration(m.PauseNs[(i+255)%256]) > slo.Target {
+			newViolations++
+		}
+	}
+	t.numGC = m.NumGC
+	t.pauses += newPauses
+	t.violations += newViolations
+
+	budget := 1 - slo.Quantile
+	if budget <= 0 {
+		return t.pauses, t.violations, 0, 0
+	}
+	if t.pauses > 0 {
+		used = float64(t.violations) / (float64(t.pauses) * budget)
+	}
+	if newPauses > 0 {
+		burnRate = float64(newViolations) / (float64(newPauses) * budget)
+	}
+	return t.pauses, t.violations, used, burnRate
+}
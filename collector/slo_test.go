@@ -0,0 +1,35 @@
+package collector
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSLOTracker(t *testing.T) {
+	slo := PauseSLO{Target: 5 * time.Millisecond, Quantile: 0.9, Window: time.Minute}
+	start := time.Now()
+
+	tracker := sloTracker{}
+	m := &runtime.MemStats{}
+	tracker.observe(start, m, slo)
+
+	// 10 pauses of which 2 exceed the target, twice the 10% budget
+	for i := 0; i < 10; i++ {
+		m.NumGC++
+		m.PauseNs[(m.NumGC+255)%256] = uint64(time.Millisecond)
+		if i < 2 {
+			m.PauseNs[(m.NumGC+255)%256] = uint64(10 * time.Millisecond)
+		}
+	}
+	pauses, violations, used, burn := tracker.observe(start.Add(time.Second), m, slo)
+	if pauses != 10 || violations != 2 || used < 1.99 || used > 2.01 || burn < 1.99 || burn > 2.01 {
+		t.Errorf("unexpected SLO stats (%d, %d, %v, %v)", pauses, violations, used, burn)
+	}
+
+	// A new window resets the budget
+	pauses, violations, used, _ = tracker.observe(start.Add(2*time.Minute), m, slo)
+	if pauses != 0 || violations != 0 || used != 0 {
+		t.Errorf("expected reset window got (%d, %d, %v)", pauses, violations, used)
+	}
+}
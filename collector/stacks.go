@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"runtime"
+	"sort"
+)
+
+// StackFingerprint is a goroutine call stack with the number of goroutines
+// currently running or blocked in it.
+type StackFingerprint struct {
+	// Hash identifies the call stack by its functions and lines, so it is stable
+	// across restarts of the same build.
+	Hash string
+	// Function, File and Line locate the first non-runtime frame.
+	Function string
+	File     string
+	Line     int
+
+	Goroutines int64
+}
+
+// TopStacks returns the n goroutine call stacks shared by the most goroutines,
+// most first. Reading the stacks briefly stops the world, proportionally to the
+// number of goroutines.
+func TopStacks(n int) []StackFingerprint {
+	var records []runtime.StackRecord
+	count, _ := runtime.GoroutineProfile(nil)
+	for {
+		// Leave room for goroutines started between the two calls.
+		records = make([]runtime.StackRecord, count+50)
+		var ok bool
+		if count, ok = runtime.GoroutineProfile(records); ok {
+			records = records[:count]
+			break
+		}
+	}
+
+	byHash := map[string]*StackFingerprint{}
+	for i := range records {
+		hash, function, file, line := hashStack(records[i].Stack())
+		if s, ok := byHash[hash]; ok {
+			s.Goroutines++
+			continue
+		}
+		byHash[hash] = &StackFingerprint{Hash: hash, Function: function, File: file, Line: line, Goroutines: 1}
+	}
+
+	stacks := make([]StackFingerprint, 0, len(byHash))
+	for _, s := range byHash {
+		stacks = append(stacks, *s)
+	}
+	sort.Slice(stacks, func(i, j int) bool {
+		if stacks[i].Goroutines != stacks[j].Goroutines {
+			return stacks[i].Goroutines > stacks[j].Goroutines
+		}
+		return stacks[i].Hash < stacks[j].Hash
+	})
+	if len(stacks) > n {
+		stacks = stacks[:n]
+	}
+	return stacks
+}
@@ -0,0 +1,34 @@
+package collector
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func stuckInTest(wg *sync.WaitGroup, release chan struct{}) {
+	wg.Done()
+	<-release
+}
+
+func TestTopStacks(t *testing.T) {
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	defer close(release)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go stuckInTest(&wg, release)
+	}
+	wg.Wait()
+
+	stacks := TopStacks(3)
+	if len(stacks) == 0 || len(stacks) > 3 {
+		t.Fatalf("expected 1 to 3 stacks got %d", len(stacks))
+	}
+	if !strings.HasSuffix(stacks[0].Function, "stuckInTest") || stacks[0].Goroutines != 100 {
+		t.Errorf("expected 100 goroutines stuck in the test got %+v", stacks[0])
+	}
+	if len(stacks[0].Hash) != 16 {
+		t.Errorf("unexpected hash %q", stacks[0].Hash)
+	}
+}
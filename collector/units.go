@@ -0,0 +1,191 @@
+package collector
+
+// Units of the Fields values, following the UCUM-like names understood by
+// OpenTelemetry and most metric backends.
+const (
+	UnitBytes       = "By"
+	UnitNanoseconds = "ns"
+	UnitSeconds     = "s"
+	UnitCount       = "1"
+	UnitRatio       = "ratio"
+	UnitCores       = "cores"
+	UnitBytesPerSec = "By/s"
+	UnitPercent     = "%"
+)
+
+// fieldUnits maps the Values keys to their unit.
+var fieldUnits = map[string]string{
+	"cpu.count":                 UnitCount,
+	"cpu.goroutines":            UnitCount,
+	"cpu.cgo_calls":             UnitCount,
+	"cpu.gomaxprocs":            UnitCount,
+	"cpu.gomaxprocs.configured": UnitCount,
+	"cgroup.cpu.quota":          UnitCores,
+	"cpu.utilization":           UnitRatio,
+
+	"cgroup.cpu.periods":           UnitCount,
+	"cgroup.cpu.throttled_periods": UnitCount,
+	"cgroup.cpu.throttled_time":    UnitNanoseconds,
+
+	"mem.alloc":   UnitBytes,
+	"mem.total":   UnitBytes,
+	"mem.sys":     UnitBytes,
+	"mem.lookups": UnitCount,
+	"mem.malloc":  UnitCount,
+	"mem.frees":   UnitCount,
+
+	"mem.objects.live":     UnitCount,
+	"mem.objects.avg_size": UnitBytes,
+
+	"mem.heap.alloc":    UnitBytes,
+	"mem.heap.sys":      UnitBytes,
+	"mem.heap.idle":     UnitBytes,
+	"mem.heap.inuse":    UnitBytes,
+	"mem.heap.released": UnitBytes,
+	"mem.heap.objects":  UnitCount,
+
+	"mem.stack.inuse":        UnitBytes,
+	"mem.stack.sys":          UnitBytes,
+	"mem.stack.mspan_inuse":  UnitBytes,
+	"mem.stack.mspan_sys":    UnitBytes,
+	"mem.stack.mcache_inuse": UnitBytes,
+	"mem.stack.mcache_sys":   UnitBytes,
+	"mem.othersys":           UnitBytes,
+
+	"mem.alloc_size.p50": UnitBytes,
+	"mem.alloc_size.p99": UnitBytes,
+
+	"cgroup.mem.limit":       UnitBytes,
+	"cgroup.mem.usage":       UnitBytes,
+	"cgroup.mem.usage_ratio": UnitRatio,
+	"mem.limit.recommended":  UnitBytes,
+	"mem.limit.applied":      UnitBytes,
+
+	"mem.gc.sys":          UnitBytes,
+	"mem.gc.next":         UnitBytes,
+	"mem.gc.last":         UnitNanoseconds,
+	"mem.gc.pause_total":  UnitNanoseconds,
+	"mem.gc.pause":        UnitNanoseconds,
+	"mem.gc.count":        UnitCount,
+	"mem.gc.cpu_fraction": UnitRatio,
+
+	"mem.gc.pause.count": UnitCount,
+	"mem.gc.pause.p50":   UnitNanoseconds,
+	"mem.gc.pause.p90":   UnitNanoseconds,
+	"mem.gc.pause.p99":   UnitNanoseconds,
+	"mem.gc.pause.max":   UnitNanoseconds,
+
+	"mem.gc.cpu.assist":         UnitNanoseconds,
+	"mem.gc.cpu.mark_dedicated": UnitNanoseconds,
+	"mem.gc.cpu.mark_idle":      UnitNanoseconds,
+	"mem.gc.cpu.pause":          UnitNanoseconds,
+
+	"mem.gc.goal_ratio":   UnitRatio,
+	"mem.gc.gogc":         UnitPercent,
+	"mem.gc.scan.heap":    UnitBytes,
+	"mem.gc.scan.stack":   UnitBytes,
+	"mem.gc.scan.globals": UnitBytes,
+	"mem.gc.count.forced": UnitCount,
+
+	"mem.gc.slo.pauses":      UnitCount,
+	"mem.gc.slo.violations":  UnitCount,
+	"mem.gc.slo.budget_used": UnitRatio,
+	"mem.gc.slo.burn_rate":   UnitRatio,
+
+	"mem.heap.live":       UnitBytes,
+	"mem.heap.live.slope": UnitBytesPerSec,
+	"mem.heap.leak_score": UnitRatio,
+
+	"sched.runqueue":       UnitCount,
+	"sched.runqueue.ratio": UnitRatio,
+
+	"sched.threads":              UnitCount,
+	"sched.goroutines.created":   UnitCount,
+	"sched.goroutines.running":   UnitCount,
+	"sched.goroutines.waiting":   UnitCount,
+	"sched.goroutines.not_in_go": UnitCount,
+
+	"sched.latency.count": UnitCount,
+	"sched.latency.p50":   UnitNanoseconds,
+	"sched.latency.p90":   UnitNanoseconds,
+	"sched.latency.p99":   UnitNanoseconds,
+	"sched.latency.max":   UnitNanoseconds,
+
+	"contention.block.count": UnitCount,
+	"contention.block.delay": UnitNanoseconds,
+	"contention.mutex.count": UnitCount,
+	"contention.mutex.delay": UnitNanoseconds,
+
+	"proc.cpu.user":   UnitNanoseconds,
+	"proc.cpu.system": UnitNanoseconds,
+	"proc.mem.rss":    UnitBytes,
+	"proc.mem.vsz":    UnitBytes,
+	"mem.offheap":     UnitBytes,
+	"proc.fds":        UnitCount,
+	"proc.fds.limit":  UnitCount,
+	"proc.threads":    UnitCount,
+	"proc.uptime":     UnitNanoseconds,
+
+	"proc.mem.rss.peak":    UnitBytes,
+	"proc.threads.created": UnitCount,
+
+	"proc.ctx_switches.voluntary":   UnitCount,
+	"proc.ctx_switches.involuntary": UnitCount,
+}
+
+// Unit returns the unit of the Values key field, or "" if it is unknown.
+func Unit(field string) string {
+	return fieldUnits[field]
+}
+
+// cumulativeFields are the Values keys of counters that only grow over the life
+// of the process. The others are gauges.
+var cumulativeFields = map[string]bool{
+	"cpu.cgo_calls":                 true,
+	"cgroup.cpu.periods":            true,
+	"cgroup.cpu.throttled_periods":  true,
+	"cgroup.cpu.throttled_time":     true,
+	"contention.block.count":        true,
+	"contention.block.delay":        true,
+	"contention.mutex.count":        true,
+	"contention.mutex.delay":        true,
+	"mem.total":                     true,
+	"mem.lookups":                   true,
+	"mem.malloc":                    true,
+	"mem.frees":                     true,
+	"mem.gc.pause_total":            true,
+	"mem.gc.count":                  true,
+	"mem.gc.count.forced":           true,
+	"mem.gc.cpu.assist":             true,
+	"mem.gc.cpu.mark_dedicated":     true,
+	"mem.gc.cpu.mark_idle":          true,
+	"mem.gc.cpu.pause":              true,
+	"sched.goroutines.created":      true,
+	"proc.cpu.user":                 true,
+	"proc.cpu.system":               true,
+	"proc.ctx_switches.voluntary":   true,
+	"proc.ctx_switches.involuntary": true,
+	"proc.threads.created":          true,
+}
+
+// Cumulative reports whether the Values key field is a counter accumulated since
+// the process started rather than a gauge.
+func Cumulative(field string) bool {
+	return cumulativeFields[field]
+}
+
+// Units returns the unit of every Values key. The map must not be modified.
+func (f *Fields) Units() map[string]string {
+	if len(f.runtimeUnits) == 0 {
+		return fieldUnits
+	}
+
+	units := make(map[string]string, len(fieldUnits)+len(f.runtimeUnits))
+	for k, v := range fieldUnits {
+		units[k] = v
+	}
+	for k, v := range f.runtimeUnits {
+		units[k] = v
+	}
+	return units
+}
@@ -0,0 +1,31 @@
+package collector
+
+import "testing"
+
+func TestUnits(t *testing.T) {
+	f := Fields{}
+	units := f.Units()
+	values := f.Values()
+	// The fields written in place of others on Darwin
+	darwin := Fields{ProcPeakRSS: 1}
+	for k, v := range darwin.Values() {
+		values[k] = v
+	}
+	numeric := 0
+	for k, v := range values {
+		switch v.(type) {
+		case bool, string:
+			if units[k] != "" {
+				t.Errorf("unexpected unit for non-numeric %s", k)
+			}
+			continue
+		}
+		numeric++
+		if units[k] == "" {
+			t.Errorf("missing unit for %s", k)
+		}
+	}
+	if len(units) != numeric {
+		t.Errorf("expected a unit per numeric value got %d units for %d values", len(units), numeric)
+	}
+}
@@ -0,0 +1,40 @@
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+// utilizationTracker computes the share of the available CPU used by the process
+// between two collections.
+type utilizationTracker struct {
+	mu      sync.Mutex
+	last    time.Time
+	lastCPU int64
+}
+
+// observe returns the percentage of cores used since the previous call, given the
+// total process CPU time in nanoseconds. The first call returns 0.
+func (t *utilizationTracker) observe(now time.Time, cpu int64, cores float64) float64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last, lastCPU := t.last, t.lastCPU
+	t.last, t.lastCPU = now, cpu
+
+	wall := now.Sub(last)
+	if last.IsZero() || wall <= 0 || cores <= 0 || cpu < lastCPU {
+		return 0
+	}
+	return float64(cpu-lastCPU) / (float64(wall) * cores) * 100
+}
+
+// availableCores is the CPU the process can actually use: GOMAXPROCS, capped by
+// the cgroup CPU quota when there is one.
+func availableCores(gomaxprocs int64, quota float64) float64 {
+	cores := float64(gomaxprocs)
+	if quota > 0 && quota < cores {
+		cores = quota
+	}
+	return cores
+}
@@ -0,0 +1,26 @@
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestUtilizationTracker(t *testing.T) {
+	start := time.Now()
+	tracker := utilizationTracker{}
+
+	if u := tracker.observe(start, 0, 2); u != 0 {
+		t.Errorf("expected first observation to be 0 got %v", u)
+	}
+
+	// One second of CPU over one second of wall time on 2 cores
+	if u := tracker.observe(start.Add(time.Second), int64(time.Second), 2); u != 50 {
+		t.Errorf("expected 50%% got %v", u)
+	}
+
+	// Throttled to half a core by the quota
+	cores := availableCores(4, 0.5)
+	if u := tracker.observe(start.Add(2*time.Second), int64(1500*time.Millisecond), cores); u != 100 {
+		t.Errorf("expected 100%% got %v", u)
+	}
+}
@@ -0,0 +1,29 @@
+// Package configfile registers YAML and TOML decoders in
+// runstats.ConfigDecoders, for the .yaml, .yml and .toml files of LoadConfig. It
+// is a module of its own so that the runstats package doesn't depend on the
+// YAML and TOML libraries; import it for its side effect:
+//
+//	import _ "github.com/nzlov/go-runtime-metrics/configfile"
+package configfile
+
+import (
+	"github.com/BurntSushi/toml"
+	runstats "github.com/nzlov/go-runtime-metrics"
+	"gopkg.in/yaml.v2"
+)
+
+func init() {
+	runstats.ConfigDecoders[".yaml"] = YAML
+	runstats.ConfigDecoders[".yml"] = YAML
+	runstats.ConfigDecoders[".toml"] = TOML
+}
+
+// YAML decodes a YAML document into v.
+func YAML(data []byte, v interface{}) error {
+	return yaml.Unmarshal(data, v)
+}
+
+// TOML decodes a TOML document into v.
+func TOML(data []byte, v interface{}) error {
+	return toml.Unmarshal(data, v)
+}
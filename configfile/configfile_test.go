@@ -0,0 +1,41 @@
+package configfile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	runstats "github.com/nzlov/go-runtime-metrics"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"runstats.yaml": "host: influx:8086\ncollection_interval: 1m\nhttp_buckets: [250ms, 1s]\ntags:\n  service: checkout\n",
+		"runstats.toml": "host = \"influx:8086\"\ncollection_interval = \"1m\"\nhttp_buckets = [\"250ms\", \"1s\"]\n\n[tags]\nservice = \"checkout\"\n",
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		config, err := runstats.LoadConfig(path)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if config.Host != "influx:8086" || config.CollectionInterval != time.Minute || config.Tags["service"] != "checkout" {
+			t.Errorf("%s: unexpected config %+v", name, config)
+		}
+		if len(config.HTTPBuckets) != 2 || config.HTTPBuckets[0] != 250*time.Millisecond || config.HTTPBuckets[1] != time.Second {
+			t.Errorf("%s: unexpected http buckets %v", name, config.HTTPBuckets)
+		}
+	}
+}
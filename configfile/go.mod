@@ -0,0 +1,13 @@
+module github.com/nzlov/go-runtime-metrics/configfile
+
+go 1.16
+
+require (
+	github.com/BurntSushi/toml v1.2.1
+	github.com/nzlov/go-runtime-metrics v0.0.0-20261016010221-3d90ade0a013
+	gopkg.in/yaml.v2 v2.3.0
+)
+
+// The root module is replaced until a release contains the APIs this module
+// uses; drop the replace and pin that release then.
+replace github.com/nzlov/go-runtime-metrics => ../
@@ -0,0 +1,243 @@
+package runstats
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix prefixes the environment variables read by ConfigFromEnv and
+// LoadConfig, e.g. GRM_HOST for Config.Host.
+const EnvPrefix = "GRM_"
+
+// ConfigDecoders decode config files by extension for LoadConfig. JSON is
+// supported out of the box; importing the configfile module registers YAML and
+// TOML, so that this package doesn't depend on their libraries. Other formats
+// may be added, e.g.
+//
+//	metrics.ConfigDecoders[".hcl"] = hcl.Unmarshal
+//
+// A decoder is given a *map[string]interface{}.
+var ConfigDecoders = map[string]func(data []byte, v interface{}) error{
+	".json": json.Unmarshal,
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// ConfigFromEnv returns a Config with the fields set by environment variables
+// named EnvPrefix followed by the upper case json name of the field, e.g.
+// GRM_HOST, GRM_TOKEN or GRM_COLLECTION_INTERVAL=30s. Lists are comma separated
+// (GRM_FAILOVER_HOSTS=a:8086,b:8086), maps are comma separated key=value pairs
+// (GRM_TAGS=service=checkout,region=eu) and durations are parsed with
+// time.ParseDuration.
+func ConfigFromEnv() (*Config, error) {
+	config := &Config{}
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// LoadConfig reads a Config from a JSON file, or a file of another format with
+// a decoder in ConfigDecoders, keyed by the json names of the fields, e.g.
+// "collection_interval"; unknown keys are rejected. Durations may be given as
+// strings such as "30s". The environment variables of ConfigFromEnv take
+// precedence over the file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, withKind(ErrInvalidConfig, err)
+	}
+	decode, ok := ConfigDecoders[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("no config decoder for %s", path))
+	}
+	var doc map[string]interface{}
+	if err := decode(data, &doc); err != nil {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("%s: %w", path, err))
+	}
+
+	doc, _ = jsonValue(doc).(map[string]interface{})
+	if err := parseDurations(doc); err != nil {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("%s: %w", path, err))
+	}
+	// Round trip through JSON to share the json names of the fields
+	data, err = json.Marshal(doc)
+	if err != nil {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("%s: %w", path, err))
+	}
+	config := &Config{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+	if err := d.Decode(config); err != nil {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("%s: %w", path, err))
+	}
+
+	if err := config.applyEnv(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// configFields calls fn with every Config field that has a json name.
+func configFields(fn func(name string, field reflect.StructField)) {
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if field.PkgPath != "" || name == "" || name == "-" {
+			continue
+		}
+		fn(name, field)
+	}
+}
+
+// applyEnv sets the fields of config given by environment variables.
+func (config *Config) applyEnv() error {
+	v := reflect.ValueOf(config).Elem()
+	var err error
+	configFields(func(name string, field reflect.StructField) {
+		key := EnvPrefix + strings.ToUpper(name)
+		s, ok := os.LookupEnv(key)
+		if !ok || err != nil {
+			return
+		}
+		if e := setEnvField(v.FieldByIndex(field.Index), s); e != nil {
+			err = withKind(ErrInvalidConfig, fmt.Errorf("%s: %w", key, e))
+		}
+	})
+	return err
+}
+
+// setEnvField parses s into f.
+func setEnvField(f reflect.Value, s string) error {
+	switch {
+	case f.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		f.SetInt(int64(d))
+		return nil
+	case f.Kind() == reflect.Slice:
+		var items []string
+		if s != "" {
+			items = strings.Split(s, ",")
+		}
+		slice := reflect.MakeSlice(f.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setEnvField(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		f.Set(slice)
+		return nil
+	case f.Kind() == reflect.Map && f.Type().Key().Kind() == reflect.String && f.Type().Elem().Kind() == reflect.String:
+		m := reflect.MakeMap(f.Type())
+		for _, pair := range strings.Split(s, ",") {
+			if pair == "" {
+				continue
+			}
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return fmt.Errorf("invalid key=value pair %q", pair)
+			}
+			m.SetMapIndex(reflect.ValueOf(strings.TrimSpace(kv[0])), reflect.ValueOf(strings.TrimSpace(kv[1])))
+		}
+		f.Set(m)
+		return nil
+	}
+
+	switch f.Kind() {
+	case reflect.String:
+		f.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		f.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return err
+		}
+		f.SetInt(n)
+	case reflect.Float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		f.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported in the environment, use a config file")
+	}
+	return nil
+}
+
+// parseDurations replaces the duration strings of doc, e.g. "30s", by their
+// nanoseconds.
+func parseDurations(doc map[string]interface{}) error {
+	var err error
+	configFields(func(name string, field reflect.StructField) {
+		value, ok := doc[name]
+		if !ok || err != nil {
+			return
+		}
+		switch {
+		case field.Type == durationType:
+			doc[name], err = parseDuration(name, value)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem() == durationType:
+			items, _ := value.([]interface{})
+			for i, item := range items {
+				if items[i], err = parseDuration(name, item); err != nil {
+					return
+				}
+			}
+		}
+	})
+	return err
+}
+
+func parseDuration(name string, value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return int64(d), nil
+}
+
+// jsonValue converts the map[interface{}]interface{} some YAML decoders produce
+// to map[string]interface{}, which encoding/json supports.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = jsonValue(item)
+		}
+		return m
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = jsonValue(item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonValue(item)
+		}
+		return v
+	}
+	return v
+}
@@ -0,0 +1,109 @@
+package runstats
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	env := map[string]string{
+		"GRM_HOST":                   "influx:8086",
+		"GRM_COLLECTION_INTERVAL":    "30s",
+		"GRM_FAILOVER_HOSTS":         "a:8086, b:8086",
+		"GRM_TAGS":                   "service=checkout,region=eu",
+		"GRM_HTTP_BUCKETS":           "100ms,1s",
+		"GRM_FORWARD_EXPVAR":         "true",
+		"GRM_FLEET_SAMPLE_RATE":      "0.5",
+		"GRM_OUTPUT_FILE_MAX_SIZE":   "1024",
+		"GRM_MAX_BYTES_PER_INTERVAL": "2048",
+	}
+	for k, v := range env {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	config, err := ConfigFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "influx:8086" || config.CollectionInterval != 30*time.Second || !config.ForwardExpvar ||
+		config.FleetSampleRate != 0.5 || config.OutputFileMaxSize != 1024 || config.MaxBytesPerInterval != 2048 {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if len(config.FailoverHosts) != 2 || config.FailoverHosts[1] != "b:8086" {
+		t.Errorf("unexpected failover hosts %q", config.FailoverHosts)
+	}
+	if config.Tags["service"] != "checkout" || config.Tags["region"] != "eu" {
+		t.Errorf("unexpected tags %v", config.Tags)
+	}
+	if len(config.HTTPBuckets) != 2 || config.HTTPBuckets[1] != time.Second {
+		t.Errorf("unexpected http buckets %v", config.HTTPBuckets)
+	}
+
+	os.Setenv("GRM_COLLECTION_INTERVAL", "often")
+	if _, err := ConfigFromEnv(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected an invalid config error got %v", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "runstats.json")
+	data := `{"host": "influx:8086", "token": "file", "collection_interval": "1m", "http_buckets": ["250ms", 1000000000], "tags": {"service": "checkout"}}`
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GRM_TOKEN", "env")
+	defer os.Unsetenv("GRM_TOKEN")
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Host != "influx:8086" || config.Token != "env" || config.CollectionInterval != time.Minute || config.Tags["service"] != "checkout" {
+		t.Errorf("unexpected config %+v", config)
+	}
+	if len(config.HTTPBuckets) != 2 || config.HTTPBuckets[0] != 250*time.Millisecond || config.HTTPBuckets[1] != time.Second {
+		t.Errorf("unexpected http buckets %v", config.HTTPBuckets)
+	}
+
+	// A YAML decoder producing map[interface{}]interface{}
+	ConfigDecoders[".yaml"] = func(data []byte, v interface{}) error {
+		*v.(*map[string]interface{}) = map[string]interface{}{
+			"host":   "yaml:8086",
+			"tags":   map[interface{}]interface{}{"service": "search"},
+			"org":    "metrics",
+			"bucket": "go",
+		}
+		return nil
+	}
+	defer delete(ConfigDecoders, ".yaml")
+	yamlPath := filepath.Join(dir, "runstats.yaml")
+	if err := ioutil.WriteFile(yamlPath, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if config, err := LoadConfig(yamlPath); err != nil || config.Host != "yaml:8086" || config.Tags["service"] != "search" {
+		t.Errorf("unexpected yaml config %+v %v", config, err)
+	}
+
+	for _, data := range []string{`{"hots": "typo"}`, `{"collection_interval": "often"}`} {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadConfig(path); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected an invalid config error for %s got %v", data, err)
+		}
+	}
+	if _, err := LoadConfig(filepath.Join(dir, "runstats.ini")); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected an invalid config error for a missing file got %v", err)
+	}
+}
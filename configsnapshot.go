@@ -0,0 +1,116 @@
+package runstats
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"sort"
+	"time"
+)
+
+// writeConfigSnapshot writes the fingerprints of the effective configuration
+// and of ConfigValues, at most every ConfigSnapshotInterval. Credentials are
+// left out of the fingerprint so that rotating them isn't reported as drift,
+// and so is the identity of the instance, see fleetConfig.
+//
+// runstats.fingerprint and app.fingerprint hash either part, fingerprint both of
+// them; fingerprint.num holds the first 48 bits of fingerprint for backends that
+// only store numbers.
+func (r *RunStats) writeConfigSnapshot(ts time.Time) {
+	if !r.lastConfigSnapshot.IsZero() && ts.Sub(r.lastConfigSnapshot) < r.config.ConfigSnapshotInterval {
+		return
+	}
+	r.lastConfigSnapshot = ts
+
+	config, err := json.Marshal(r.fleetConfig())
+	if err != nil {
+		r.logError("runstats: failed to fingerprint config:", err)
+		return
+	}
+	var app map[string]string
+	if r.config.ConfigValues != nil {
+		app = r.config.ConfigValues()
+	}
+
+	runstatsSum := sha256.Sum256(config)
+	appSum := hashConfigValues(app)
+	sum := sha256.Sum256(append(runstatsSum[:], appSum[:]...))
+
+	r.writer.WritePoint(r.config.ConfigMeasurement, r.pointTags(nil), map[string]interface{}{
+		"fingerprint":          fingerprint(sum),
+		"fingerprint.num":      int64(binary.BigEndian.Uint64(sum[:8]) >> 16),
+		"runstats.fingerprint": fingerprint(runstatsSum),
+		"app.fingerprint":      fingerprint(appSum),
+		"app.values":           int64(len(app)),
+	}, ts)
+}
+
+// fleetConfig returns the effective configuration without the values derived
+// from the identity or the host of the instance, so that instances sharing a
+// configuration share its fingerprint: the default Measurement, Pushgateway
+// grouping and CloudEvents source, and the tags set to the identity or the
+// hostname.
+func (r *RunStats) fleetConfig() Config {
+	config := r.effectiveConfig()
+	instance := config.instance
+	host, _ := os.Hostname()
+	shared := func(v string) string {
+		switch {
+		case v == instance:
+			return "{instance}"
+		case v == host && host != "":
+			return "{host}"
+		}
+		return v
+	}
+
+	if config.Measurement == defaultMeasurement+"."+instance {
+		config.Measurement = ""
+	}
+	if config.CloudEventsSource == "/go-runtime-metrics/"+url.PathEscape(instance) {
+		config.CloudEventsSource = ""
+	}
+	for _, m := range []*map[string]string{&config.Tags, &config.PushgatewayGrouping} {
+		if len(*m) == 0 {
+			continue
+		}
+		values := make(map[string]string, len(*m))
+		for k, v := range *m {
+			values[k] = shared(v)
+		}
+		*m = values
+	}
+	return config
+}
+
+// hashConfigValues hashes values in key order.
+func hashConfigValues(values map[string]string) [sha256.Size]byte {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		// Length prefixes keep "a"="bc" and "ab"="c" apart
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(k)))
+		h.Write(n[:])
+		h.Write([]byte(k))
+		binary.BigEndian.PutUint64(n[:], uint64(len(values[k])))
+		h.Write(n[:])
+		h.Write([]byte(values[k]))
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// fingerprint shortens sum to 16 hex digits, plenty to tell configurations apart.
+func fingerprint(sum [sha256.Size]byte) string {
+	return hex.EncodeToString(sum[:8])
+}
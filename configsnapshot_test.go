@@ -0,0 +1,90 @@
+package runstats
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWriteConfigSnapshot(t *testing.T) {
+	region := "eu-west-1"
+	stats, tr := newTestRunStats(&Config{
+		ConfigSnapshotInterval: time.Minute,
+		Token:                  "secret",
+		ConfigValues: func() map[string]string {
+			return map[string]string{"region": region, "pool_size": "8"}
+		},
+	})
+	now := time.Now()
+
+	stats.writeConfigSnapshot(now)
+	stats.writeConfigSnapshot(now.Add(30 * time.Second))
+	points := tr.Points(stats)
+	if len(points) != 1 {
+		t.Fatalf("expected a point per interval got %d", len(points))
+	}
+	first := points[0]
+	if first.Measurement != defaultConfigMeasurement || first.Fields["app.values"] != int64(2) {
+		t.Fatalf("unexpected point %v %v", first.Measurement, first.Fields)
+	}
+	if fp, _ := first.Fields["fingerprint"].(string); len(fp) != 16 {
+		t.Errorf("unexpected fingerprint %q", fp)
+	}
+
+	// Rotating credentials isn't drift, changing an app value is
+	stats.config.Token = "rotated"
+	region = "us-east-1"
+	stats.writeConfigSnapshot(now.Add(time.Minute))
+	points = tr.Points(stats)
+	if len(points) != 2 {
+		t.Fatalf("expected a second point got %d", len(points)-1)
+	}
+	second := points[1]
+	if second.Fields["runstats.fingerprint"] != first.Fields["runstats.fingerprint"] {
+		t.Error("expected rotating the token not to change the runstats fingerprint")
+	}
+	if second.Fields["app.fingerprint"] == first.Fields["app.fingerprint"] || second.Fields["fingerprint"] == first.Fields["fingerprint"] {
+		t.Error("expected changing an app value to change the fingerprints")
+	}
+	if second.Fields["fingerprint.num"] == first.Fields["fingerprint.num"] {
+		t.Error("expected the numeric fingerprint to change")
+	}
+}
+
+func TestHashConfigValues(t *testing.T) {
+	if hashConfigValues(map[string]string{"a": "bc"}) == hashConfigValues(map[string]string{"ab": "c"}) {
+		t.Error("expected keys and values to be delimited")
+	}
+	if hashConfigValues(nil) != hashConfigValues(map[string]string{}) {
+		t.Error("expected nil and empty values to hash alike")
+	}
+}
+
+func TestConfigSnapshotFleet(t *testing.T) {
+	host, _ := os.Hostname()
+	fingerprints := map[string]interface{}{}
+	for _, id := range []string{"pod-1", "pod-2"} {
+		stats, tr := newTestRunStats(&Config{
+			ConfigSnapshotInterval: time.Minute,
+			Identity:               StaticIdentity(id),
+			Tags:                   map[string]string{"pod": id, "node": host, "team": "payments"},
+			PushgatewayURL:         "http://pushgateway:9091",
+		})
+		stats.writeConfigSnapshot(time.Now())
+		fingerprints[id] = tr.Points(stats)[0].Fields["runstats.fingerprint"]
+	}
+	if fingerprints["pod-1"] != fingerprints["pod-2"] {
+		t.Errorf("expected instances sharing a config to share the fingerprint got %v", fingerprints)
+	}
+
+	stats, tr := newTestRunStats(&Config{
+		ConfigSnapshotInterval: time.Minute,
+		Identity:               StaticIdentity("pod-3"),
+		Tags:                   map[string]string{"pod": "pod-3", "node": host, "team": "checkout"},
+		PushgatewayURL:         "http://pushgateway:9091",
+	})
+	stats.writeConfigSnapshot(time.Now())
+	if tr.Points(stats)[0].Fields["runstats.fingerprint"] == fingerprints["pod-1"] {
+		t.Error("expected a different config to change the fingerprint")
+	}
+}
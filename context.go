@@ -0,0 +1,83 @@
+package runstats
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+type contextTagsKey struct{}
+
+// ContextTagsFunc extracts request-scoped tags, e.g. a tenant ID, from a context.
+type ContextTagsFunc func(ctx context.Context) map[string]string
+
+// WithTags returns a copy of ctx carrying tags, merged over the tags already in
+// ctx. Middleware and the *Context methods attach them to the points they write.
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	parent := TagsFromContext(ctx)
+	merged := make(map[string]string, len(parent)+len(tags))
+	for k, v := range parent {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, contextTagsKey{}, merged)
+}
+
+// TagsFromContext returns the tags set with WithTags. The map must not be
+// modified.
+func TagsFromContext(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(contextTagsKey{}).(map[string]string)
+	return tags
+}
+
+// contextTags returns the tags of ctx set with WithTags and extracted by the
+// configured ContextTags, the latter taking precedence.
+func (r *RunStats) contextTags(ctx context.Context) map[string]string {
+	tags := TagsFromContext(ctx)
+	if r.config.ContextTags == nil {
+		return tags
+	}
+
+	extracted := r.config.ContextTags(ctx)
+	if len(tags) == 0 {
+		return extracted
+	}
+	merged := make(map[string]string, len(tags)+len(extracted))
+	for k, v := range tags {
+		merged[k] = v
+	}
+	for k, v := range extracted {
+		merged[k] = v
+	}
+	return merged
+}
+
+// AnnotateContext is like Annotate but also attaches the tags of ctx.
+func (r *RunStats) AnnotateContext(ctx context.Context, title, text string, tags map[string]string) {
+	merged := r.contextTags(ctx)
+	if len(merged) == 0 {
+		r.Annotate(title, text, tags)
+		return
+	}
+
+	_tags := make(map[string]string, len(merged)+len(tags))
+	for k, v := range merged {
+		_tags[k] = v
+	}
+	for k, v := range tags {
+		_tags[k] = v
+	}
+	r.Annotate(title, text, _tags)
+}
+
+// tagsKey returns a stable string for tags, to aggregate by tag set.
+func tagsKey(tags map[string]string) string {
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
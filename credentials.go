@@ -0,0 +1,202 @@
+package runstats
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CredentialsProvider authorizes the requests sent to the backend. It is called
+// for every request, so implementations can rotate credentials without
+// reconnecting.
+type CredentialsProvider interface {
+	// Authorize adds credentials to req, e.g. an Authorization header or a
+	// request signature.
+	Authorize(req *http.Request) error
+}
+
+// invalidator is implemented by CredentialsProviders caching credentials, which
+// are discarded when the backend rejects them.
+type invalidator interface {
+	Invalidate()
+}
+
+// TokenFunc returns the current InfluxDB token.
+type TokenFunc func() (string, error)
+
+// Authorize sets the "Authorization: Token <token>" header expected by InfluxDB.
+func (f TokenFunc) Authorize(req *http.Request) error {
+	token, err := f()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+token)
+	return nil
+}
+
+// StaticCredentials always uses token, like Config.Token.
+func StaticCredentials(token string) CredentialsProvider {
+	return TokenFunc(func() (string, error) {
+		return token, nil
+	})
+}
+
+// EnvCredentials reads the token from the environment variable name on every
+// request.
+func EnvCredentials(name string) CredentialsProvider {
+	return TokenFunc(func() (string, error) {
+		token, ok := os.LookupEnv(name)
+		if !ok || token == "" {
+			return "", fmt.Errorf("runstats: environment variable %s not set", name)
+		}
+		return token, nil
+	})
+}
+
+// FileCredentials reads the token from the file at path, e.g. a mounted
+// Kubernetes secret, and reads it again whenever the file changes.
+func FileCredentials(path string) CredentialsProvider {
+	var mu sync.Mutex
+	var token string
+	var modTime time.Time
+	return TokenFunc(func() (string, error) {
+		fi, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if token == "" || !fi.ModTime().Equal(modTime) {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			token, modTime = strings.TrimSpace(string(b)), fi.ModTime()
+		}
+		return token, nil
+	})
+}
+
+// BasicAuth authorizes requests with HTTP basic authentication, e.g. for an
+// authenticating reverse proxy in front of the backend.
+func BasicAuth(username, password string) CredentialsProvider {
+	return basicAuth{username, password}
+}
+
+type basicAuth struct {
+	username, password string
+}
+
+func (a basicAuth) Authorize(req *http.Request) error {
+	req.SetBasicAuth(a.username, a.password)
+	return nil
+}
+
+// credentialsTransport adds static headers to every request and authorizes it
+// with a CredentialsProvider.
+type credentialsTransport struct {
+	base        http.RoundTripper
+	credentials CredentialsProvider // optional
+	headers     map[string]string
+}
+
+// httpClient returns the http.Client for requests to the backend, adding the
+// configured Headers and credentials, or nil if the default client will do.
+func (config *Config) httpClient() *http.Client {
+	credentials := config.Credentials
+	if credentials == nil && config.Username != "" {
+		credentials = BasicAuth(config.Username, config.Password)
+	}
+	if credentials == nil && len(config.Headers) == 0 {
+		return nil
+	}
+
+	return &http.Client{Transport: &credentialsTransport{
+		base:        http.DefaultTransport,
+		credentials: credentials,
+		headers:     config.Headers,
+	}}
+}
+
+// RoundTrip authorizes req and sends it. When the backend answers 401 and the
+// credentials can be invalidated, the request is sent once more with the
+// renewed credentials, e.g. a rotated Vault token, and only a repeated 401 is
+// returned.
+func (t *credentialsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	i, renewable := t.credentials.(invalidator)
+	if renewable && req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// Keep the body to send it again
+		b, err := readBody(req)
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(b))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(b)), nil
+		}
+	}
+
+	resp, err := t.send(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !renewable {
+		return resp, err
+	}
+	i.Invalidate()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+
+	resp, err = t.send(req)
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		i.Invalidate()
+	}
+	return resp, err
+}
+
+// send adds the headers and credentials to a copy of req and sends it.
+func (t *credentialsTransport) send(req *http.Request) (*http.Response, error) {
+	// A RoundTripper must not modify the request
+	req = req.Clone(req.Context())
+	if req.Body != nil && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = body
+	}
+
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+
+	if t.credentials == nil {
+		return t.base.RoundTrip(req)
+	}
+	if err := t.credentials.Authorize(req); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, fmt.Errorf("runstats: credentials: %w", err)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// readBody returns the body of req and replaces it with an unread copy.
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
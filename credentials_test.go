@@ -0,0 +1,160 @@
+package runstats
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileCredentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+	if err := ioutil.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	creds := FileCredentials(path)
+
+	req := httptest.NewRequest("POST", "/api/v2/write", nil)
+	if err := creds.Authorize(req); err != nil || req.Header.Get("Authorization") != "Token first" {
+		t.Fatalf("unexpected authorization %q (%v)", req.Header.Get("Authorization"), err)
+	}
+
+	// Rotated tokens are picked up
+	if err := ioutil.WriteFile(path, []byte("second"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if err := creds.Authorize(req); err != nil || req.Header.Get("Authorization") != "Token second" {
+		t.Errorf("expected rotated token got %q (%v)", req.Header.Get("Authorization"), err)
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	if err := EnvCredentials("RUNSTATS_TEST_UNSET_TOKEN").Authorize(httptest.NewRequest("GET", "/", nil)); err == nil {
+		t.Error("expected error for unset variable")
+	}
+}
+
+// Test vector "get-vanilla" of the AWS Signature Version 4 test suite.
+func TestSigV4Credentials(t *testing.T) {
+	creds := &SigV4Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Region:          "us-east-1",
+		Service:         "service",
+		now:             func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+
+	req, _ := http.NewRequest("GET", "https://example.amazonaws.com/", nil)
+	if err := creds.Authorize(req); err != nil {
+		t.Fatal(err)
+	}
+	exp := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if got := req.Header.Get("Authorization"); got != exp {
+		t.Errorf("expected\n%s\ngot\n%s", exp, got)
+	}
+}
+
+func TestCredentialsTransport(t *testing.T) {
+	var auth, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		auth, body = req.Header.Get("Authorization"), string(b)
+	}))
+	defer srv.Close()
+
+	client := (&Config{Credentials: StaticCredentials("secret")}).httpClient()
+	resp, err := client.Post(srv.URL, "text/plain", strings.NewReader("m f=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if auth != "Token secret" || body != "m f=1" {
+		t.Errorf("unexpected request %q %q", auth, body)
+	}
+
+	if client := (&Config{Token: "secret"}).httpClient(); client != nil {
+		t.Error("expected the default client without credentials or headers")
+	}
+}
+
+// rotatingCredentials hands out tokens[0] until invalidated, then the next one.
+type rotatingCredentials struct {
+	tokens []string
+}
+
+func (c *rotatingCredentials) Authorize(req *http.Request) error {
+	req.Header.Set("Authorization", "Token "+c.tokens[0])
+	return nil
+}
+
+func (c *rotatingCredentials) Invalidate() {
+	if len(c.tokens) > 1 {
+		c.tokens = c.tokens[1:]
+	}
+}
+
+func TestCredentialsTransportRenewal(t *testing.T) {
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		bodies = append(bodies, string(b))
+		if req.Header.Get("Authorization") != "Token rotated" {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer srv.Close()
+
+	// The request failing with the revoked token is sent again with the new one
+	creds := &rotatingCredentials{tokens: []string{"revoked", "rotated"}}
+	client := (&Config{Credentials: creds}).httpClient()
+	resp, err := client.Post(srv.URL, "text/plain", ioutil.NopCloser(strings.NewReader("m f=1")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || len(bodies) != 2 || bodies[1] != "m f=1" {
+		t.Errorf("expected the request sent again with its body got %d %q", resp.StatusCode, bodies)
+	}
+
+	// Only once
+	bodies = nil
+	creds.tokens = []string{"revoked", "expired"}
+	resp, err = client.Post(srv.URL, "text/plain", strings.NewReader("m f=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized || len(bodies) != 2 {
+		t.Errorf("expected a repeated 401 returned got %d after %d requests", resp.StatusCode, len(bodies))
+	}
+}
+
+func TestBasicAuthAndHeaders(t *testing.T) {
+	var user, pass, tenant string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		user, pass, _ = req.BasicAuth()
+		tenant = req.Header.Get("X-Scope-OrgID")
+	}))
+	defer srv.Close()
+
+	client := (&Config{
+		Username: "proxy",
+		Password: "pass",
+		Headers:  map[string]string{"X-Scope-OrgID": "team-a"},
+	}).httpClient()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if user != "proxy" || pass != "pass" || tenant != "team-a" {
+		t.Errorf("unexpected request credentials %q %q %q", user, pass, tenant)
+	}
+}
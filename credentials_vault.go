@@ -0,0 +1,186 @@
+package runstats
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const defaultVaultRefresh = 5 * time.Minute
+
+// VaultCredentials reads the InfluxDB token from HashiCorp Vault, either a
+// dynamic secret with a lease (e.g. "influxdb/creds/metrics"), which is renewed
+// at two thirds of its duration and read again once it can't be renewed, or a
+// static KV secret (e.g. "secret/data/influxdb"), which is read again every
+// Refresh to pick up rotations. After a request is rejected with 401 the token is
+// read again on the next request.
+type VaultCredentials struct {
+	// Address of Vault. Defaults to $VAULT_ADDR.
+	Address string
+	// Token to authenticate to Vault with. Defaults to $VAULT_TOKEN.
+	Token string
+	// Path of the secret, without the /v1/ prefix.
+	Path string
+	// Key of the secret data holding the InfluxDB token. Defaults to "token".
+	Key string
+	// Refresh is how often secrets without a lease are read again. Defaults to 5
+	// minutes.
+	Refresh time.Duration
+	// Client used for requests to Vault. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// now is replaced in tests.
+	now func() time.Time
+
+	mu        sync.Mutex
+	token     string
+	leaseID   string
+	renewable bool
+	// renewAt is when the token is renewed or read again, expires when the lease
+	// ends.
+	renewAt time.Time
+	expires time.Time
+}
+
+// vaultSecret is the response to secret reads and lease renewals.
+type vaultSecret struct {
+	LeaseID       string                 `json:"lease_id"`
+	LeaseDuration int64                  `json:"lease_duration"`
+	Renewable     bool                   `json:"renewable"`
+	Data          map[string]interface{} `json:"data"`
+	Errors        []string               `json:"errors"`
+}
+
+func (c *VaultCredentials) Authorize(req *http.Request) error {
+	token, err := c.currentToken(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+token)
+	return nil
+}
+
+// Invalidate discards the current token so the next request reads it again.
+func (c *VaultCredentials) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = ""
+}
+
+func (c *VaultCredentials) currentToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.clock()
+	if c.token != "" && now.Before(c.renewAt) {
+		return c.token, nil
+	}
+
+	if c.token != "" && c.renewable && now.Before(c.expires) {
+		secret, err := c.do(ctx, http.MethodPut, "sys/leases/renew", map[string]interface{}{"lease_id": c.leaseID})
+		if err == nil {
+			c.setLease(secret, now)
+			return c.token, nil
+		}
+		// Read a new secret below
+	}
+
+	secret, err := c.do(ctx, http.MethodGet, c.Path, nil)
+	if err != nil {
+		return "", err
+	}
+
+	data := secret.Data
+	// KV version 2 nests the secret data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, ok := data["metadata"]; ok {
+			data = nested
+		}
+	}
+	key := c.Key
+	if key == "" {
+		key = "token"
+	}
+	token, ok := data[key].(string)
+	if !ok || token == "" {
+		return "", fmt.Errorf("runstats: vault secret %s has no %q", c.Path, key)
+	}
+
+	c.token = token
+	c.setLease(secret, now)
+	return c.token, nil
+}
+
+func (c *VaultCredentials) setLease(secret *vaultSecret, now time.Time) {
+	c.leaseID, c.renewable = secret.LeaseID, secret.Renewable
+	if secret.LeaseID == "" || secret.LeaseDuration <= 0 {
+		refresh := c.Refresh
+		if refresh <= 0 {
+			refresh = defaultVaultRefresh
+		}
+		c.renewAt, c.expires = now.Add(refresh), time.Time{}
+		return
+	}
+
+	lease := time.Duration(secret.LeaseDuration) * time.Second
+	c.renewAt, c.expires = now.Add(lease*2/3), now.Add(lease)
+}
+
+func (c *VaultCredentials) do(ctx context.Context, method, path string, body interface{}) (*vaultSecret, error) {
+	address := c.Address
+	if address == "" {
+		address = os.Getenv("VAULT_ADDR")
+	}
+	token := c.Token
+	if token == "" {
+		token = os.Getenv("VAULT_TOKEN")
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(address, "/")+"/v1/"+strings.TrimPrefix(path, "/"), r)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Vault-Token", token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("runstats: vault: %w", err)
+	}
+	defer resp.Body.Close()
+
+	secret := &vaultSecret{}
+	b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	json.Unmarshal(b, secret)
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("runstats: vault %s %s: %s %s", method, path, resp.Status, strings.Join(secret.Errors, "; "))
+	}
+	return secret, nil
+}
+
+func (c *VaultCredentials) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,102 @@
+package runstats
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestVaultCredentials(t *testing.T) {
+	var reads, renewals int
+	renewable := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("X-Vault-Token") != "vault-token" {
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+		switch req.URL.Path {
+		case "/v1/influxdb/creds/metrics":
+			reads++
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"lease_id":       "influxdb/creds/metrics/abc",
+				"lease_duration": 30,
+				"renewable":      renewable,
+				"data":           map[string]interface{}{"token": "influx-" + string(rune('0'+reads))},
+			})
+		case "/v1/sys/leases/renew":
+			renewals++
+			var body map[string]string
+			json.NewDecoder(req.Body).Decode(&body)
+			if body["lease_id"] != "influxdb/creds/metrics/abc" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"lease_id": body["lease_id"], "lease_duration": 30, "renewable": true})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	now := time.Now()
+	creds := &VaultCredentials{Address: srv.URL, Token: "vault-token", Path: "influxdb/creds/metrics", now: func() time.Time { return now }}
+	authorize := func() string {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/api/v2/write", nil)
+		if err := creds.Authorize(req); err != nil {
+			t.Fatal(err)
+		}
+		return req.Header.Get("Authorization")
+	}
+
+	if auth := authorize(); auth != "Token influx-1" || reads != 1 {
+		t.Fatalf("unexpected authorization %q after %d reads", auth, reads)
+	}
+	authorize()
+	if reads != 1 || renewals != 0 {
+		t.Errorf("expected cached token got %d reads %d renewals", reads, renewals)
+	}
+
+	// The lease is renewed at two thirds of its duration
+	now = now.Add(25 * time.Second)
+	if auth := authorize(); auth != "Token influx-1" || renewals != 1 {
+		t.Errorf("expected renewed lease got %q after %d renewals", auth, renewals)
+	}
+
+	// Expired leases are read again
+	renewable = false
+	creds.Invalidate()
+	if auth := authorize(); auth != "Token influx-2" || reads != 2 {
+		t.Errorf("expected new token after invalidation got %q", auth)
+	}
+	now = now.Add(time.Minute)
+	if auth := authorize(); auth != "Token influx-3" || renewals != 1 {
+		t.Errorf("expected new token for a non-renewable lease got %q", auth)
+	}
+
+	creds = &VaultCredentials{Address: srv.URL, Token: "wrong", Path: "influxdb/creds/metrics"}
+	if err := creds.Authorize(httptest.NewRequest("GET", "/", nil)); err == nil {
+		t.Error("expected error with an invalid vault token")
+	}
+}
+
+func TestVaultCredentialsKV(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{
+				"data":     map[string]interface{}{"influx": "kv-token"},
+				"metadata": map[string]interface{}{"version": 3},
+			},
+		})
+	}))
+	defer srv.Close()
+
+	creds := &VaultCredentials{Address: srv.URL, Token: "t", Path: "secret/data/influxdb", Key: "influx"}
+	req := httptest.NewRequest("GET", "/", nil)
+	if err := creds.Authorize(req); err != nil || req.Header.Get("Authorization") != "Token kv-token" {
+		t.Errorf("unexpected authorization %q (%v)", req.Header.Get("Authorization"), err)
+	}
+}
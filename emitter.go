@@ -0,0 +1,69 @@
+package runstats
+
+import (
+	"time"
+)
+
+// Emitter writes custom points and annotations with a set of tags attached, see
+// RunStats.With.
+type Emitter struct {
+	r    *RunStats
+	tags map[string]string
+}
+
+// With returns an Emitter attaching tags, given as key/value pairs, to every
+// point it writes, e.g. to attribute the points of a subsystem. It panics if
+// given an odd number of arguments.
+func (r *RunStats) With(tags ...string) *Emitter {
+	return (&Emitter{r: r}).With(tags...)
+}
+
+// With returns a child Emitter attaching tags, given as key/value pairs, on top
+// of the ones of e; tags take precedence. It panics if given an odd number of
+// arguments.
+func (e *Emitter) With(tags ...string) *Emitter {
+	if len(tags)%2 == 1 {
+		panic("runstats: odd argument count")
+	}
+
+	child := &Emitter{r: e.r, tags: make(map[string]string, len(e.tags)+len(tags)/2)}
+	for k, v := range e.tags {
+		child.tags[k] = v
+	}
+	for i := 0; i < len(tags); i += 2 {
+		child.tags[tags[i]] = tags[i+1]
+	}
+	return child
+}
+
+// WritePoint is like RunStats.WritePoint with the tags of e attached.
+func (e *Emitter) WritePoint(measurement string, tags map[string]string, fields map[string]interface{}) {
+	e.r.WritePoint(measurement, e.merge(tags), fields)
+}
+
+// WriteAt is like RunStats.WriteAt with the tags of e attached.
+func (e *Emitter) WriteAt(ts time.Time, fields map[string]interface{}, tags map[string]string) {
+	e.r.WriteAt(ts, fields, e.merge(tags))
+}
+
+// WriteLeaderPoint is like RunStats.WriteLeaderPoint with the tags of e attached.
+func (e *Emitter) WriteLeaderPoint(measurement string, tags map[string]string, fields map[string]interface{}) bool {
+	return e.r.WriteLeaderPoint(measurement, e.merge(tags), fields)
+}
+
+// Annotate is like RunStats.Annotate with the tags of e attached.
+func (e *Emitter) Annotate(title, text string, tags map[string]string) {
+	e.r.Annotate(title, text, e.merge(tags))
+}
+
+// merge returns the tags of e overridden by tags.
+func (e *Emitter) merge(tags map[string]string) map[string]string {
+	merged := make(map[string]string, len(e.tags)+len(tags))
+	for k, v := range e.tags {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return merged
+}
@@ -0,0 +1,39 @@
+package runstats
+
+import (
+	"testing"
+)
+
+func TestEmitterWith(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{Environment: "test"})
+
+	db := stats.With("subsystem", "db", "pool", "primary")
+	db.With("pool", "replica").WritePoint("app.queries", map[string]string{"table": "users"}, map[string]interface{}{"count": 1})
+	db.WritePoint("app.queries", nil, map[string]interface{}{"count": 2})
+
+	points := tr.Points(stats)
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points got %d", len(points))
+	}
+	want := []map[string]string{
+		{"env": "test", "subsystem": "db", "pool": "replica", "table": "users"},
+		{"env": "test", "subsystem": "db", "pool": "primary"},
+	}
+	for i, p := range points {
+		for k, v := range want[i] {
+			if p.Tags[k] != v {
+				t.Errorf("point %d: expected tag %s=%s got %v", i, k, v, p.Tags)
+			}
+		}
+	}
+}
+
+func TestEmitterWithOddArguments(t *testing.T) {
+	stats, _ := newTestRunStats(nil)
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic")
+		}
+	}()
+	stats.With("subsystem")
+}
@@ -0,0 +1,119 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+)
+
+// Kinds of errors returned or reported by the package, to tell failure modes
+// apart with errors.Is. Errors of a kind still unwrap to their cause, e.g. an
+// *http.Error of the InfluxDB client.
+var (
+	// ErrBackendUnavailable is reported when the backend can't be reached or is
+	// overloaded, rather than rejecting the points.
+	ErrBackendUnavailable = errors.New("runstats: backend unavailable")
+
+	// ErrInvalidConfig is returned for Config values that can't be used.
+	ErrInvalidConfig = errors.New("runstats: invalid config")
+
+	// ErrBufferFull is reported for the points of failed writes dropped because the
+	// retry buffer is full, see Config.RetryBufferSize.
+	ErrBufferFull = errors.New("runstats: retry buffer full")
+)
+
+// kindError is an error of one of the Err* kinds.
+type kindError struct {
+	kind error
+	err  error
+}
+
+// withKind returns err as an error of kind.
+func withKind(kind, err error) error {
+	return &kindError{kind: kind, err: err}
+}
+
+func (e *kindError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+func (e *kindError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *kindError) Unwrap() error {
+	return e.err
+}
+
+// statusError is an error response of an HTTP backend.
+type statusError struct {
+	code int
+	err  error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// errorClass is the kind of failure of a write, which decides whether its
+// points are retried.
+type errorClass string
+
+const (
+	// classAuth is an authentication or authorization failure, which retrying
+	// won't fix. Renewable credentials are renewed and the request sent once more
+	// before a 401 is returned, see credentialsTransport.
+	classAuth errorClass = "auth"
+	// classRejected is a batch the backend refused, e.g. malformed points.
+	classRejected errorClass = "rejected"
+	// classThrottled is a backend asking to slow down, retried after a backoff.
+	classThrottled errorClass = "throttled"
+	// classTimeout is a write that didn't complete in time.
+	classTimeout errorClass = "timeout"
+	// classUnavailable is a backend that can't be reached or failed, and any
+	// error not classified otherwise.
+	classUnavailable errorClass = "unavailable"
+)
+
+// errorClasses are all the error classes, in a stable order.
+var errorClasses = []errorClass{classAuth, classRejected, classThrottled, classTimeout, classUnavailable}
+
+// classifyError returns the class of the write error err.
+func classifyError(err error) errorClass {
+	var rejected *rejectedPointsError
+	if errors.As(err, &rejected) && rejected.err == nil {
+		return classRejected
+	}
+
+	var status *statusError
+	if errors.As(err, &status) {
+		switch {
+		case status.code == http.StatusUnauthorized || status.code == http.StatusForbidden:
+			return classAuth
+		case status.code == http.StatusTooManyRequests:
+			return classThrottled
+		case status.code == http.StatusRequestTimeout || status.code == http.StatusGatewayTimeout:
+			return classTimeout
+		case status.code >= 400 && status.code < 500:
+			return classRejected
+		}
+		return classUnavailable
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr) && netErr.Timeout() {
+		return classTimeout
+	}
+	return classUnavailable
+}
+
+// retryable reports whether the points of a write failing with an error of
+// class c may succeed later.
+func (c errorClass) retryable() bool {
+	return c != classAuth && c != classRejected
+}
@@ -0,0 +1,61 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err   error
+		class errorClass
+	}{
+		{&statusError{http.StatusUnauthorized, errors.New("unauthorized")}, classAuth},
+		{&statusError{http.StatusForbidden, errors.New("forbidden")}, classAuth},
+		{&statusError{http.StatusBadRequest, errors.New("bad points")}, classRejected},
+		{withKind(ErrBackendUnavailable, &statusError{http.StatusTooManyRequests, errors.New("slow down")}), classThrottled},
+		{withKind(ErrBackendUnavailable, &statusError{http.StatusServiceUnavailable, errors.New("down")}), classUnavailable},
+		{fmt.Errorf("write: %w", context.DeadlineExceeded), classTimeout},
+		{errors.New("connection refused"), classUnavailable},
+	}
+	for _, c := range cases {
+		if class := classifyError(c.err); class != c.class {
+			t.Errorf("%v: expected %s got %s", c.err, c.class, class)
+		}
+	}
+}
+
+func TestOnError(t *testing.T) {
+	var errs []error
+	failing := &testPlugin{err: errors.New("device lost")}
+	stats, tr := newTestRunStats(&Config{
+		Plugins: []Plugin{failing},
+		OnError: func(err error) { errs = append(errs, err) },
+	})
+	stats.writer.onError = stats.writeError
+	stats.writer.onRetry = stats.retryError
+	stats.writer.retryLimit = 10
+	tr.err = withKind(ErrBackendUnavailable, errors.New("connection refused"))
+
+	stats.collectPlugins(time.Now())
+	stats.writer.WritePoint("m", nil, map[string]interface{}{"i": 0}, time.Now())
+	stats.writer.Flush(context.Background())
+	stats.writer.retryLimit = 0
+	stats.writer.Flush(context.Background())
+
+	if len(errs) != 3 {
+		t.Fatalf("expected the plugin failure, a retried and a dropped write got %v", errs)
+	}
+	if !errors.Is(errs[0], failing.err) {
+		t.Errorf("expected the plugin error got %v", errs[0])
+	}
+	for _, err := range errs[1:] {
+		if !errors.Is(err, ErrBackendUnavailable) {
+			t.Errorf("expected ErrBackendUnavailable got %v", err)
+		}
+	}
+}
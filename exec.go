@@ -0,0 +1,106 @@
+package runstats
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// Cmd wraps an exec.Cmd and writes a point describing the child process (CPU
+// time, peak RSS, wall time and exit code) to the configured ChildMeasurement
+// once it has exited.
+type Cmd struct {
+	*exec.Cmd
+
+	stats   *RunStats
+	tags    map[string]string
+	started time.Time
+}
+
+// Command wraps cmd so its resource usage is reported when it exits. The child
+// point is tagged with tags, the static tags and "cmd", the base name of the
+// executable.
+//
+//	err := stats.Command(exec.Command("convert", in, out), nil).Run()
+func (r *RunStats) Command(cmd *exec.Cmd, tags map[string]string) *Cmd {
+	_tags := r.pointTags(tags)
+	_tags["cmd"] = filepath.Base(cmd.Path)
+
+	return &Cmd{
+		Cmd:   cmd,
+		stats: r,
+		tags:  _tags,
+	}
+}
+
+// Start starts the child process, see exec.Cmd.Start.
+func (c *Cmd) Start() error {
+	c.started = time.Now()
+	return c.Cmd.Start()
+}
+
+// Run starts the child process and waits for it to complete, see exec.Cmd.Run.
+func (c *Cmd) Run() error {
+	if err := c.Start(); err != nil {
+		return err
+	}
+	return c.Wait()
+}
+
+// Output runs the child process and returns its standard output, see
+// exec.Cmd.Output.
+func (c *Cmd) Output() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	var stdout, stderr bytes.Buffer
+	c.Stdout = &stdout
+	captureErr := c.Stderr == nil
+	if captureErr {
+		c.Stderr = &stderr
+	}
+
+	err := c.Run()
+	if ee, ok := err.(*exec.ExitError); ok && captureErr {
+		ee.Stderr = stderr.Bytes()
+	}
+	return stdout.Bytes(), err
+}
+
+// CombinedOutput runs the child process and returns its combined standard output
+// and standard error, see exec.Cmd.CombinedOutput.
+func (c *Cmd) CombinedOutput() ([]byte, error) {
+	if c.Stdout != nil {
+		return nil, errors.New("exec: Stdout already set")
+	}
+	if c.Stderr != nil {
+		return nil, errors.New("exec: Stderr already set")
+	}
+	var b bytes.Buffer
+	c.Stdout = &b
+	c.Stderr = &b
+	err := c.Run()
+	return b.Bytes(), err
+}
+
+// Wait waits for the child process to exit and writes its point, see exec.Cmd.Wait.
+func (c *Cmd) Wait() error {
+	err := c.Cmd.Wait()
+	if c.ProcessState != nil {
+		c.stats.writer.WritePoint(c.stats.config.ChildMeasurement, c.tags, childFields(c.ProcessState, time.Since(c.started)), time.Now())
+	}
+	return err
+}
+
+func childFields(state *os.ProcessState, wall time.Duration) map[string]interface{} {
+	return map[string]interface{}{
+		"child.cpu.user":   int64(state.UserTime()),
+		"child.cpu.system": int64(state.SystemTime()),
+		"child.mem.maxrss": maxRSS(state),
+		"child.duration":   int64(wall),
+		"child.exit_code":  int64(state.ExitCode()),
+	}
+}
@@ -0,0 +1,18 @@
+//go:build linux || freebsd || netbsd || openbsd || dragonfly
+// +build linux freebsd netbsd openbsd dragonfly
+
+package runstats
+
+import (
+	"os"
+	"syscall"
+)
+
+// maxRSS returns the peak RSS of the exited process in bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		// ru_maxrss is in kilobytes
+		return int64(ru.Maxrss) * 1024
+	}
+	return 0
+}
@@ -0,0 +1,15 @@
+package runstats
+
+import (
+	"os"
+	"syscall"
+)
+
+// maxRSS returns the peak RSS of the exited process in bytes.
+func maxRSS(state *os.ProcessState) int64 {
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		// ru_maxrss is in bytes on Darwin
+		return ru.Maxrss
+	}
+	return 0
+}
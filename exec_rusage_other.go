@@ -0,0 +1,13 @@
+//go:build !linux && !freebsd && !netbsd && !openbsd && !dragonfly && !darwin
+// +build !linux,!freebsd,!netbsd,!openbsd,!dragonfly,!darwin
+
+package runstats
+
+import (
+	"os"
+)
+
+// maxRSS returns 0 as the peak RSS of child processes isn't available.
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
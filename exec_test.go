@@ -0,0 +1,64 @@
+package runstats
+
+import (
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping test because it requires sh")
+	}
+
+	stats, tr := newTestRunStats(&Config{Identity: StaticIdentity("test")})
+
+	err := stats.Command(exec.Command("sh", "-c", "exit 3"), map[string]string{"job": "resize"}).Run()
+	if err == nil {
+		t.Fatal("expected exit error")
+	}
+
+	points := tr.Points(stats)
+	if len(points) != 1 {
+		t.Fatalf("expected 1 point got %d", len(points))
+	}
+	p := points[0]
+	if p.Measurement != defaultChildMeasurement {
+		t.Errorf("expected measurement (%s) got (%s)", defaultChildMeasurement, p.Measurement)
+	}
+	if p.Tags["cmd"] != "sh" || p.Tags["job"] != "resize" {
+		t.Errorf("unexpected tags %v", p.Tags)
+	}
+	if code := p.Fields["child.exit_code"]; code != int64(3) {
+		t.Errorf("expected exit code (3) got (%v)", code)
+	}
+	if d, _ := p.Fields["child.duration"].(int64); d <= 0 {
+		t.Errorf("expected positive duration got (%v)", d)
+	}
+}
+
+func TestCommandOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping test because it requires sh")
+	}
+
+	stats, tr := newTestRunStats(&Config{Identity: StaticIdentity("test")})
+
+	out, err := stats.Command(exec.Command("sh", "-c", "echo out; echo err >&2; exit 2"), nil).Output()
+	ee, ok := err.(*exec.ExitError)
+	if !ok || string(out) != "out\n" || string(ee.Stderr) != "err\n" {
+		t.Errorf("expected the output and exit error got (%q, %v)", out, err)
+	}
+	out, err = stats.Command(exec.Command("sh", "-c", "echo out; echo err >&2"), nil).CombinedOutput()
+	if err != nil || string(out) != "out\nerr\n" {
+		t.Errorf("expected the combined output got (%q, %v)", out, err)
+	}
+
+	points := tr.Points(stats)
+	if len(points) != 2 {
+		t.Fatalf("expected a point per command got %d", len(points))
+	}
+	if points[0].Fields["child.exit_code"] != int64(2) || points[1].Fields["child.exit_code"] != int64(0) {
+		t.Errorf("unexpected exit codes %v and %v", points[0].Fields["child.exit_code"], points[1].Fields["child.exit_code"])
+	}
+}
@@ -0,0 +1,67 @@
+package runstats
+
+import (
+	"encoding/json"
+	"expvar"
+	"strings"
+	"time"
+)
+
+// defaultExpvarExclude are the standard library Vars not forwarded by default:
+// memstats is covered by the collector and cmdline isn't numeric.
+var defaultExpvarExclude = []string{"cmdline", "memstats"}
+
+// forwardExpvar writes all published expvar Vars with numeric or boolean values as
+// a single point to the ExpvarMeasurement. JSON objects are flattened into
+// dot-separated field names, e.g. a Map "requests" with key "ok" becomes
+// "requests.ok"; strings and arrays are skipped.
+func (r *RunStats) forwardExpvar(ts time.Time) {
+	r.configMu.RLock()
+	exclude := r.config.ExpvarExclude
+	r.configMu.RUnlock()
+
+	fields := expvarFields(exclude)
+	if len(fields) == 0 {
+		return
+	}
+	r.writer.WritePoint(r.config.ExpvarMeasurement, r.pointTags(nil), fields, ts)
+}
+
+func expvarFields(exclude []string) map[string]interface{} {
+	fields := map[string]interface{}{}
+
+	expvar.Do(func(kv expvar.KeyValue) {
+		for _, e := range exclude {
+			if kv.Key == e {
+				return
+			}
+		}
+
+		d := json.NewDecoder(strings.NewReader(kv.Value.String()))
+		d.UseNumber()
+		var v interface{}
+		if err := d.Decode(&v); err != nil {
+			return
+		}
+		flattenJSON(kv.Key, v, fields)
+	})
+
+	return fields
+}
+
+func flattenJSON(key string, v interface{}, fields map[string]interface{}) {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			fields[key] = i
+		} else if f, err := v.Float64(); err == nil {
+			fields[key] = f
+		}
+	case bool:
+		fields[key] = v
+	case map[string]interface{}:
+		for k, child := range v {
+			flattenJSON(key+"."+k, child, fields)
+		}
+	}
+}
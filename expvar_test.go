@@ -0,0 +1,33 @@
+package runstats
+
+import (
+	"expvar"
+	"testing"
+)
+
+func TestExpvarFields(t *testing.T) {
+	expvar.NewInt("runstats_test_int").Set(42)
+	expvar.NewFloat("runstats_test_float").Set(1.5)
+	expvar.NewString("runstats_test_string").Set("skipped")
+	m := expvar.NewMap("runstats_test_map")
+	m.Add("ok", 3)
+	m.Add("failed", 1)
+
+	fields := expvarFields(append(defaultExpvarExclude, "runstats_test_float"))
+
+	exp := map[string]interface{}{
+		"runstats_test_int":        int64(42),
+		"runstats_test_map.ok":     int64(3),
+		"runstats_test_map.failed": int64(1),
+	}
+	for k, v := range exp {
+		if fields[k] != v {
+			t.Errorf("expected field %s (%v) got (%v)", k, v, fields[k])
+		}
+	}
+	for _, k := range []string{"runstats_test_float", "runstats_test_string", "memstats.Alloc"} {
+		if _, ok := fields[k]; ok {
+			t.Errorf("expected field %s to be skipped", k)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package runstats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// healthChecker is implemented by transports able to probe their backend.
+type healthChecker interface {
+	Healthy(ctx context.Context) error
+}
+
+// failoverTransport writes to the first healthy of a prioritized list of
+// transports. A transport whose write fails is skipped for retryAfter; after that
+// it is probed (if it is a healthChecker) and used again once healthy, so writes
+// fail back to the preferred backend automatically.
+type failoverTransport struct {
+	transports []transport
+	retryAfter time.Duration
+	// failover reports whether an error means the backend is down, as opposed to
+	// e.g. rejecting invalid points, which another backend would reject too.
+	failover func(err error) bool
+	now      func() time.Time
+
+	mu        sync.Mutex
+	downUntil []time.Time
+}
+
+func newFailoverTransport(transports []transport, retryAfter time.Duration, failover func(error) bool) *failoverTransport {
+	return &failoverTransport{
+		transports: transports,
+		retryAfter: retryAfter,
+		failover:   failover,
+		now:        time.Now,
+		downUntil:  make([]time.Time, len(transports)),
+	}
+}
+
+func (f *failoverTransport) Write(ctx context.Context, points []*point.Point) error {
+	var lastErr error
+	// Try the available transports in order, then the ones marked down as a last
+	// resort rather than dropping the batch.
+	for _, lastResort := range []bool{false, true} {
+		for i, t := range f.transports {
+			if f.isDown(i) != lastResort {
+				continue
+			}
+			if !lastResort && !f.probe(ctx, i) {
+				continue
+			}
+
+			err := t.Write(ctx, points)
+			if err == nil {
+				f.setDown(i, false)
+				return nil
+			}
+			if !f.failover(err) {
+				return err
+			}
+			f.setDown(i, true)
+			lastErr = err
+			if ctx.Err() != nil {
+				return lastErr
+			}
+		}
+	}
+	return lastErr
+}
+
+// isDown reports whether transport i is marked down and not due for a retry.
+func (f *failoverTransport) isDown(i int) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now().Before(f.downUntil[i])
+}
+
+// probe checks the health of transport i when it is due for a retry after being
+// marked down.
+func (f *failoverTransport) probe(ctx context.Context, i int) bool {
+	f.mu.Lock()
+	retry := !f.downUntil[i].IsZero()
+	f.mu.Unlock()
+
+	hc, ok := f.transports[i].(healthChecker)
+	if !retry || !ok {
+		return true
+	}
+	if err := hc.Healthy(ctx); err != nil {
+		f.setDown(i, true)
+		return false
+	}
+	return true
+}
+
+func (f *failoverTransport) setDown(i int, down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if down {
+		f.downUntil[i] = f.now().Add(f.retryAfter)
+	} else {
+		f.downUntil[i] = time.Time{}
+	}
+}
+
+func (f *failoverTransport) Close() {
+	for _, t := range f.transports {
+		t.Close()
+	}
+}
@@ -0,0 +1,81 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// healthTransport is a testTransport that is a healthChecker.
+type healthTransport struct {
+	testTransport
+	unhealthy error
+}
+
+func (t *healthTransport) Healthy(ctx context.Context) error {
+	return t.unhealthy
+}
+
+func TestFailoverTransport(t *testing.T) {
+	primary := &healthTransport{}
+	secondary := &testTransport{}
+	errRejected := errors.New("rejected")
+	f := newFailoverTransport([]transport{primary, secondary}, time.Minute, func(err error) bool {
+		return err != errRejected
+	})
+	now := time.Now()
+	f.now = func() time.Time { return now }
+
+	batch := []*point.Point{{Measurement: "m"}}
+	write := func() {
+		t.Helper()
+		if err := f.Write(context.Background(), batch); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Fail over when the primary is down
+	primary.err = errors.New("unavailable")
+	write()
+	write()
+	if primary.writes != 1 || len(secondary.points) != 2 {
+		t.Fatalf("expected failover to secondary got %d primary writes, %d secondary points", primary.writes, len(secondary.points))
+	}
+
+	// Still skipped after the retry delay while unhealthy
+	primary.err, primary.unhealthy = nil, errors.New("not ready")
+	now = now.Add(2 * time.Minute)
+	write()
+	if primary.writes != 1 || len(secondary.points) != 3 {
+		t.Fatalf("expected unhealthy primary to be skipped got %d primary writes", primary.writes)
+	}
+
+	// Fail back once healthy
+	primary.unhealthy = nil
+	now = now.Add(2 * time.Minute)
+	write()
+	if len(primary.points) != 1 || len(secondary.points) != 3 {
+		t.Fatalf("expected fail back to primary got %d primary points", len(primary.points))
+	}
+
+	// Rejected points aren't retried elsewhere
+	primary.err = errRejected
+	if err := f.Write(context.Background(), batch); err != errRejected {
+		t.Errorf("expected rejection error got %v", err)
+	}
+	if len(secondary.points) != 3 {
+		t.Error("expected rejected points not to fail over")
+	}
+
+	// With all backends down the batch is still attempted
+	primary.err, secondary.err = errors.New("unavailable"), errors.New("unavailable")
+	f.Write(context.Background(), batch)
+	primary.err, secondary.err = nil, nil
+	write()
+	if len(primary.points) != 2 {
+		t.Errorf("expected write to a down backend as last resort got %d primary points", len(primary.points))
+	}
+}
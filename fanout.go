@@ -0,0 +1,100 @@
+package runstats
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// SinkConfig is a further backend the points are fanned out to, see
+// Config.Sinks. Every sink is flushed on its own schedule, independently of the
+// CollectionInterval and of the other backends.
+type SinkConfig struct {
+	Sink Sink
+
+	// How often the pending points are written to Sink.
+	// Default is Config.FlushInterval
+	FlushInterval time.Duration
+
+	// Maximum number of points written to Sink at once.
+	// Default is Config.BatchSize
+	BatchSize int
+
+	// Write only the latest point of every series (measurement and tags) per
+	// flush, e.g. to limit the cost of a backend billed by the point. Combined with
+	// a FlushInterval longer than the CollectionInterval it samples the points
+	// down to one per series and FlushInterval.
+	// Default is false
+	Aggregate bool
+
+	// Tags to add, rename or strip on the points written to Sink.
+	// Default is nil
+	Tags *TagRules
+}
+
+// validate reports the invalid settings of the i-th of Config.Sinks.
+func (s *SinkConfig) validate(i int) error {
+	if s.Sink == nil {
+		return withKind(ErrInvalidConfig, fmt.Errorf("sinks[%d]: sink is required", i))
+	}
+	if s.FlushInterval < 0 || s.BatchSize < 0 {
+		return withKind(ErrInvalidConfig, fmt.Errorf("sinks[%d]: flush interval and batch size must not be negative", i))
+	}
+	return nil
+}
+
+// fanOut adds a writer for every one of sinks to w, sharing its retry and error
+// settings. Points queued to w are queued to them as well and they're closed
+// with w.
+func (w *writer) fanOut(sinks []SinkConfig) {
+	for _, s := range sinks {
+		batchSize, flushInterval := s.BatchSize, s.FlushInterval
+		if batchSize == 0 {
+			batchSize = w.batchSize
+		}
+		if flushInterval == 0 {
+			flushInterval = w.flushInterval
+		}
+
+		tee := newWriter(withTagRules(newSinkTransport(s.Sink), s.Tags), batchSize, flushInterval)
+		tee.onError = w.onError
+		tee.onRetry = w.onRetry
+		tee.retryLimit = w.retryLimit
+		tee.maxRetries = w.maxRetries
+		tee.maxAge = w.maxAge
+		tee.aggregate = s.Aggregate
+		w.tees = append(w.tees, tee)
+	}
+}
+
+// latestPerSeries returns the latest point of every series of points, in the
+// order of their first point.
+func latestPerSeries(points []*point.Point) []*point.Point {
+	index := make(map[string]int, len(points))
+	latest := points[:0:0]
+	for _, p := range points {
+		key := p.SeriesKey()
+		if i, ok := index[key]; ok {
+			if !p.Time.Before(latest[i].Time) {
+				latest[i] = p
+			}
+			continue
+		}
+		index[key] = len(latest)
+		latest = append(latest, p)
+	}
+	return latest
+}
+
+// closeTees closes the writers of fanOut within ctx, returning the first error.
+func (w *writer) closeTees(ctx context.Context) error {
+	var firstErr error
+	for _, tee := range w.tees {
+		if err := tee.Close(ctx); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,74 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWriterFanOut(t *testing.T) {
+	tr := &testTransport{}
+	w := newWriter(tr, defaultBatchSize, time.Hour)
+	raw, aggregated := &testSink{}, &testSink{}
+	w.fanOut([]SinkConfig{
+		{Sink: raw, FlushInterval: 10 * time.Millisecond},
+		{Sink: aggregated, FlushInterval: time.Hour, Aggregate: true},
+	})
+
+	now := time.Now()
+	for i := 0; i < 3; i++ {
+		w.WritePoint("m", map[string]string{"a": "1"}, map[string]interface{}{"i": i}, now.Add(time.Duration(i)*time.Second))
+	}
+	w.WritePoint("m", map[string]string{"a": "2"}, map[string]interface{}{"i": 0}, now)
+
+	// Only the sink with the short interval has been flushed yet
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		raw.mu.Lock()
+		n := len(raw.measurements)
+		raw.mu.Unlock()
+		if n == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 4 points written to the first sink got %d", n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if len(tr.points) != 0 || len(aggregated.measurements) != 0 {
+		t.Fatalf("expected nothing written to the others got %d and %d", len(tr.points), len(aggregated.measurements))
+	}
+
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.points) != 4 || len(aggregated.measurements) != 2 || !aggregated.closed || !raw.closed {
+		t.Errorf("expected 4 points and 2 aggregated ones written and the sinks closed got %d and %d", len(tr.points), len(aggregated.measurements))
+	}
+}
+
+func TestLatestPerSeries(t *testing.T) {
+	tr := &testTransport{}
+	w := newWriter(tr, defaultBatchSize, time.Hour)
+	w.aggregate = true
+	defer w.Close(context.Background())
+
+	now := time.Now()
+	w.WritePoint("m", map[string]string{"a": "1", "b": "2"}, map[string]interface{}{"i": 1}, now.Add(time.Second))
+	w.WritePoint("m", map[string]string{"b": "2", "a": "1"}, map[string]interface{}{"i": 0}, now)
+	w.WritePoint("n", map[string]string{"a": "1", "b": "2"}, map[string]interface{}{"i": 2}, now)
+	w.Flush(context.Background())
+
+	if len(tr.points) != 2 || tr.points[0].Fields["i"] != 1 || tr.points[1].Measurement != "n" {
+		t.Errorf("expected the latest point of 2 series got %v", tr.points)
+	}
+}
+
+func TestConfigSinks(t *testing.T) {
+	for _, sinks := range [][]SinkConfig{{{}}, {{Sink: &testSink{}, FlushInterval: -time.Second}}} {
+		if _, err := (&Config{Sinks: sinks}).init(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected ErrInvalidConfig for %+v got %v", sinks, err)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package runstats
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// Formats of Config.OutputFile, KafkaFormat and NatsFormat.
+const (
+	OutputFormatJSON        = "json"
+	OutputFormatLine        = "line"
+	OutputFormatCloudEvents = "cloudevents"
+	OutputFormatProtobuf    = "protobuf"
+	OutputFormatDelta       = "delta"
+)
+
+// encoder returns the encoder of format, one of the OutputFormat constants.
+func (config *Config) encoder(format string) point.Encoder {
+	switch format {
+	case OutputFormatLine:
+		return point.LineProtocol
+	case OutputFormatCloudEvents:
+		return point.CloudEvents(config.CloudEventsSource, config.CloudEventsType)
+	case OutputFormatProtobuf:
+		return point.Protobuf
+	case OutputFormatDelta:
+		return point.NewDeltaEncoder(config.DeltaKeyframeInterval)
+	}
+	return point.JSON
+}
+
+// resetEncoder starts a new stream of a stateful encoder, see
+// point.NewDeltaEncoder, once points it encoded may not have been delivered.
+func resetEncoder(e point.Encoder) {
+	if r, ok := e.(interface{ Reset() }); ok {
+		r.Reset()
+	}
+}
+
+// encodeMessage returns the encoding of p as the payload of a message, without
+// the trailing newline of the text formats. The protobuf formats keep their
+// size prefix, so that the messages of a stream can be decoded in order.
+func encodeMessage(e point.Encoder, p *point.Point) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := e.Encode(&buf, p); err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(e.ContentType(), "application/x-protobuf") {
+		return buf.Bytes(), nil
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func validOutputFormat(format string) bool {
+	switch format {
+	case OutputFormatJSON, OutputFormatLine, OutputFormatCloudEvents, OutputFormatProtobuf, OutputFormatDelta:
+		return true
+	}
+	return false
+}
+
+// fileTransport writes points as newline-delimited JSON (see point.JSON), in
+// InfluxDB line protocol, as CloudEvents or length-delimited protocol buffers to
+// stdout or to a file. The file is rotated before it would exceed maxSize bytes,
+// keeping maxBackups rotated files named <path>.1 (the newest) to
+// <path>.<maxBackups>. A rotated or failed delta stream restarts with keyframes.
+type fileTransport struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	encoder    point.Encoder
+
+	mu   sync.Mutex
+	w    io.Writer
+	file *os.File // nil for stdout
+	size int64
+}
+
+func newFileTransport(config *Config) (*fileTransport, error) {
+	t := &fileTransport{
+		path:       config.OutputFile,
+		maxSize:    config.OutputFileMaxSize,
+		maxBackups: config.OutputFileMaxBackups,
+		encoder:    config.encoder(config.OutputFormat),
+	}
+	if t.path == "-" {
+		t.w = os.Stdout
+		return t, nil
+	}
+	if err := t.open(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+func (t *fileTransport) open() error {
+	file, err := os.OpenFile(t.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	t.file, t.w, t.size = file, file, info.Size()
+	return nil
+}
+
+// rotate shifts the backups, moves the current file to <path>.1 and opens a new
+// one.
+func (t *fileTransport) rotate() error {
+	t.file.Close()
+	os.Remove(fmt.Sprintf("%s.%d", t.path, t.maxBackups))
+	for i := t.maxBackups - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", t.path, i), fmt.Sprintf("%s.%d", t.path, i+1))
+	}
+	if t.maxBackups > 0 {
+		os.Rename(t.path, t.path+".1")
+	} else {
+		os.Remove(t.path)
+	}
+	return t.open()
+}
+
+func (t *fileTransport) Write(ctx context.Context, points []*point.Point) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b, rejected := t.encode(points)
+	if t.file != nil && t.size > 0 && t.size+int64(len(b)) > t.maxSize {
+		if err := t.rotate(); err != nil {
+			resetEncoder(t.encoder)
+			return rejected.with(err)
+		}
+		// The new file must not depend on the points of the previous one
+		resetEncoder(t.encoder)
+		b, rejected = t.encode(points)
+	}
+	n, err := t.w.Write(b)
+	t.size += int64(n)
+	if err != nil {
+		resetEncoder(t.encoder)
+	}
+	return rejected.with(err)
+}
+
+// encode returns the encoding of the points, skipping those that can't be
+// encoded; t.mu must be held.
+func (t *fileTransport) encode(points []*point.Point) ([]byte, *rejectedPointsError) {
+	encoded, rejected := encodeBatch(points, func(p *point.Point) ([]byte, error) {
+		var buf bytes.Buffer
+		err := t.encoder.Encode(&buf, p)
+		return buf.Bytes(), err
+	})
+	return bytes.Join(encoded, nil), rejected
+}
+
+func (t *fileTransport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.file != nil {
+		t.file.Close()
+	}
+}
@@ -0,0 +1,171 @@
+package runstats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+func TestFileTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.json")
+
+	config, _ := (&Config{OutputFile: path, OutputFileMaxSize: 300, OutputFileMaxBackups: 2}).init()
+	tr, err := newFileTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	for i := 0; i < 10; i++ {
+		err := tr.Write(context.Background(), []*point.Point{{
+			Measurement: "go.runtime",
+			Tags:        map[string]string{"go.os": "linux"},
+			Fields:      map[string]interface{}{"mem.heap.alloc": int64(i)},
+			Time:        time.Now(),
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(b)), "\n")
+	var last struct {
+		Fields map[string]interface{} `json:"fields"`
+	}
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil || last.Fields["mem.heap.alloc"] != float64(9) {
+		t.Errorf("expected the last point in the current file got %v %v", last, err)
+	}
+	if len(b) > 300 {
+		t.Errorf("expected the file rotated below 300 bytes got %d", len(b))
+	}
+
+	for _, name := range []string{"metrics.json.1", "metrics.json.2"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected backup %s got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "metrics.json.3")); !os.IsNotExist(err) {
+		t.Errorf("expected 2 backups at most got %v", err)
+	}
+}
+
+func TestFileTransportLineProtocol(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.lp")
+
+	config, _ := (&Config{OutputFile: path, OutputFormat: OutputFormatLine}).init()
+	tr, err := newFileTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	tr.Write(context.Background(), []*point.Point{{Measurement: "m", Fields: map[string]interface{}{"v": int64(1)}, Time: time.Unix(0, 1)}})
+	if b, _ := ioutil.ReadFile(path); string(b) != "m v=1i 1\n" {
+		t.Errorf("expected line protocol got %q", b)
+	}
+}
+
+func TestFileTransportCloudEvents(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.ce")
+
+	config, err := (&Config{OutputFile: path, OutputFormat: OutputFormatCloudEvents, Identity: StaticIdentity("pod 1")}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := newFileTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	tr.Write(context.Background(), []*point.Point{{Measurement: "m", Fields: map[string]interface{}{"v": int64(1)}, Time: time.Unix(0, 1)}})
+	b, _ := ioutil.ReadFile(path)
+	var event map[string]interface{}
+	if err := json.Unmarshal(b, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event["source"] != "/go-runtime-metrics/pod%201" || event["type"] != point.DefaultCloudEventsType || event["subject"] != "m" {
+		t.Errorf("unexpected event %s", b)
+	}
+
+	if _, err := (&Config{KafkaFormat: "avro"}).init(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for an unknown format got %v", err)
+	}
+}
+
+func TestFileTransportDelta(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "metrics.pb")
+
+	config, err := (&Config{OutputFile: path, OutputFormat: OutputFormatDelta, OutputFileMaxSize: 100}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := newFileTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+
+	for i := 0; i < 6; i++ {
+		tr.Write(context.Background(), []*point.Point{{Measurement: "m", Fields: map[string]interface{}{"static": "value", "v": int64(i)}, Time: time.Unix(0, int64(i))}})
+	}
+
+	// Every file starts with a keyframe, so that it decodes on its own
+	for _, name := range []string{path + ".1", path} {
+		f, err := os.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		d := point.NewDeltaDecoder(f)
+		n := 0
+		for {
+			var p point.Point
+			if err := d.Decode(&p); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatal(err)
+			}
+			if p.Fields["static"] != "value" {
+				t.Errorf("expected all the fields restored from %s got %v", name, p.Fields)
+			}
+			n++
+		}
+		if n == 0 {
+			t.Errorf("expected points in %s", name)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package runstats
+
+import (
+	"hash/fnv"
+)
+
+// defaultFleetReducedFields are the runtime fields written by the instances left
+// out of the fleet sample.
+var defaultFleetReducedFields = []string{
+	"cpu.goroutines",
+	"mem.heap.alloc",
+	"mem.gc.count",
+	"mem.gc.pause",
+	"proc.cpu.user",
+	"proc.cpu.system",
+	"proc.mem.rss",
+	"proc.mem.rss.peak",
+}
+
+// fleetSampled reports whether instance is among the share rate of the fleet
+// writing full resolution metrics. The choice only depends on the identity, so
+// an instance keeps its role across restarts and every instance agrees on it
+// without coordination.
+func fleetSampled(instance string, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	h := fnv.New64a()
+	h.Write([]byte(instance))
+	return float64(h.Sum64()>>11)/(1<<53) < rate
+}
+
+// reduceFields keeps the values and units of the fields in names.
+func reduceFields(names []string, values map[string]interface{}, units map[string]string) (map[string]interface{}, map[string]string) {
+	reducedValues := make(map[string]interface{}, len(names))
+	reducedUnits := make(map[string]string, len(names))
+	for _, name := range names {
+		if v, ok := values[name]; ok {
+			reducedValues[name] = v
+			if unit, ok := units[name]; ok {
+				reducedUnits[name] = unit
+			}
+		}
+	}
+	return reducedValues, reducedUnits
+}
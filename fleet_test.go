@@ -0,0 +1,39 @@
+package runstats
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestFleetSampled(t *testing.T) {
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		id := fmt.Sprintf("pod-%d", i)
+		if fleetSampled(id, 0.25) {
+			sampled++
+		}
+		if fleetSampled(id, 0.25) != fleetSampled(id, 0.25) || !fleetSampled(id, 1) {
+			t.Fatalf("expected a deterministic choice for %s", id)
+		}
+	}
+	if sampled < 200 || sampled > 300 {
+		t.Errorf("expected about a quarter of the fleet sampled got %d", sampled)
+	}
+}
+
+func TestFleetReduced(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{
+		Identity:           StaticIdentity("pod-1"),
+		FleetSampleRate:    1e-9,
+		FleetReducedFields: []string{"mem.heap.alloc", "cpu.goroutines"},
+	})
+	stats.onNewPoint(stats.collector.OneOff())
+
+	points := tr.Points(stats)
+	if len(points) != 1 {
+		t.Fatalf("expected 1 point got %d", len(points))
+	}
+	if len(points[0].Fields) != 2 || len(points[0].Units) != 2 || points[0].Tags["fleet.sampled"] != "false" {
+		t.Errorf("expected the reduced fields got %v %v", points[0].Fields, points[0].Tags)
+	}
+}
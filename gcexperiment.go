@@ -0,0 +1,97 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// gcExperimentRunning guards against concurrent experiments, which would
+// measure each other's settings.
+var gcExperimentRunning int32
+
+// GCExperiment is a step of a GC tuning experiment, see RunStats.RunGCExperiment.
+type GCExperiment struct {
+	// Name tags the points of the step.
+	Name string
+	// Ballast is the size in bytes of a heap ballast allocated during the step.
+	// It counts towards the heap the GC paces itself against without being
+	// touched, so it raises the heap goal without using physical memory.
+	Ballast int64
+	// GOGC applied during the step, -1 turning the GC off. 0 keeps the current
+	// value.
+	GOGC int
+	// Duration of the step.
+	Duration time.Duration
+}
+
+// RunGCExperiment measures the GC frequency under the current settings for
+// baseline, then under each of the steps in order, and restores the original
+// GOGC and drops the ballast when done or when ctx is done. Every phase writes a
+// point tagged "experiment.step" (its Name, "baseline" for the first) to
+// Config.GCExperimentMeasurement with the GC cycles (gc.count, gc.per_minute)
+// and pauses (gc.pause_total, gc.pause_avg in ns) it saw, along with its ballast
+// and gogc, to compare settings on the live workload. Only one experiment runs at a time.
+func (r *RunStats) RunGCExperiment(ctx context.Context, baseline time.Duration, steps ...GCExperiment) error {
+	if !atomic.CompareAndSwapInt32(&gcExperimentRunning, 0, 1) {
+		return errors.New("runstats: a GC experiment is already running")
+	}
+	defer atomic.StoreInt32(&gcExperimentRunning, 0)
+
+	if err := r.gcExperimentStep(ctx, GCExperiment{Name: "baseline", Duration: baseline}); err != nil {
+		return err
+	}
+	for _, step := range steps {
+		if err := r.gcExperimentStep(ctx, step); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *RunStats) gcExperimentStep(ctx context.Context, step GCExperiment) error {
+	var ballast []byte
+	if step.Ballast > 0 {
+		ballast = make([]byte, step.Ballast)
+	}
+	if step.GOGC != 0 {
+		defer debug.SetGCPercent(debug.SetGCPercent(step.GOGC))
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	timer := time.NewTimer(step.Duration)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+
+	runtime.ReadMemStats(&after)
+	elapsed := time.Since(start)
+	runtime.KeepAlive(ballast)
+
+	count := int64(after.NumGC - before.NumGC)
+	pauses := int64(after.PauseTotalNs - before.PauseTotalNs)
+	fields := map[string]interface{}{
+		"gc.count":       count,
+		"gc.per_minute":  float64(count) / elapsed.Minutes(),
+		"gc.pause_total": pauses,
+		"gc.pause_avg":   0.0,
+		"ballast":        step.Ballast,
+	}
+	if step.GOGC != 0 {
+		fields["gogc"] = int64(step.GOGC)
+	}
+	if count > 0 {
+		fields["gc.pause_avg"] = float64(pauses) / float64(count)
+	}
+	r.writer.WritePoint(r.config.GCExperimentMeasurement, r.pointTags(map[string]string{"experiment.step": step.Name}), fields, time.Now())
+	return nil
+}
@@ -0,0 +1,52 @@
+package runstats
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+func TestRunGCExperiment(t *testing.T) {
+	stats, tr := newTestRunStats(nil)
+	gogc := debug.SetGCPercent(100)
+	defer debug.SetGCPercent(gogc)
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				runtime.GC()
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	err := stats.RunGCExperiment(context.Background(), 20*time.Millisecond,
+		GCExperiment{Name: "ballast", Ballast: 64 << 20, GOGC: 200, Duration: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored := debug.SetGCPercent(100); restored != 100 {
+		t.Errorf("expected GOGC restored got %d", restored)
+	}
+
+	points := tr.Points(stats)
+	if len(points) != 2 || points[0].Tags["experiment.step"] != "baseline" || points[1].Tags["experiment.step"] != "ballast" {
+		t.Fatalf("expected a point per phase got %v", points)
+	}
+	if points[0].Fields["gc.count"].(int64) == 0 || points[1].Fields["gogc"] != int64(200) {
+		t.Errorf("unexpected phase fields %v %v", points[0].Fields, points[1].Fields)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := stats.RunGCExperiment(ctx, time.Hour); err != context.Canceled {
+		t.Errorf("expected the experiment canceled got %v", err)
+	}
+}
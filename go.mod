@@ -1,8 +1,13 @@
 module github.com/nzlov/go-runtime-metrics
 
-go 1.16
+go 1.17
+
+require github.com/influxdata/influxdb-client-go/v2 v2.4.0
 
 require (
-	github.com/influxdata/influxdb-client-go/v2 v2.4.0
-	github.com/pkg/errors v0.9.1
+	github.com/deepmap/oapi-codegen v1.6.0 // indirect
+	github.com/influxdata/line-protocol v0.0.0-20200327222509-2487e7298839 // indirect
+	github.com/pkg/errors v0.9.1 // indirect
+	golang.org/x/net v0.0.0-20210119194325-5f4716e94777 // indirect
+	gopkg.in/yaml.v2 v2.3.0 // indirect
 )
@@ -0,0 +1,26 @@
+package runstats
+
+import (
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+)
+
+// writeGoroutineLabels writes one point per value of each GoroutineLabels key to
+// the GoroutineMeasurement, tagged with the label and holding the number of
+// goroutines carrying it.
+func (r *RunStats) writeGoroutineLabels(ts time.Time) {
+	counts, err := collector.GoroutineLabels(r.config.GoroutineLabels)
+	if err != nil {
+		r.logError("runstats: failed to read goroutine labels:", err)
+		return
+	}
+
+	for key, values := range counts {
+		for value, n := range values {
+			r.writer.WritePoint(r.config.GoroutineMeasurement, r.pointTags(map[string]string{key: value}), map[string]interface{}{
+				"goroutines": n,
+			}, ts)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+module github.com/nzlov/go-runtime-metrics/gpu
+
+go 1.16
+
+require (
+	github.com/NVIDIA/go-nvml v0.12.4-1
+	github.com/nzlov/go-runtime-metrics v0.0.0-20261016010221-3d90ade0a013
+)
+
+// The root module is replaced until a release contains the APIs this module
+// uses; drop the replace and pin that release then.
+replace github.com/nzlov/go-runtime-metrics => ../
@@ -0,0 +1,166 @@
+// Package gpu is a runstats.Plugin reporting the utilization, memory, temperature
+// and power draw of the NVIDIA GPUs of the host through NVML, for ML serving
+// workloads. It is a module of its own so that the NVML bindings, which need cgo
+// and load libnvidia-ml at runtime, remain optional:
+//
+//	p, err := gpu.New("")
+//	if err != nil {
+//		return err
+//	}
+//	defer p.Close()
+//	config.Plugins = append(config.Plugins, p)
+package gpu
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+	"github.com/nzlov/go-runtime-metrics/collector"
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+const defaultMeasurement = "go.runtime.gpu"
+
+// units of the fields written by Plugin.
+var units = map[string]string{
+	"gpu.utilization":     collector.UnitPercent,
+	"gpu.mem.utilization": collector.UnitPercent,
+	"gpu.mem.used":        collector.UnitBytes,
+	"gpu.mem.free":        collector.UnitBytes,
+	"gpu.mem.total":       collector.UnitBytes,
+	"gpu.temperature":     "Cel",
+	"gpu.power":           "W",
+}
+
+// device is the subset of nvml.Device read by Plugin.
+type device interface {
+	GetUtilizationRates() (nvml.Utilization, nvml.Return)
+	GetMemoryInfo() (nvml.Memory, nvml.Return)
+	GetTemperature(nvml.TemperatureSensors) (uint32, nvml.Return)
+	GetPowerUsage() (uint32, nvml.Return)
+}
+
+type gpu struct {
+	device device
+	tags   map[string]string
+}
+
+// Plugin writes a point per GPU every collection, tagged with its gpu.index,
+// gpu.uuid and gpu.name. Statistics a GPU doesn't support are left out of its
+// point.
+type Plugin struct {
+	measurement string
+	gpus        []gpu
+}
+
+// New initializes NVML and returns a Plugin for the GPUs of the host, writing to
+// measurement ("go.runtime.gpu" if empty). It fails if NVML isn't available.
+func New(measurement string) (*Plugin, error) {
+	if measurement == "" {
+		measurement = defaultMeasurement
+	}
+	if ret := nvml.Init(); ret != nvml.SUCCESS {
+		return nil, fmt.Errorf("gpu: initializing NVML: %s", nvml.ErrorString(ret))
+	}
+
+	count, ret := nvml.DeviceGetCount()
+	if ret != nvml.SUCCESS {
+		nvml.Shutdown()
+		return nil, fmt.Errorf("gpu: counting devices: %s", nvml.ErrorString(ret))
+	}
+	p := &Plugin{measurement: measurement}
+	for i := 0; i < count; i++ {
+		d, ret := nvml.DeviceGetHandleByIndex(i)
+		if ret != nvml.SUCCESS {
+			nvml.Shutdown()
+			return nil, fmt.Errorf("gpu: opening device %d: %s", i, nvml.ErrorString(ret))
+		}
+		tags := map[string]string{"gpu.index": strconv.Itoa(i)}
+		if uuid, ret := d.GetUUID(); ret == nvml.SUCCESS {
+			tags["gpu.uuid"] = uuid
+		}
+		if name, ret := d.GetName(); ret == nvml.SUCCESS {
+			tags["gpu.name"] = name
+		}
+		p.gpus = append(p.gpus, gpu{device: d, tags: tags})
+	}
+	return p, nil
+}
+
+// Collect implements runstats.Plugin.
+func (p *Plugin) Collect() ([]*point.Point, error) {
+	points := make([]*point.Point, 0, len(p.gpus))
+	for _, g := range p.gpus {
+		fields, err := readFields(g.device)
+		if err != nil {
+			return nil, fmt.Errorf("gpu %s: %w", g.tags["gpu.index"], err)
+		}
+		tags := make(map[string]string, len(g.tags))
+		for k, v := range g.tags {
+			tags[k] = v
+		}
+		points = append(points, &point.Point{
+			Measurement: p.measurement,
+			Tags:        tags,
+			Fields:      fields,
+			Units:       units,
+		})
+	}
+	return points, nil
+}
+
+// Close shuts NVML down.
+func (p *Plugin) Close() error {
+	if ret := nvml.Shutdown(); ret != nvml.SUCCESS {
+		return fmt.Errorf("gpu: shutting down NVML: %s", nvml.ErrorString(ret))
+	}
+	return nil
+}
+
+// readFields reads the statistics of d, skipping the unsupported ones.
+func readFields(d device) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	check := func(what string, ret nvml.Return) (bool, error) {
+		switch ret {
+		case nvml.SUCCESS:
+			return true, nil
+		case nvml.ERROR_NOT_SUPPORTED:
+			return false, nil
+		}
+		return false, fmt.Errorf("reading %s: %s", what, nvml.ErrorString(ret))
+	}
+
+	utilization, ret := d.GetUtilizationRates()
+	if ok, err := check("utilization", ret); err != nil {
+		return nil, err
+	} else if ok {
+		fields["gpu.utilization"] = int64(utilization.Gpu)
+		fields["gpu.mem.utilization"] = int64(utilization.Memory)
+	}
+
+	memory, ret := d.GetMemoryInfo()
+	if ok, err := check("memory", ret); err != nil {
+		return nil, err
+	} else if ok {
+		fields["gpu.mem.used"] = int64(memory.Used)
+		fields["gpu.mem.free"] = int64(memory.Free)
+		fields["gpu.mem.total"] = int64(memory.Total)
+	}
+
+	temperature, ret := d.GetTemperature(nvml.TEMPERATURE_GPU)
+	if ok, err := check("temperature", ret); err != nil {
+		return nil, err
+	} else if ok {
+		fields["gpu.temperature"] = int64(temperature)
+	}
+
+	// Power usage is in milliwatts
+	power, ret := d.GetPowerUsage()
+	if ok, err := check("power usage", ret); err != nil {
+		return nil, err
+	} else if ok {
+		fields["gpu.power"] = float64(power) / 1000
+	}
+	return fields, nil
+}
@@ -0,0 +1,45 @@
+package gpu
+
+import (
+	"testing"
+
+	"github.com/NVIDIA/go-nvml/pkg/nvml"
+)
+
+type testDevice struct {
+	power nvml.Return
+	temp  nvml.Return
+}
+
+func (d testDevice) GetUtilizationRates() (nvml.Utilization, nvml.Return) {
+	return nvml.Utilization{Gpu: 80, Memory: 35}, nvml.SUCCESS
+}
+
+func (d testDevice) GetMemoryInfo() (nvml.Memory, nvml.Return) {
+	return nvml.Memory{Total: 16 << 30, Free: 4 << 30, Used: 12 << 30}, nvml.SUCCESS
+}
+
+func (d testDevice) GetTemperature(nvml.TemperatureSensors) (uint32, nvml.Return) {
+	return 65, d.temp
+}
+
+func (d testDevice) GetPowerUsage() (uint32, nvml.Return) {
+	return 250500, d.power
+}
+
+func TestReadFields(t *testing.T) {
+	fields, err := readFields(testDevice{power: nvml.ERROR_NOT_SUPPORTED, temp: nvml.SUCCESS})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fields["gpu.utilization"] != int64(80) || fields["gpu.mem.used"] != int64(12<<30) || fields["gpu.temperature"] != int64(65) {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if _, ok := fields["gpu.power"]; ok {
+		t.Error("expected the unsupported power usage left out")
+	}
+
+	if _, err := readFields(testDevice{power: nvml.SUCCESS, temp: nvml.ERROR_GPU_IS_LOST}); err == nil {
+		t.Error("expected an error for a lost GPU")
+	}
+}
@@ -0,0 +1,126 @@
+package runstats
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// dialTimeout bounds connecting to stream backends without a context deadline.
+const dialTimeout = 10 * time.Second
+
+// graphiteTransport writes points to Graphite/Carbon over TCP with the plaintext
+// protocol. Every numeric or boolean field becomes a "<prefix><measurement>.<field>
+// <value> <timestamp>" line, with the point tags appended as ";tag=value" in the
+// tagged series format of Graphite 1.1 if enabled. The connection is opened on the
+// first write and again after a failed one.
+type graphiteTransport struct {
+	address string
+	prefix  string
+	tags    bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newGraphiteTransport(config *Config) *graphiteTransport {
+	return &graphiteTransport{
+		address: config.GraphiteAddress,
+		prefix:  config.GraphitePrefix,
+		tags:    config.GraphiteTags,
+	}
+}
+
+func (t *graphiteTransport) Write(ctx context.Context, points []*point.Point) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn == nil {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		conn, err := dialer.DialContext(ctx, "tcp", t.address)
+		if err != nil {
+			return withKind(ErrBackendUnavailable, err)
+		}
+		t.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		t.conn.SetWriteDeadline(deadline)
+	} else {
+		t.conn.SetWriteDeadline(time.Time{})
+	}
+
+	w := bufio.NewWriter(t.conn)
+	for _, p := range points {
+		path := graphitePath(t.prefix + p.Measurement)
+		tags := ""
+		if t.tags {
+			tags = graphiteTags(p.Tags)
+		}
+		ts := strconv.FormatInt(p.Time.Unix(), 10)
+		for _, field := range sortedFields(p.Fields) {
+			value, ok := promValue(p.Fields[field])
+			if !ok {
+				continue
+			}
+			w.WriteString(path + "." + graphitePath(field) + tags + " " + strconv.FormatFloat(value, 'f', -1, 64) + " " + ts + "\n")
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.conn.Close()
+		t.conn = nil
+		return withKind(ErrBackendUnavailable, err)
+	}
+	return nil
+}
+
+func (t *graphiteTransport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
+
+// flushInterval returns the interval points are flushed to the backend at.
+func (config *Config) flushInterval() time.Duration {
+	if config.GraphiteAddress != "" && config.GraphiteFlushInterval > 0 {
+		return config.GraphiteFlushInterval
+	}
+	return config.FlushInterval
+}
+
+// graphiteTags returns the tags in the tagged series format, ";tag=value" sorted
+// by tag. Empty values aren't allowed and are left out.
+func graphiteTags(tags map[string]string) string {
+	var b strings.Builder
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if tags[k] == "" {
+			continue
+		}
+		b.WriteString(";" + graphiteTagReplacer.Replace(k) + "=" + graphiteTagReplacer.Replace(tags[k]))
+	}
+	return b.String()
+}
+
+var (
+	graphitePathReplacer = strings.NewReplacer(" ", "_", ";", "_", "\n", "_")
+	graphiteTagReplacer  = strings.NewReplacer(" ", "_", ";", "_", "=", "_", "~", "_", "\n", "_")
+)
+
+// graphitePath replaces the characters that delimit a plaintext line.
+func graphitePath(s string) string {
+	return graphitePathReplacer.Replace(s)
+}
@@ -0,0 +1,80 @@
+package runstats
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+func TestGraphiteTransport(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 10)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s := bufio.NewScanner(conn)
+		for s.Scan() {
+			lines <- s.Text()
+		}
+	}()
+
+	config, _ := (&Config{
+		GraphiteAddress:       ln.Addr().String(),
+		GraphitePrefix:        "apps.api.",
+		GraphiteTags:          true,
+		GraphiteFlushInterval: 5 * time.Second,
+	}).init()
+	if config.flushInterval() != 5*time.Second {
+		t.Errorf("expected the Graphite flush interval got %v", config.flushInterval())
+	}
+	tr := newGraphiteTransport(config)
+	defer tr.Close()
+
+	err = tr.Write(context.Background(), []*point.Point{{
+		Measurement: "go.runtime",
+		Tags:        map[string]string{"env": "prod", "host name": "web 1", "empty": ""},
+		Fields:      map[string]interface{}{"mem.heap.alloc": int64(1024), "mem.gc.forced": true, "go.godebug": "skipped"},
+		Time:        time.Unix(1700000000, 0),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"apps.api.go.runtime.mem.gc.forced;env=prod;host_name=web_1 1 1700000000",
+		"apps.api.go.runtime.mem.heap.alloc;env=prod;host_name=web_1 1024 1700000000",
+	}
+	for _, e := range expected {
+		select {
+		case line := <-lines:
+			if line != e {
+				t.Errorf("expected %q got %q", e, line)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("expected %q", e)
+		}
+	}
+}
+
+func TestGraphiteTransportUnavailable(t *testing.T) {
+	ln, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := ln.Addr().String()
+	ln.Close()
+
+	err := newGraphiteTransport(&Config{GraphiteAddress: addr}).Write(context.Background(), nil)
+	if !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("expected ErrBackendUnavailable got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package runstats
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// EnableGroup enables the named metric group ("cpu", "mem", "gc", "process",
+// "runqueue", "scheduler" or "contention") from the next collection on, without
+// restarting the collector.
+func (r *RunStats) EnableGroup(name string) error {
+	return r.collector.SetGroup(name, true)
+}
+
+// DisableGroup disables the named metric group from the next collection on.
+func (r *RunStats) DisableGroup(name string) error {
+	return r.collector.SetGroup(name, false)
+}
+
+// Groups returns whether each metric group is enabled.
+func (r *RunStats) Groups() map[string]bool {
+	return r.collector.Groups()
+}
+
+// GroupsHandler returns an http.Handler to toggle metric groups remotely. GET
+// returns the enabled state of every group as a JSON object, POST or PATCH apply
+// a JSON object of the same shape (e.g. {"gc": true, "cpu": false}) and return
+// the resulting state. The handler does no authentication of its own.
+func (r *RunStats) GroupsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPost, http.MethodPatch:
+			changes := map[string]bool{}
+			if err := json.NewDecoder(req.Body).Decode(&changes); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for name := range changes {
+				if _, ok := r.collector.Groups()[name]; !ok {
+					http.Error(w, "unknown metric group "+name, http.StatusBadRequest)
+					return
+				}
+			}
+			for name, enabled := range changes {
+				r.collector.SetGroup(name, enabled)
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST, PATCH")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(r.collector.Groups())
+	})
+}
@@ -0,0 +1,39 @@
+package runstats
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGroupsHandler(t *testing.T) {
+	stats, _ := newTestRunStats(nil)
+	h := stats.GroupsHandler()
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"gc": false, "runqueue": true}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 got %d: %s", rec.Code, rec.Body)
+	}
+
+	groups := map[string]bool{}
+	json.Unmarshal(rec.Body.Bytes(), &groups)
+	if groups["gc"] || !groups["runqueue"] || !groups["cpu"] {
+		t.Errorf("unexpected groups %v", groups)
+	}
+	if fields := stats.collector.OneOff(); fields.NumGC != 0 || fields.NumGoroutine == 0 {
+		t.Errorf("expected gc group disabled and cpu enabled got %+v", fields)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"bogus": true}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400 for unknown group got %d", rec.Code)
+	}
+
+	if err := stats.EnableGroup("gc"); err != nil || !stats.Groups()["gc"] {
+		t.Errorf("expected gc group enabled got %v", err)
+	}
+}
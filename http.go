@@ -0,0 +1,183 @@
+package runstats
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// defaultHTTPBuckets are the default latency SLO buckets of Middleware handlers.
+var defaultHTTPBuckets = []time.Duration{100 * time.Millisecond, 250 * time.Millisecond, time.Second}
+
+// routeStats aggregates the requests of a route between two collections.
+type routeStats struct {
+	route      string
+	tags       map[string]string
+	requests   int64
+	errors     int64
+	total      time.Duration
+	max        time.Duration
+	buckets    []int64
+	satisfied  int64
+	tolerating int64
+}
+
+// httpStats holds the routeStats of all Middleware handlers.
+type httpStats struct {
+	mu sync.Mutex
+	// routes is keyed by route and request tags.
+	routes map[string]*routeStats
+	// closed is set by drainHTTPStats, requests observed afterwards are ignored.
+	closed bool
+}
+
+// statusWriter records the status code written by a handler. It keeps the
+// http.Flusher and http.Hijacker of the underlying ResponseWriter available, and
+// the others through Unwrap, see http.ResponseController.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("runstats: %T doesn't support hijacking", w.ResponseWriter)
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the underlying ResponseWriter.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Middleware wraps next to measure its request latency under the given route
+// name. Every collection a point per route is written to the HTTPMeasurement with
+// the number of requests, 5xx errors, mean and max latency, the number of
+// requests within each of the HTTPBuckets (e.g. "slo.le_100ms") and the apdex
+// score for ApdexTarget. Requests whose context carries tags, see WithTags and
+// Config.ContextTags, are aggregated into a separate point per tag set. Requests
+// whose handler panics are counted as 5xx errors.
+func (r *RunStats) Middleware(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		tags := r.contextTags(req.Context())
+		sw := &statusWriter{ResponseWriter: w}
+		start := time.Now()
+		completed := false
+		defer func() {
+			status := sw.status
+			if !completed {
+				status = http.StatusInternalServerError
+			}
+			r.observeRequest(route, tags, time.Since(start), status)
+		}()
+		next.ServeHTTP(sw, req)
+		completed = true
+	})
+}
+
+func (r *RunStats) observeRequest(route string, tags map[string]string, d time.Duration, status int) {
+	r.http.mu.Lock()
+	defer r.http.mu.Unlock()
+
+	if r.http.closed {
+		return
+	}
+	if r.http.routes == nil {
+		r.http.routes = map[string]*routeStats{}
+	}
+	key := route
+	if len(tags) > 0 {
+		key += "\x00" + tagsKey(tags)
+	}
+	s, ok := r.http.routes[key]
+	if !ok {
+		s = &routeStats{route: route, tags: tags, buckets: make([]int64, len(r.config.HTTPBuckets))}
+		r.http.routes[key] = s
+	}
+
+	s.requests++
+	if status >= http.StatusInternalServerError {
+		s.errors++
+	}
+	s.total += d
+	if d > s.max {
+		s.max = d
+	}
+	for i, b := range r.config.HTTPBuckets {
+		if d <= b {
+			s.buckets[i]++
+		}
+	}
+	switch {
+	case d <= r.config.ApdexTarget:
+		s.satisfied++
+	case d <= 4*r.config.ApdexTarget:
+		s.tolerating++
+	}
+}
+
+// drainHTTPStats writes the route statistics gathered since the last collection
+// when closing, so that the requests served just before Close are part of the
+// final batch. Requests completing afterwards aren't recorded.
+func (r *RunStats) drainHTTPStats(ts time.Time) {
+	r.http.mu.Lock()
+	r.http.closed = true
+	r.http.mu.Unlock()
+	r.writeHTTPStats(ts)
+}
+
+// writeHTTPStats writes and resets the route statistics gathered since the
+// previous collection. Routes without requests aren't written.
+func (r *RunStats) writeHTTPStats(ts time.Time) {
+	r.http.mu.Lock()
+	routes := r.http.routes
+	r.http.routes = nil
+	r.http.mu.Unlock()
+
+	for _, s := range routes {
+		fields := map[string]interface{}{
+			"requests":     s.requests,
+			"errors":       s.errors,
+			"latency.mean": int64(s.total) / s.requests,
+			"latency.max":  int64(s.max),
+			"apdex":        (float64(s.satisfied) + float64(s.tolerating)/2) / float64(s.requests),
+		}
+		for i, b := range r.config.HTTPBuckets {
+			fields["slo.le_"+b.String()] = s.buckets[i]
+		}
+		tags := r.pointTags(s.tags)
+		tags["route"] = s.route
+		r.writer.WritePoint(r.config.HTTPMeasurement, tags, fields, ts)
+	}
+}
@@ -0,0 +1,118 @@
+package runstats
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestMiddleware(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{
+		HTTPBuckets: []time.Duration{time.Hour},
+		ApdexTarget: time.Hour,
+	})
+
+	ok := stats.Middleware("ok", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	fail := stats.Middleware("fail", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "fail", http.StatusInternalServerError)
+	}))
+	for i := 0; i < 3; i++ {
+		ok.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}
+	fail.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	stats.writeHTTPStats(time.Now())
+	stats.writeHTTPStats(time.Now())
+
+	points := tr.Points(stats)
+	if len(points) != 2 {
+		t.Fatalf("expected a point per route got %d", len(points))
+	}
+	for _, p := range points {
+		switch p.Tags["route"] {
+		case "ok":
+			if p.Fields["requests"] != int64(3) || p.Fields["errors"] != int64(0) || p.Fields["slo.le_1h0m0s"] != int64(3) || p.Fields["apdex"] != 1.0 {
+				t.Errorf("unexpected ok fields %v", p.Fields)
+			}
+		case "fail":
+			if p.Fields["requests"] != int64(1) || p.Fields["errors"] != int64(1) {
+				t.Errorf("unexpected fail fields %v", p.Fields)
+			}
+		default:
+			t.Errorf("unexpected route %v", p.Tags)
+		}
+	}
+}
+
+type shardKey struct{}
+
+func TestMiddlewareContextTags(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{
+		ContextTags: func(ctx context.Context) map[string]string {
+			if shard, ok := ctx.Value(shardKey{}).(string); ok {
+				return map[string]string{"shard": shard}
+			}
+			return nil
+		},
+	})
+
+	h := stats.Middleware("orders", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	for _, tenant := range []string{"a", "b", "b"} {
+		req := httptest.NewRequest("GET", "/", nil)
+		ctx := WithTags(req.Context(), map[string]string{"tenant": tenant})
+		ctx = context.WithValue(ctx, shardKey{}, "1")
+		h.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+	}
+	stats.writeHTTPStats(time.Now())
+
+	requests := map[string]interface{}{}
+	for _, p := range tr.Points(stats) {
+		if p.Tags["route"] != "orders" || p.Tags["shard"] != "1" {
+			t.Errorf("unexpected tags %v", p.Tags)
+		}
+		requests[p.Tags["tenant"]] = p.Fields["requests"]
+	}
+	if requests["a"] != int64(1) || requests["b"] != int64(2) {
+		t.Errorf("expected a point per tenant got %v", requests)
+	}
+}
+
+func TestMiddlewareResponseWriter(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{})
+
+	flushed := false
+	flush := stats.Middleware("flush", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.(http.Flusher).Flush()
+		_, flushed = w.(interface{ Unwrap() http.ResponseWriter }).Unwrap().(*httptest.ResponseRecorder)
+	}))
+	panics := stats.Middleware("panic", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	rec := httptest.NewRecorder()
+	flush.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !rec.Flushed || !flushed {
+		t.Error("expected the recorder flushed and unwrapped through the middleware")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to propagate")
+			}
+		}()
+		panics.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	stats.writeHTTPStats(time.Now())
+	errors := map[string]interface{}{}
+	for _, p := range tr.Points(stats) {
+		errors[p.Tags["route"]] = p.Fields["errors"]
+	}
+	if errors["flush"] != int64(0) || errors["panic"] != int64(1) {
+		t.Errorf("expected the panicking request counted as an error got %v", errors)
+	}
+}
@@ -0,0 +1,210 @@
+package runstats
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metadataEndpoint is the link-local address of the instance metadata services
+// of EC2, GCE and Azure.
+var metadataEndpoint = "http://169.254.169.254"
+
+const metadataTimeout = time.Second
+
+// identityRetryBackoff is the first delay before CachedIdentity retries a
+// failing IdentityFunc.
+const identityRetryBackoff = time.Second
+
+// IdentityFunc determines the identity of the running instance (hostname, pod
+// name, random id, ...). The identity is resolved when the collector starts and
+// used as the suffix of the default measurement and as the "instance" tag of
+// annotations. With Config.IdentityRefreshInterval it is resolved again at that
+// interval, see CachedIdentity.
+type IdentityFunc func() (string, error)
+
+// HostnameIdentity uses os.Hostname as the instance identity. This is the default.
+func HostnameIdentity() (string, error) {
+	return os.Hostname()
+}
+
+// StaticIdentity always returns id, e.g. a value passed on the command line.
+func StaticIdentity(id string) IdentityFunc {
+	return func() (string, error) {
+		return id, nil
+	}
+}
+
+// EnvIdentity reads the identity from the environment variable key, e.g.
+// "POD_NAME" when exposed through the Kubernetes downward API.
+func EnvIdentity(key string) IdentityFunc {
+	return func() (string, error) {
+		if v := os.Getenv(key); v != "" {
+			return v, nil
+		}
+		return "", fmt.Errorf("environment variable %s is not set", key)
+	}
+}
+
+// RandomIdentity returns a random 16 character hex identity, generated once and
+// stable for the lifetime of the returned IdentityFunc.
+func RandomIdentity() IdentityFunc {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	id := hex.EncodeToString(b)
+	return func() (string, error) {
+		return id, err
+	}
+}
+
+// FallbackIdentity returns the first identity that resolves without error.
+func FallbackIdentity(fns ...IdentityFunc) IdentityFunc {
+	return func() (string, error) {
+		err := fmt.Errorf("no identity configured")
+		for _, fn := range fns {
+			var id string
+			if id, err = fn(); err == nil && id != "" {
+				return id, nil
+			}
+		}
+		return "", err
+	}
+}
+
+// FQDNIdentity uses the fully qualified domain name of the host, as resolved
+// from os.Hostname, as the instance identity.
+func FQDNIdentity() (string, error) {
+	host, err := os.Hostname()
+	if err != nil {
+		return "", err
+	}
+	cname, err := net.LookupCNAME(host)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(cname, "."), nil
+}
+
+// PodIdentity uses the Kubernetes pod name as the instance identity, read from
+// the POD_NAME environment variable if exposed through the downward API, or else
+// from the hostname Kubernetes sets to the pod name.
+func PodIdentity() (string, error) {
+	if v := os.Getenv("POD_NAME"); v != "" {
+		return v, nil
+	}
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return "", fmt.Errorf("not running in kubernetes")
+	}
+	return os.Hostname()
+}
+
+// CloudInstanceIdentity uses the instance id of the EC2, GCE or Azure virtual
+// machine, read from the instance metadata service, as the instance identity.
+func CloudInstanceIdentity() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), metadataTimeout)
+	defer cancel()
+
+	// IMDSv2 needs a session token
+	token, err := metadataGet(ctx, http.MethodPut, "/latest/api/token", "X-Aws-Ec2-Metadata-Token-Ttl-Seconds", "60")
+	if err == nil {
+		if id, err := metadataGet(ctx, http.MethodGet, "/latest/meta-data/instance-id", "X-Aws-Ec2-Metadata-Token", token); err == nil {
+			return id, nil
+		}
+	}
+	if id, err := metadataGet(ctx, http.MethodGet, "/computeMetadata/v1/instance/id", "Metadata-Flavor", "Google"); err == nil {
+		return id, nil
+	}
+	id, err := metadataGet(ctx, http.MethodGet, "/metadata/instance/compute/vmId?api-version=2021-02-01&format=text", "Metadata", "true")
+	if err != nil {
+		return "", fmt.Errorf("no instance metadata service: %w", err)
+	}
+	return id, nil
+}
+
+// metadataGet requests path from the instance metadata service with the header
+// key set to value.
+func metadataGet(ctx context.Context, method, path, key, value string) (string, error) {
+	req, err := http.NewRequest(method, metadataEndpoint+path, nil)
+	if err != nil {
+		return "", err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set(key, value)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("instance metadata %s: %s", path, resp.Status)
+	}
+	id := strings.TrimSpace(string(body))
+	if id == "" {
+		return "", fmt.Errorf("instance metadata %s: empty response", path)
+	}
+	return id, nil
+}
+
+// CachedIdentity resolves fn at most once per ttl and returns the cached
+// identity in between. Failures are cached too: after one, fn is retried with an
+// exponential backoff from a second up to ttl, and meanwhile the last identity is
+// returned, or the last error if fn never succeeded. It is safe for concurrent
+// use.
+func CachedIdentity(fn IdentityFunc, ttl time.Duration) IdentityFunc {
+	var (
+		mu       sync.Mutex
+		id       string
+		resolved time.Time
+		lastErr  error
+		failed   time.Time
+		backoff  time.Duration
+	)
+	return func() (string, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !resolved.IsZero() && time.Since(resolved) < ttl {
+			return id, nil
+		}
+		if !failed.IsZero() && time.Since(failed) < backoff {
+			if id != "" {
+				return id, nil
+			}
+			return "", lastErr
+		}
+		v, err := fn()
+		if err != nil || v == "" {
+			if err == nil {
+				err = fmt.Errorf("empty identity")
+			}
+			if failed.IsZero() {
+				backoff = identityRetryBackoff
+			} else {
+				backoff *= 2
+			}
+			if backoff > ttl {
+				backoff = ttl
+			}
+			lastErr, failed = err, time.Now()
+			if id != "" {
+				return id, nil
+			}
+			return "", err
+		}
+		id, resolved = v, time.Now()
+		lastErr, failed, backoff = nil, time.Time{}, 0
+		return id, nil
+	}
+}
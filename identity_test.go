@@ -0,0 +1,170 @@
+package runstats
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestEnvIdentity(t *testing.T) {
+	os.Setenv("RUNSTATS_TEST_POD", "pod-1")
+	defer os.Unsetenv("RUNSTATS_TEST_POD")
+
+	if id, err := EnvIdentity("RUNSTATS_TEST_POD")(); err != nil || id != "pod-1" {
+		t.Errorf("expected identity (pod-1) got (%s, %v)", id, err)
+	}
+	if _, err := EnvIdentity("RUNSTATS_TEST_MISSING")(); err == nil {
+		t.Error("expected error for missing environment variable")
+	}
+}
+
+func TestRandomIdentity(t *testing.T) {
+	fn := RandomIdentity()
+	a, _ := fn()
+	b, _ := fn()
+	if len(a) != 16 || a != b {
+		t.Errorf("expected stable 16 character identity got (%s, %s)", a, b)
+	}
+}
+
+func TestFallbackIdentity(t *testing.T) {
+	failing := func() (string, error) { return "", errors.New("failing") }
+
+	id, err := FallbackIdentity(failing, StaticIdentity("static"))()
+	if err != nil || id != "static" {
+		t.Errorf("expected identity (static) got (%s, %v)", id, err)
+	}
+	if _, err := FallbackIdentity(failing)(); err == nil {
+		t.Error("expected error when no identity resolves")
+	}
+}
+
+func TestCloudInstanceIdentity(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch {
+		case req.Method == http.MethodPut && req.URL.Path == "/latest/api/token":
+			w.Write([]byte("token"))
+		case req.URL.Path == "/latest/meta-data/instance-id" && req.Header.Get("X-Aws-Ec2-Metadata-Token") == "token":
+			w.Write([]byte("i-0123456789abcdef0\n"))
+		default:
+			http.NotFound(w, req)
+		}
+	}))
+	defer srv.Close()
+	defer func(endpoint string) { metadataEndpoint = endpoint }(metadataEndpoint)
+	metadataEndpoint = srv.URL
+
+	if id, err := CloudInstanceIdentity(); err != nil || id != "i-0123456789abcdef0" {
+		t.Errorf("expected identity (i-0123456789abcdef0) got (%s, %v)", id, err)
+	}
+
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/computeMetadata/v1/instance/id" && req.Header.Get("Metadata-Flavor") == "Google" {
+			w.Write([]byte("4520031799277581759"))
+			return
+		}
+		http.NotFound(w, req)
+	})
+	if id, err := CloudInstanceIdentity(); err != nil || id != "4520031799277581759" {
+		t.Errorf("expected identity (4520031799277581759) got (%s, %v)", id, err)
+	}
+
+	srv.Config.Handler = http.NotFoundHandler()
+	if _, err := CloudInstanceIdentity(); err == nil {
+		t.Error("expected error without instance metadata")
+	}
+}
+
+func TestPodIdentity(t *testing.T) {
+	os.Setenv("POD_NAME", "checkout-7d9f")
+	defer os.Unsetenv("POD_NAME")
+
+	if id, err := PodIdentity(); err != nil || id != "checkout-7d9f" {
+		t.Errorf("expected identity (checkout-7d9f) got (%s, %v)", id, err)
+	}
+}
+
+func TestCachedIdentity(t *testing.T) {
+	var calls int
+	cached := CachedIdentity(func() (string, error) {
+		calls++
+		return "host-a", nil
+	}, time.Hour)
+	a, _ := cached()
+	b, _ := cached()
+	if a != "host-a" || b != a || calls != 1 {
+		t.Errorf("expected a cached identity got (%s, %s) after %d calls", a, b, calls)
+	}
+
+	id, err := "host-a", error(nil)
+	refreshed := CachedIdentity(func() (string, error) { return id, err }, 0)
+	refreshed()
+	id = "host-b"
+	if v, _ := refreshed(); v != "host-b" {
+		t.Errorf("expected the refreshed identity got %s", v)
+	}
+	err = errors.New("failing")
+	if v, err := refreshed(); err != nil || v != "host-b" {
+		t.Errorf("expected the last identity to be kept got (%s, %v)", v, err)
+	}
+
+	if _, err := CachedIdentity(func() (string, error) { return "", nil }, 0)(); err == nil {
+		t.Error("expected error when the identity never resolved")
+	}
+}
+
+func TestCachedIdentityBackoff(t *testing.T) {
+	var calls int
+	failing := CachedIdentity(func() (string, error) {
+		calls++
+		return "", errors.New("failing")
+	}, time.Hour)
+	_, err1 := failing()
+	_, err2 := failing()
+	if err1 == nil || err2 == nil || calls != 1 {
+		t.Errorf("expected the failure to be cached got (%v, %v) after %d calls", err1, err2, calls)
+	}
+
+	calls = 0
+	retried := CachedIdentity(func() (string, error) {
+		calls++
+		return "", errors.New("failing")
+	}, 20*time.Millisecond)
+	retried()
+	retried()
+	time.Sleep(25 * time.Millisecond)
+	retried()
+	if calls != 2 {
+		t.Errorf("expected a retry after the backoff got %d calls", calls)
+	}
+}
+
+func TestIdentityRefresh(t *testing.T) {
+	host := "host-a"
+	stats, tr := newTestRunStats(&Config{
+		Identity:                func() (string, error) { return host, nil },
+		IdentityRefreshInterval: time.Nanosecond,
+	})
+	measurement := stats.config.Measurement
+
+	stats.onNewPoint(stats.collector.OneOff())
+	host = "host-b"
+	time.Sleep(time.Millisecond)
+	stats.onNewPoint(stats.collector.OneOff())
+
+	var instances []string
+	for _, p := range tr.Points(stats) {
+		if p.Measurement == measurement {
+			instances = append(instances, p.Tags["instance"])
+		}
+	}
+	if len(instances) != 2 || instances[0] != "host-a" || instances[1] != "host-b" {
+		t.Errorf("expected the refreshed identity to be tagged got %v", instances)
+	}
+	if measurement != defaultMeasurement+".host-a" {
+		t.Errorf("expected the measurement to keep the initial identity got %s", measurement)
+	}
+}
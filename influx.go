@@ -0,0 +1,166 @@
+//go:build !noinflux
+// +build !noinflux
+
+package runstats
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
+	"github.com/influxdata/influxdb-client-go/v2/api"
+	"github.com/influxdata/influxdb-client-go/v2/api/http"
+	"github.com/influxdata/influxdb-client-go/v2/api/write"
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// influxTransport writes batches of points to InfluxDB through the blocking WriteAPI.
+type influxTransport struct {
+	client influxdb2.Client
+	write  api.WriteAPIBlocking
+}
+
+// newInfluxTransport connects to Host, or with FailoverHosts to the first of the
+// hosts that is ready. RunStats writing to the same hosts, org and bucket with
+// the same token share the connection, unless DisableSharedClient is set or
+// other credentials are configured.
+func newInfluxTransport(config *Config) (transport, error) {
+	if config.DisableSharedClient || config.httpClient() != nil {
+		return dialInfluxHosts(config)
+	}
+	return acquireShared(config.sharedKey(), func() (transport, error) {
+		return dialInfluxHosts(config)
+	})
+}
+
+func dialInfluxHosts(config *Config) (transport, error) {
+	if len(config.FailoverHosts) == 0 {
+		t := dialInflux(config, config.Host)
+
+		// Ping InfluxDB to ensure there is a connection
+		if err := t.Healthy(context.Background()); err != nil {
+			t.Close()
+			return nil, err
+		}
+		return t, nil
+	}
+
+	hosts := append([]string{config.Host}, config.FailoverHosts...)
+	transports := make([]transport, len(hosts))
+	var err error
+	ready := false
+	for i, host := range hosts {
+		t := dialInflux(config, host)
+		transports[i] = t
+		if !ready {
+			err = t.Healthy(context.Background())
+			ready = err == nil
+		}
+	}
+
+	f := newFailoverTransport(transports, config.FailbackInterval, influxDown)
+	if !ready {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func dialInflux(config *Config, host string) *influxTransport {
+	var client influxdb2.Client
+	if httpClient := config.httpClient(); httpClient != nil {
+		options := influxdb2.DefaultOptions().SetHTTPClient(httpClient)
+		client = influxdb2.NewClientWithOptions(host, config.Token, options)
+	} else {
+		client = influxdb2.NewClient(host, config.Token)
+	}
+	return &influxTransport{
+		client: client,
+		write:  client.WriteAPIBlocking(config.Org, config.Bucket),
+	}
+}
+
+// influxDown reports whether err means the server is unavailable, rather than
+// rejecting the points.
+func influxDown(err error) bool {
+	var httpErr *http.Error
+	if errors.As(err, &httpErr) && httpErr.StatusCode != 0 {
+		return httpErr.StatusCode >= 500 || httpErr.StatusCode == 429
+	}
+	return true
+}
+
+func (t *influxTransport) Healthy(ctx context.Context) error {
+	if _, err := t.client.Ready(ctx); err != nil {
+		return withKind(ErrBackendUnavailable, fmt.Errorf("influxdb not ready: %w", err))
+	}
+	return nil
+}
+
+func (t *influxTransport) Write(ctx context.Context, points []*point.Point) error {
+	_points := make([]*write.Point, len(points))
+	for i, p := range points {
+		_points[i] = influxdb2.NewPoint(p.Measurement, p.Tags, p.Fields, p.Time)
+	}
+	err := t.write.WritePoint(ctx, _points...)
+	if err == nil {
+		return nil
+	}
+
+	var httpErr *http.Error
+	if errors.As(err, &httpErr) && httpErr.StatusCode != 0 {
+		err = &statusError{httpErr.StatusCode, err}
+	}
+	if influxDown(err) {
+		return withKind(ErrBackendUnavailable, err)
+	}
+	return err
+}
+
+func (t *influxTransport) Close() {
+	t.client.Close()
+}
+
+// dialInfluxQuery returns a function running Flux queries against the Host of
+// config, and the function closing its client.
+func dialInfluxQuery(config *Config) (func(ctx context.Context, flux string) ([]fluxRecord, error), func(), error) {
+	t := dialInflux(config, config.Host)
+	if err := t.Healthy(context.Background()); err != nil {
+		t.client.Close()
+		return nil, nil, err
+	}
+
+	queryAPI := t.client.QueryAPI(config.Org)
+	query := func(ctx context.Context, flux string) ([]fluxRecord, error) {
+		result, err := queryAPI.Query(ctx, flux)
+		if err != nil {
+			return nil, fmt.Errorf("influxdb query: %w", err)
+		}
+		defer result.Close()
+
+		var records []fluxRecord
+		for result.Next() {
+			rec := result.Record()
+			tags := map[string]string{}
+			for k, v := range rec.Values() {
+				if s, ok := v.(string); ok && !strings.HasPrefix(k, "_") && k != "result" && k != "table" {
+					tags[k] = s
+				}
+			}
+			records = append(records, fluxRecord{
+				Time:        rec.Time(),
+				Measurement: rec.Measurement(),
+				Field:       rec.Field(),
+				Value:       rec.Value(),
+				Tags:        tags,
+			})
+		}
+		if err := result.Err(); err != nil {
+			return nil, fmt.Errorf("influxdb query: %w", err)
+		}
+		return records, nil
+	}
+	return query, t.client.Close, nil
+}
@@ -0,0 +1,20 @@
+//go:build noinflux
+// +build noinflux
+
+package runstats
+
+import (
+	"context"
+	"fmt"
+)
+
+// newInfluxTransport always fails when built with the noinflux tag, which keeps the
+// influxdb-client-go packages out of the build.
+func newInfluxTransport(config *Config) (transport, error) {
+	return nil, fmt.Errorf("%w: influxdb support disabled by the noinflux build tag", ErrInvalidConfig)
+}
+
+// dialInfluxQuery always fails when built with the noinflux tag.
+func dialInfluxQuery(config *Config) (func(ctx context.Context, flux string) ([]fluxRecord, error), func(), error) {
+	return nil, nil, fmt.Errorf("%w: influxdb support disabled by the noinflux build tag", ErrInvalidConfig)
+}
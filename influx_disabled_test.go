@@ -0,0 +1,24 @@
+//go:build noinflux
+// +build noinflux
+
+package runstats
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRunCollectorWithoutInflux(t *testing.T) {
+	if _, err := RunCollector(context.Background(), &Config{}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected an invalid config error without a backend got %v", err)
+	}
+
+	stats, err := RunCollector(context.Background(), &Config{Sink: &testSink{}})
+	if err != nil {
+		t.Fatalf("expected a sink to work without InfluxDB got %v", err)
+	}
+	if err := stats.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,145 @@
+package runstats
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+)
+
+// Job collects runtime statistics of a short-lived process (CLI, batch job) in
+// memory at JobInterval and writes a single summary point to the JobMeasurement
+// when finished, so processes exiting before the next regular collection still
+// produce useful metrics.
+type Job struct {
+	config    *Config
+	writer    *writer
+	tags      map[string]string
+	collector *collector.Collector
+	started   time.Time
+	first     collector.Fields
+
+	mu  sync.Mutex
+	max collector.Fields
+
+	done       chan struct{}
+	stopped    chan struct{}
+	finishOnce sync.Once
+	finishErr  error
+}
+
+// StartJob starts collecting for a Job. Call Finish before the process exits.
+func StartJob(config *Config) (*Job, error) {
+	var err error
+	if config, err = config.init(); err != nil {
+		return nil, err
+	}
+
+	t, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	w := newWriter(t, config.BatchSize, config.flushInterval())
+	w.prefix = config.Prefix
+	return startJob(config, w), nil
+}
+
+func startJob(config *Config, w *writer) *Job {
+	j := &Job{
+		config:  config,
+		writer:  w,
+		tags:    config.staticTags(),
+		started: time.Now(),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+
+	j.collector = collector.New(j.observe)
+	j.collector.PauseDur = config.JobInterval
+	j.collector.EnableCPU = !config.DisableCpu
+	j.collector.EnableMem = !config.DisableMem
+	j.collector.EnableGC = !config.DisableGc
+	j.collector.Done = j.done
+	j.first = j.collector.OneOff()
+	j.observe(j.first)
+
+	go func() {
+		defer close(j.stopped)
+		j.collector.Run()
+	}()
+
+	return j
+}
+
+// observe keeps the maximum of the gauges worth summarizing.
+func (j *Job) observe(fields collector.Fields) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if fields.HeapAlloc > j.max.HeapAlloc {
+		j.max.HeapAlloc = fields.HeapAlloc
+	}
+	if fields.Sys > j.max.Sys {
+		j.max.Sys = fields.Sys
+	}
+	if fields.NumGoroutine > j.max.NumGoroutine {
+		j.max.NumGoroutine = fields.NumGoroutine
+	}
+	if fields.ProcRSS > j.max.ProcRSS {
+		j.max.ProcRSS = fields.ProcRSS
+	}
+	// Darwin only knows the peak
+	if fields.ProcPeakRSS > j.max.ProcRSS {
+		j.max.ProcRSS = fields.ProcPeakRSS
+	}
+}
+
+// Finish stops collecting and pushes the summary point: wall time, exit code,
+// maximum heap, memory obtained from the OS, goroutines and RSS, plus the GC
+// cycles, GC pause time and bytes allocated while the job ran. The point is
+// tagged with status "ok" for exit code 0 and "failed" otherwise. Finish blocks
+// until the point is written or the ShutdownTimeout expires; later calls return
+// the same error.
+func (j *Job) Finish(exitCode int) error {
+	j.finishOnce.Do(func() {
+		close(j.done)
+		<-j.stopped
+
+		last := j.collector.OneOff()
+		j.observe(last)
+
+		tags := make(map[string]string, len(j.tags)+2)
+		for k, v := range j.tags {
+			tags[k] = v
+		}
+		tags["instance"] = j.config.instance
+		tags["status"] = "ok"
+		if exitCode != 0 {
+			tags["status"] = "failed"
+		}
+
+		j.mu.Lock()
+		fields := map[string]interface{}{
+			"duration":           int64(time.Since(j.started)),
+			"exit_code":          exitCode,
+			"mem.heap.max":       j.max.HeapAlloc,
+			"mem.sys.max":        j.max.Sys,
+			"cpu.goroutines.max": j.max.NumGoroutine,
+			"mem.total":          last.TotalAlloc - j.first.TotalAlloc,
+			"mem.gc.count":       last.NumGC - j.first.NumGC,
+			"mem.gc.pause_total": last.PauseTotalNs - j.first.PauseTotalNs,
+			"proc.mem.rss.max":   j.max.ProcRSS,
+			"proc.cpu.user":      last.ProcCPUUser,
+			"proc.cpu.system":    last.ProcCPUSystem,
+		}
+		j.mu.Unlock()
+
+		j.writer.WritePoint(j.config.JobMeasurement, tags, fields, time.Now())
+
+		ctx, cancel := context.WithTimeout(context.Background(), j.config.ShutdownTimeout)
+		defer cancel()
+		j.finishErr = j.writer.Close(ctx)
+	})
+	return j.finishErr
+}
@@ -0,0 +1,41 @@
+package runstats
+
+import (
+	"testing"
+	"time"
+)
+
+var jobSink [][]byte
+
+func TestJob(t *testing.T) {
+	config, _ := (&Config{JobInterval: 10 * time.Millisecond, DisableBuildTags: true}).init()
+	tr := &testTransport{}
+	j := startJob(config, newWriter(tr, defaultBatchSize, time.Hour))
+
+	for i := 0; i < 16; i++ {
+		jobSink = append(jobSink, make([]byte, 1<<20))
+	}
+	time.Sleep(50 * time.Millisecond)
+	jobSink = nil
+
+	if err := j.Finish(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Finish(0); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr.points) != 1 {
+		t.Fatalf("expected a single summary point got %d", len(tr.points))
+	}
+	p := tr.points[0]
+	if p.Measurement != defaultJobMeasurement || p.Tags["status"] != "failed" || p.Fields["exit_code"] != 3 {
+		t.Errorf("unexpected summary %v %v", p.Tags, p.Fields)
+	}
+	if heap, _ := p.Fields["mem.heap.max"].(int64); heap < 16<<20 {
+		t.Errorf("expected max heap of at least 16MiB got %v", p.Fields["mem.heap.max"])
+	}
+	if total, _ := p.Fields["mem.total"].(int64); total < 16<<20 {
+		t.Errorf("expected at least 16MiB allocated got %v", p.Fields["mem.total"])
+	}
+}
@@ -0,0 +1,56 @@
+package runstats
+
+import (
+	"context"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// KafkaProducer publishes messages to a Kafka topic, see Config.KafkaProducer.
+// Implement it on top of the Kafka client of the application (sarama, franz-go,
+// segmentio/kafka-go, ...), which owns the brokers, compression, acks and
+// partitioning settings. Produce must block until the message is acknowledged or
+// failed, so that failed points are retried like for the other backends.
+type KafkaProducer interface {
+	Produce(ctx context.Context, topic string, key, value []byte) error
+}
+
+// kafkaTransport publishes every point as a message of its own, encoded as JSON
+// (see point.JSON), InfluxDB line protocol, CloudEvents or protocol buffers and
+// keyed by the instance identity, so that the points of a process stay ordered
+// within their partition.
+type kafkaTransport struct {
+	producer KafkaProducer
+	topic    string
+	key      []byte
+	encoder  point.Encoder
+}
+
+func newKafkaTransport(config *Config) *kafkaTransport {
+	t := &kafkaTransport{
+		producer: config.KafkaProducer,
+		topic:    config.KafkaTopic,
+		key:      []byte(config.instance),
+		encoder:  config.encoder(config.KafkaFormat),
+	}
+	return t
+}
+
+// Write produces the points in order, skipping those that can't be encoded. When
+// one fails, those produced before it are reported written so that only the
+// rest is retried.
+func (t *kafkaTransport) Write(ctx context.Context, points []*point.Point) error {
+	msgs, rejected := encodeBatch(points, func(p *point.Point) ([]byte, error) {
+		return encodeMessage(t.encoder, p)
+	})
+	for i, msg := range msgs {
+		if err := t.producer.Produce(ctx, t.topic, t.key, msg); err != nil {
+			resetEncoder(t.encoder)
+			return rejected.with(&partialWriteError{i, withKind(ErrBackendUnavailable, err)})
+		}
+	}
+	return rejected.with(nil)
+}
+
+// Close leaves the producer open, it belongs to the application.
+func (t *kafkaTransport) Close() {}
@@ -0,0 +1,108 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+type testProducer struct {
+	topics, keys, values []string
+	err                  error
+	// failAfter, if set, fails the messages once that many were produced.
+	failAfter int
+	closed    bool
+}
+
+func (p *testProducer) Produce(ctx context.Context, topic string, key, value []byte) error {
+	if p.err != nil {
+		return p.err
+	}
+	if p.failAfter > 0 && len(p.values) >= p.failAfter {
+		return errors.New("request timed out")
+	}
+	p.topics = append(p.topics, topic)
+	p.keys = append(p.keys, string(key))
+	p.values = append(p.values, string(value))
+	return nil
+}
+
+func TestKafkaTransport(t *testing.T) {
+	producer := &testProducer{}
+	config, err := (&Config{Identity: StaticIdentity("pod-1"), KafkaProducer: producer, KafkaFormat: OutputFormatLine}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := newKafkaTransport(config)
+
+	points := []*point.Point{
+		{Measurement: "m", Fields: map[string]interface{}{"v": int64(1)}, Time: time.Unix(0, 1)},
+		{Measurement: "m", Fields: map[string]interface{}{"v": int64(2)}, Time: time.Unix(0, 2)},
+	}
+	if err := tr.Write(context.Background(), points); err != nil {
+		t.Fatal(err)
+	}
+	if len(producer.values) != 2 || producer.values[1] != "m v=2i 2" || producer.topics[0] != "go.runtime" || producer.keys[0] != "pod-1" {
+		t.Errorf("unexpected messages %v %v %v", producer.topics, producer.keys, producer.values)
+	}
+
+	producer.err = errors.New("leader not available")
+	if err := tr.Write(context.Background(), points); !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("expected ErrBackendUnavailable got %v", err)
+	}
+}
+
+func (p *testProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestKafkaTransportPartialWrite(t *testing.T) {
+	producer := &testProducer{failAfter: 2}
+	config, err := (&Config{KafkaProducer: producer, KafkaFormat: OutputFormatLine}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newWriter(newKafkaTransport(config), defaultBatchSize, time.Hour)
+	w.retryLimit = 10
+	for i := 0; i < 3; i++ {
+		w.WritePoint("m", nil, map[string]interface{}{"v": i}, time.Unix(0, int64(i)))
+	}
+	w.Flush(context.Background())
+	if len(w.retry) != 1 {
+		t.Fatalf("expected only the failed message kept for retry got %d", len(w.retry))
+	}
+
+	producer.failAfter = 0
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(producer.values) != 3 || producer.values[2] != "m v=2i 2" {
+		t.Errorf("expected every message produced once got %v", producer.values)
+	}
+	if producer.closed {
+		t.Error("expected the producer of the application left open")
+	}
+}
+
+func TestKafkaTransportProtobuf(t *testing.T) {
+	producer := &testProducer{}
+	config, err := (&Config{KafkaProducer: producer, KafkaFormat: OutputFormatProtobuf}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A timestamp of 10ns ends the message with a newline byte
+	points := []*point.Point{{Measurement: "m", Tags: map[string]string{"k": "v"}, Time: time.Unix(0, 10)}}
+	if err := newKafkaTransport(config).Write(context.Background(), points); err != nil {
+		t.Fatal(err)
+	}
+
+	var p point.Point
+	if err := point.NewProtobufDecoder(strings.NewReader(producer.values[0])).Decode(&p); err != nil || p.Tags["k"] != "v" || p.Time.UnixNano() != 10 {
+		t.Errorf("expected the message decoded got %v %v", p, err)
+	}
+}
@@ -0,0 +1,48 @@
+package runstats
+
+import (
+	"sync/atomic"
+)
+
+// LeaderFunc reports whether the instance currently leads its replicas, see
+// Config.IsLeader.
+type LeaderFunc func() bool
+
+// LeaderFlag holds the outcome of a leader election run by the application, for
+// use as Config.IsLeader. Set it from the election callbacks, e.g. from the
+// OnStartedLeading and OnStoppedLeading callbacks of the leaderelection package
+// of client-go for a Kubernetes Lease. The zero value isn't the leader.
+type LeaderFlag struct {
+	leader int32 // accessed atomically
+}
+
+// Set records whether the instance is the leader.
+func (f *LeaderFlag) Set(leader bool) {
+	var v int32
+	if leader {
+		v = 1
+	}
+	atomic.StoreInt32(&f.leader, v)
+}
+
+// IsLeader reports the last value passed to Set.
+func (f *LeaderFlag) IsLeader() bool {
+	return atomic.LoadInt32(&f.leader) == 1
+}
+
+// IsLeader reports whether the instance is the leader of its replicas according
+// to Config.IsLeader. Without it every instance is the leader.
+func (r *RunStats) IsLeader() bool {
+	return r.config.IsLeader == nil || r.config.IsLeader()
+}
+
+// WriteLeaderPoint writes a point like WritePoint, but only on the leader, for
+// cluster-wide metrics (queue depths, shard counts, ...) that every replica can
+// compute but only one should write. It reports whether the point was written.
+func (r *RunStats) WriteLeaderPoint(measurement string, tags map[string]string, fields map[string]interface{}) bool {
+	if !r.IsLeader() {
+		return false
+	}
+	r.WritePoint(measurement, tags, fields)
+	return true
+}
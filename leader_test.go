@@ -0,0 +1,21 @@
+package runstats
+
+import "testing"
+
+func TestWriteLeaderPoint(t *testing.T) {
+	var flag LeaderFlag
+	stats, tr := newTestRunStats(&Config{IsLeader: flag.IsLeader})
+
+	if stats.WriteLeaderPoint("queue", nil, map[string]interface{}{"depth": 1}) {
+		t.Error("expected no point before being elected")
+	}
+	flag.Set(true)
+	if !stats.With("queue", "jobs").WriteLeaderPoint("queue", nil, map[string]interface{}{"depth": 2}) {
+		t.Error("expected the leader to write")
+	}
+
+	points := tr.Points(stats)
+	if len(points) != 1 || points[0].Fields["depth"] != 2 || points[0].Tags["queue"] != "jobs" {
+		t.Errorf("expected the leader point only got %v", points)
+	}
+}
@@ -0,0 +1,26 @@
+package runstats
+
+import (
+	"fmt"
+	"runtime"
+)
+
+const (
+	markerStart = "start"
+	markerStop  = "stop"
+)
+
+// marker writes a deployment marker annotation for the given event (start or
+// stop), including the main module path and version and the Go version.
+func (r *RunStats) marker(event string) {
+	info := readBuildInfo()
+
+	r.Annotate(
+		event,
+		fmt.Sprintf("%s %s (%s) %s", info.Path, info.Version, runtime.Version(), event),
+		map[string]string{
+			"event":   event,
+			"version": info.Version,
+		},
+	)
+}
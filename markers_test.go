@@ -0,0 +1,30 @@
+package runstats
+
+import (
+	"context"
+	"testing"
+)
+
+func TestStartMarker(t *testing.T) {
+	tr := &testTransport{}
+	stats, err := RunCollector(context.Background(), &Config{Sink: &transportSink{transport: tr}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stats.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	if len(tr.points) == 0 {
+		t.Fatal("expected the start marker written")
+	}
+	p := tr.points[0]
+	if p.Measurement != defaultAnnotation || p.Fields["title"] != markerStart {
+		t.Fatalf("expected the start marker first got %s %v", p.Measurement, p.Fields)
+	}
+	if p.Tags["event"] != markerStart || p.Tags["version"] != readBuildInfo().Version || p.Tags["instance"] == "" {
+		t.Errorf("unexpected start marker tags %v", p.Tags)
+	}
+}
@@ -0,0 +1,85 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// NatsPublisher publishes messages to a NATS subject, see Config.NatsPublisher.
+// A *nats.Conn of github.com/nats-io/nats.go implements it as is.
+type NatsPublisher interface {
+	Publish(subject string, data []byte) error
+}
+
+// NatsAckPublisher is implemented by publishers that wait for the JetStream
+// acknowledgement of every message, e.g. an adapter over the Publish method of
+// a jetstream.JetStream. It is used instead of Publish when implemented.
+type NatsAckPublisher interface {
+	PublishAck(ctx context.Context, subject string, data []byte) error
+}
+
+// errNatsDisconnected is returned while the connection is reconnecting.
+var errNatsDisconnected = errors.New("nats: not connected")
+
+// natsTransport publishes every point as a message of its own, encoded as JSON
+// (see point.JSON), InfluxDB line protocol, CloudEvents or protocol buffers.
+// While the connection of the publisher is down the points are left to the
+// writer to retry, rather than filling the reconnect buffer of the client, and
+// core NATS publishes are flushed so that a batch is only reported written once
+// the server has it.
+type natsTransport struct {
+	publisher NatsPublisher
+	subject   string
+	encoder   point.Encoder
+}
+
+func newNatsTransport(config *Config) *natsTransport {
+	t := &natsTransport{
+		publisher: config.NatsPublisher,
+		subject:   config.NatsSubject,
+		encoder:   config.encoder(config.NatsFormat),
+	}
+	return t
+}
+
+func (t *natsTransport) Write(ctx context.Context, points []*point.Point) error {
+	if c, ok := t.publisher.(interface{ IsConnected() bool }); ok && !c.IsConnected() {
+		return withKind(ErrBackendUnavailable, errNatsDisconnected)
+	}
+
+	msgs, rejected := encodeBatch(points, func(p *point.Point) ([]byte, error) {
+		return encodeMessage(t.encoder, p)
+	})
+	ack, jetstream := t.publisher.(NatsAckPublisher)
+	for i, data := range msgs {
+		if jetstream {
+			// Acknowledged messages are stored, don't publish them again
+			if err := ack.PublishAck(ctx, t.subject, data); err != nil {
+				resetEncoder(t.encoder)
+				return rejected.with(&partialWriteError{i, withKind(ErrBackendUnavailable, err)})
+			}
+			continue
+		}
+		if err := t.publisher.Publish(t.subject, data); err != nil {
+			resetEncoder(t.encoder)
+			return rejected.with(withKind(ErrBackendUnavailable, err))
+		}
+	}
+
+	if f, ok := t.publisher.(interface {
+		FlushWithContext(context.Context) error
+	}); ok && !jetstream {
+		if err := f.FlushWithContext(ctx); err != nil {
+			resetEncoder(t.encoder)
+			return rejected.with(withKind(ErrBackendUnavailable, err))
+		}
+	}
+	return rejected.with(nil)
+}
+
+// Close leaves the publisher open: it belongs to the application, which may
+// keep using it after RunStats is closed or reconfigured. Every batch is
+// flushed once written, so nothing is left to deliver.
+func (t *natsTransport) Close() {}
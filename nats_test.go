@@ -0,0 +1,108 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+type testNatsConn struct {
+	subjects, data []string
+	disconnected   bool
+	flushes        int
+	drained        bool
+}
+
+func (c *testNatsConn) Publish(subject string, data []byte) error {
+	c.subjects = append(c.subjects, subject)
+	c.data = append(c.data, string(data))
+	return nil
+}
+
+func (c *testNatsConn) IsConnected() bool { return !c.disconnected }
+
+func (c *testNatsConn) Drain() error {
+	c.drained = true
+	return nil
+}
+
+func (c *testNatsConn) FlushWithContext(ctx context.Context) error {
+	c.flushes++
+	return nil
+}
+
+type testJetStream struct {
+	testNatsConn
+	acked int
+	err   error
+}
+
+func (js *testJetStream) PublishAck(ctx context.Context, subject string, data []byte) error {
+	if js.err != nil {
+		return js.err
+	}
+	js.acked++
+	return nil
+}
+
+func TestNatsTransport(t *testing.T) {
+	conn := &testNatsConn{}
+	config, err := (&Config{NatsPublisher: conn, NatsFormat: OutputFormatLine}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := newNatsTransport(config)
+
+	points := []*point.Point{
+		{Measurement: "m", Fields: map[string]interface{}{"v": int64(1)}, Time: time.Unix(0, 1)},
+		{Measurement: "m", Fields: map[string]interface{}{"v": int64(2)}, Time: time.Unix(0, 2)},
+	}
+	if err := tr.Write(context.Background(), points); err != nil {
+		t.Fatal(err)
+	}
+	if len(conn.data) != 2 || conn.data[1] != "m v=2i 2" || conn.subjects[0] != "go.runtime" || conn.flushes != 1 {
+		t.Errorf("unexpected messages %v %v flushed %d", conn.subjects, conn.data, conn.flushes)
+	}
+
+	conn.disconnected = true
+	if err := tr.Write(context.Background(), points); !errors.Is(err, ErrBackendUnavailable) || len(conn.data) != 2 {
+		t.Errorf("expected ErrBackendUnavailable without publishing got %v", err)
+	}
+}
+
+func TestNatsTransportJetStream(t *testing.T) {
+	js := &testJetStream{}
+	config, err := (&Config{NatsPublisher: js, NatsSubject: "telemetry.runtime"}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := newNatsTransport(config)
+
+	points := []*point.Point{{Measurement: "m", Fields: map[string]interface{}{"v": int64(1)}, Time: time.Unix(0, 1)}}
+	if err := tr.Write(context.Background(), points); err != nil {
+		t.Fatal(err)
+	}
+	if js.acked != 1 || len(js.data) != 0 || js.flushes != 0 {
+		t.Errorf("expected an acknowledged publish got %d acked, %d published, %d flushes", js.acked, len(js.data), js.flushes)
+	}
+
+	js.err = errors.New("nats: no response from stream")
+	if err := tr.Write(context.Background(), points); !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("expected ErrBackendUnavailable got %v", err)
+	}
+}
+
+func TestNatsTransportLeavesConnOpen(t *testing.T) {
+	conn := &testNatsConn{}
+	config, err := (&Config{NatsPublisher: conn}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	newNatsTransport(config).Close()
+	if conn.drained {
+		t.Error("expected the connection of the application left open")
+	}
+}
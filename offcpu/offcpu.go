@@ -0,0 +1,94 @@
+// Package offcpu is a runstats.Plugin measuring the time the threads of the
+// process spend off-CPU, blocked in syscalls, page faults, futexes or sleeping,
+// with an eBPF program on the sched:sched_switch tracepoint. It complements the
+// scheduler latency metrics, which only cover the time runnable goroutines wait
+// for a P. It is Linux only, needs CAP_BPF and CAP_PERFMON (or root) and the
+// host PID namespace, and is a module of its own to keep cilium/ebpf optional:
+//
+//	p, err := offcpu.New("")
+//	if err != nil {
+//		return err
+//	}
+//	defer p.Close()
+//	config.Plugins = append(config.Plugins, p)
+//
+// Every collection a point is written with offcpu.time, the total off-CPU time
+// of the threads in ns, offcpu.switches, the number of times they were switched
+// back in, and offcpu.avg, the mean off-CPU time per switch, all since the
+// previous collection. The idle threads of the Go runtime park off-CPU too, so
+// the time is best compared across intervals rather than to the wall time.
+package offcpu
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+const defaultMeasurement = "go.runtime.offcpu"
+
+// units of the fields written by Plugin.
+var units = map[string]string{
+	"offcpu.time":     collector.UnitNanoseconds,
+	"offcpu.switches": collector.UnitCount,
+	"offcpu.avg":      collector.UnitNanoseconds,
+}
+
+// totals are the cumulative off-CPU time and switches read from the eBPF map.
+type totals struct {
+	Time, Switches uint64
+}
+
+// fields returns the fields of the interval between prev and t.
+func (t totals) fields(prev totals) map[string]interface{} {
+	time, switches := int64(t.Time-prev.Time), int64(t.Switches-prev.Switches)
+	avg := 0.0
+	if switches > 0 {
+		avg = float64(time) / float64(switches)
+	}
+	return map[string]interface{}{
+		"offcpu.time":     time,
+		"offcpu.switches": switches,
+		"offcpu.avg":      avg,
+	}
+}
+
+func (p *Plugin) point(t totals) *point.Point {
+	fields := t.fields(p.prev)
+	p.prev = t
+	return &point.Point{Measurement: p.measurement, Fields: fields, Units: units}
+}
+
+// fieldOffset returns the offset of field in the record of a tracepoint, given
+// its format file, e.g. /sys/kernel/tracing/events/sched/sched_switch/format. The
+// layout of sched_switch changed across kernel versions, so it isn't hardcoded.
+func fieldOffset(format io.Reader, field string) (int, error) {
+	s := bufio.NewScanner(format)
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if !strings.HasPrefix(line, "field:") {
+			continue
+		}
+		// field:pid_t next_pid;	offset:56;	size:4;	signed:1;
+		parts := strings.Split(line, ";")
+		decl := strings.Fields(strings.TrimPrefix(parts[0], "field:"))
+		if len(decl) == 0 || decl[len(decl)-1] != field {
+			continue
+		}
+		for _, part := range parts[1:] {
+			part = strings.TrimSpace(part)
+			if strings.HasPrefix(part, "offset:") {
+				return strconv.Atoi(strings.TrimPrefix(part, "offset:"))
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("offcpu: no field %s in the tracepoint format", field)
+}
@@ -0,0 +1,170 @@
+package offcpu
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/cilium/ebpf"
+	"github.com/cilium/ebpf/asm"
+	"github.com/cilium/ebpf/link"
+	"github.com/cilium/ebpf/rlimit"
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// formatFiles are the locations of the sched_switch format, with tracefs mounted
+// on its own or under debugfs.
+var formatFiles = []string{
+	"/sys/kernel/tracing/events/sched/sched_switch/format",
+	"/sys/kernel/debug/tracing/events/sched/sched_switch/format",
+}
+
+// maxThreads bounds the threads switched out at a time that are tracked.
+const maxThreads = 16384
+
+// Plugin writes the off-CPU time of the threads of the process every collection.
+type Plugin struct {
+	measurement string
+	start       *ebpf.Map // thread ID -> time switched out
+	totals      *ebpf.Map // totals, at key 0
+	prog        *ebpf.Program
+	link        link.Link
+	prev        totals
+}
+
+// New loads and attaches the eBPF program measuring the off-CPU time of the
+// process, written to measurement ("go.runtime.offcpu" if empty).
+func New(measurement string) (*Plugin, error) {
+	if measurement == "" {
+		measurement = defaultMeasurement
+	}
+	nextPid, err := nextPidOffset()
+	if err != nil {
+		return nil, err
+	}
+	// Kernels before 5.11 account eBPF memory against RLIMIT_MEMLOCK
+	if err := rlimit.RemoveMemlock(); err != nil {
+		return nil, fmt.Errorf("offcpu: %w", err)
+	}
+
+	p := &Plugin{measurement: measurement}
+	if p.start, err = ebpf.NewMap(&ebpf.MapSpec{Type: ebpf.LRUHash, KeySize: 4, ValueSize: 8, MaxEntries: maxThreads}); err != nil {
+		return nil, fmt.Errorf("offcpu: creating map: %w", err)
+	}
+	if p.totals, err = ebpf.NewMap(&ebpf.MapSpec{Type: ebpf.Array, KeySize: 4, ValueSize: 16, MaxEntries: 1}); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("offcpu: creating map: %w", err)
+	}
+	p.prog, err = ebpf.NewProgram(&ebpf.ProgramSpec{
+		Type:         ebpf.TracePoint,
+		License:      "GPL",
+		Instructions: instructions(uint32(os.Getpid()), nextPid, p.start.FD(), p.totals.FD()),
+	})
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("offcpu: loading program: %w", err)
+	}
+	if p.link, err = link.Tracepoint("sched", "sched_switch", p.prog, nil); err != nil {
+		p.Close()
+		return nil, fmt.Errorf("offcpu: attaching program: %w", err)
+	}
+	return p, nil
+}
+
+func nextPidOffset() (int, error) {
+	var err error
+	for _, path := range formatFiles {
+		var f *os.File
+		if f, err = os.Open(path); err != nil {
+			continue
+		}
+		defer f.Close()
+		return fieldOffset(f, "next_pid")
+	}
+	return 0, fmt.Errorf("offcpu: reading the sched_switch format: %w", err)
+}
+
+// instructions returns the program run on every context switch, called in the
+// context of the thread switched out. It records when the threads of tgid are
+// switched out and, when the thread switched in (next_pid of the record at ctx)
+// was recorded, adds the time it spent off-CPU and a switch to the totals.
+func instructions(tgid uint32, nextPid, start, totals int) asm.Instructions {
+	return asm.Instructions{
+		asm.Mov.Reg(asm.R6, asm.R1),
+
+		// start[tid] = now, for the threads of the process
+		asm.FnGetCurrentPidTgid.Call(),
+		asm.Mov.Reg(asm.R7, asm.R0),
+		asm.RSh.Imm(asm.R7, 32),
+		asm.JNE.Imm(asm.R7, int32(tgid), "next"),
+		asm.StoreMem(asm.RFP, -4, asm.R0, asm.Word),
+		asm.FnKtimeGetNs.Call(),
+		asm.StoreMem(asm.RFP, -16, asm.R0, asm.DWord),
+		asm.LoadMapPtr(asm.R1, start),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -4),
+		asm.Mov.Reg(asm.R3, asm.RFP),
+		asm.Add.Imm(asm.R3, -16),
+		asm.Mov.Imm(asm.R4, 0),
+		asm.FnMapUpdateElem.Call(),
+
+		// delta = now - start[next_pid]
+		asm.LoadMem(asm.R1, asm.R6, int16(nextPid), asm.Word).WithSymbol("next"),
+		asm.StoreMem(asm.RFP, -4, asm.R1, asm.Word),
+		asm.LoadMapPtr(asm.R1, start),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -4),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "exit"),
+		asm.LoadMem(asm.R9, asm.R0, 0, asm.DWord),
+		asm.FnKtimeGetNs.Call(),
+		asm.Sub.Reg(asm.R0, asm.R9),
+		asm.Mov.Reg(asm.R7, asm.R0),
+		asm.LoadMapPtr(asm.R1, start),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -4),
+		asm.FnMapDeleteElem.Call(),
+
+		// totals.Time += delta, totals.Switches++
+		asm.StoreImm(asm.RFP, -8, 0, asm.Word),
+		asm.LoadMapPtr(asm.R1, totals),
+		asm.Mov.Reg(asm.R2, asm.RFP),
+		asm.Add.Imm(asm.R2, -8),
+		asm.FnMapLookupElem.Call(),
+		asm.JEq.Imm(asm.R0, 0, "exit"),
+		asm.StoreXAdd(asm.R0, asm.R7, asm.DWord),
+		asm.Add.Imm(asm.R0, 8),
+		asm.Mov.Imm(asm.R1, 1),
+		asm.StoreXAdd(asm.R0, asm.R1, asm.DWord),
+
+		asm.Mov.Imm(asm.R0, 0).WithSymbol("exit"),
+		asm.Return(),
+	}
+}
+
+// Collect implements runstats.Plugin.
+func (p *Plugin) Collect() ([]*point.Point, error) {
+	var t totals
+	if err := p.totals.Lookup(uint32(0), &t); err != nil {
+		return nil, fmt.Errorf("offcpu: reading totals: %w", err)
+	}
+	return []*point.Point{p.point(t)}, nil
+}
+
+// Close detaches the program and releases its maps.
+func (p *Plugin) Close() error {
+	var errs []error
+	if p.link != nil {
+		errs = append(errs, p.link.Close())
+	}
+	if p.prog != nil {
+		errs = append(errs, p.prog.Close())
+	}
+	if p.totals != nil {
+		errs = append(errs, p.totals.Close())
+	}
+	if p.start != nil {
+		errs = append(errs, p.start.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,30 @@
+//go:build !linux
+
+package offcpu
+
+import (
+	"errors"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// Plugin writes the off-CPU time of the threads of the process every collection.
+type Plugin struct {
+	measurement string
+	prev        totals
+}
+
+// New returns an error as eBPF is only available on Linux.
+func New(measurement string) (*Plugin, error) {
+	return nil, errors.New("offcpu: eBPF is only supported on Linux")
+}
+
+// Collect implements runstats.Plugin.
+func (p *Plugin) Collect() ([]*point.Point, error) {
+	return nil, errors.New("offcpu: eBPF is only supported on Linux")
+}
+
+// Close does nothing.
+func (p *Plugin) Close() error {
+	return nil
+}
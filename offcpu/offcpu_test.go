@@ -0,0 +1,43 @@
+package offcpu
+
+import (
+	"strings"
+	"testing"
+)
+
+const schedSwitchFormat = `name: sched_switch
+ID: 316
+format:
+	field:unsigned short common_type;	offset:0;	size:2;	signed:0;
+	field:unsigned char common_flags;	offset:2;	size:1;	signed:0;
+	field:unsigned char common_preempt_count;	offset:3;	size:1;	signed:0;
+	field:int common_pid;	offset:4;	size:4;	signed:1;
+
+	field:char prev_comm[16];	offset:8;	size:16;	signed:0;
+	field:pid_t prev_pid;	offset:24;	size:4;	signed:1;
+	field:int prev_prio;	offset:28;	size:4;	signed:1;
+	field:long prev_state;	offset:32;	size:8;	signed:1;
+	field:char next_comm[16];	offset:40;	size:16;	signed:0;
+	field:pid_t next_pid;	offset:56;	size:4;	signed:1;
+	field:int next_prio;	offset:60;	size:4;	signed:1;
+`
+
+func TestFieldOffset(t *testing.T) {
+	offset, err := fieldOffset(strings.NewReader(schedSwitchFormat), "next_pid")
+	if err != nil || offset != 56 {
+		t.Errorf("expected next_pid at 56 got %d, %v", offset, err)
+	}
+	if _, err := fieldOffset(strings.NewReader(schedSwitchFormat), "next_tgid"); err == nil {
+		t.Error("expected an error for a missing field")
+	}
+}
+
+func TestTotalsFields(t *testing.T) {
+	p := &Plugin{measurement: defaultMeasurement}
+	p.point(totals{Time: 1000, Switches: 2})
+
+	pt := p.point(totals{Time: 7000, Switches: 5})
+	if pt.Fields["offcpu.time"] != int64(6000) || pt.Fields["offcpu.switches"] != int64(3) || pt.Fields["offcpu.avg"] != 2000.0 {
+		t.Errorf("unexpected interval fields %v", pt.Fields)
+	}
+}
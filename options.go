@@ -0,0 +1,113 @@
+package runstats
+
+import (
+	"time"
+)
+
+// Option configures RunCollector. A *Config is an Option too: passed alone it is
+// used as is, like before options existed, and among other options it sets the
+// whole configuration, the options after it taking precedence.
+type Option interface {
+	apply(config *Config)
+}
+
+// OptionFunc adapts a function changing the Config to an Option, to set fields
+// that have no option of their own:
+//
+//	metrics.OptionFunc(func(c *metrics.Config) { c.HeapTopN = 10 })
+type OptionFunc func(config *Config)
+
+func (fn OptionFunc) apply(config *Config) {
+	fn(config)
+}
+
+func (c *Config) apply(config *Config) {
+	if c != nil {
+		*config = *c
+	}
+}
+
+// newConfig returns the Config the options amount to. A lone *Config is
+// returned as is, and no options or a lone nil give nil, i.e. DefaultConfig.
+func newConfig(opts []Option) *Config {
+	switch len(opts) {
+	case 0:
+		return nil
+	case 1:
+		if opts[0] == nil {
+			return nil
+		}
+		if c, ok := opts[0].(*Config); ok {
+			return c
+		}
+	}
+
+	config := &Config{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt.apply(config)
+		}
+	}
+	return config
+}
+
+// WithHost sets the InfluxDb host:port pair, see Config.Host.
+func WithHost(host string) Option {
+	return OptionFunc(func(config *Config) { config.Host = host })
+}
+
+// WithToken sets the InfluxDb authentication token, see Config.Token.
+func WithToken(token string) Option {
+	return OptionFunc(func(config *Config) { config.Token = token })
+}
+
+// WithOrg sets the InfluxDb organization, see Config.Org.
+func WithOrg(org string) Option {
+	return OptionFunc(func(config *Config) { config.Org = org })
+}
+
+// WithBucket sets the InfluxDb bucket, see Config.Bucket.
+func WithBucket(bucket string) Option {
+	return OptionFunc(func(config *Config) { config.Bucket = bucket })
+}
+
+// WithInterval sets the collection interval, see Config.CollectionInterval.
+func WithInterval(interval time.Duration) Option {
+	return OptionFunc(func(config *Config) { config.CollectionInterval = interval })
+}
+
+// WithSink writes the points to sink instead of InfluxDb, see Config.Sink.
+func WithSink(sink Sink) Option {
+	return OptionFunc(func(config *Config) { config.Sink = sink })
+}
+
+// WithMeasurement sets the measurement of the runtime points, see
+// Config.Measurement.
+func WithMeasurement(measurement string) Option {
+	return OptionFunc(func(config *Config) { config.Measurement = measurement })
+}
+
+// WithIdentity sets the instance identity, see Config.Identity.
+func WithIdentity(identity IdentityFunc) Option {
+	return OptionFunc(func(config *Config) { config.Identity = identity })
+}
+
+// WithEnvironment sets the "env" tag, see Config.Environment.
+func WithEnvironment(env string) Option {
+	return OptionFunc(func(config *Config) { config.Environment = env })
+}
+
+// WithStaticTags adds tags to every point, see Config.Tags. The tags of several
+// WithStaticTags are merged, later ones taking precedence.
+func WithStaticTags(tags map[string]string) Option {
+	return OptionFunc(func(config *Config) {
+		merged := make(map[string]string, len(config.Tags)+len(tags))
+		for k, v := range config.Tags {
+			merged[k] = v
+		}
+		for k, v := range tags {
+			merged[k] = v
+		}
+		config.Tags = merged
+	})
+}
@@ -0,0 +1,56 @@
+package runstats
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNewConfig(t *testing.T) {
+	if newConfig(nil) != nil || newConfig([]Option{nil}) != nil {
+		t.Error("expected no options to give the default config")
+	}
+	config := &Config{Host: "influx:8086"}
+	if newConfig([]Option{config}) != config {
+		t.Error("expected a lone config to be used as is")
+	}
+
+	c := newConfig([]Option{
+		config,
+		WithToken("token"),
+		WithInterval(time.Minute),
+		WithStaticTags(map[string]string{"service": "checkout", "region": "eu"}),
+		WithStaticTags(map[string]string{"region": "us"}),
+		OptionFunc(func(c *Config) { c.HeapTopN = 10 }),
+	})
+	if c == config || c.Host != "influx:8086" || c.Token != "token" || c.CollectionInterval != time.Minute || c.HeapTopN != 10 {
+		t.Errorf("unexpected config %+v", c)
+	}
+	if c.Tags["service"] != "checkout" || c.Tags["region"] != "us" {
+		t.Errorf("expected the tags to be merged got %v", c.Tags)
+	}
+	if config.Token != "" {
+		t.Error("expected the options not to change the given config")
+	}
+}
+
+func TestRunCollectorOptions(t *testing.T) {
+	sink := &testSink{}
+	stats, err := RunCollector(context.Background(), WithSink(sink), WithMeasurement("go.runtime.test"), WithEnvironment("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stats.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	var found bool
+	for _, m := range sink.measurements {
+		found = found || m == "go.runtime.test"
+	}
+	if !found || stats.config.Environment != "test" {
+		t.Errorf("expected the options to be applied got %v", sink.measurements)
+	}
+}
@@ -0,0 +1,244 @@
+// Package otel pushes the runtime statistics of the collector package to an
+// OpenTelemetry collector, or any other OTLP receiver, as OTLP metrics. The tags
+// of the statistics become resource attributes.
+//
+// Metrics are sent with OTLP/HTTP using the JSON encoding, which needs neither
+// the OpenTelemetry SDK nor gRPC. For OTLP/gRPC, set Config.Sender to the one of
+// the otelgrpc module.
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+)
+
+const (
+	defaultEndpoint  = "http://localhost:4318/v1/metrics"
+	defaultInterval  = 10 * time.Second
+	defaultNamespace = "go.runtime"
+	defaultTimeout   = 10 * time.Second
+
+	scopeName = "github.com/nzlov/go-runtime-metrics/otel"
+
+	// temporalityCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+	temporalityCumulative = 2
+)
+
+// processStart approximates the start of the cumulative fields.
+var processStart = time.Now()
+
+// Config configures an Exporter.
+type Config struct {
+	// Endpoint is the URL of the OTLP/HTTP metrics receiver.
+	// Default is "http://localhost:4318/v1/metrics".
+	Endpoint string `json:"endpoint" yaml:"endpoint" mapstructure:"endpoint"`
+
+	// Headers are added to every request, e.g. for authentication.
+	Headers map[string]string `json:"headers" yaml:"headers" mapstructure:"headers"`
+
+	// Interval is the time between two exports of Run.
+	// Default is 10s.
+	Interval time.Duration `json:"interval" yaml:"interval" mapstructure:"interval"`
+
+	// Timeout bounds every export.
+	// Default is 10s.
+	Timeout time.Duration `json:"timeout" yaml:"timeout" mapstructure:"timeout"`
+
+	// Namespace prefixes the metric names, e.g. "go.runtime.mem.heap.alloc".
+	// Default is "go.runtime".
+	Namespace string `json:"namespace" yaml:"namespace" mapstructure:"namespace"`
+
+	// Resource holds resource attributes added to the tags, e.g. service.name.
+	Resource map[string]string `json:"resource" yaml:"resource" mapstructure:"resource"`
+
+	// Client sends the requests.
+	// Default is a new http.Client.
+	Client *http.Client `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Sender, if set, sends the requests in the Protobuf encoding instead of
+	// Client, e.g. over gRPC. Endpoint and Headers are left to it.
+	// Default is nil (OTLP/HTTP)
+	Sender Sender `json:"-" yaml:"-" mapstructure:"-"`
+}
+
+// Sender sends a Protobuf encoded ExportMetricsServiceRequest message.
+type Sender interface {
+	Send(ctx context.Context, request []byte) error
+}
+
+func (config *Config) init() *Config {
+	if config == nil {
+		config = &Config{}
+	}
+	c := *config
+	if c.Endpoint == "" {
+		c.Endpoint = defaultEndpoint
+	}
+	if c.Interval <= 0 {
+		c.Interval = defaultInterval
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = defaultTimeout
+	}
+	if c.Namespace == "" {
+		c.Namespace = defaultNamespace
+	}
+	if c.Client == nil {
+		c.Client = &http.Client{}
+	}
+	return &c
+}
+
+// Exporter collects the runtime statistics and pushes them as OTLP metrics.
+type Exporter struct {
+	config    *Config
+	collector *collector.Collector
+}
+
+// New returns an Exporter for config, which may be nil for the defaults.
+func New(config *Config) *Exporter {
+	return &Exporter{config: config.init(), collector: collector.New(nil)}
+}
+
+// Run exports the runtime statistics every Interval until ctx is done. Failed
+// exports are passed to onError, if set, and not retried.
+func (e *Exporter) Run(ctx context.Context, onError func(error)) {
+	tick := time.NewTicker(e.config.Interval)
+	defer tick.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+			if err := e.Export(ctx); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+// Export collects the runtime statistics and pushes them once.
+func (e *Exporter) Export(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, e.config.Timeout)
+	defer cancel()
+
+	request := e.request(e.collector.OneOff(), time.Now())
+	if e.config.Sender != nil {
+		if err := e.config.Sender.Send(ctx, request.marshalProtobuf()); err != nil {
+			return fmt.Errorf("otel: %w", err)
+		}
+		return nil
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(request); err != nil {
+		return fmt.Errorf("otel: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.config.Endpoint, &body)
+	if err != nil {
+		return fmt.Errorf("otel: %w", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.config.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("otel: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("otel: export failed with %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	return nil
+}
+
+// request converts fields to an ExportMetricsServiceRequest. Cumulative fields
+// are monotonic sums, the other numeric and boolean fields gauges; strings have
+// no metric representation and are skipped.
+func (e *Exporter) request(fields collector.Fields, now time.Time) *exportRequest {
+	attrs := map[string]string{}
+	for k, v := range fields.Tags() {
+		attrs[k] = v
+	}
+	for k, v := range e.config.Resource {
+		attrs[k] = v
+	}
+
+	units := fields.Units()
+	values := fields.Values()
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+	metrics := make([]metric, 0, len(names))
+	for _, field := range names {
+		dp, ok := newDataPoint(values[field])
+		if !ok {
+			continue
+		}
+		dp.TimeUnixNano = ts
+
+		m := metric{Name: e.config.Namespace + "." + field, Unit: units[field]}
+		if collector.Cumulative(field) {
+			dp.StartTimeUnixNano = strconv.FormatInt(processStart.UnixNano(), 10)
+			m.Sum = &sum{DataPoints: []dataPoint{dp}, AggregationTemporality: temporalityCumulative, IsMonotonic: true}
+		} else {
+			m.Gauge = &gauge{DataPoints: []dataPoint{dp}}
+		}
+		metrics = append(metrics, m)
+	}
+
+	return &exportRequest{ResourceMetrics: []resourceMetrics{{
+		Resource:     resource{Attributes: newAttributes(attrs)},
+		ScopeMetrics: []scopeMetrics{{Scope: scope{Name: scopeName}, Metrics: metrics}},
+	}}}
+}
+
+func newDataPoint(v interface{}) (dataPoint, bool) {
+	switch v := v.(type) {
+	case int64:
+		return dataPoint{AsInt: strconv.FormatInt(v, 10)}, true
+	case float64:
+		return dataPoint{AsDouble: &v}, true
+	case bool:
+		if v {
+			return dataPoint{AsInt: "1"}, true
+		}
+		return dataPoint{AsInt: "0"}, true
+	}
+	return dataPoint{}, false
+}
+
+func newAttributes(attrs map[string]string) []keyValue {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	kvs := make([]keyValue, len(keys))
+	for i, k := range keys {
+		kvs[i] = keyValue{Key: k, Value: anyValue{StringValue: attrs[k]}}
+	}
+	return kvs
+}
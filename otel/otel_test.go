@@ -0,0 +1,99 @@
+package otel
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	var req exportRequest
+	var auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+
+	e := New(&Config{
+		Endpoint: srv.URL,
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+		Resource: map[string]string{"service.name": "api"},
+	})
+	if err := e.Export(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if auth != "Bearer token" || len(req.ResourceMetrics) != 1 {
+		t.Fatalf("unexpected request %q %+v", auth, req)
+	}
+
+	attrs := map[string]string{}
+	for _, kv := range req.ResourceMetrics[0].Resource.Attributes {
+		attrs[kv.Key] = kv.Value.StringValue
+	}
+	if attrs["service.name"] != "api" || attrs["go.os"] == "" {
+		t.Errorf("expected tags and resource as attributes got %v", attrs)
+	}
+
+	metrics := map[string]metric{}
+	for _, m := range req.ResourceMetrics[0].ScopeMetrics[0].Metrics {
+		metrics[m.Name] = m
+	}
+	if m := metrics["go.runtime.mem.heap.alloc"]; m.Gauge == nil || m.Unit != "By" || m.Gauge.DataPoints[0].AsInt == "" {
+		t.Errorf("expected a gauge for mem.heap.alloc got %+v", m)
+	}
+	if m := metrics["go.runtime.mem.gc.count"]; m.Sum == nil || !m.Sum.IsMonotonic || m.Sum.DataPoints[0].StartTimeUnixNano == "" {
+		t.Errorf("expected a cumulative sum for mem.gc.count got %+v", m)
+	}
+	if _, ok := metrics["go.runtime.go.godebug"]; ok {
+		t.Error("expected string fields to be skipped")
+	}
+}
+
+func TestExportError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad payload", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	err := New(&Config{Endpoint: srv.URL}).Export(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "bad payload") {
+		t.Errorf("expected export error got %v", err)
+	}
+}
+
+type testSender struct {
+	requests [][]byte
+}
+
+func (s *testSender) Send(ctx context.Context, request []byte) error {
+	s.requests = append(s.requests, request)
+	return nil
+}
+
+func TestExportSender(t *testing.T) {
+	sender := &testSender{}
+	if err := New(&Config{Endpoint: "http://unused", Sender: sender}).Export(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sender.requests) != 1 || sender.requests[0][0] != 0x0a || !bytes.Contains(sender.requests[0], []byte("go.runtime.mem.heap.alloc")) {
+		t.Errorf("expected a Protobuf request got %q", sender.requests)
+	}
+}
+
+func TestDataPointProtobuf(t *testing.T) {
+	dp := dataPoint{TimeUnixNano: "1", AsInt: "-2"}
+	expected := []byte{
+		0x19, 1, 0, 0, 0, 0, 0, 0, 0, // time_unix_nano fixed64
+		0x31, 0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, // as_int sfixed64
+	}
+	if b := dp.appendProtobuf(nil); !bytes.Equal(b, expected) {
+		t.Errorf("unexpected encoding % x", b)
+	}
+}
@@ -0,0 +1,129 @@
+package otel
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+)
+
+// Protocol buffer wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+)
+
+// marshalProtobuf returns the Protobuf encoding of r, with the field numbers of
+// opentelemetry/proto/collector/metrics/v1/metrics_service.proto and the
+// messages it imports.
+func (r *exportRequest) marshalProtobuf() []byte {
+	var b []byte
+	for _, rm := range r.ResourceMetrics {
+		b = appendBytes(b, 1, rm.appendProtobuf(nil))
+	}
+	return b
+}
+
+func (rm *resourceMetrics) appendProtobuf(b []byte) []byte {
+	var res []byte
+	for _, kv := range rm.Resource.Attributes {
+		res = appendBytes(res, 1, kv.appendProtobuf(nil))
+	}
+	b = appendBytes(b, 1, res)
+
+	for _, sm := range rm.ScopeMetrics {
+		var msg []byte
+		msg = appendBytes(msg, 1, appendString(nil, 1, sm.Scope.Name))
+		for _, m := range sm.Metrics {
+			msg = appendBytes(msg, 2, m.appendProtobuf(nil))
+		}
+		b = appendBytes(b, 2, msg)
+	}
+	return b
+}
+
+func (kv *keyValue) appendProtobuf(b []byte) []byte {
+	b = appendString(b, 1, kv.Key)
+	return appendBytes(b, 2, appendString(nil, 1, kv.Value.StringValue))
+}
+
+func (m *metric) appendProtobuf(b []byte) []byte {
+	b = appendString(b, 1, m.Name)
+	if m.Unit != "" {
+		b = appendString(b, 3, m.Unit)
+	}
+	if m.Gauge != nil {
+		var g []byte
+		for _, dp := range m.Gauge.DataPoints {
+			g = appendBytes(g, 1, dp.appendProtobuf(nil))
+		}
+		b = appendBytes(b, 5, g)
+	}
+	if m.Sum != nil {
+		var s []byte
+		for _, dp := range m.Sum.DataPoints {
+			s = appendBytes(s, 1, dp.appendProtobuf(nil))
+		}
+		s = appendVarint(s, 2, uint64(m.Sum.AggregationTemporality))
+		if m.Sum.IsMonotonic {
+			s = appendVarint(s, 3, 1)
+		}
+		b = appendBytes(b, 7, s)
+	}
+	return b
+}
+
+// appendProtobuf appends the NumberDataPoint encoding of dp. Its 64-bit integers
+// are the decimal strings of the JSON mapping.
+func (dp *dataPoint) appendProtobuf(b []byte) []byte {
+	if dp.StartTimeUnixNano != "" {
+		b = appendFixed64(b, 2, parseUint(dp.StartTimeUnixNano))
+	}
+	b = appendFixed64(b, 3, parseUint(dp.TimeUnixNano))
+	if dp.AsDouble != nil {
+		b = appendFixed64(b, 4, math.Float64bits(*dp.AsDouble))
+	}
+	if dp.AsInt != "" {
+		v, _ := strconv.ParseInt(dp.AsInt, 10, 64)
+		b = appendFixed64(b, 6, uint64(v))
+	}
+	return b
+}
+
+func parseUint(s string) uint64 {
+	v, _ := strconv.ParseUint(s, 10, 64)
+	return v
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendTag(b []byte, field int, wire int) []byte {
+	return appendUvarint(b, uint64(field)<<3|uint64(wire))
+}
+
+func appendVarint(b []byte, field int, v uint64) []byte {
+	b = appendTag(b, field, wireVarint)
+	return appendUvarint(b, v)
+}
+
+func appendFixed64(b []byte, field int, v uint64) []byte {
+	b = appendTag(b, field, wireFixed64)
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendString(b []byte, field int, v string) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
@@ -0,0 +1,52 @@
+package otelgrpc
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/nzlov/go-runtime-metrics/otel"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type testConn struct {
+	method  string
+	request []byte
+	md      metadata.MD
+}
+
+func (c *testConn) Invoke(ctx context.Context, method string, args, reply interface{}, opts ...grpc.CallOption) error {
+	var codec rawCodec
+	for _, opt := range opts {
+		if o, ok := opt.(grpc.ForceCodecCallOption); ok {
+			codec = o.Codec.(rawCodec)
+		}
+	}
+	request, err := codec.Marshal(args)
+	if err != nil {
+		return err
+	}
+	c.method, c.request = method, request
+	c.md, _ = metadata.FromOutgoingContext(ctx)
+	return codec.Unmarshal(nil, reply)
+}
+
+func (c *testConn) NewStream(context.Context, *grpc.StreamDesc, string, ...grpc.CallOption) (grpc.ClientStream, error) {
+	panic("unexpected stream")
+}
+
+func TestSender(t *testing.T) {
+	conn := &testConn{}
+	sender := NewSender(conn, map[string]string{"Authorization": "Bearer token"})
+	if err := otel.New(&otel.Config{Sender: sender}).Export(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if conn.method != exportMethod || !bytes.Contains(conn.request, []byte("go.runtime.mem.heap.alloc")) {
+		t.Errorf("unexpected call %s with %q", conn.method, conn.request)
+	}
+	if auth := conn.md.Get("authorization"); len(auth) != 1 || auth[0] != "Bearer token" {
+		t.Errorf("expected the headers as metadata got %v", conn.md)
+	}
+}
@@ -0,0 +1,36 @@
+package runstats
+
+import (
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// Plugin collects additional points every collection, e.g. the GPU statistics of
+// the gpu module, to write them through the same writer as the runtime points.
+// Plugins are kept in separate modules when they need dependencies of their own.
+type Plugin interface {
+	// Collect returns the points of a collection. The static tags are attached to
+	// them, their own taking precedence, and points without a timestamp get the
+	// one of the collection.
+	Collect() ([]*point.Point, error)
+}
+
+// collectPlugins writes the points of the configured plugins. A failing plugin
+// is logged and doesn't prevent the others from being collected.
+func (r *RunStats) collectPlugins(ts time.Time) {
+	for _, plugin := range r.config.Plugins {
+		points, err := plugin.Collect()
+		if err != nil {
+			r.logError("runstats: plugin failed:", err)
+			continue
+		}
+		for _, p := range points {
+			p.Tags = r.pointTags(p.Tags)
+			if p.Time.IsZero() {
+				p.Time = ts
+			}
+			r.writer.Write(p)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package runstats
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+type testPlugin struct {
+	points []*point.Point
+	err    error
+}
+
+func (p *testPlugin) Collect() ([]*point.Point, error) {
+	return p.points, p.err
+}
+
+func TestCollectPlugins(t *testing.T) {
+	failing := &testPlugin{err: errors.New("device lost")}
+	gpu := &testPlugin{points: []*point.Point{{
+		Measurement: "go.runtime.gpu",
+		Tags:        map[string]string{"gpu.index": "0", "env": "gpu"},
+		Fields:      map[string]interface{}{"utilization": int64(80)},
+	}}}
+	stats, tr := newTestRunStats(&Config{Environment: "test", Plugins: []Plugin{failing, gpu}})
+
+	ts := time.Now()
+	stats.collectPlugins(ts)
+
+	points := tr.Points(stats)
+	if len(points) != 1 {
+		t.Fatalf("expected the point of the healthy plugin got %d", len(points))
+	}
+	p := points[0]
+	if p.Measurement != "go.runtime.gpu" || !p.Time.Equal(ts) || p.Tags["gpu.index"] != "0" || p.Tags["env"] != "gpu" {
+		t.Errorf("unexpected point %s at %s with tags %v", p.Measurement, p.Time, p.Tags)
+	}
+}
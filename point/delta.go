@@ -0,0 +1,144 @@
+package point
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// deltaEncoder writes the Protobuf encoding, omitting the fields that didn't
+// change since the previous point of the same series.
+type deltaEncoder struct {
+	keyframeInterval int
+	series           map[string]*deltaSeries
+}
+
+type deltaSeries struct {
+	fields map[string]interface{}
+	// since is the number of deltas written since the last keyframe.
+	since int
+}
+
+// NewDeltaEncoder returns an Encoder for high-frequency streams of mostly static
+// fields. Points are written like by Protobuf, but only carry the fields that
+// changed since the previous point of the same series (measurement and tags) and
+// have the delta flag set. The first point of a series, every keyframeInterval-th
+// point after it and points that lack a field of their predecessor are written in
+// full as keyframes; keyframeInterval <= 0 only writes the required ones.
+//
+// The encoder is stateful: use one per stream, from a single goroutine, and read
+// the stream with a DeltaDecoder. Its Reset() method starts a new stream, e.g.
+// after points were lost, so that the next point of every series is a keyframe.
+func NewDeltaEncoder(keyframeInterval int) Encoder {
+	return &deltaEncoder{keyframeInterval: keyframeInterval, series: map[string]*deltaSeries{}}
+}
+
+func (e *deltaEncoder) Encode(w io.Writer, p *Point) error {
+	key := p.SeriesKey()
+	s, ok := e.series[key]
+	delta := ok && (e.keyframeInterval <= 0 || s.since+1 < e.keyframeInterval)
+	if delta {
+		for k := range s.fields {
+			if _, ok := p.Fields[k]; !ok {
+				delta = false
+				break
+			}
+		}
+	}
+
+	fields := make(map[string]interface{}, len(p.Fields))
+	for k, v := range p.Fields {
+		fields[k] = normalize(v)
+	}
+
+	out := p
+	if delta {
+		changed := map[string]interface{}{}
+		for k, v := range fields {
+			if prev, ok := s.fields[k]; !ok || prev != v {
+				changed[k] = v
+			}
+		}
+		out = &Point{Measurement: p.Measurement, Tags: p.Tags, Fields: changed, Time: p.Time, Units: p.Units}
+		s.since++
+	} else {
+		s = &deltaSeries{}
+		e.series[key] = s
+	}
+	s.fields = fields
+
+	msg := AppendProtobuf(nil, out)
+	if delta {
+		msg = appendVarint(msg, 5, 1)
+	}
+
+	var size [binary.MaxVarintLen64]byte
+	if _, err := w.Write(size[:binary.PutUvarint(size[:], uint64(len(msg)))]); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// Reset forgets the previous points of every series.
+func (e *deltaEncoder) Reset() {
+	e.series = map[string]*deltaSeries{}
+}
+
+func (e *deltaEncoder) ContentType() string {
+	return "application/x-protobuf; delta=true"
+}
+
+// DeltaDecoder reads the streams written by a NewDeltaEncoder encoder, restoring
+// the omitted fields of every point.
+type DeltaDecoder struct {
+	d      *ProtobufDecoder
+	series map[string]*Point
+}
+
+// NewDeltaDecoder returns a decoder reading from r.
+func NewDeltaDecoder(r io.Reader) *DeltaDecoder {
+	return &DeltaDecoder{d: NewProtobufDecoder(r), series: map[string]*Point{}}
+}
+
+// Decode reads the next point into p with all of its fields. It returns io.EOF
+// when no points are left.
+func (d *DeltaDecoder) Decode(p *Point) error {
+	if err := d.d.next(); err != nil {
+		return err
+	}
+	delta, err := unmarshalProtobuf(d.d.buf, p)
+	if err != nil {
+		return err
+	}
+
+	key := p.SeriesKey()
+	if prev, ok := d.series[key]; ok && delta {
+		for k, v := range prev.Fields {
+			if _, ok := p.Fields[k]; !ok {
+				p.Fields[k] = v
+			}
+		}
+		for k, v := range prev.Units {
+			if _, ok := p.Units[k]; !ok {
+				if p.Units == nil {
+					p.Units = map[string]string{}
+				}
+				p.Units[k] = v
+			}
+		}
+	}
+
+	// Keep copies, p belongs to the caller
+	last := &Point{Fields: make(map[string]interface{}, len(p.Fields))}
+	for k, v := range p.Fields {
+		last.Fields[k] = v
+	}
+	if p.Units != nil {
+		last.Units = make(map[string]string, len(p.Units))
+		for k, v := range p.Units {
+			last.Units[k] = v
+		}
+	}
+	d.series[key] = last
+	return nil
+}
@@ -0,0 +1,33 @@
+package point
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// jsonPoint is the JSON representation of a Point.
+type jsonPoint struct {
+	Measurement string                 `json:"measurement"`
+	Tags        map[string]string      `json:"tags"`
+	Fields      map[string]interface{} `json:"fields"`
+	Time        string                 `json:"time"`
+	Units       map[string]string      `json:"units,omitempty"`
+}
+
+// jsonEncoder writes points as one JSON object per line.
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(w io.Writer, p *Point) error {
+	return json.NewEncoder(w).Encode(&jsonPoint{
+		Measurement: p.Measurement,
+		Tags:        p.Tags,
+		Fields:      p.Fields,
+		Time:        p.Time.UTC().Format(time.RFC3339Nano),
+		Units:       p.Units,
+	})
+}
+
+func (jsonEncoder) ContentType() string {
+	return "application/json"
+}
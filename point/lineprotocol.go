@@ -0,0 +1,94 @@
+package point
+
+import (
+	"errors"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	keyEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+// lineProtocolEncoder writes points in InfluxDB line protocol with nanosecond
+// timestamps. Tags and fields are sorted by key.
+type lineProtocolEncoder struct{}
+
+func (lineProtocolEncoder) Encode(w io.Writer, p *Point) error {
+	b, err := AppendLineProtocol(nil, p)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+func (lineProtocolEncoder) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// AppendLineProtocol appends the line protocol encoding of p, including the
+// trailing newline, to b. Fields with unsupported values (or NaN/Inf floats) are
+// skipped; a point without any field is an error.
+func AppendLineProtocol(b []byte, p *Point) ([]byte, error) {
+	start := len(b)
+	b = append(b, measurementEscaper.Replace(p.Measurement)...)
+
+	for _, k := range sortedTagKeys(p.Tags) {
+		v := p.Tags[k]
+		if k == "" || v == "" {
+			// Empty tag values aren't allowed by the line protocol
+			continue
+		}
+		b = append(b, ',')
+		b = append(b, keyEscaper.Replace(k)...)
+		b = append(b, '=')
+		b = append(b, keyEscaper.Replace(v)...)
+	}
+
+	sep := byte(' ')
+	for _, k := range sortedFieldKeys(p.Fields) {
+		v := normalize(p.Fields[k])
+		if f, ok := v.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			continue
+		}
+		if v == nil {
+			continue
+		}
+
+		b = append(b, sep)
+		sep = ','
+		b = append(b, keyEscaper.Replace(k)...)
+		b = append(b, '=')
+
+		switch v := v.(type) {
+		case int64:
+			b = strconv.AppendInt(b, v, 10)
+			b = append(b, 'i')
+		case uint64:
+			b = strconv.AppendUint(b, v, 10)
+			b = append(b, 'u')
+		case float64:
+			b = strconv.AppendFloat(b, v, 'f', -1, 64)
+		case bool:
+			b = strconv.AppendBool(b, v)
+		case string:
+			b = append(b, '"')
+			b = append(b, stringEscaper.Replace(v)...)
+			b = append(b, '"')
+		}
+	}
+	if sep == ' ' {
+		return b[:start], errors.New("point: no valid fields in point " + p.Measurement)
+	}
+
+	if !p.Time.IsZero() {
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, p.Time.UnixNano(), 10)
+	}
+	return append(b, '\n'), nil
+}
@@ -0,0 +1,102 @@
+package point
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Point is a single measurement: a set of tags and fields at a point in time.
+// Field values are int64, uint64, float64, string or bool; other integer and
+// float types are converted by the encoders.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]interface{}
+	Time        time.Time
+
+	// Units optionally maps field names to their unit, e.g. "By" or "ns", for
+	// backends supporting unit metadata. See the Unit constants of the collector
+	// package.
+	Units map[string]string
+}
+
+// SeriesKey identifies the series of p by its measurement and tags, e.g. to keep
+// state per series.
+func (p *Point) SeriesKey() string {
+	var b strings.Builder
+	b.WriteString(p.Measurement)
+	for _, k := range sortedTagKeys(p.Tags) {
+		b.WriteByte(0)
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(p.Tags[k])
+	}
+	return b.String()
+}
+
+// Encoder serializes points into a wire format, separating encoding from the
+// transport of a sink.
+type Encoder interface {
+	// Encode writes the encoding of p to w.
+	Encode(w io.Writer, p *Point) error
+
+	// ContentType is the MIME type of the encoding.
+	ContentType() string
+}
+
+// Encoders shipped with this package.
+var (
+	JSON         Encoder = jsonEncoder{}
+	LineProtocol Encoder = lineProtocolEncoder{}
+	Protobuf     Encoder = protobufEncoder{}
+)
+
+// sortedTagKeys and sortedFieldKeys return the keys of m in sorted order, for
+// deterministic output.
+func sortedTagKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedFieldKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// normalize converts a field value to one of int64, uint64, float64, string or bool.
+// It returns nil for unsupported types.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case int64, uint64, float64, string, bool:
+		return v
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case uint:
+		return uint64(v)
+	case uint8:
+		return uint64(v)
+	case uint16:
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case float32:
+		return float64(v)
+	}
+	return nil
+}
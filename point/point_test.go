@@ -0,0 +1,240 @@
+package point
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testPoint = &Point{
+	Measurement: "go.runtime host",
+	Tags:        map[string]string{"go.os": "linux", "env": "prod,eu", "empty": ""},
+	Fields: map[string]interface{}{
+		"mem.alloc":   int64(1024),
+		"cpu.util":    12.5,
+		"gc.forced":   true,
+		"title":       `say "hi"`,
+		"unsupported": struct{}{},
+	},
+	Time: time.Unix(1, 5),
+}
+
+func TestLineProtocol(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := LineProtocol.Encode(buf, testPoint); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := `go.runtime\ host,env=prod\,eu,go.os=linux cpu.util=12.5,gc.forced=true,mem.alloc=1024i,title="say \"hi\"" 1000000005` + "\n"
+	if got := buf.String(); got != exp {
+		t.Errorf("unexpected line protocol:\ngot: %s\nexp: %s", got, exp)
+	}
+
+	if _, err := AppendLineProtocol(nil, &Point{Measurement: "m"}); err == nil {
+		t.Error("expected error for point without fields")
+	}
+}
+
+func TestJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := JSON.Encode(buf, &Point{Measurement: "m", Fields: map[string]interface{}{"a": int64(1)}, Time: time.Unix(0, 0)}); err != nil {
+		t.Fatal(err)
+	}
+
+	got := map[string]interface{}{}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["measurement"] != "m" || got["time"] != "1970-01-01T00:00:00Z" {
+		t.Errorf("unexpected JSON %s", buf.String())
+	}
+}
+
+func TestProtobuf(t *testing.T) {
+	got := AppendProtobuf(nil, &Point{
+		Measurement: "m",
+		Tags:        map[string]string{"k": "v"},
+		Fields:      map[string]interface{}{"f": int64(1)},
+		Time:        time.Unix(0, 2),
+	})
+
+	exp := []byte{
+		0x0a, 0x01, 'm', // measurement
+		0x12, 0x06, 0x0a, 0x01, 'k', 0x12, 0x01, 'v', // tags entry
+		0x1a, 0x07, 0x0a, 0x01, 'f', 0x12, 0x02, 0x08, 0x01, // fields entry
+		0x20, 0x02, // timestamp
+	}
+	if !bytes.Equal(got, exp) {
+		t.Errorf("unexpected protobuf encoding:\ngot: %x\nexp: %x", got, exp)
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	exp := &Point{
+		Measurement: "go.runtime",
+		Tags:        map[string]string{"go.os": "linux"},
+		Fields: map[string]interface{}{
+			"mem.alloc": int64(-1),
+			"cpu.util":  12.5,
+			"gc.forced": true,
+			"title":     "deploy",
+			"mem.sys":   uint64(1 << 63),
+		},
+		Time: time.Unix(1, 5),
+	}
+
+	buf := &bytes.Buffer{}
+	for i := 0; i < 2; i++ {
+		if err := Protobuf.Encode(buf, exp); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Unknown fields of newer schema revisions are skipped
+	extra := appendVarint(AppendProtobuf(nil, exp), 15, 42)
+	buf.Write(appendUvarint(nil, uint64(len(extra))))
+	buf.Write(extra)
+
+	d := NewProtobufDecoder(buf)
+	for i := 0; i < 3; i++ {
+		got := &Point{}
+		if err := d.Decode(got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("round trip mismatch:\ngot: %+v\nexp: %+v", got, exp)
+		}
+	}
+	if err := d.Decode(&Point{}); err != io.EOF {
+		t.Errorf("expected EOF got %v", err)
+	}
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	tags := map[string]string{"go.os": "linux"}
+	points := []*Point{
+		{Measurement: "m", Tags: tags, Fields: map[string]interface{}{"a": int64(1), "b": "x"}, Time: time.Unix(1, 0)},
+		{Measurement: "m", Tags: tags, Fields: map[string]interface{}{"a": int64(2), "b": "x"}, Time: time.Unix(2, 0)},
+		{Measurement: "n", Fields: map[string]interface{}{"c": true}, Time: time.Unix(2, 0)},
+		{Measurement: "m", Tags: tags, Fields: map[string]interface{}{"a": int64(2), "b": "x"}, Time: time.Unix(3, 0)},
+		// Drops a field, forcing a keyframe
+		{Measurement: "m", Tags: tags, Fields: map[string]interface{}{"a": int64(2)}, Time: time.Unix(4, 0)},
+		{Measurement: "m", Tags: tags, Fields: map[string]interface{}{"a": int64(3)}, Time: time.Unix(5, 0)},
+		{Measurement: "m", Tags: tags, Fields: map[string]interface{}{"a": int64(3)}, Time: time.Unix(6, 0)},
+		// Keyframe interval reached
+		{Measurement: "m", Tags: tags, Fields: map[string]interface{}{"a": int64(3)}, Time: time.Unix(7, 0)},
+	}
+
+	buf := &bytes.Buffer{}
+	enc := NewDeltaEncoder(3)
+	var sizes []int
+	for _, p := range points {
+		before := buf.Len()
+		if err := enc.Encode(buf, p); err != nil {
+			t.Fatal(err)
+		}
+		sizes = append(sizes, buf.Len()-before)
+	}
+	if sizes[3] >= sizes[0] || sizes[6] >= sizes[4] || sizes[7] != sizes[4] {
+		t.Errorf("unexpected message sizes %v", sizes)
+	}
+
+	// Plain protobuf decoders see the changed fields only
+	plain := &Point{}
+	if err := UnmarshalProtobuf(buf.Bytes()[sizes[0]+1:sizes[0]+sizes[1]], plain); err != nil || len(plain.Fields) != 1 {
+		t.Errorf("expected the delta to carry 1 field got %v (%v)", plain.Fields, err)
+	}
+
+	d := NewDeltaDecoder(buf)
+	for i, exp := range points {
+		got := &Point{}
+		if err := d.Decode(got); err != nil {
+			t.Fatal(err)
+		}
+		if exp.Tags == nil {
+			exp.Tags = map[string]string{}
+		}
+		if !reflect.DeepEqual(got, exp) {
+			t.Errorf("point %d: round trip mismatch:\ngot: %+v\nexp: %+v", i, got, exp)
+		}
+	}
+	if err := d.Decode(&Point{}); err != io.EOF {
+		t.Errorf("expected EOF got %v", err)
+	}
+}
+
+func TestUnits(t *testing.T) {
+	exp := &Point{
+		Measurement: "m",
+		Tags:        map[string]string{},
+		Fields:      map[string]interface{}{"a": int64(1), "b": int64(2)},
+		Time:        time.Unix(1, 0),
+		Units:       map[string]string{"a": "By", "b": "ns", "gone": "1"},
+	}
+
+	got := &Point{}
+	if err := UnmarshalProtobuf(AppendProtobuf(nil, exp), got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Units) != 2 || got.Units["a"] != "By" || got.Units["b"] != "ns" {
+		t.Errorf("expected the units of the encoded fields got %v", got.Units)
+	}
+
+	// Deltas restore the units of unchanged fields
+	buf := &bytes.Buffer{}
+	enc := NewDeltaEncoder(0)
+	enc.Encode(buf, exp)
+	next := *exp
+	next.Fields = map[string]interface{}{"a": int64(1), "b": int64(3)}
+	enc.Encode(buf, &next)
+
+	d := NewDeltaDecoder(buf)
+	d.Decode(got)
+	if err := d.Decode(got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Units["a"] != "By" || got.Units["b"] != "ns" {
+		t.Errorf("expected delta units to be restored got %v", got.Units)
+	}
+
+	b := &bytes.Buffer{}
+	JSON.Encode(b, exp)
+	if !bytes.Contains(b.Bytes(), []byte(`"units":{"a":"By"`)) {
+		t.Errorf("expected units in JSON got %s", b)
+	}
+}
+
+func TestCloudEvents(t *testing.T) {
+	p := &Point{Measurement: "m", Tags: map[string]string{"k": "v"}, Fields: map[string]interface{}{"a": int64(1)}, Time: time.Unix(0, 0)}
+	enc := CloudEvents("/services/checkout", "")
+	if enc.ContentType() != "application/cloudevents+json" {
+		t.Errorf("unexpected content type %s", enc.ContentType())
+	}
+
+	var events []map[string]interface{}
+	for _, p := range []*Point{p, p, {Measurement: "m", Tags: map[string]string{"k": "w"}, Time: p.Time}} {
+		buf := &bytes.Buffer{}
+		if err := enc.Encode(buf, p); err != nil {
+			t.Fatal(err)
+		}
+		event := map[string]interface{}{}
+		if err := json.Unmarshal(buf.Bytes(), &event); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, event)
+	}
+
+	got := events[0]
+	data, _ := got["data"].(map[string]interface{})
+	if got["specversion"] != "1.0" || got["source"] != "/services/checkout" || got["type"] != DefaultCloudEventsType ||
+		got["subject"] != "m" || got["time"] != "1970-01-01T00:00:00Z" || got["datacontenttype"] != "application/json" ||
+		data["measurement"] != "m" {
+		t.Errorf("unexpected event %v", got)
+	}
+	if got["id"] == "" || got["id"] != events[1]["id"] || got["id"] == events[2]["id"] {
+		t.Errorf("expected ids stable per point and distinct per series got %v %v %v", got["id"], events[1]["id"], events[2]["id"])
+	}
+}
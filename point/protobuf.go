@@ -0,0 +1,124 @@
+package point
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// Protocol buffer wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// protobufEncoder writes points as length-delimited runstats.v1.Point messages,
+// see point.proto. Every message is prefixed with its size as a varint, the
+// framing used by protobuf's writeDelimitedTo.
+type protobufEncoder struct{}
+
+func (protobufEncoder) Encode(w io.Writer, p *Point) error {
+	msg := AppendProtobuf(nil, p)
+
+	var size [binary.MaxVarintLen64]byte
+	if _, err := w.Write(size[:binary.PutUvarint(size[:], uint64(len(msg)))]); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+func (protobufEncoder) ContentType() string {
+	return "application/x-protobuf"
+}
+
+// AppendProtobuf appends the runstats.v1.Point message encoding of p to b.
+// Fields with unsupported values are skipped.
+func AppendProtobuf(b []byte, p *Point) []byte {
+	b = appendString(b, 1, p.Measurement)
+
+	for _, k := range sortedTagKeys(p.Tags) {
+		var entry []byte
+		entry = appendString(entry, 1, k)
+		entry = appendString(entry, 2, p.Tags[k])
+		b = appendBytes(b, 2, entry)
+	}
+
+	for _, k := range sortedFieldKeys(p.Fields) {
+		var value []byte
+		switch v := normalize(p.Fields[k]).(type) {
+		case int64:
+			value = appendVarint(value, 1, uint64(v))
+		case float64:
+			value = appendTag(value, 2, wireFixed64)
+			value = appendFixed64(value, math.Float64bits(v))
+		case string:
+			value = appendString(value, 3, v)
+		case bool:
+			var x uint64
+			if v {
+				x = 1
+			}
+			value = appendVarint(value, 4, x)
+		case uint64:
+			value = appendVarint(value, 5, v)
+		default:
+			continue
+		}
+
+		var entry []byte
+		entry = appendString(entry, 1, k)
+		entry = appendBytes(entry, 2, value)
+		b = appendBytes(b, 3, entry)
+	}
+
+	if !p.Time.IsZero() {
+		b = appendVarint(b, 4, uint64(p.Time.UnixNano()))
+	}
+
+	// Only the units of the encoded fields
+	for _, k := range sortedTagKeys(p.Units) {
+		if _, ok := p.Fields[k]; !ok {
+			continue
+		}
+		var entry []byte
+		entry = appendString(entry, 1, k)
+		entry = appendString(entry, 2, p.Units[k])
+		b = appendBytes(b, 6, entry)
+	}
+	return b
+}
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
+
+func appendFixed64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendTag(b []byte, field int, wire int) []byte {
+	return appendUvarint(b, uint64(field)<<3|uint64(wire))
+}
+
+func appendVarint(b []byte, field int, v uint64) []byte {
+	b = appendTag(b, field, wireVarint)
+	return appendUvarint(b, v)
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+func appendString(b []byte, field int, v string) []byte {
+	b = appendTag(b, field, wireBytes)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
@@ -0,0 +1,199 @@
+package point
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// ProtobufSchemaVersion is the major version of point.proto, reflected in its
+// runstats.v<N> package name. It only changes on incompatible schema changes.
+const ProtobufSchemaVersion = 1
+
+// maxProtobufSize limits the size of a single delimited message.
+const maxProtobufSize = 4 << 20
+
+var errProtobufTruncated = errors.New("point: truncated protobuf message")
+
+// UnmarshalProtobuf decodes a runstats.v1.Point message into p. Unknown fields
+// are skipped, so messages written by newer revisions of the schema can be read.
+func UnmarshalProtobuf(b []byte, p *Point) error {
+	_, err := unmarshalProtobuf(b, p)
+	return err
+}
+
+// unmarshalProtobuf is UnmarshalProtobuf also reporting the delta flag.
+func unmarshalProtobuf(b []byte, p *Point) (delta bool, err error) {
+	*p = Point{Tags: map[string]string{}, Fields: map[string]interface{}{}}
+
+	err = walkProtobuf(b, func(field int, wire int, v uint64, data []byte) error {
+		switch {
+		case field == 1 && wire == wireBytes:
+			p.Measurement = string(data)
+		case field == 2 && wire == wireBytes:
+			k, v, err := decodeEntry(data, decodeTagValue)
+			if err != nil {
+				return err
+			}
+			// proto3 writers may omit empty values
+			p.Tags[k], _ = v.(string)
+		case field == 3 && wire == wireBytes:
+			k, v, err := decodeEntry(data, decodeFieldValue)
+			if err != nil {
+				return err
+			}
+			if v != nil {
+				p.Fields[k] = v
+			}
+		case field == 4 && wire == wireVarint:
+			p.Time = time.Unix(0, int64(v))
+		case field == 5 && wire == wireVarint:
+			delta = v != 0
+		case field == 6 && wire == wireBytes:
+			k, v, err := decodeEntry(data, decodeTagValue)
+			if err != nil {
+				return err
+			}
+			if p.Units == nil {
+				p.Units = map[string]string{}
+			}
+			p.Units[k], _ = v.(string)
+		}
+		return nil
+	})
+	return delta, err
+}
+
+// ProtobufDecoder reads the length-delimited messages written by the Protobuf encoder.
+type ProtobufDecoder struct {
+	r   *bufio.Reader
+	buf []byte
+}
+
+// NewProtobufDecoder returns a decoder reading from r.
+func NewProtobufDecoder(r io.Reader) *ProtobufDecoder {
+	return &ProtobufDecoder{r: bufio.NewReader(r)}
+}
+
+// Decode reads the next message into p. It returns io.EOF when no messages are left.
+func (d *ProtobufDecoder) Decode(p *Point) error {
+	if err := d.next(); err != nil {
+		return err
+	}
+	return UnmarshalProtobuf(d.buf, p)
+}
+
+// next reads the next message into d.buf.
+func (d *ProtobufDecoder) next() error {
+	size, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return err
+	}
+	if size > maxProtobufSize {
+		return errors.New("point: protobuf message too large")
+	}
+
+	if uint64(cap(d.buf)) < size {
+		d.buf = make([]byte, size)
+	}
+	d.buf = d.buf[:size]
+	if _, err := io.ReadFull(d.r, d.buf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return nil
+}
+
+// walkProtobuf calls fn for every field of the message b. For varint and fixed
+// fields v holds the value, for length-delimited fields data holds the payload.
+func walkProtobuf(b []byte, fn func(field int, wire int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errProtobufTruncated
+		}
+		b = b[n:]
+		field, wire := int(tag>>3), int(tag&7)
+
+		var v uint64
+		var data []byte
+		switch wire {
+		case wireVarint:
+			if v, n = binary.Uvarint(b); n <= 0 {
+				return errProtobufTruncated
+			}
+			b = b[n:]
+		case wireFixed64:
+			if len(b) < 8 {
+				return errProtobufTruncated
+			}
+			v, b = binary.LittleEndian.Uint64(b), b[8:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return errProtobufTruncated
+			}
+			data, b = b[n:n+int(size)], b[n+int(size):]
+		case wireFixed32:
+			if len(b) < 4 {
+				return errProtobufTruncated
+			}
+			v, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+		default:
+			return errors.New("point: unsupported protobuf wire type")
+		}
+
+		if err := fn(field, wire, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeEntry decodes a map entry message, decoding the value with value.
+func decodeEntry(b []byte, value func(wire int, v uint64, data []byte) (interface{}, error)) (string, interface{}, error) {
+	var key string
+	var val interface{}
+	err := walkProtobuf(b, func(field int, wire int, v uint64, data []byte) error {
+		var err error
+		switch field {
+		case 1:
+			key = string(data)
+		case 2:
+			val, err = value(wire, v, data)
+		}
+		return err
+	})
+	return key, val, err
+}
+
+func decodeTagValue(wire int, v uint64, data []byte) (interface{}, error) {
+	return string(data), nil
+}
+
+// decodeFieldValue decodes a runstats.v1.Value message. Values of unknown kinds
+// decode to nil.
+func decodeFieldValue(wire int, v uint64, data []byte) (interface{}, error) {
+	var val interface{}
+	err := walkProtobuf(data, func(field int, wire int, v uint64, data []byte) error {
+		switch field {
+		case 1:
+			val = int64(v)
+		case 2:
+			val = math.Float64frombits(v)
+		case 3:
+			val = string(data)
+		case 4:
+			val = v != 0
+		case 5:
+			val = v
+		}
+		return nil
+	})
+	return val, err
+}
@@ -0,0 +1,11 @@
+package runstats
+
+import (
+	"strings"
+)
+
+// pprofURL returns the Config.PprofURL of the instance, "{instance}" replaced by
+// the current identity.
+func (r *RunStats) pprofURL() string {
+	return strings.ReplaceAll(r.config.PprofURL, "{instance}", r.instance())
+}
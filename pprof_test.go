@@ -0,0 +1,30 @@
+package runstats
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPprofURL(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{
+		Identity: StaticIdentity("10.0.0.5"),
+		PprofURL: "http://{instance}:6060/debug/pprof/",
+	})
+	stats.onNewPoint(stats.collector.OneOff())
+	stats.WritePoint("app.orders", nil, map[string]interface{}{"count": 1})
+
+	points := tr.Points(stats)
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points got %d", len(points))
+	}
+	if url := points[0].Tags["pprof.url"]; url != "http://10.0.0.5:6060/debug/pprof/" {
+		t.Errorf("expected the pprof url of the instance got %q", url)
+	}
+	if _, ok := points[1].Tags["pprof.url"]; ok {
+		t.Error("expected only the runtime points to be tagged")
+	}
+
+	if _, err := (&Config{PprofURL: "http://[::1/debug/pprof/"}).init(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected an invalid config error got %v", err)
+	}
+}
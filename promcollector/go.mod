@@ -0,0 +1,13 @@
+module github.com/nzlov/go-runtime-metrics/promcollector
+
+go 1.16
+
+require (
+	github.com/nzlov/go-runtime-metrics v0.0.0-20261016010221-3d90ade0a013
+	github.com/prometheus/client_golang v1.11.0
+	github.com/prometheus/client_model v0.2.0
+)
+
+// The root module is replaced until a release contains the APIs this module
+// uses; drop the replace and pin that release then.
+replace github.com/nzlov/go-runtime-metrics => ../
@@ -0,0 +1,52 @@
+// Package promcollector exposes the runtime statistics as a prometheus.Collector,
+// for services registering their metrics with client_golang. It is a module of
+// its own so that the prometheus package remains free of the client libraries:
+//
+//	prometheus.MustRegister(promcollector.New("go_runtime"))
+package promcollector
+
+import (
+	"github.com/nzlov/go-runtime-metrics/prometheus"
+	prom "github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector collects the runtime statistics on every scrape, named like by the
+// handler of the prometheus package.
+type Collector struct {
+	exporter *prometheus.Exporter
+}
+
+// New returns a Collector naming its metrics namespace_<field>, see
+// prometheus.New.
+func New(namespace string) *Collector {
+	return &Collector{exporter: prometheus.New(namespace)}
+}
+
+// Describe sends no descriptors: the fields vary with the platform and the
+// collected groups, so c is an unchecked collector. Registering it next to
+// client_golang's Go collector needs a namespace other than "go".
+func (c *Collector) Describe(chan<- *prom.Desc) {}
+
+// Collect sends the runtime statistics to ch.
+func (c *Collector) Collect(ch chan<- prom.Metric) {
+	s := c.exporter.Snapshot()
+	for _, m := range s.Metrics {
+		desc := prom.NewDesc(m.Name, m.Help, nil, s.Labels)
+		kind := prom.GaugeValue
+		if m.Counter {
+			kind = prom.CounterValue
+		}
+		metric, err := prom.NewConstMetric(desc, kind, m.Value)
+		if err != nil {
+			metric = prom.NewInvalidMetric(desc, err)
+		}
+		ch <- metric
+	}
+
+	desc := prom.NewDesc(s.Pauses.Name, s.Pauses.Help, nil, s.Labels)
+	metric, err := prom.NewConstSummary(desc, s.Pauses.Count, s.Pauses.Sum, s.Pauses.Quantiles)
+	if err != nil {
+		metric = prom.NewInvalidMetric(desc, err)
+	}
+	ch <- metric
+}
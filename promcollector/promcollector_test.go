@@ -0,0 +1,32 @@
+package promcollector
+
+import (
+	"testing"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestCollector(t *testing.T) {
+	reg := prom.NewPedanticRegistry()
+	reg.MustRegister(New("go_runtime"))
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := map[string]dto.MetricType{}
+	for _, f := range families {
+		types[f.GetName()] = f.GetType()
+	}
+
+	for name, typ := range map[string]dto.MetricType{
+		"go_runtime_mem_heap_alloc":     dto.MetricType_GAUGE,
+		"go_runtime_mem_gc_count_total": dto.MetricType_COUNTER,
+		"go_runtime_gc_pause_seconds":   dto.MetricType_SUMMARY,
+	} {
+		if got, ok := types[name]; !ok || got != typ {
+			t.Errorf("expected %s to be a %s got %v", name, typ, types)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Package prometheus exposes the runtime statistics of the collector package in
+// the Prometheus text exposition format, for services already scraped by
+// Prometheus rather than pushing to InfluxDB. It doesn't depend on the Prometheus
+// client libraries: mount its Handler next to promhttp's.
+package prometheus
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"runtime/debug"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// pauseQuantiles are the quantiles of the GC pause summary, as returned by
+// debug.ReadGCStats for 5 quantiles.
+var pauseQuantiles = []float64{0, 0.25, 0.5, 0.75, 1}
+
+// Exporter collects the runtime statistics on every scrape.
+type Exporter struct {
+	namespace string
+	collector *collector.Collector
+}
+
+// New returns an Exporter naming its metrics namespace_<field>, with the dots of
+// the field names as underscores, e.g. "go_runtime_mem_heap_alloc" for the
+// namespace "go_runtime". Cumulative fields are counters with a _total suffix.
+// The GC pauses are exposed as the namespace_gc_pause_seconds summary.
+func New(namespace string) *Exporter {
+	return &Exporter{namespace: namespace, collector: collector.New(nil)}
+}
+
+// Handler returns the http.Handler of an Exporter for namespace.
+func Handler(namespace string) http.Handler {
+	return New(namespace)
+}
+
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", contentType)
+	e.WriteTo(w)
+}
+
+// WriteTo collects the runtime statistics and writes them to w.
+func (e *Exporter) WriteTo(w io.Writer) (int64, error) {
+	s := e.Snapshot()
+	labels := promLabels(s.Labels)
+
+	cw := &countingWriter{w: w}
+	b := bufio.NewWriter(cw)
+	for _, m := range s.Metrics {
+		kind := "gauge"
+		if m.Counter {
+			kind = "counter"
+		}
+		if m.Help != "" {
+			fmt.Fprintf(b, "# HELP %s %s\n", m.Name, m.Help)
+		}
+		fmt.Fprintf(b, "# TYPE %s %s\n%s%s %s\n", m.Name, kind, m.Name, labels, formatFloat(m.Value))
+	}
+	writePauseSummary(b, s.Pauses, s.Labels)
+
+	err := b.Flush()
+	return cw.n, err
+}
+
+// Snapshot is the runtime statistics collected for one scrape.
+type Snapshot struct {
+	// Labels of every metric, from the tags of the collector.
+	Labels map[string]string
+	// Metrics sorted by name.
+	Metrics []Metric
+	// Pauses is the GC pause summary.
+	Pauses Summary
+}
+
+// Metric is a gauge, or a counter for cumulative fields.
+type Metric struct {
+	Name    string
+	Help    string
+	Counter bool
+	Value   float64
+}
+
+// Summary is the GC pause summary: the pause quantiles of the recent GC cycles,
+// in seconds, and the total pause and count of all cycles.
+type Summary struct {
+	Name      string
+	Help      string
+	Quantiles map[float64]float64
+	Sum       float64
+	Count     uint64
+}
+
+// Snapshot collects the runtime statistics, named like by WriteTo. It is meant
+// for adapters to other exposition formats or client libraries.
+func (e *Exporter) Snapshot() Snapshot {
+	fields := e.collector.OneOff()
+	units := fields.Units()
+	values := fields.Values()
+
+	s := Snapshot{Labels: map[string]string{}, Metrics: make([]Metric, 0, len(values))}
+	for k, v := range fields.Tags() {
+		s.Labels[promName(k)] = v
+	}
+	for field, v := range values {
+		value, ok := promValue(v)
+		if !ok {
+			continue
+		}
+
+		m := Metric{Name: promName(e.namespace + "_" + field), Value: value}
+		if collector.Cumulative(field) {
+			m.Name, m.Counter = m.Name+"_total", true
+		}
+		if unit := units[field]; unit != "" {
+			m.Help = "Unit: " + unit
+		}
+		s.Metrics = append(s.Metrics, m)
+	}
+	sort.Slice(s.Metrics, func(i, j int) bool { return s.Metrics[i].Name < s.Metrics[j].Name })
+
+	stats := debug.GCStats{PauseQuantiles: make([]time.Duration, len(pauseQuantiles))}
+	debug.ReadGCStats(&stats)
+	s.Pauses = Summary{
+		Name:      promName(e.namespace + "_gc_pause_seconds"),
+		Help:      "Unit: s",
+		Quantiles: make(map[float64]float64, len(pauseQuantiles)),
+		Sum:       stats.PauseTotal.Seconds(),
+		Count:     uint64(stats.NumGC),
+	}
+	for i, q := range pauseQuantiles {
+		s.Pauses.Quantiles[q] = stats.PauseQuantiles[i].Seconds()
+	}
+	return s
+}
+
+// writePauseSummary writes the pause summary s, its quantiles in increasing
+// order.
+func writePauseSummary(w io.Writer, s Summary, labels map[string]string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s summary\n", s.Name, s.Help, s.Name)
+	for _, q := range pauseQuantiles {
+		qlabels := make(map[string]string, len(labels)+1)
+		for k, v := range labels {
+			qlabels[k] = v
+		}
+		qlabels["quantile"] = formatFloat(q)
+		fmt.Fprintf(w, "%s%s %s\n", s.Name, promLabels(qlabels), formatFloat(s.Quantiles[q]))
+	}
+	l := promLabels(labels)
+	fmt.Fprintf(w, "%s_sum%s %s\n", s.Name, l, formatFloat(s.Sum))
+	fmt.Fprintf(w, "%s_count%s %d\n", s.Name, l, s.Count)
+}
+
+func promLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, promName(k)+"="+strconv.Quote(v))
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func promValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// promName replaces the characters not allowed in metric and label names.
+func promName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
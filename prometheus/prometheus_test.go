@@ -0,0 +1,43 @@
+package prometheus
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler("go_runtime").ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != contentType {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	b, _ := ioutil.ReadAll(rec.Body)
+	body := string(b)
+
+	expected := []string{
+		"# HELP go_runtime_mem_heap_alloc Unit: By\n# TYPE go_runtime_mem_heap_alloc gauge\ngo_runtime_mem_heap_alloc{go_arch=",
+		"# TYPE go_runtime_mem_gc_count_total counter\n",
+		"# TYPE go_runtime_gc_pause_seconds summary\n",
+		`quantile="0.5"} `,
+		"go_runtime_gc_pause_seconds_count{",
+	}
+	for _, e := range expected {
+		if !strings.Contains(body, e) {
+			t.Errorf("expected %q in\n%s", e, body)
+		}
+	}
+}
+
+func TestPromName(t *testing.T) {
+	for in, out := range map[string]string{
+		"go_runtime_mem.heap.alloc": "go_runtime_mem_heap_alloc",
+		"1st-value":                 "_st_value",
+	} {
+		if name := promName(in); name != out {
+			t.Errorf("expected %q for %q got %q", out, in, name)
+		}
+	}
+}
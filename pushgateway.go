@@ -0,0 +1,184 @@
+package runstats
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// pushgatewayTransport pushes points to a Prometheus Pushgateway. Every numeric or
+// boolean field becomes a sample named <measurement>_<field>, with the point tags
+// as labels and dots replaced by underscores, e.g. field "mem.heap.alloc" of
+// "go.runtime" becomes go_runtime_mem_heap_alloc. Samples are pushed with POST, so
+// each push replaces only the metrics it contains within the grouping key.
+type pushgatewayTransport struct {
+	client *http.Client
+	url    string
+}
+
+func newPushgatewayTransport(config *Config) *pushgatewayTransport {
+	var path strings.Builder
+	path.WriteString(strings.TrimSuffix(config.PushgatewayURL, "/"))
+	path.WriteString("/metrics")
+	writeGroupingLabel(&path, "job", config.PushgatewayJob)
+
+	names := make([]string, 0, len(config.PushgatewayGrouping))
+	for name := range config.PushgatewayGrouping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeGroupingLabel(&path, promName(name), config.PushgatewayGrouping[name])
+	}
+
+	client := config.httpClient()
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &pushgatewayTransport{client: client, url: path.String()}
+}
+
+// writeGroupingLabel appends a label to the push URL path, base64 encoding values
+// the path can't carry.
+func writeGroupingLabel(path *strings.Builder, name, value string) {
+	if value == "" || strings.Contains(value, "/") {
+		fmt.Fprintf(path, "/%s@base64/%s", name, base64.URLEncoding.EncodeToString([]byte(value)))
+		return
+	}
+	fmt.Fprintf(path, "/%s/%s", name, url.PathEscape(value))
+}
+
+func (t *pushgatewayTransport) Write(ctx context.Context, points []*point.Point) error {
+	var body bytes.Buffer
+	writePromText(&body, points)
+
+	req, err := http.NewRequest(http.MethodPost, t.url, &body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return withKind(ErrBackendUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+		err := &statusError{resp.StatusCode, fmt.Errorf("pushgateway: %s: %s", resp.Status, bytes.TrimSpace(msg))}
+		if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+			return withKind(ErrBackendUnavailable, err)
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *pushgatewayTransport) Close() {}
+
+// writePromText writes points in the Prometheus text exposition format, with
+// the unit of a field, if known, as help text. Samples of the same metric are
+// grouped as the format requires, only the latest sample
+// of a series is kept and timestamps are omitted since the Pushgateway rejects
+// them.
+func writePromText(w io.Writer, points []*point.Point) {
+	series := map[string]map[string]float64{}
+	units := map[string]string{}
+	for _, p := range points {
+		labels := promLabels(p.Tags)
+		for field, v := range p.Fields {
+			value, ok := promValue(v)
+			if !ok {
+				continue
+			}
+			name := promName(p.Measurement + "." + field)
+			if series[name] == nil {
+				series[name] = map[string]float64{}
+			}
+			series[name][labels] = value
+			if unit := p.Units[field]; unit != "" {
+				units[name] = unit
+			}
+		}
+	}
+
+	names := make([]string, 0, len(series))
+	for name := range series {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if unit := units[name]; unit != "" {
+			fmt.Fprintf(w, "# HELP %s Unit: %s\n", name, unit)
+		}
+		fmt.Fprintf(w, "# TYPE %s untyped\n", name)
+		labels := make([]string, 0, len(series[name]))
+		for l := range series[name] {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+		for _, l := range labels {
+			fmt.Fprintf(w, "%s%s %s\n", name, l, strconv.FormatFloat(series[name][l], 'g', -1, 64))
+		}
+	}
+}
+
+// promLabelEscaper escapes label values, the exposition format only knows the
+// \\, \" and \n escapes.
+var promLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promLabels(tags map[string]string) string {
+	if len(tags) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, promName(k)+`="`+promLabelEscaper.Replace(v)+`"`)
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func promValue(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// promName replaces the characters not allowed in Prometheus metric and label
+// names with underscores.
+func promName(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if !(c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
@@ -0,0 +1,84 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+func TestPushgatewayTransport(t *testing.T) {
+	var method, path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		b, _ := ioutil.ReadAll(req.Body)
+		method, path, body = req.Method, req.URL.EscapedPath(), string(b)
+	}))
+	defer srv.Close()
+
+	config, _ := (&Config{
+		PushgatewayURL:      srv.URL + "/",
+		PushgatewayGrouping: map[string]string{"instance": "web-1", "path": "/srv/app"},
+	}).init()
+	tr := newPushgatewayTransport(config)
+
+	now := time.Now()
+	err := tr.Write(context.Background(), []*point.Point{
+		{Measurement: "go.runtime", Tags: map[string]string{"go.os": "linux"}, Fields: map[string]interface{}{"mem.heap.alloc": int64(1), "title": "skipped"}, Time: now},
+		{Measurement: "go.runtime", Tags: map[string]string{"go.os": "linux"}, Fields: map[string]interface{}{"mem.heap.alloc": int64(2), "ok": true}, Time: now, Units: map[string]string{"mem.heap.alloc": "By"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if method != http.MethodPost || path != "/metrics/job/go_runtime/instance/web-1/path@base64/L3Nydi9hcHA=" {
+		t.Errorf("unexpected push %s %s", method, path)
+	}
+	expected := `# HELP go_runtime_mem_heap_alloc Unit: By
+# TYPE go_runtime_mem_heap_alloc untyped
+go_runtime_mem_heap_alloc{go_os="linux"} 2
+# TYPE go_runtime_ok untyped
+go_runtime_ok{go_os="linux"} 1
+`
+	if body != expected {
+		t.Errorf("expected body\n%s\ngot\n%s", expected, body)
+	}
+}
+
+func TestPromLabels(t *testing.T) {
+	labels := promLabels(map[string]string{"city": "Zürich\tCH", "path": `C:\app "x"` + "\n"})
+	if expected := `{city="Zürich` + "\t" + `CH",path="C:\\app \"x\"\n"}`; labels != expected {
+		t.Errorf("expected %s got %s", expected, labels)
+	}
+}
+
+func TestPushgatewayTransportError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "bad metrics", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	config, _ := (&Config{PushgatewayURL: srv.URL}).init()
+	err := newPushgatewayTransport(config).Write(context.Background(), nil)
+	if err == nil || !strings.Contains(err.Error(), "bad metrics") || errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("expected pushgateway error got %v", err)
+	}
+}
+
+func TestPushgatewayTransportUnavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	config, _ := (&Config{PushgatewayURL: srv.URL}).init()
+	err := newPushgatewayTransport(config).Write(context.Background(), nil)
+	if !errors.Is(err, ErrBackendUnavailable) {
+		t.Errorf("expected ErrBackendUnavailable got %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package runstats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Reader reads the runtime points written by RunStats back from InfluxDB, e.g.
+// for an admin page showing the runtime health of a fleet, without writing Flux.
+type Reader struct {
+	bucket string
+	// Runtime measurements are either the given measurement, or the default
+	// measurement of every instance when prefix is set.
+	measurement string
+	prefix      bool
+	query       func(ctx context.Context, flux string) ([]fluxRecord, error)
+	close       func()
+}
+
+// fluxRecord is a row of a Flux query result.
+type fluxRecord struct {
+	Time        time.Time
+	Measurement string
+	Field       string
+	Value       interface{}
+	Tags        map[string]string
+}
+
+// Snapshot is the latest runtime point of an instance, see Reader.Latest.
+type Snapshot struct {
+	// Instance is the identity of the instance.
+	Instance    string
+	Measurement string
+	// Time of the newest field.
+	Time   time.Time
+	Tags   map[string]string
+	Fields map[string]interface{}
+}
+
+// Sample is a field value at a point in time, see Reader.Range.
+type Sample struct {
+	Time  time.Time
+	Value interface{}
+}
+
+// NewReader connects to the InfluxDB Host of config with its Token, Org, Bucket
+// and credentials. It reads the runtime points of config.Measurement if set,
+// and otherwise those of every instance writing to the default measurements.
+// The Reader must be closed once done.
+func NewReader(config *Config) (*Reader, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	c := *config
+	if c.Host == "" {
+		c.Host = defaultHost
+	}
+	if c.Org == "" {
+		c.Org = defaultOrg
+	}
+	if c.Bucket == "" {
+		c.Bucket = defaultBucket
+	}
+
+	query, closeFn, err := dialInfluxQuery(&c)
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{bucket: c.Bucket, measurement: c.Measurement, query: query, close: closeFn}
+	if r.measurement == "" {
+		r.measurement, r.prefix = defaultMeasurement+".", true
+	}
+	return r, nil
+}
+
+// Close closes the connection to InfluxDB.
+func (r *Reader) Close() {
+	r.close()
+}
+
+// Latest returns the latest runtime point of every instance that wrote one
+// within since, sorted by instance.
+func (r *Reader) Latest(ctx context.Context, since time.Duration) ([]Snapshot, error) {
+	records, err := r.query(ctx, r.flux(time.Now().Add(-since), time.Time{}, "")+"\n  |> last()")
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := map[string]*Snapshot{}
+	fieldTimes := map[string]time.Time{}
+	for _, rec := range records {
+		instance := r.instance(rec)
+		s, ok := snapshots[instance]
+		if !ok {
+			s = &Snapshot{Instance: instance, Fields: map[string]interface{}{}}
+			snapshots[instance] = s
+		}
+		// Points with other tags, e.g. warm-up ones, are series of their own
+		key := instance + "\x00" + rec.Field
+		if t, ok := fieldTimes[key]; ok && rec.Time.Before(t) {
+			continue
+		}
+		fieldTimes[key] = rec.Time
+		s.Fields[rec.Field] = rec.Value
+		if !rec.Time.Before(s.Time) {
+			s.Time, s.Measurement, s.Tags = rec.Time, rec.Measurement, rec.Tags
+		}
+	}
+
+	result := make([]Snapshot, 0, len(snapshots))
+	for _, s := range snapshots {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Instance < result[j].Instance })
+	return result, nil
+}
+
+// Range returns the values of the runtime field, e.g. "mem.heap.alloc", written
+// from start until stop by instance, in time order.
+func (r *Reader) Range(ctx context.Context, field string, start, stop time.Time) (map[string][]Sample, error) {
+	records, err := r.query(ctx, r.flux(start, stop, field))
+	if err != nil {
+		return nil, err
+	}
+
+	samples := map[string][]Sample{}
+	for _, rec := range records {
+		instance := r.instance(rec)
+		samples[instance] = append(samples[instance], Sample{Time: rec.Time, Value: rec.Value})
+	}
+	for _, s := range samples {
+		sort.SliceStable(s, func(i, j int) bool { return s[i].Time.Before(s[j].Time) })
+	}
+	return samples, nil
+}
+
+// flux returns the query of the runtime points from start until stop, if set,
+// restricted to field if set. Runtime points are told apart from the other
+// points of their measurement by their schema.version tag.
+func (r *Reader) flux(start, stop time.Time, field string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "import \"strings\"\n\nfrom(bucket: %s)\n", fluxString(r.bucket))
+	if stop.IsZero() {
+		fmt.Fprintf(&b, "  |> range(start: %s)\n", fluxTime(start))
+	} else {
+		fmt.Fprintf(&b, "  |> range(start: %s, stop: %s)\n", fluxTime(start), fluxTime(stop))
+	}
+	if r.prefix {
+		fmt.Fprintf(&b, "  |> filter(fn: (r) => strings.hasPrefix(v: r._measurement, prefix: %s))\n", fluxString(r.measurement))
+	} else {
+		fmt.Fprintf(&b, "  |> filter(fn: (r) => r._measurement == %s)\n", fluxString(r.measurement))
+	}
+	b.WriteString("  |> filter(fn: (r) => exists r[\"schema.version\"])")
+	if field != "" {
+		fmt.Fprintf(&b, "\n  |> filter(fn: (r) => r._field == %s)", fluxString(field))
+	}
+	return b.String()
+}
+
+// instance returns the identity of the instance that wrote rec: its instance
+// tag, see Config.IdentityRefreshInterval, or else the suffix of the default
+// measurement.
+func (r *Reader) instance(rec fluxRecord) string {
+	if id := rec.Tags["instance"]; id != "" {
+		return id
+	}
+	if r.prefix {
+		return strings.TrimPrefix(rec.Measurement, r.measurement)
+	}
+	return rec.Measurement
+}
+
+// fluxString quotes s as a Flux string literal.
+func fluxString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "${", `\${`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + `"`
+}
+
+// fluxTime formats t as a Flux date-time literal.
+func fluxTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
@@ -0,0 +1,67 @@
+package runstats
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReaderLatest(t *testing.T) {
+	now := time.Now()
+	var flux string
+	r := &Reader{bucket: "go", measurement: "go.runtime.", prefix: true, query: func(ctx context.Context, q string) ([]fluxRecord, error) {
+		flux = q
+		return []fluxRecord{
+			{Time: now.Add(-time.Minute), Measurement: "go.runtime.web-1", Field: "mem.heap.alloc", Value: int64(1), Tags: map[string]string{"warmup": "true"}},
+			{Time: now, Measurement: "go.runtime.web-1", Field: "mem.heap.alloc", Value: int64(2)},
+			{Time: now, Measurement: "go.runtime.web-1", Field: "cpu.goroutines", Value: int64(10)},
+			{Time: now.Add(-time.Second), Measurement: "go.runtime.app", Field: "mem.heap.alloc", Value: int64(3), Tags: map[string]string{"instance": "web-0"}},
+		}, nil
+	}}
+
+	snapshots, err := r.Latest(context.Background(), time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[0].Instance != "web-0" || snapshots[1].Instance != "web-1" {
+		t.Fatalf("expected a snapshot per instance got %+v", snapshots)
+	}
+	web1 := snapshots[1]
+	if web1.Fields["mem.heap.alloc"] != int64(2) || web1.Fields["cpu.goroutines"] != int64(10) || !web1.Time.Equal(now) {
+		t.Errorf("expected the latest fields got %+v", web1)
+	}
+	if !strings.Contains(flux, `strings.hasPrefix(v: r._measurement, prefix: "go.runtime.")`) || !strings.HasSuffix(flux, "|> last()") {
+		t.Errorf("unexpected query %s", flux)
+	}
+}
+
+func TestReaderRange(t *testing.T) {
+	now := time.Now()
+	var flux string
+	r := &Reader{bucket: "go", measurement: "go.runtime.web", query: func(ctx context.Context, q string) ([]fluxRecord, error) {
+		flux = q
+		return []fluxRecord{
+			{Time: now, Measurement: "go.runtime.web", Field: "mem.heap.alloc", Value: int64(2)},
+			{Time: now.Add(-time.Minute), Measurement: "go.runtime.web", Field: "mem.heap.alloc", Value: int64(1)},
+		}, nil
+	}}
+
+	samples, err := r.Range(context.Background(), `mem.heap.alloc"`, now.Add(-time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := samples["go.runtime.web"]
+	if len(web) != 2 || web[0].Value != int64(1) || web[1].Value != int64(2) {
+		t.Errorf("expected the samples in time order got %v", samples)
+	}
+	if !strings.Contains(flux, `r._measurement == "go.runtime.web"`) || !strings.Contains(flux, `r._field == "mem.heap.alloc\""`) {
+		t.Errorf("unexpected query %s", flux)
+	}
+}
+
+func TestFluxString(t *testing.T) {
+	if s := fluxString("a\"b\\c${d}\n"); s != `"a\"b\\c\${d}\n"` {
+		t.Errorf("unexpected flux string %s", s)
+	}
+}
@@ -0,0 +1,129 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+)
+
+// Reconfigure applies the collection interval, the metric groups, the tags and
+// ExpvarExclude of config without restarting the collector; the other fields of
+// config are ignored. config is validated and defaulted like the one given to
+// RunCollector: a zero CollectionInterval is the default interval, not the
+// current one. Nothing is applied if config is invalid.
+//
+// The metric groups follow the Disable* and EnableRunQueue fields, contention
+// is enabled by a BlockProfileRate or MutexProfileFraction, which are applied
+// too. The static tags are those of Tags, Environment and DisableBuildTags.
+func (r *RunStats) Reconfigure(config *Config) error {
+	if config == nil {
+		return withKind(ErrInvalidConfig, errors.New("nil config"))
+	}
+	c := *config
+	config, err := c.init()
+	if err != nil {
+		return err
+	}
+
+	r.configMu.Lock()
+	defer r.configMu.Unlock()
+
+	r.setIntervals(config.CollectionInterval, config.FleetReducedInterval)
+
+	if config.BlockProfileRate != r.config.BlockProfileRate {
+		runtime.SetBlockProfileRate(config.BlockProfileRate)
+	}
+	if config.MutexProfileFraction != r.config.MutexProfileFraction {
+		runtime.SetMutexProfileFraction(config.MutexProfileFraction)
+	}
+	for name, enabled := range map[string]bool{
+		collector.GroupCPU:        !config.DisableCpu,
+		collector.GroupMem:        !config.DisableMem,
+		collector.GroupGC:         !config.DisableGc,
+		collector.GroupProcess:    !config.DisableProcess,
+		collector.GroupRunQueue:   config.EnableRunQueue,
+		collector.GroupScheduler:  !config.DisableScheduler,
+		collector.GroupContention: config.BlockProfileRate > 0 || config.MutexProfileFraction > 0,
+	} {
+		r.collector.SetGroup(name, enabled)
+	}
+
+	// The fleet sample is kept, so is its tag
+	config.fleetSampled = r.config.fleetSampled
+	r.tags = config.staticTags()
+	r.config.Tags = config.Tags
+	r.config.Environment = config.Environment
+	r.config.DisableBuildTags = config.DisableBuildTags
+	r.config.ExpvarExclude = config.ExpvarExclude
+	r.config.DisableCpu = config.DisableCpu
+	r.config.DisableMem = config.DisableMem
+	r.config.DisableGc = config.DisableGc
+	r.config.DisableProcess = config.DisableProcess
+	r.config.EnableRunQueue = config.EnableRunQueue
+	r.config.DisableScheduler = config.DisableScheduler
+	r.config.BlockProfileRate = config.BlockProfileRate
+	r.config.MutexProfileFraction = config.MutexProfileFraction
+	return nil
+}
+
+// setIntervals sets CollectionInterval and FleetReducedInterval and collects
+// every one of them, depending on whether the instance is in the fleet sample.
+// r.configMu must be held.
+func (r *RunStats) setIntervals(collection, fleetReduced time.Duration) {
+	r.config.CollectionInterval = collection
+	r.config.FleetReducedInterval = fleetReduced
+	interval := collection
+	if !r.config.fleetSampled {
+		interval = fleetReduced
+	}
+	if interval != r.collector.Interval() {
+		r.collector.SetInterval(interval)
+	}
+}
+
+// WatchConfigFile checks path every interval and, when the file changed, loads
+// it with LoadConfig and applies it with Reconfigure, until ctx is done or r is
+// closed. Errors are reported to the logger and leave the configuration as is.
+func (r *RunStats) WatchConfigFile(ctx context.Context, path string, interval time.Duration) {
+	var modTime time.Time
+	var size int64
+	if info, err := os.Stat(path); err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	}
+
+	go func() {
+		tick := time.NewTicker(interval)
+		defer tick.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-r.done:
+				return
+			case <-tick.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				r.logError("runstats: failed to watch config file:", err)
+				continue
+			}
+			if info.ModTime().Equal(modTime) && info.Size() == size {
+				continue
+			}
+			modTime, size = info.ModTime(), info.Size()
+
+			config, err := LoadConfig(path)
+			if err == nil {
+				err = r.Reconfigure(config)
+			}
+			if err != nil {
+				r.logError("runstats: failed to reload config file:", err)
+			}
+		}
+	}()
+}
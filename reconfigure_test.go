@@ -0,0 +1,80 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+)
+
+func TestReconfigure(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{Environment: "staging"})
+
+	err := stats.Reconfigure(&Config{
+		CollectionInterval: time.Minute,
+		DisableGc:          true,
+		EnableRunQueue:     true,
+		Environment:        "prod",
+		Tags:               map[string]string{"service": "checkout"},
+		ExpvarExclude:      []string{"cmdline"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.collector.Interval() != time.Minute {
+		t.Errorf("expected the new interval got %v", stats.collector.Interval())
+	}
+	groups := stats.Groups()
+	if groups[collector.GroupGC] || !groups[collector.GroupRunQueue] || !groups[collector.GroupCPU] {
+		t.Errorf("unexpected groups %v", groups)
+	}
+	if stats.effectiveConfig().ExpvarExclude[0] != "cmdline" {
+		t.Error("expected the new expvar exclusions")
+	}
+
+	stats.WritePoint("app.orders", nil, map[string]interface{}{"count": 1})
+	points := tr.Points(stats)
+	if tags := points[len(points)-1].Tags; tags["env"] != "prod" || tags["service"] != "checkout" {
+		t.Errorf("expected the new tags got %v", tags)
+	}
+
+	if err := stats.Reconfigure(&Config{CollectionInterval: time.Second, SchemaVersion: -1}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected an invalid config error got %v", err)
+	}
+	if stats.collector.Interval() != time.Minute {
+		t.Error("expected an invalid config not to be applied")
+	}
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "runstats.json")
+	if err := ioutil.WriteFile(path, []byte(`{"collection_interval": "10s"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, _ := newTestRunStats(nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stats.WatchConfigFile(ctx, path, time.Millisecond)
+
+	if err := ioutil.WriteFile(path, []byte(`{"collection_interval": "1m", "disable_mem": true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for stats.collector.Interval() != time.Minute || stats.Groups()[collector.GroupMem] {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the changed file to be applied got %v %v", stats.collector.Interval(), stats.Groups())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
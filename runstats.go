@@ -2,22 +2,57 @@ package runstats
 
 import (
 	"context"
+	"fmt"
 	"log"
-	"os"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	influxdb2 "github.com/influxdata/influxdb-client-go/v2"
-	"github.com/influxdata/influxdb-client-go/v2/api"
 	"github.com/nzlov/go-runtime-metrics/collector"
-	"github.com/pkg/errors"
+	"github.com/nzlov/go-runtime-metrics/point"
 )
 
 const (
-	defaultHost               = "localhost:8086"
-	defaultMeasurement        = "go.runtime"
-	defaultBucket             = "go"
-	defaultOrg                = "metrics"
-	defaultCollectionInterval = 10 * time.Second
+	defaultHost                     = "localhost:8086"
+	defaultMeasurement              = "go.runtime"
+	defaultAnnotation               = "go.runtime.events"
+	defaultChildMeasurement         = "go.runtime.children"
+	defaultExpvarMeasurement        = "go.runtime.expvar"
+	defaultGoroutineMeasurement     = "go.runtime.goroutines"
+	defaultAllocMeasurement         = "go.runtime.allocs"
+	defaultHeapProfileInterval      = time.Minute
+	defaultStackMeasurement         = "go.runtime.stacks"
+	defaultWatchdogMeasurement      = "go.runtime.watchdog"
+	defaultCertMeasurement          = "go.runtime.certs"
+	defaultConfigMeasurement        = "go.runtime.config"
+	defaultAppMeasurement           = "go.runtime.app"
+	defaultWriterMeasurement        = "go.runtime.writer"
+	defaultGCExperimentMeasurement  = "go.runtime.gc_experiment"
+	defaultGoroutineProfileInterval = time.Minute
+	defaultHTTPMeasurement          = "go.runtime.http"
+	defaultFailbackInterval         = 30 * time.Second
+	defaultPushgatewayJob           = "go_runtime"
+	defaultJobMeasurement           = "go.runtime.jobs"
+	defaultJobInterval              = 100 * time.Millisecond
+	defaultApdexTarget              = 250 * time.Millisecond
+	defaultBucket                   = "go"
+	defaultOrg                      = "metrics"
+	defaultCollectionInterval       = 10 * time.Second
+	defaultPauseQuantile            = 0.99
+	defaultPauseWindow              = time.Minute
+	defaultShutdownTimeout          = 5 * time.Second
+	defaultTraceMinAge              = 10 * time.Second
+	defaultTagBuckets               = 64
+	defaultFleetReducedInterval     = time.Minute
+	defaultOutputFileMaxSize        = 100 << 20
+	defaultKafkaTopic               = "go.runtime"
+	defaultNatsSubject              = "go.runtime"
+	defaultOutputFileMaxBackups     = 3
+	defaultDeltaKeyframeInterval    = 60
 )
 
 // A configuration with default values.
@@ -28,23 +63,497 @@ type Config struct {
 	// Default is "localhost:8086".
 	Host string `json:"host" yaml:"host" mapstructure:"host"`
 
+	// Further InfluxDb host:port pairs in order of preference. Writes fail over to
+	// the next host when one is unavailable and fail back once it recovers. All
+	// hosts share Token, Org and Bucket.
+	// Default is []
+	FailoverHosts []string `json:"failover_hosts" yaml:"failover_hosts" mapstructure:"failover_hosts"`
+
+	// How long an unavailable host is skipped before it is health checked and
+	// tried again.
+	// Default is 30 seconds
+	FailbackInterval time.Duration `json:"failback_interval" yaml:"failback_interval" mapstructure:"failback_interval"`
+
+	// Give this RunStats an InfluxDB client of its own. By default the RunStats of
+	// a process writing to the same hosts, org and bucket with the same token share
+	// one client, and batches written at the same time go out as one request.
+	// Default is false
+	DisableSharedClient bool `json:"disable_shared_client" yaml:"disable_shared_client" mapstructure:"disable_shared_client"`
+
 	// Token.
 	Token string `json:"token" yaml:"token" mapstructure:"token"`
 
+	// Authorizes every request to the backend instead of the static Token, e.g.
+	// FileCredentials, EnvCredentials or SigV4Credentials.
+	Credentials CredentialsProvider `json:"-" yaml:"-" mapstructure:"-"`
+
+	// HTTP basic authentication for a reverse proxy or API gateway in front of the
+	// backend. It replaces the Token's Authorization header; ignored if
+	// Credentials is set.
+	// Default is "" (disabled)
+	Username string `json:"username" yaml:"username" mapstructure:"username"`
+	Password string `json:"password" yaml:"password" mapstructure:"password"`
+
+	// Static HTTP headers added to every request to the backend.
+	// Default is {}
+	Headers map[string]string `json:"headers" yaml:"headers" mapstructure:"headers"`
+
 	// Org.
 	Org string `json:"org" yaml:"org" mapstructure:"org"`
 
+	// Called after every batch of points confirmed written by the backend, from the
+	// writer's goroutine, so it must not block.
+	OnWritten func(ack WriteAck) `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Called with the errors that would otherwise only reach the Logger: failed
+	// writes, including those kept for retry, dropped points and failures of the
+	// collector and the optional features, so they can be fed to the alerting of
+	// the application. The errors wrap their cause, see ErrBackendUnavailable. It
+	// is called from the writer's and the collector's goroutines, so it must not
+	// block.
+	// Default is nil
+	OnError func(err error) `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Number of points of failed writes kept in memory and retried with the next
+	// flush, e.g. to ride out a backend outage. When full the oldest points are
+	// dropped. Points rejected by the backend or failing authentication aren't
+	// retried, see RunStats.WriteErrors.
+	// Default is 0 (failed writes are dropped)
+	RetryBufferSize int `json:"retry_buffer_size" yaml:"retry_buffer_size" mapstructure:"retry_buffer_size"`
+
+	// Number of times a point of a failed write is retried before it is dropped,
+	// within the RetryBufferSize.
+	// Default is 0 (retried until written, expired or pushed out of the buffer)
+	MaxRetries int `json:"max_retries" yaml:"max_retries" mapstructure:"max_retries"`
+
+	// Maximum number of points written at once. Reaching it flushes the pending
+	// points right away rather than at the next FlushInterval.
+	// Default is 1000.
+	BatchSize int `json:"batch_size" yaml:"batch_size" mapstructure:"batch_size"`
+
+	// How often the pending points are written to the backend. Longer intervals
+	// make fewer, larger writes at the cost of latency.
+	// Default is 1 second
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval" mapstructure:"flush_interval"`
+
+	// Points older than this are dropped instead of written, so points buffered
+	// during an outage aren't replayed hours later. Dropped points are counted by
+	// RunStats.Dropped.
+	// Default is 0 (no limit)
+	MaxPointAge time.Duration `json:"max_point_age" yaml:"max_point_age" mapstructure:"max_point_age"`
+
+	// Measurement to write the failed writes by class and the dropped points to,
+	// once a write failed.
+	// Default is "go.runtime.writer".
+	WriterMeasurement string `json:"writer_measurement" yaml:"writer_measurement" mapstructure:"writer_measurement"`
+
+	// Measurement to write the phases of RunStats.RunGCExperiment to.
+	// Default is "go.runtime.gc_experiment".
+	GCExperimentMeasurement string `json:"gc_experiment_measurement" yaml:"gc_experiment_measurement" mapstructure:"gc_experiment_measurement"`
+
+	// Maximum bytes written to the backend per CollectionInterval, estimated by
+	// the line protocol size of the points. Over budget only the CriticalFields of
+	// a point are written and points without any are dropped, see
+	// RunStats.DroppedFields.
+	// Default is 0 (unlimited)
+	MaxBytesPerInterval int `json:"max_bytes_per_interval" yaml:"max_bytes_per_interval" mapstructure:"max_bytes_per_interval"`
+
+	// Fields still written when MaxBytesPerInterval is exceeded, e.g.
+	// ["mem.heap.alloc", "mem.gc.pause"].
+	// Default is []
+	CriticalFields []string `json:"critical_fields" yaml:"critical_fields" mapstructure:"critical_fields"`
+
+	// Tags to add, rename or strip on the points written to InfluxDB.
+	// Default is nil
+	InfluxTags *TagRules `json:"influx_tags" yaml:"influx_tags" mapstructure:"influx_tags"`
+
+	// Custom backend to write points to instead of InfluxDB or the Pushgateway.
+	// Default is nil (disabled)
+	Sink Sink `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Further backends the points are fanned out to besides the one above, each
+	// with its own flush interval and batch size, e.g. InfluxDB every 10 seconds
+	// and an aggregated copy to CloudWatch every minute.
+	// Default is nil
+	Sinks []SinkConfig `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Prometheus Pushgateway URL, e.g. "http://pushgateway:9091". When set, points
+	// are pushed there instead of InfluxDB.
+	// Default is "" (disabled)
+	PushgatewayURL string `json:"pushgateway_url" yaml:"pushgateway_url" mapstructure:"pushgateway_url"`
+
+	// Pushgateway job label.
+	// Default is "go_runtime".
+	PushgatewayJob string `json:"pushgateway_job" yaml:"pushgateway_job" mapstructure:"pushgateway_job"`
+
+	// Additional Pushgateway grouping labels.
+	// Default is {"instance": <identity>}
+	PushgatewayGrouping map[string]string `json:"pushgateway_grouping" yaml:"pushgateway_grouping" mapstructure:"pushgateway_grouping"`
+
+	// StatsD or DogStatsD host:port to send points to over UDP instead of InfluxDB,
+	// e.g. "localhost:8125" for the Datadog agent or Telegraf's statsd input.
+	// Default is "" (disabled)
+	StatsdAddress string `json:"statsd_address" yaml:"statsd_address" mapstructure:"statsd_address"`
+
+	// StatsD line format, "statsd" or "dogstatsd". Only DogStatsD lines carry the
+	// point tags.
+	// Default is "statsd".
+	StatsdFormat string `json:"statsd_format" yaml:"statsd_format" mapstructure:"statsd_format"`
+
+	// Prefix of the StatsD metric names, e.g. "myapp.".
+	// Default is ""
+	StatsdPrefix string `json:"statsd_prefix" yaml:"statsd_prefix" mapstructure:"statsd_prefix"`
+
+	// Share of the StatsD counter increments sent, in (0, 1]. The server scales
+	// sampled counters back up; gauges are always sent.
+	// Default is 1.
+	StatsdSampleRate float64 `json:"statsd_sample_rate" yaml:"statsd_sample_rate" mapstructure:"statsd_sample_rate"`
+
+	// Graphite/Carbon host:port to write points to over TCP with the plaintext
+	// protocol instead of InfluxDB, e.g. "carbon:2003".
+	// Default is "" (disabled)
+	GraphiteAddress string `json:"graphite_address" yaml:"graphite_address" mapstructure:"graphite_address"`
+
+	// Prefix of the Graphite metric paths, e.g. "apps.myapp.".
+	// Default is ""
+	GraphitePrefix string `json:"graphite_prefix" yaml:"graphite_prefix" mapstructure:"graphite_prefix"`
+
+	// Append the point tags to the Graphite metric paths in the tagged series
+	// format of Graphite 1.1, e.g. "go.runtime.mem.heap.alloc;env=prod".
+	// Default is false
+	GraphiteTags bool `json:"graphite_tags" yaml:"graphite_tags" mapstructure:"graphite_tags"`
+
+	// How often points are flushed to Graphite.
+	// Default is 1 second
+	GraphiteFlushInterval time.Duration `json:"graphite_flush_interval" yaml:"graphite_flush_interval" mapstructure:"graphite_flush_interval"`
+
+	// Kafka producer to publish points with instead of writing them to InfluxDB,
+	// one message per point keyed by the instance identity. The producer carries
+	// the brokers, compression and delivery settings.
+	// Default is nil (disabled)
+	KafkaProducer KafkaProducer `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Kafka topic the points are published to.
+	// Default is "go.runtime"
+	KafkaTopic string `json:"kafka_topic" yaml:"kafka_topic" mapstructure:"kafka_topic"`
+
+	// Payload format of the Kafka messages, "json", "line" for InfluxDB line
+	// protocol, "cloudevents" for CloudEvents, "protobuf" for point.Protobuf or
+	// "delta" for point.NewDeltaEncoder.
+	// Default is "json"
+	KafkaFormat string `json:"kafka_format" yaml:"kafka_format" mapstructure:"kafka_format"`
+
+	// NATS connection to publish points with instead of writing them to InfluxDB,
+	// one message per point. A publisher implementing NatsAckPublisher waits for
+	// the JetStream acknowledgements.
+	// Default is nil (disabled)
+	NatsPublisher NatsPublisher `json:"-" yaml:"-" mapstructure:"-"`
+
+	// NATS subject the points are published to.
+	// Default is "go.runtime"
+	NatsSubject string `json:"nats_subject" yaml:"nats_subject" mapstructure:"nats_subject"`
+
+	// Payload format of the NATS messages, "json", "line" for InfluxDB line
+	// protocol, "cloudevents" for CloudEvents, "protobuf" for point.Protobuf or
+	// "delta" for point.NewDeltaEncoder.
+	// Default is "json"
+	NatsFormat string `json:"nats_format" yaml:"nats_format" mapstructure:"nats_format"`
+
+	// Socket to write points to in InfluxDB line protocol instead of InfluxDB over
+	// HTTP, e.g. "unix:///var/run/telegraf.sock" for the socket_listener input of
+	// a local Telegraf. The unix, unixgram, tcp and udp schemes are supported.
+	// Default is "" (disabled)
+	SocketURL string `json:"socket_url" yaml:"socket_url" mapstructure:"socket_url"`
+
+	// File to write points to instead of InfluxDB, or "-" for stdout, to debug
+	// what is collected, when no backend is reachable or for Telegraf's tail input.
+	// Default is "" (disabled)
+	OutputFile string `json:"output_file" yaml:"output_file" mapstructure:"output_file"`
+
+	// Format of OutputFile, "json" for newline-delimited JSON, "line" for
+	// InfluxDB line protocol, "cloudevents" for newline-delimited CloudEvents,
+	// "protobuf" for point.Protobuf or "delta" for point.NewDeltaEncoder.
+	// Default is "json"
+	OutputFormat string `json:"output_format" yaml:"output_format" mapstructure:"output_format"`
+
+	// Keyframe interval of the "delta" formats, see point.NewDeltaEncoder. A
+	// negative interval writes only the required keyframes.
+	// Default is 60
+	DeltaKeyframeInterval int `json:"delta_keyframe_interval" yaml:"delta_keyframe_interval" mapstructure:"delta_keyframe_interval"`
+
+	// Source attribute of the CloudEvents written with the "cloudevents" formats,
+	// a URI reference identifying the process.
+	// Default is "/go-runtime-metrics/<identity>"
+	CloudEventsSource string `json:"cloudevents_source" yaml:"cloudevents_source" mapstructure:"cloudevents_source"`
+
+	// Type attribute of the CloudEvents written with the "cloudevents" formats.
+	// Default is "com.github.nzlov.go-runtime-metrics.point"
+	CloudEventsType string `json:"cloudevents_type" yaml:"cloudevents_type" mapstructure:"cloudevents_type"`
+
+	// Size in bytes above which OutputFile is rotated.
+	// Default is 100 MiB
+	OutputFileMaxSize int64 `json:"output_file_max_size" yaml:"output_file_max_size" mapstructure:"output_file_max_size"`
+
+	// Number of rotated OutputFile files kept, named <OutputFile>.1 (the newest)
+	// and up.
+	// Default is 3
+	OutputFileMaxBackups int `json:"output_file_max_backups" yaml:"output_file_max_backups" mapstructure:"output_file_max_backups"`
+
+	// Tags to add, rename or strip on the points pushed to the Pushgateway, e.g.
+	// {"strip": ["pod.uid"]}.
+	// Default is nil
+	PushgatewayTags *TagRules `json:"pushgateway_tags" yaml:"pushgateway_tags" mapstructure:"pushgateway_tags"`
+
+	// Tags to add, rename or strip on the points written to the other backends:
+	// Sink, StatsD, Graphite, Kafka, NATS, SocketURL or OutputFile. The Sinks
+	// have their own SinkConfig.Tags.
+	// Default is nil
+	BackendTags *TagRules `json:"backend_tags" yaml:"backend_tags" mapstructure:"backend_tags"`
+
+	// Bearer token required by ConfigHandler.
+	// Default is "" (admin API disabled)
+	AdminToken string `json:"admin_token" yaml:"admin_token" mapstructure:"admin_token"`
+
 	// Bucket.
 	Bucket string `json:"bucket" yaml:"bucket" mapstructure:"bucket"`
 
 	// Measurement to write points to.
-	// Default is "go.runtime.<hostname>".
+	// Default is "go.runtime.<identity>".
 	Measurement string `json:"measurement" yaml:"measurement" mapstructure:"measurement"`
 
+	// Prefix prepended to the field names of every point, e.g. "myapp_", to avoid
+	// collisions between services sharing a bucket. CriticalFields must include
+	// it.
+	// Default is "" (none)
+	Prefix string `json:"prefix" yaml:"prefix" mapstructure:"prefix"`
+
+	// Measurement to write child process points to, see RunStats.Command.
+	// Default is "go.runtime.children".
+	ChildMeasurement string `json:"child_measurement" yaml:"child_measurement" mapstructure:"child_measurement"`
+
+	// Forward all published expvar Vars with numeric or boolean values to the
+	// ExpvarMeasurement every collection.
+	// Default is false
+	ForwardExpvar bool `json:"forward_expvar" yaml:"forward_expvar" mapstructure:"forward_expvar"`
+
+	// Measurement to write forwarded expvar Vars to.
+	// Default is "go.runtime.expvar".
+	ExpvarMeasurement string `json:"expvar_measurement" yaml:"expvar_measurement" mapstructure:"expvar_measurement"`
+
+	// Names of the expvar Vars not to forward.
+	// Default is ["cmdline", "memstats"]
+	ExpvarExclude []string `json:"expvar_exclude" yaml:"expvar_exclude" mapstructure:"expvar_exclude"`
+
+	// pprof label keys to count goroutines by, e.g. ["subsystem"]. Every collection
+	// a point per label value is written to the GoroutineMeasurement.
+	// Default is [] (disabled)
+	GoroutineLabels []string `json:"goroutine_labels" yaml:"goroutine_labels" mapstructure:"goroutine_labels"`
+
+	// Measurement to write goroutine label counts to.
+	// Default is "go.runtime.goroutines".
+	GoroutineMeasurement string `json:"goroutine_measurement" yaml:"goroutine_measurement" mapstructure:"goroutine_measurement"`
+
+	// Number of allocation sites with the most bytes in use to write to the
+	// AllocMeasurement, sampled from the heap profile.
+	// Default is 0 (disabled)
+	HeapTopN int `json:"heap_top_n" yaml:"heap_top_n" mapstructure:"heap_top_n"`
+
+	// How often to sample the heap profile for HeapTopN.
+	// Default is 1 minute
+	HeapProfileInterval time.Duration `json:"heap_profile_interval" yaml:"heap_profile_interval" mapstructure:"heap_profile_interval"`
+
+	// Measurement to write the top allocation sites to.
+	// Default is "go.runtime.allocs".
+	AllocMeasurement string `json:"alloc_measurement" yaml:"alloc_measurement" mapstructure:"alloc_measurement"`
+
+	// Number of goroutine call stacks shared by the most goroutines to write to the
+	// StackMeasurement, e.g. to tell where thousands of goroutines are stuck.
+	// Default is 0 (disabled)
+	GoroutineTopN int `json:"goroutine_top_n" yaml:"goroutine_top_n" mapstructure:"goroutine_top_n"`
+
+	// How often to fingerprint the goroutine stacks for GoroutineTopN.
+	// Default is 1 minute
+	GoroutineProfileInterval time.Duration `json:"goroutine_profile_interval" yaml:"goroutine_profile_interval" mapstructure:"goroutine_profile_interval"`
+
+	// Measurement to write the top goroutine stacks to.
+	// Default is "go.runtime.stacks".
+	StackMeasurement string `json:"stack_measurement" yaml:"stack_measurement" mapstructure:"stack_measurement"`
+
+	// Deadline for the collector loop to complete a collection, past which the
+	// watchdog raises an alarm, see RunStats.Watch. It must exceed the
+	// CollectionInterval, or the longest gap of the CollectionSchedule.
+	// Default is 0 (disabled)
+	WatchdogDeadline time.Duration `json:"watchdog_deadline" yaml:"watchdog_deadline" mapstructure:"watchdog_deadline"`
+
+	// Directory the stacks of all goroutines are dumped to when a watched
+	// goroutine stalls.
+	// Default is "" (disabled)
+	WatchdogStackDir string `json:"watchdog_stack_dir" yaml:"watchdog_stack_dir" mapstructure:"watchdog_stack_dir"`
+
+	// Measurement to write watchdog alarms to.
+	// Default is "go.runtime.watchdog".
+	WatchdogMeasurement string `json:"watchdog_measurement" yaml:"watchdog_measurement" mapstructure:"watchdog_measurement"`
+
+	// Measurement to write the expiry of the certificates watched with
+	// WatchCertificate to.
+	// Default is "go.runtime.certs".
+	CertMeasurement string `json:"cert_measurement" yaml:"cert_measurement" mapstructure:"cert_measurement"`
+
+	// Interval at which a fingerprint of the effective configuration, and of
+	// ConfigValues, is written to the ConfigMeasurement, so that configuration
+	// drift across a fleet shows up as differing fingerprints.
+	// Default is 0 (disabled)
+	ConfigSnapshotInterval time.Duration `json:"config_snapshot_interval" yaml:"config_snapshot_interval" mapstructure:"config_snapshot_interval"`
+
+	// Measurement to write the configuration fingerprints to.
+	// Default is "go.runtime.config".
+	ConfigMeasurement string `json:"config_measurement" yaml:"config_measurement" mapstructure:"config_measurement"`
+
+	// ConfigValues returns application settings to fingerprint along with the
+	// configuration of runstats. Only their hash is written, never the values.
+	// Default is nil
+	ConfigValues func() map[string]string `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Measurement to write the counters and gauges of RunStats.NewCounter,
+	// NewGauge and RegisterGauge to.
+	// Default is "go.runtime.app".
+	AppMeasurement string `json:"app_measurement" yaml:"app_measurement" mapstructure:"app_measurement"`
+
+	// Measurement StartJob writes the summary point of a Job to.
+	// Default is "go.runtime.jobs".
+	JobMeasurement string `json:"job_measurement" yaml:"job_measurement" mapstructure:"job_measurement"`
+
+	// Interval a Job collects at.
+	// Default is 100 milliseconds
+	JobInterval time.Duration `json:"job_interval" yaml:"job_interval" mapstructure:"job_interval"`
+
+	// Measurement to write the request statistics of Middleware handlers to.
+	// Default is "go.runtime.http".
+	HTTPMeasurement string `json:"http_measurement" yaml:"http_measurement" mapstructure:"http_measurement"`
+
+	// Reports whether the instance leads its replicas, see WriteLeaderPoint and
+	// LeaderFlag. Per-instance points are written by every replica regardless.
+	// Default is nil (every instance is the leader)
+	IsLeader LeaderFunc `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Extracts request-scoped tags (tenant, shard, ...) from the request context of
+	// Middleware handlers and AnnotateContext, in addition to tags set with WithTags.
+	ContextTags ContextTagsFunc `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Plugins collecting additional points every collection.
+	// Default is nil
+	Plugins []Plugin `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Buckets of the histogram of the time from collection to acknowledged write
+	// of the points, written to the WriterMeasurement every collection as the
+	// number of points written within each, e.g. "latency.le_5s".
+	// Default is [1s, 5s, 30s]
+	WriteLatencyBuckets []time.Duration `json:"write_latency_buckets" yaml:"write_latency_buckets" mapstructure:"write_latency_buckets"`
+
+	// Latency SLO buckets of Middleware handlers. Each is written as the number of
+	// requests served within it, e.g. "slo.le_250ms".
+	// Default is [100ms, 250ms, 1s]
+	HTTPBuckets []time.Duration `json:"http_buckets" yaml:"http_buckets" mapstructure:"http_buckets"`
+
+	// Apdex target latency T of Middleware handlers: requests up to T are
+	// satisfied, up to 4T tolerating.
+	// Default is 250 milliseconds
+	ApdexTarget time.Duration `json:"apdex_target" yaml:"apdex_target" mapstructure:"apdex_target"`
+
+	// Directory execution traces are written to when a trace trigger fires. Setting
+	// it starts the runtime/trace flight recorder (requires Go 1.25).
+	// Default is "" (disabled)
+	TraceDir string `json:"trace_dir" yaml:"trace_dir" mapstructure:"trace_dir"`
+
+	// How much execution trace the flight recorder keeps.
+	// Default is 10 seconds
+	TraceMinAge time.Duration `json:"trace_min_age" yaml:"trace_min_age" mapstructure:"trace_min_age"`
+
+	// Capture a trace when a GC pause exceeds this duration.
+	// Default is 0 (disabled)
+	TracePauseThreshold time.Duration `json:"trace_pause_threshold" yaml:"trace_pause_threshold" mapstructure:"trace_pause_threshold"`
+
+	// Capture a trace when the p99 scheduler latency of a collection interval
+	// exceeds this duration. Needs the scheduler stats, see DisableScheduler.
+	// Default is 0 (disabled)
+	TraceSchedLatencyThreshold time.Duration `json:"trace_sched_latency_threshold" yaml:"trace_sched_latency_threshold" mapstructure:"trace_sched_latency_threshold"`
+
+	// Additional triggers evaluated after every collection.
+	TraceTriggers []TraceTrigger `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Minimum time between two captures.
+	// Default is 1 minute
+	TraceCooldown time.Duration `json:"trace_cooldown" yaml:"trace_cooldown" mapstructure:"trace_cooldown"`
+
+	// Called with the path of every captured trace, e.g. to upload it to object
+	// storage.
+	OnTrace func(path string) `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Identity determines the instance identity used in the default measurement
+	// and in annotation tags.
+	// Default is HostnameIdentity.
+	Identity IdentityFunc `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Interval at which Identity is resolved again, so that e.g. a hostname changed
+	// by DHCP is picked up without a restart. The current identity is then tagged
+	// as "instance" on every point; the default measurement, the Pushgateway
+	// grouping, the Kafka key and the fleet sample keep the identity resolved at
+	// start.
+	// Default is 0 (resolved once)
+	IdentityRefreshInterval time.Duration `json:"identity_refresh_interval" yaml:"identity_refresh_interval" mapstructure:"identity_refresh_interval"`
+
+	// Measurement to write annotation (event) points to.
+	// Default is "go.runtime.events".
+	AnnotationMeasurement string `json:"annotation_measurement" yaml:"annotation_measurement" mapstructure:"annotation_measurement"`
+
 	// Interval at which to collect points.
 	// Default is 10 seconds
 	CollectionInterval time.Duration `json:"collection_interval" yaml:"collection_interval" mapstructure:"collection_interval"`
 
+	// Share of the fleet, in (0, 1], writing full resolution metrics. Instances are
+	// chosen by the hash of their identity (see Identity), the others only write
+	// the FleetReducedFields every FleetReducedInterval. Points are tagged
+	// "fleet.sampled" when set.
+	// Default is 1 (every instance)
+	FleetSampleRate float64 `json:"fleet_sample_rate" yaml:"fleet_sample_rate" mapstructure:"fleet_sample_rate"`
+
+	// Runtime fields written by the instances outside of the fleet sample.
+	// Default is cpu.goroutines, mem.heap.alloc, mem.gc.count, mem.gc.pause,
+	// proc.cpu.user, proc.cpu.system, proc.mem.rss and proc.mem.rss.peak
+	FleetReducedFields []string `json:"fleet_reduced_fields" yaml:"fleet_reduced_fields" mapstructure:"fleet_reduced_fields"`
+
+	// Collection interval of the instances outside of the fleet sample, unless a
+	// CollectionSchedule is set.
+	// Default is 1 minute
+	FleetReducedInterval time.Duration `json:"fleet_reduced_interval" yaml:"fleet_reduced_interval" mapstructure:"fleet_reduced_interval"`
+
+	// Align collections to wall clock multiples of CollectionInterval, e.g. on :00,
+	// :10, :20 seconds for 10 seconds, and timestamp points with the boundary, so
+	// points of many hosts share timestamps.
+	// Default is false
+	AlignCollection bool `json:"align_collection" yaml:"align_collection" mapstructure:"align_collection"`
+
+	// Delay before the first collection.
+	// Default is 0
+	StartDelay time.Duration `json:"start_delay" yaml:"start_delay" mapstructure:"start_delay"`
+
+	// Points collected within this window after start are tagged warmup=true, so
+	// alerting queries can exclude the noise of process initialization with
+	// WHERE "warmup" != 'true'.
+	// Default is 0 (disabled)
+	WarmupWindow time.Duration `json:"warmup_window" yaml:"warmup_window" mapstructure:"warmup_window"`
+
+	// Time allowed to flush pending points when the context passed to RunCollector
+	// is done.
+	// Default is 5 seconds
+	ShutdownTimeout time.Duration `json:"shutdown_timeout" yaml:"shutdown_timeout" mapstructure:"shutdown_timeout"`
+
+	// Cron expression(s) determining when to collect points, used instead of
+	// CollectionInterval when set, e.g. "* 9-17 * * 1-5; */10 0-8,18-23 * * *" for
+	// every minute during business hours and every 10 minutes overnight. See
+	// collector.ParseCron.
+	Schedule string `json:"schedule" yaml:"schedule" mapstructure:"schedule"`
+
 	// Disable collecting CPU Statistics. cpu.*
 	// Default is false
 	DisableCpu bool `json:"disable_cpu" yaml:"disable_cpu" mapstructure:"disable_cpu"`
@@ -54,6 +563,137 @@ type Config struct {
 
 	// Disable collecting GC Statistics (requires Memory be not be disabled). mem.gc.*
 	DisableGc bool `json:"disable_gc" yaml:"disable_gc" mapstructure:"disable_gc"`
+
+	// Disable collecting process statistics (CPU time, memory, file descriptors,
+	// threads, context switches and uptime). proc.*
+	// Default is false
+	DisableProcess bool `json:"disable_process" yaml:"disable_process" mapstructure:"disable_process"`
+
+	// Set GOMAXPROCS to the container CPU quota before collecting, unless the
+	// GOMAXPROCS environment variable is set.
+	// Default is false
+	AdjustMaxProcs bool `json:"adjust_maxprocs" yaml:"adjust_maxprocs" mapstructure:"adjust_maxprocs"`
+
+	// Set GOMEMLIMIT to MemoryLimitRatio of the container memory limit before
+	// collecting, unless the GOMEMLIMIT environment variable is set. Requires Go 1.19.
+	// Default is false
+	ApplyMemoryLimit bool `json:"apply_memory_limit" yaml:"apply_memory_limit" mapstructure:"apply_memory_limit"`
+
+	// Share of the container memory limit recommended (and applied) as GOMEMLIMIT.
+	// Default is 0.9
+	MemoryLimitRatio float64 `json:"memory_limit_ratio" yaml:"memory_limit_ratio" mapstructure:"memory_limit_ratio"`
+
+	// Number of GC cycles the live heap trend is computed over.
+	// Default is 30
+	LeakWindow int `json:"leak_window" yaml:"leak_window" mapstructure:"leak_window"`
+
+	// Leak score (0-1) at or above which OnLeak is called.
+	// Default is 0.9
+	LeakThreshold float64 `json:"leak_threshold" yaml:"leak_threshold" mapstructure:"leak_threshold"`
+
+	// Called after each collection whose leak score reached LeakThreshold.
+	OnLeak collector.LeakFunc `json:"-" yaml:"-" mapstructure:"-"`
+
+	// GC pause budget, e.g. 5ms for a p99 <= 5ms per minute SLO. Setting it enables
+	// the mem.gc.slo.* fields.
+	// Default is 0 (disabled)
+	PauseTarget time.Duration `json:"pause_target" yaml:"pause_target" mapstructure:"pause_target"`
+
+	// Quantile of the GC pauses that must not exceed PauseTarget, in (0, 1].
+	// Default is 0.99
+	PauseQuantile float64 `json:"pause_quantile" yaml:"pause_quantile" mapstructure:"pause_quantile"`
+
+	// Window the GC pause budget applies to.
+	// Default is 1 minute
+	PauseWindow time.Duration `json:"pause_window" yaml:"pause_window" mapstructure:"pause_window"`
+
+	// Enable collecting scheduler run queue statistics. sched.runqueue*
+	// Default is false
+	EnableRunQueue bool `json:"enable_runqueue" yaml:"enable_runqueue" mapstructure:"enable_runqueue"`
+
+	// Count the runnable goroutines of the run queue statistics in a goroutine
+	// stack dump, which stops the world, on Go versions whose runtime/metrics
+	// don't expose them. Without it the statistics are left out there.
+	// Default is false
+	RunQueueStackFallback bool `json:"runqueue_stack_fallback" yaml:"runqueue_stack_fallback" mapstructure:"runqueue_stack_fallback"`
+
+	// Disable collecting scheduler thread, goroutine state and latency statistics.
+	// sched.threads, sched.goroutines.*, sched.latency.*
+	// Default is false
+	DisableScheduler bool `json:"disable_scheduler" yaml:"disable_scheduler" mapstructure:"disable_scheduler"`
+
+	// Block profile rate set with runtime.SetBlockProfileRate: on average one
+	// blocking event per rate nanoseconds spent blocked is recorded, 1 recording
+	// all of them. Setting it or MutexProfileFraction enables the contention.*
+	// fields with the totals of the profiles.
+	// Default is 0 (disabled)
+	BlockProfileRate int `json:"block_profile_rate" yaml:"block_profile_rate" mapstructure:"block_profile_rate"`
+
+	// Mutex profile fraction set with runtime.SetMutexProfileFraction: on average
+	// 1/fraction of the mutex contention events are recorded.
+	// Default is 0 (disabled)
+	MutexProfileFraction int `json:"mutex_profile_fraction" yaml:"mutex_profile_fraction" mapstructure:"mutex_profile_fraction"`
+
+	// Collect every runtime/metrics metric, histograms included, instead of the
+	// runtime.MemStats based mem.* fields, which stop the world to read.
+	// Default is false
+	RuntimeMetrics bool `json:"runtime_metrics" yaml:"runtime_metrics" mapstructure:"runtime_metrics"`
+
+	// Environment added as the "env" tag to every point, e.g. "prod".
+	Environment string `json:"environment" yaml:"environment" mapstructure:"environment"`
+
+	// Tags added to every point, e.g. {"service": "checkout", "region":
+	// "us-east-1"}. They take precedence over the build tags and Environment, but
+	// not over the tags of a point itself.
+	// Default is nil
+	Tags map[string]string `json:"tags" yaml:"tags" mapstructure:"tags"`
+
+	// URL of the net/http/pprof index of the instance, tagged as "pprof.url" on the
+	// runtime points so that dashboards can link a panel to live profiles of the
+	// instance. "{instance}" is replaced by the identity, e.g.
+	// "http://{instance}:6060/debug/pprof/".
+	// Default is "" (not tagged)
+	PprofURL string `json:"pprof_url" yaml:"pprof_url" mapstructure:"pprof_url"`
+
+	// Providers of tags that change at runtime, e.g. the leader or follower role,
+	// evaluated at the start of every collection and added to the points written
+	// until the next one. They take precedence over Tags.
+	// Default is nil
+	TagProviders []TagProvider `json:"-" yaml:"-" mapstructure:"-"`
+
+	// Disable tagging every point with the main module version (app.version) and
+	// short VCS revision (app.revision).
+	// Default is false
+	DisableBuildTags bool `json:"disable_build_tags" yaml:"disable_build_tags" mapstructure:"disable_build_tags"`
+
+	// Salt of RunStats.BucketTag. Share it between instances so that a value maps
+	// to the same tag everywhere, and keep it secret when bucketing personal data.
+	// Default is ""
+	TagSalt string `json:"tag_salt" yaml:"tag_salt" mapstructure:"tag_salt"`
+
+	// Number of tag values RunStats.BucketTag maps to.
+	// Default is 64.
+	TagBuckets int `json:"tag_buckets" yaml:"tag_buckets" mapstructure:"tag_buckets"`
+
+	// Schema version of the runtime field names to write, to upgrade this package
+	// before migrating dashboards to renamed fields, see collector.Migrations.
+	// Runtime points are tagged "schema.version" with it.
+	// Default is collector.SchemaVersion
+	SchemaVersion int `json:"schema_version" yaml:"schema_version" mapstructure:"schema_version"`
+
+	// Disable writing start/stop deployment marker annotations.
+	// Default is false
+	DisableMarkers bool `json:"disable_markers" yaml:"disable_markers" mapstructure:"disable_markers"`
+
+	// instance is the resolved Identity.
+	instance string
+
+	// schedule is the parsed Schedule.
+	schedule collector.Schedule
+
+	// fleetSampled is whether the instance writes full resolution metrics, see
+	// FleetSampleRate.
+	fleetSampled bool
 }
 
 func (config *Config) init() (*Config, error) {
@@ -72,72 +712,508 @@ func (config *Config) init() (*Config, error) {
 		config.Host = defaultHost
 	}
 
+	if config.Identity == nil {
+		config.Identity = HostnameIdentity
+	}
+	if config.IdentityRefreshInterval > 0 {
+		config.Identity = CachedIdentity(config.Identity, config.IdentityRefreshInterval)
+	}
+
+	if id, err := config.Identity(); err != nil || id == "" {
+		config.instance = "unknown"
+	} else {
+		config.instance = id
+	}
+
 	if config.Measurement == "" {
-		config.Measurement = defaultMeasurement
+		config.Measurement = defaultMeasurement + "." + config.instance
+	}
 
-		if hn, err := os.Hostname(); err != nil {
-			config.Measurement += ".unknown"
-		} else {
-			config.Measurement += "." + hn
+	if config.AnnotationMeasurement == "" {
+		config.AnnotationMeasurement = defaultAnnotation
+	}
+
+	if config.PauseQuantile == 0 {
+		config.PauseQuantile = defaultPauseQuantile
+	}
+	if !(config.PauseQuantile > 0 && config.PauseQuantile <= 1) {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("pause quantile %v out of (0, 1]", config.PauseQuantile))
+	}
+	if config.PauseWindow == 0 {
+		config.PauseWindow = defaultPauseWindow
+	}
+
+	if config.ChildMeasurement == "" {
+		config.ChildMeasurement = defaultChildMeasurement
+	}
+
+	if config.ExpvarMeasurement == "" {
+		config.ExpvarMeasurement = defaultExpvarMeasurement
+	}
+	if config.ExpvarExclude == nil {
+		config.ExpvarExclude = defaultExpvarExclude
+	}
+
+	if config.GoroutineMeasurement == "" {
+		config.GoroutineMeasurement = defaultGoroutineMeasurement
+	}
+
+	if config.HeapProfileInterval == 0 {
+		config.HeapProfileInterval = defaultHeapProfileInterval
+	}
+	if config.AllocMeasurement == "" {
+		config.AllocMeasurement = defaultAllocMeasurement
+	}
+
+	if config.GoroutineProfileInterval == 0 {
+		config.GoroutineProfileInterval = defaultGoroutineProfileInterval
+	}
+	if config.StackMeasurement == "" {
+		config.StackMeasurement = defaultStackMeasurement
+	}
+
+	if config.WatchdogMeasurement == "" {
+		config.WatchdogMeasurement = defaultWatchdogMeasurement
+	}
+	if config.CertMeasurement == "" {
+		config.CertMeasurement = defaultCertMeasurement
+	}
+	if config.ConfigMeasurement == "" {
+		config.ConfigMeasurement = defaultConfigMeasurement
+	}
+	if config.AppMeasurement == "" {
+		config.AppMeasurement = defaultAppMeasurement
+	}
+
+	if config.BatchSize == 0 {
+		config.BatchSize = defaultBatchSize
+	}
+	if config.FlushInterval == 0 {
+		config.FlushInterval = defaultFlushInterval
+	}
+	if config.BatchSize < 0 || config.FlushInterval < 0 || config.MaxRetries < 0 {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("batch size, flush interval and max retries must not be negative"))
+	}
+	for i := range config.Sinks {
+		if err := config.Sinks[i].validate(i); err != nil {
+			return nil, err
+		}
+	}
+	if config.WriterMeasurement == "" {
+		config.WriterMeasurement = defaultWriterMeasurement
+	}
+	if config.GCExperimentMeasurement == "" {
+		config.GCExperimentMeasurement = defaultGCExperimentMeasurement
+	}
+
+	if config.FailbackInterval == 0 {
+		config.FailbackInterval = defaultFailbackInterval
+	}
+
+	if config.PushgatewayJob == "" {
+		config.PushgatewayJob = defaultPushgatewayJob
+	}
+	if config.PushgatewayGrouping == nil {
+		config.PushgatewayGrouping = map[string]string{"instance": config.instance}
+	}
+
+	if config.StatsdFormat == "" {
+		config.StatsdFormat = StatsdFormatPlain
+	}
+	if config.StatsdFormat != StatsdFormatPlain && config.StatsdFormat != StatsdFormatDatadog {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("unknown statsd format %q", config.StatsdFormat))
+	}
+	if config.StatsdSampleRate == 0 {
+		config.StatsdSampleRate = 1
+	}
+	if config.StatsdSampleRate < 0 || config.StatsdSampleRate > 1 {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("statsd sample rate %v out of (0, 1]", config.StatsdSampleRate))
+	}
+
+	if config.FleetSampleRate == 0 {
+		config.FleetSampleRate = 1
+	}
+	if config.FleetSampleRate < 0 || config.FleetSampleRate > 1 {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("fleet sample rate %v out of (0, 1]", config.FleetSampleRate))
+	}
+	if config.FleetReducedFields == nil {
+		config.FleetReducedFields = defaultFleetReducedFields
+	}
+	if config.FleetReducedInterval == 0 {
+		config.FleetReducedInterval = defaultFleetReducedInterval
+	}
+	config.fleetSampled = fleetSampled(config.instance, config.FleetSampleRate)
+
+	if config.SchemaVersion == 0 {
+		config.SchemaVersion = collector.SchemaVersion
+	}
+	if config.SchemaVersion < 1 || config.SchemaVersion > collector.SchemaVersion {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("unknown schema version %d", config.SchemaVersion))
+	}
+
+	if config.PprofURL != "" {
+		if _, err := url.Parse(strings.ReplaceAll(config.PprofURL, "{instance}", config.instance)); err != nil {
+			return nil, withKind(ErrInvalidConfig, err)
 		}
 	}
+	for k := range config.Tags {
+		if k == "" {
+			return nil, withKind(ErrInvalidConfig, fmt.Errorf("empty tag key"))
+		}
+	}
+	if config.TagBuckets == 0 {
+		config.TagBuckets = defaultTagBuckets
+	}
+
+	if config.OutputFormat == "" {
+		config.OutputFormat = OutputFormatJSON
+	}
+	if !validOutputFormat(config.OutputFormat) {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("unknown output format %q", config.OutputFormat))
+	}
+	if config.KafkaTopic == "" {
+		config.KafkaTopic = defaultKafkaTopic
+	}
+	if config.KafkaFormat == "" {
+		config.KafkaFormat = OutputFormatJSON
+	}
+	if !validOutputFormat(config.KafkaFormat) {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("unknown kafka format %q", config.KafkaFormat))
+	}
+	if config.NatsSubject == "" {
+		config.NatsSubject = defaultNatsSubject
+	}
+	if config.NatsFormat == "" {
+		config.NatsFormat = OutputFormatJSON
+	}
+	if config.DeltaKeyframeInterval == 0 {
+		config.DeltaKeyframeInterval = defaultDeltaKeyframeInterval
+	}
+	if !validOutputFormat(config.NatsFormat) {
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("unknown nats format %q", config.NatsFormat))
+	}
+
+	if config.CloudEventsSource == "" {
+		config.CloudEventsSource = "/go-runtime-metrics/" + url.PathEscape(config.instance)
+	}
+	if config.CloudEventsType == "" {
+		config.CloudEventsType = point.DefaultCloudEventsType
+	}
+
+	if config.OutputFileMaxSize == 0 {
+		config.OutputFileMaxSize = defaultOutputFileMaxSize
+	}
+	if config.OutputFileMaxBackups == 0 {
+		config.OutputFileMaxBackups = defaultOutputFileMaxBackups
+	}
+
+	if config.JobMeasurement == "" {
+		config.JobMeasurement = defaultJobMeasurement
+	}
+	if config.JobInterval == 0 {
+		config.JobInterval = defaultJobInterval
+	}
+
+	if config.HTTPMeasurement == "" {
+		config.HTTPMeasurement = defaultHTTPMeasurement
+	}
+	if config.WriteLatencyBuckets == nil {
+		config.WriteLatencyBuckets = defaultWriteLatencyBuckets
+	}
+	if config.HTTPBuckets == nil {
+		config.HTTPBuckets = defaultHTTPBuckets
+	}
+	if config.ApdexTarget == 0 {
+		config.ApdexTarget = defaultApdexTarget
+	}
 
 	if config.CollectionInterval == 0 {
 		config.CollectionInterval = defaultCollectionInterval
 	}
 
+	if config.TraceMinAge == 0 {
+		config.TraceMinAge = defaultTraceMinAge
+	}
+	if config.TraceCooldown == 0 {
+		config.TraceCooldown = defaultTraceCooldown
+	}
+
+	if config.ShutdownTimeout == 0 {
+		config.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	if config.Schedule != "" {
+		schedule, err := collector.ParseCron(config.Schedule)
+		if err != nil {
+			return nil, withKind(ErrInvalidConfig, err)
+		}
+		config.schedule = schedule
+	}
+
 	return config, nil
 }
 
-func RunCollector(ctx context.Context, config *Config) (*RunStats, error) {
-	var err error
-	if config, err = config.init(); err != nil {
+// RunCollector starts collecting the runtime statistics until ctx is done or
+// Close is called. It is configured with a *Config, nil for DefaultConfig, or
+// with options:
+//
+//	metrics.RunCollector(ctx, metrics.WithHost("influx:8086"), metrics.WithToken(token))
+func RunCollector(ctx context.Context, opts ...Option) (*RunStats, error) {
+	config, err := newConfig(opts).init()
+	if err != nil {
 		return nil, err
 	}
 
-	// Make client
-	client := influxdb2.NewClient(config.Host, config.Token)
-	// always close client at the end
+	if config.AdjustMaxProcs {
+		collector.AdjustMaxProcs()
+	}
 
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to create influxdb client")
+	if config.BlockProfileRate > 0 {
+		runtime.SetBlockProfileRate(config.BlockProfileRate)
+	}
+	if config.MutexProfileFraction > 0 {
+		runtime.SetMutexProfileFraction(config.MutexProfileFraction)
+	}
+
+	if config.ApplyMemoryLimit {
+		collector.ApplyMemoryLimit(config.MemoryLimitRatio)
 	}
 
-	// Ping InfluxDB to ensure there is a connection
-	if _, err := client.Ready(context.Background()); err != nil {
-		return nil, errors.Wrap(err, "influxdb no ready")
+	var _tracer *tracer
+	if config.TraceDir != "" {
+		if _tracer, err = newTracer(config); err != nil {
+			return nil, err
+		}
+	}
+
+	t, err := newTransport(config)
+	if err != nil {
+		if _tracer != nil {
+			_tracer.recorder.stop()
+		}
+		return nil, err
 	}
 
 	_runStats := &RunStats{
-		client: client,
-		config: config,
-		write:  client.WriteAPI(config.Org, config.Bucket),
+		config:  config,
+		writer:  newWriter(t, config.BatchSize, config.flushInterval()),
+		tags:    config.staticTags(),
+		started: time.Now(),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		tracer:  _tracer,
+		latency: newWriteLatency(config.WriteLatencyBuckets),
+	}
+	_runStats.writer.onError = _runStats.writeError
+	_runStats.writer.onRetry = _runStats.retryError
+	_runStats.writer.retryLimit = config.RetryBufferSize
+	_runStats.writer.maxRetries = config.MaxRetries
+	_runStats.writer.onWritten = _runStats.onWritten
+	_runStats.writer.maxAge = config.MaxPointAge
+	_runStats.writer.prefix = config.Prefix
+	_runStats.writer.fanOut(config.Sinks)
+	if config.MaxBytesPerInterval > 0 {
+		_runStats.writer.budget = newBudget(config.MaxBytesPerInterval, config.CollectionInterval, config.CriticalFields)
 	}
 
 	_collector := collector.New(_runStats.onNewPoint)
 	_collector.PauseDur = config.CollectionInterval
+	if !config.fleetSampled {
+		_collector.PauseDur = config.FleetReducedInterval
+	}
+	_collector.Delay = config.StartDelay
+	_collector.OnError = _runStats.onError
+	_collector.Schedule = config.schedule
+	_collector.Align = config.AlignCollection
 	_collector.EnableCPU = !config.DisableCpu
 	_collector.EnableMem = !config.DisableMem
 	_collector.EnableGC = !config.DisableGc
+	_collector.EnableProcess = !config.DisableProcess
+	_collector.EnableRunQueue = config.EnableRunQueue
+	_collector.RunQueueStackFallback = config.RunQueueStackFallback
+	_collector.EnableScheduler = !config.DisableScheduler
+	_collector.EnableContention = config.BlockProfileRate > 0 || config.MutexProfileFraction > 0
+	_collector.EnableRuntimeMetrics = config.RuntimeMetrics
+	if config.MemoryLimitRatio > 0 {
+		_collector.MemoryLimitRatio = config.MemoryLimitRatio
+	}
+	if config.LeakWindow > 0 {
+		_collector.LeakWindow = config.LeakWindow
+	}
+	if config.LeakThreshold > 0 {
+		_collector.LeakThreshold = config.LeakThreshold
+	}
+	_collector.OnLeak = config.OnLeak
+	if config.PauseTarget > 0 {
+		_collector.PauseSLO = &collector.PauseSLO{
+			Target:   config.PauseTarget,
+			Quantile: config.PauseQuantile,
+			Window:   config.PauseWindow,
+		}
+	}
+
+	_collector.Done = _runStats.done
+	_runStats.collector = _collector
+
+	if config.WatchdogDeadline > 0 {
+		// The first collection is only due after the StartDelay
+		_runStats.collectorWatch = _runStats.Watch("collector", config.WatchdogDeadline)
+		atomic.StoreInt64(&_runStats.collectorWatch.last, time.Now().Add(config.StartDelay).UnixNano())
+	}
+
+	// The start marker comes before the first collection
+	if !config.DisableMarkers {
+		_runStats.marker(markerStart)
+	}
 
-	go _collector.Run()
+	go func() {
+		defer close(_runStats.stopped)
+		_collector.Run()
+	}()
+
+	// Close once ctx is done
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-_runStats.done:
+			return
+		}
+
+		closeCtx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+		defer cancel()
+		if err := _runStats.Close(closeCtx); err != nil {
+			_runStats.log(err)
+		}
+	}()
 
 	return _runStats, nil
 }
 
 type RunStats struct {
-	logger Logger
-	client influxdb2.Client
-	config *Config
-	write  api.WriteAPI
+	logger      atomic.Value // holds a loggerBox
+	config      *Config
+	writer      *writer
+	tags        map[string]string
+	dynamicTags atomic.Value // holds the map[string]string of the TagProviders
+	collector   *collector.Collector
+	started     time.Time
+	tracer      *tracer
+	clock       func() time.Time // optional, replaces time.Now in tests
+
+	lastHeapProfile    time.Time
+	lastStackProfile   time.Time
+	lastConfigSnapshot time.Time
+	watchdogs          watchdogs
+	collectorWatch     *Watchdog // optional, see Config.WatchdogDeadline
+	http               httpStats
+	latency            *writeLatency
+	certs              certificates
+	app                appMetrics
+	configMu           sync.RWMutex // guards tags and the config fields changed by ConfigHandler and Reconfigure
+
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// now returns the current time of the clock.
+func (r *RunStats) now() time.Time {
+	if r.clock != nil {
+		return r.clock()
+	}
+	return time.Now()
 }
 
+// loggerBox lets logger hold any Logger implementation, and nil.
+type loggerBox struct{ Logger }
+
+// Logger sets the logger errors are reported to, for r and its collector. It is
+// safe to call at any time, e.g. once the application's logger is set up.
 func (r *RunStats) Logger(log Logger) {
-	r.logger = log
+	r.logger.Store(loggerBox{log})
+	r.collector.SetLogger(log)
+}
+
+// log reports v to the logger, if any.
+func (r *RunStats) log(v ...interface{}) {
+	if box, _ := r.logger.Load().(loggerBox); box.Logger != nil {
+		box.Println(v...)
+	}
+}
+
+// logError reports err, prefixed by msg, to the logger and to Config.OnError.
+func (r *RunStats) logError(msg string, err error) {
+	r.log(msg, err)
+	r.onError(fmt.Errorf("%s %w", msg, err))
+}
+
+// onError passes err to Config.OnError, if set.
+func (r *RunStats) onError(err error) {
+	if r.config.OnError != nil {
+		r.config.OnError(err)
+	}
 }
 
 func (r *RunStats) onNewPoint(fields collector.Fields) {
-	r.write.WritePoint(influxdb2.NewPoint(r.config.Measurement, fields.Tags(), fields.Values(), time.Now()))
+	if r.collectorWatch != nil {
+		r.collectorWatch.CheckIn()
+	}
+
+	r.refreshTags()
+	tags := r.pointTags(fields.Tags())
+	now := r.now()
+	if now.Sub(r.started) < r.config.WarmupWindow {
+		tags["warmup"] = "true"
+	}
+	tags["schema.version"] = strconv.Itoa(r.config.SchemaVersion)
+	if r.config.PprofURL != "" {
+		tags["pprof.url"] = r.pprofURL()
+	}
+	if r.config.AlignCollection && r.config.schedule == nil {
+		now = collector.AlignTime(now, r.collector.Interval())
+	}
+	values, units := fields.Values(), fields.Units()
+	if !r.config.fleetSampled {
+		values, units = reduceFields(r.config.FleetReducedFields, values, units)
+	}
+	values, units = collector.FieldsAt(r.config.SchemaVersion, values, units)
+	r.writer.Write(&point.Point{
+		Measurement: r.config.Measurement,
+		Tags:        tags,
+		Fields:      values,
+		Time:        now,
+		Units:       units,
+	})
+
+	if r.config.ForwardExpvar {
+		r.forwardExpvar(now)
+	}
+
+	if len(r.config.GoroutineLabels) > 0 {
+		r.writeGoroutineLabels(now)
+	}
+
+	r.writeAppMetrics(now)
+	r.writeHTTPStats(now)
+	r.writeWriterStats(now)
+	r.writeCertificates(now)
+	if r.config.ConfigSnapshotInterval > 0 {
+		r.writeConfigSnapshot(now)
+	}
+	r.collectPlugins(now)
+
+	if r.config.HeapTopN > 0 {
+		r.writeTopAllocators(now)
+	}
+
+	if r.config.GoroutineTopN > 0 {
+		r.writeTopStacks(now)
+	}
+
+	if r.tracer != nil {
+		r.checkTrace(fields, now)
+	}
 }
 
 type Logger interface {
@@ -0,0 +1,117 @@
+package runstats
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+)
+
+func TestWarmupWindow(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{WarmupWindow: time.Hour})
+
+	stats.onNewPoint(stats.collector.OneOff())
+	stats.clock = func() time.Time { return stats.started.Add(2 * time.Hour) }
+	stats.onNewPoint(stats.collector.OneOff())
+
+	points := tr.Points(stats)
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points got %d", len(points))
+	}
+	if points[0].Tags["warmup"] != "true" {
+		t.Errorf("expected warmup tag during the window got %v", points[0].Tags)
+	}
+	if _, ok := points[1].Tags["warmup"]; ok {
+		t.Errorf("expected no warmup tag after the window got %v", points[1].Tags)
+	}
+}
+
+func TestPointUnits(t *testing.T) {
+	stats, tr := newTestRunStats(nil)
+	stats.onNewPoint(stats.collector.OneOff())
+
+	points := tr.Points(stats)
+	if len(points) != 1 || points[0].Units["mem.heap.alloc"] != collector.UnitBytes {
+		t.Errorf("expected units on the runtime point got %v", points)
+	}
+	if points[0].Tags["schema.version"] != "1" {
+		t.Errorf("expected the schema version tag got %v", points[0].Tags)
+	}
+}
+
+func TestInvalidConfig(t *testing.T) {
+	if _, err := (&Config{Schedule: "every minute"}).init(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig got %v", err)
+	}
+	if _, err := (&Config{SchemaVersion: collector.SchemaVersion + 1}).init(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig for a future schema got %v", err)
+	}
+	for _, q := range []float64{-0.5, 1.5, math.NaN()} {
+		if _, err := (&Config{PauseQuantile: q}).init(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected ErrInvalidConfig for pause quantile %v got %v", q, err)
+		}
+	}
+}
+
+type testLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *testLogger) Println(v ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, fmt.Sprintln(v...))
+}
+
+func (l *testLogger) Fatalln(v ...interface{}) {}
+
+func TestLoggerSwap(t *testing.T) {
+	stats, _ := newTestRunStats(nil)
+	stats.log("discarded")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			stats.log("logged")
+		}
+	}()
+	first, second := &testLogger{}, &testLogger{}
+	stats.Logger(first)
+	stats.Logger(second)
+	<-done
+
+	stats.log("swapped")
+	if n := len(second.lines); n == 0 || second.lines[n-1] != "swapped\n" {
+		t.Errorf("expected the last logger to be used got %v", second.lines)
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{Prefix: "myapp_"})
+	stats.onNewPoint(stats.collector.OneOff())
+	fields := map[string]interface{}{"orders": int64(1)}
+	stats.WritePoint("checkout", nil, fields)
+
+	points := tr.Points(stats)
+	if len(points) != 2 {
+		t.Fatalf("expected 2 points got %d", len(points))
+	}
+	if _, ok := points[0].Fields["myapp_mem.heap.alloc"]; !ok || points[0].Units["myapp_mem.heap.alloc"] != collector.UnitBytes {
+		t.Errorf("expected prefixed fields and units got %v", points[0].Units)
+	}
+	if _, ok := points[0].Fields["mem.heap.alloc"]; ok {
+		t.Error("expected no unprefixed field")
+	}
+	if _, ok := points[1].Fields["myapp_orders"]; !ok {
+		t.Errorf("expected the application point to be prefixed got %v", points[1].Fields)
+	}
+	if _, ok := fields["orders"]; !ok || len(fields) != 1 {
+		t.Errorf("expected the written fields to be left alone got %v", fields)
+	}
+}
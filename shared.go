@@ -0,0 +1,187 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// sharedTransports holds the transports shared by the RunStats of a process that
+// write to the same backend, see acquireShared.
+var sharedTransports = struct {
+	sync.Mutex
+	m map[string]*sharedTransport
+}{m: map[string]*sharedTransport{}}
+
+// sharedTransport is a transport used by several RunStats. Batches written while
+// another one is in flight are queued and written together as soon as it
+// returns, so concurrent writers share requests as well as the client.
+type sharedTransport struct {
+	key       string
+	transport transport
+	refs      int // guarded by sharedTransports
+
+	mu      sync.Mutex
+	pending []*sharedWrite
+	writing bool
+}
+
+type sharedWrite struct {
+	points []*point.Point
+	done   chan error
+}
+
+// sharedHandle is the reference of one RunStats to a sharedTransport.
+type sharedHandle struct {
+	*sharedTransport
+	once sync.Once
+}
+
+// sharedKey identifies the InfluxDB backend of config: its hosts, org, bucket
+// and token.
+func (config *Config) sharedKey() string {
+	hosts := append([]string{config.Host}, config.FailoverHosts...)
+	return strings.Join([]string{strings.Join(hosts, ","), config.Org, config.Bucket, config.Token}, "\x00")
+}
+
+// acquireShared returns a reference to the transport shared under key, dialing
+// it if there is none yet. The transport is closed with its last reference.
+func acquireShared(key string, dial func() (transport, error)) (transport, error) {
+	sharedTransports.Lock()
+	defer sharedTransports.Unlock()
+
+	s, ok := sharedTransports.m[key]
+	if !ok {
+		t, err := dial()
+		if err != nil {
+			return nil, err
+		}
+		s = &sharedTransport{key: key, transport: t}
+		sharedTransports.m[key] = s
+	}
+	s.refs++
+	return &sharedHandle{sharedTransport: s}, nil
+}
+
+// Write writes points, along with the batches queued meanwhile if no other write
+// is in flight, or queues them for the write in flight to pick up. A queued
+// batch whose ctx is done is withdrawn unless the write picked it up already:
+// then its outcome is awaited, so that points which may have been written
+// aren't reported failed and retried.
+func (s *sharedTransport) Write(ctx context.Context, points []*point.Point) error {
+	w := &sharedWrite{points: points, done: make(chan error, 1)}
+
+	s.mu.Lock()
+	s.pending = append(s.pending, w)
+	if s.writing {
+		s.mu.Unlock()
+		select {
+		case err := <-w.done:
+			return err
+		case <-ctx.Done():
+		}
+
+		s.mu.Lock()
+		for i, queued := range s.pending {
+			if queued == w {
+				s.pending = append(s.pending[:i], s.pending[i+1:]...)
+				s.mu.Unlock()
+				return ctx.Err()
+			}
+		}
+		s.mu.Unlock()
+		return <-w.done
+	}
+
+	// Write the queued batches, including the ones queued meanwhile
+	s.writing = true
+	for len(s.pending) > 0 {
+		batch := s.pending
+		s.pending = nil
+		s.mu.Unlock()
+
+		var merged []*point.Point
+		for _, w := range batch {
+			merged = append(merged, w.points...)
+		}
+		err := s.transport.Write(ctx, merged)
+		for i, err := range splitResult(err, batch) {
+			batch[i].done <- err
+		}
+
+		s.mu.Lock()
+	}
+	s.writing = false
+	s.mu.Unlock()
+	return <-w.done
+}
+
+// splitResult returns the outcome of every write of batch, merged into a single
+// write that returned err: the rejected points and partial writes of err are
+// narrowed down to the points of each.
+func splitResult(err error, batch []*sharedWrite) []error {
+	errs := make([]error, len(batch))
+	if err == nil {
+		return errs
+	}
+
+	var rejected *rejectedPointsError
+	isRejected := map[*point.Point]bool{}
+	if errors.As(err, &rejected) {
+		for _, p := range rejected.rejected {
+			isRejected[p] = true
+		}
+		err = rejected.err
+	}
+	var partial *partialWriteError
+	errors.As(err, &partial)
+
+	// offset counts the points written before those of w, not the rejected ones
+	offset := 0
+	for i, w := range batch {
+		var mine *rejectedPointsError
+		kept := 0
+		for _, p := range w.points {
+			if !isRejected[p] {
+				kept++
+				continue
+			}
+			if mine == nil {
+				mine = &rejectedPointsError{reason: rejected.reason}
+			}
+			mine.rejected = append(mine.rejected, p)
+		}
+
+		werr := err
+		if partial != nil {
+			switch written := partial.written - offset; {
+			case written >= kept:
+				werr = nil
+			case written > 0:
+				werr = &partialWriteError{written, partial.err}
+			default:
+				werr = partial.err
+			}
+		}
+		errs[i] = mine.with(werr)
+		offset += kept
+	}
+	return errs
+}
+
+// Close releases the reference, closing the transport if it was the last one.
+func (h *sharedHandle) Close() {
+	h.once.Do(func() {
+		sharedTransports.Lock()
+		defer sharedTransports.Unlock()
+
+		h.refs--
+		if h.refs == 0 {
+			delete(sharedTransports.m, h.key)
+			h.transport.Close()
+		}
+	})
+}
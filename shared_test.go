@@ -0,0 +1,161 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+func TestSharedTransport(t *testing.T) {
+	dials := 0
+	tr := &testTransport{}
+	dial := func() (transport, error) {
+		dials++
+		return tr, nil
+	}
+
+	first, _ := acquireShared("test", dial)
+	second, _ := acquireShared("test", dial)
+	if dials != 1 {
+		t.Fatalf("expected a single dial got %d", dials)
+	}
+
+	// Batches queued behind a write in flight go out together
+	s := first.(*sharedHandle).sharedTransport
+	s.mu.Lock()
+	s.writing = true
+	s.mu.Unlock()
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			second.Write(context.Background(), []*point.Point{{Measurement: "m", Fields: map[string]interface{}{"i": i}, Time: time.Now()}})
+		}(i)
+	}
+	for {
+		s.mu.Lock()
+		n := len(s.pending)
+		s.mu.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.mu.Lock()
+	s.writing = false
+	s.mu.Unlock()
+	if err := first.Write(context.Background(), []*point.Point{{Measurement: "m", Fields: map[string]interface{}{"i": 3}, Time: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+	if tr.writes != 1 || len(tr.points) != 4 {
+		t.Errorf("expected 4 points in a single write got %d in %d", len(tr.points), tr.writes)
+	}
+
+	first.Close()
+	first.Close()
+	if tr.closed {
+		t.Error("expected the transport to stay open while referenced")
+	}
+	second.Close()
+	if !tr.closed {
+		t.Error("expected the transport closed with its last reference")
+	}
+	if _, ok := sharedTransports.m["test"]; ok {
+		t.Error("expected the shared transport to be released")
+	}
+}
+
+func TestSharedTransportCancel(t *testing.T) {
+	release := make(chan struct{})
+	tr := &gatedTransport{release: release, started: make(chan struct{}, 2)}
+	first, _ := acquireShared("cancel", func() (transport, error) { return tr, nil })
+	second, _ := acquireShared("cancel", func() (transport, error) { return tr, nil })
+	defer first.Close()
+	defer second.Close()
+
+	leader := make(chan error)
+	go func() {
+		leader <- first.Write(context.Background(), []*point.Point{{Measurement: "leader"}})
+	}()
+	<-tr.started
+
+	// A batch withdrawn before the leader picks it up is not written
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := second.Write(ctx, []*point.Point{{Measurement: "withdrawn"}}); err != context.Canceled {
+		t.Errorf("expected the cancellation got %v", err)
+	}
+
+	// A batch picked up by the leader reports the outcome of its write
+	ctx, cancel = context.WithCancel(context.Background())
+	follower := make(chan error)
+	go func() {
+		follower <- second.Write(ctx, []*point.Point{{Measurement: "follower"}})
+	}()
+	s := first.(*sharedHandle).sharedTransport
+	for {
+		s.mu.Lock()
+		n := len(s.pending)
+		s.mu.Unlock()
+		if n == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	release <- struct{}{}
+	<-tr.started
+	cancel()
+	release <- struct{}{}
+	if err := <-leader; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-follower; err != nil {
+		t.Errorf("expected the handed off batch written got %v", err)
+	}
+	if len(tr.measurements) != 2 || tr.measurements[1] != "follower" {
+		t.Errorf("expected the leader and follower points written got %v", tr.measurements)
+	}
+}
+
+func TestSplitResult(t *testing.T) {
+	a, b, c := &point.Point{}, &point.Point{}, &point.Point{}
+	batch := []*sharedWrite{{points: []*point.Point{a, b}}, {points: []*point.Point{c}}}
+
+	// b is rejected, a written and c failed
+	failed := errors.New("unavailable")
+	err := &rejectedPointsError{rejected: []*point.Point{b}, reason: errors.New("NaN"), err: &partialWriteError{1, failed}}
+	errs := splitResult(err, batch)
+
+	var rejected *rejectedPointsError
+	if !errors.As(errs[0], &rejected) || len(rejected.rejected) != 1 || rejected.err != nil {
+		t.Errorf("expected only b rejected from the first write got %v", errs[0])
+	}
+	if errs[1] != failed {
+		t.Errorf("expected the second write failed got %v", errs[1])
+	}
+}
+
+// gatedTransport records the measurements written, signalling started when a
+// write begins and completing it once released.
+type gatedTransport struct {
+	release      chan struct{}
+	started      chan struct{}
+	measurements []string
+}
+
+func (t *gatedTransport) Write(ctx context.Context, points []*point.Point) error {
+	t.started <- struct{}{}
+	<-t.release
+	for _, p := range points {
+		t.measurements = append(t.measurements, p.Measurement)
+	}
+	return nil
+}
+
+func (t *gatedTransport) Close() {}
@@ -0,0 +1,86 @@
+package runstats
+
+import (
+	"context"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// Sink receives the points of RunStats, to write them to a backend other than
+// the built-in InfluxDB and Pushgateway ones, see Config.Sink. Points are
+// batched, budgeted and retried like for those: a failing WritePoint fails the
+// rest of its batch, which may be retried. Besides numbers, field values may be
+// bools or strings, e.g. mem.gc.forced and go.godebug. WritePoint is called from
+// one goroutine at a time. If the Sink also has a Close() error method it is
+// called by RunStats.Close.
+type Sink interface {
+	WritePoint(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error
+}
+
+// sinkTransport writes batches point by point to a Sink.
+type sinkTransport struct {
+	sink Sink
+}
+
+// newSinkTransport returns the transport writing to sink, unwrapping the
+// Sinks returned by NewInfluxSink.
+func newSinkTransport(sink Sink) transport {
+	if s, ok := sink.(*transportSink); ok {
+		return s.transport
+	}
+	return &sinkTransport{sink: sink}
+}
+
+// Write writes the points in order. When one fails, those written before it are
+// reported written so that only the rest is retried.
+func (t *sinkTransport) Write(ctx context.Context, points []*point.Point) error {
+	for i, p := range points {
+		if err := ctx.Err(); err != nil {
+			return &partialWriteError{i, err}
+		}
+		if err := t.sink.WritePoint(p.Measurement, p.Tags, p.Fields, p.Time); err != nil {
+			return &partialWriteError{i, err}
+		}
+	}
+	return nil
+}
+
+func (t *sinkTransport) Close() {
+	if c, ok := t.sink.(interface{ Close() error }); ok {
+		c.Close()
+	}
+}
+
+// transportSink is a Sink writing to a built-in transport.
+type transportSink struct {
+	transport transport
+}
+
+// NewInfluxSink connects to the InfluxDB of config like RunCollector does,
+// returning it as a Sink, e.g. to tee points to InfluxDB from a custom Sink.
+func NewInfluxSink(config *Config) (Sink, error) {
+	config, err := config.init()
+	if err != nil {
+		return nil, err
+	}
+	t, err := newInfluxTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	return &transportSink{transport: withTagRules(t, config.InfluxTags)}, nil
+}
+
+func (s *transportSink) WritePoint(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	return s.transport.Write(context.Background(), []*point.Point{{
+		Measurement: measurement,
+		Tags:        tags,
+		Fields:      fields,
+		Time:        ts,
+	}})
+}
+
+func (s *transportSink) Close() error {
+	s.transport.Close()
+	return nil
+}
@@ -0,0 +1,50 @@
+package runstats
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+type testSink struct {
+	mu           sync.Mutex
+	measurements []string
+	closed       bool
+}
+
+func (s *testSink) WritePoint(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.measurements = append(s.measurements, measurement)
+	return nil
+}
+
+func (s *testSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = true
+	return nil
+}
+
+func TestSink(t *testing.T) {
+	sink := &testSink{}
+	stats, err := RunCollector(context.Background(), &Config{Sink: sink, Measurement: "go.runtime.test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats.WritePoint("app.orders", nil, map[string]interface{}{"count": 1})
+	if err := stats.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	seen := map[string]bool{}
+	for _, m := range sink.measurements {
+		seen[m] = true
+	}
+	if !seen["go.runtime.test"] || !seen["app.orders"] || !sink.closed {
+		t.Errorf("expected the runtime and custom points written and the sink closed got %v closed %v", sink.measurements, sink.closed)
+	}
+}
@@ -0,0 +1,119 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+var soakDuration = flag.Duration("soak", 6*time.Hour, "simulated duration of TestSoak")
+
+// soakTransport discards the points and fails every failEvery-th write as
+// unavailable, so that failed batches go through the retry buffer.
+type soakTransport struct {
+	mu        sync.Mutex
+	writes    int
+	points    int
+	failEvery int
+}
+
+func (t *soakTransport) Write(ctx context.Context, points []*point.Point) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.writes++
+	if t.failEvery > 0 && t.writes%t.failEvery == 0 {
+		return withKind(ErrBackendUnavailable, errors.New("soak: unavailable"))
+	}
+	t.points += len(points)
+	return nil
+}
+
+func (t *soakTransport) Close() {}
+
+// soakUsage is the live heap and the goroutines of the process.
+type soakUsage struct {
+	heap       uint64
+	goroutines int
+}
+
+func readSoakUsage() soakUsage {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return soakUsage{heap: m.HeapAlloc, goroutines: runtime.NumGoroutine()}
+}
+
+// soak drives stats through d of simulated time, a collection every interval
+// on a fake clock, with requests through the Middleware, application metrics,
+// custom points and failed writes every collection. It returns the usage once
+// warmed up, after a tenth of d, and at the end.
+func soak(stats *RunStats, d, interval time.Duration) (warm, end soakUsage) {
+	clock := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := func() time.Time { return clock }
+	stats.clock = now
+	stats.writer.now = now
+
+	handler := stats.Middleware("/orders", http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	orders := stats.NewCounter("orders")
+	db := stats.With("subsystem", "db")
+
+	collections := int(d / interval)
+	for i := 0; i < collections; i++ {
+		clock = clock.Add(interval)
+		for j := 0; j < 10; j++ {
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orders", nil))
+			orders.Inc()
+		}
+		// Bounded cardinality, a leak would not be
+		db.WritePoint("app.queries", map[string]string{"table": "t" + strconv.Itoa(i%5)}, map[string]interface{}{"count": i})
+		stats.onNewPoint(stats.collector.OneOff())
+		stats.writer.Flush(context.Background())
+
+		if i == collections/10 {
+			warm = readSoakUsage()
+		}
+	}
+	return warm, readSoakUsage()
+}
+
+// TestSoak runs the collector for hours of simulated time and fails if the heap
+// or the goroutines of the package grow, e.g. through a buffer or a map that is
+// never trimmed. Run it longer with -soak=72h.
+func TestSoak(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping test because testing.Short is enabled")
+	}
+
+	stats, _ := newTestRunStats(&Config{
+		RetryBufferSize:        1000,
+		ConfigSnapshotInterval: time.Minute,
+	})
+	tr := &soakTransport{failEvery: 7}
+	stats.writer.transport = tr
+	stats.writer.retryLimit = stats.config.RetryBufferSize
+	stats.latency = newWriteLatency(stats.config.WriteLatencyBuckets)
+	stats.writer.onWritten = stats.onWritten
+
+	warm, end := soak(stats, *soakDuration, 10*time.Second)
+	if tr.points == 0 {
+		t.Fatal("expected points to be written")
+	}
+
+	// Allow for the noise of the runtime, not for growth with the collections
+	const heapSlack = 2 << 20
+	if end.heap > warm.heap+heapSlack {
+		t.Errorf("heap grew from %d to %d bytes over %v", warm.heap, end.heap, *soakDuration)
+	}
+	if end.goroutines > warm.goroutines {
+		t.Errorf("goroutines grew from %d to %d over %v", warm.goroutines, end.goroutines, *soakDuration)
+	}
+}
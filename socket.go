@@ -0,0 +1,101 @@
+package runstats
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// socketTransport writes points in InfluxDB line protocol to a socket, e.g. the
+// socket_listener input of a local Telegraf. Stream sockets (unix, tcp) are
+// connected on the first write and again after a failed one. Datagram sockets
+// (unixgram, udp) get lines packed into datagrams of at most maxDatagramSize
+// bytes; a longer line is sent on its own.
+type socketTransport struct {
+	network  string
+	address  string
+	datagram bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newSocketTransport(config *Config) (*socketTransport, error) {
+	u, err := url.Parse(config.SocketURL)
+	if err != nil {
+		return nil, withKind(ErrInvalidConfig, err)
+	}
+
+	t := &socketTransport{network: u.Scheme}
+	switch u.Scheme {
+	case "unix", "unixgram":
+		t.address = u.Path
+	case "tcp", "udp":
+		t.address = u.Host
+	default:
+		return nil, withKind(ErrInvalidConfig, fmt.Errorf("unsupported socket %q", config.SocketURL))
+	}
+	t.datagram = u.Scheme == "unixgram" || u.Scheme == "udp"
+	return t, nil
+}
+
+func (t *socketTransport) Write(ctx context.Context, points []*point.Point) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.conn == nil {
+		dialer := &net.Dialer{Timeout: dialTimeout}
+		conn, err := dialer.DialContext(ctx, t.network, t.address)
+		if err != nil {
+			return withKind(ErrBackendUnavailable, err)
+		}
+		t.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		t.conn.SetWriteDeadline(deadline)
+	} else {
+		t.conn.SetWriteDeadline(time.Time{})
+	}
+
+	lines, rejected := encodeBatch(points, func(p *point.Point) ([]byte, error) {
+		return point.AppendLineProtocol(nil, p)
+	})
+	var buf bytes.Buffer
+	for _, line := range lines {
+		if t.datagram && buf.Len() > 0 && buf.Len()+len(line) > maxDatagramSize {
+			if err := t.send(buf.Bytes()); err != nil {
+				return rejected.with(err)
+			}
+			buf.Reset()
+		}
+		buf.Write(line)
+	}
+	if buf.Len() == 0 {
+		return rejected.with(nil)
+	}
+	return rejected.with(t.send(buf.Bytes()))
+}
+
+func (t *socketTransport) send(b []byte) error {
+	if _, err := t.conn.Write(b); err != nil {
+		t.conn.Close()
+		t.conn = nil
+		return withKind(ErrBackendUnavailable, err)
+	}
+	return nil
+}
+
+func (t *socketTransport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil {
+		t.conn.Close()
+		t.conn = nil
+	}
+}
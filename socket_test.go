@@ -0,0 +1,70 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+func TestSocketTransport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "telegraf.sock")
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skip(err)
+	}
+	defer ln.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		b, _ := ioutil.ReadAll(conn)
+		received <- string(b)
+	}()
+
+	config, _ := (&Config{SocketURL: "unix://" + path}).init()
+	tr, err := newSocketTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tr.Write(context.Background(), []*point.Point{{
+		Measurement: "go.runtime",
+		Tags:        map[string]string{"go.os": "linux"},
+		Fields:      map[string]interface{}{"mem.heap.alloc": int64(1024)},
+		Time:        time.Unix(0, 1),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr.Close()
+
+	select {
+	case line := <-received:
+		if line != "go.runtime,go.os=linux mem.heap.alloc=1024i 1\n" {
+			t.Errorf("unexpected line protocol %q", line)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a line")
+	}
+}
+
+func TestSocketTransportInvalid(t *testing.T) {
+	if _, err := newSocketTransport(&Config{SocketURL: "http://localhost:8186"}); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected ErrInvalidConfig got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package runstats
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+)
+
+// writeTopStacks writes the GoroutineTopN call stacks shared by the most
+// goroutines to the StackMeasurement, at most once per GoroutineProfileInterval.
+// Points are tagged with the stack hash and its first non-runtime frame.
+func (r *RunStats) writeTopStacks(ts time.Time) {
+	if !r.lastStackProfile.IsZero() && ts.Sub(r.lastStackProfile) < r.config.GoroutineProfileInterval {
+		return
+	}
+	r.lastStackProfile = ts
+
+	for i, stack := range collector.TopStacks(r.config.GoroutineTopN) {
+		r.writer.WritePoint(r.config.StackMeasurement, r.pointTags(map[string]string{
+			"stack":    stack.Hash,
+			"function": stack.Function,
+			"location": stack.File + ":" + strconv.Itoa(stack.Line),
+		}), map[string]interface{}{
+			"rank":       i + 1,
+			"goroutines": stack.Goroutines,
+		}, ts)
+	}
+}
@@ -0,0 +1,165 @@
+package runstats
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// StatsD line formats, see Config.StatsdFormat.
+const (
+	StatsdFormatPlain   = "statsd"
+	StatsdFormatDatadog = "dogstatsd"
+)
+
+// maxDatagramSize keeps datagrams below the usual path MTU.
+const maxDatagramSize = 1432
+
+// statsdTransport sends points over UDP as StatsD lines. Every numeric or boolean
+// field becomes a gauge named <prefix><measurement>.<field>, except the cumulative
+// runtime fields (see collector.Cumulative) which are sent as counters of their
+// increase since the previous point, starting from the second one. The DogStatsD format adds the point tags.
+// Lines are packed into datagrams of at most maxDatagramSize bytes.
+type statsdTransport struct {
+	conn        net.Conn
+	prefix      string
+	datadog     bool
+	sampleRate  float64
+	measurement string
+	fieldPrefix string
+
+	mu     sync.Mutex
+	last   map[string]float64 // previous value of the counters by line prefix
+	sample func() float64
+}
+
+func newStatsdTransport(config *Config) (*statsdTransport, error) {
+	conn, err := net.Dial("udp", config.StatsdAddress)
+	if err != nil {
+		return nil, withKind(ErrInvalidConfig, err)
+	}
+	return &statsdTransport{
+		conn:        conn,
+		prefix:      config.StatsdPrefix,
+		datadog:     config.StatsdFormat == StatsdFormatDatadog,
+		sampleRate:  config.StatsdSampleRate,
+		measurement: config.Measurement,
+		fieldPrefix: config.Prefix,
+		last:        map[string]float64{},
+		sample:      rand.Float64,
+	}, nil
+}
+
+func (t *statsdTransport) Write(ctx context.Context, points []*point.Point) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var packet, line bytes.Buffer
+	for _, p := range points {
+		tags := t.tags(p.Tags)
+		for _, field := range sortedFields(p.Fields) {
+			value, ok := promValue(p.Fields[field])
+			if !ok {
+				continue
+			}
+
+			line.Reset()
+			name := statsdName(t.prefix + p.Measurement + "." + field)
+			if p.Measurement == t.measurement && collector.Cumulative(strings.TrimPrefix(field, t.fieldPrefix)) {
+				key := name + tags
+				last, seen := t.last[key]
+				t.last[key] = value
+				delta := value - last
+				// The first value is only the baseline of the increases
+				if !seen || delta <= 0 || t.sampleRate < 1 && t.sample() >= t.sampleRate {
+					continue
+				}
+				line.WriteString(name + ":" + strconv.FormatFloat(delta, 'f', -1, 64) + "|c")
+				if t.sampleRate < 1 {
+					line.WriteString("|@" + strconv.FormatFloat(t.sampleRate, 'f', -1, 64))
+				}
+			} else {
+				if value < 0 {
+					// A signed gauge value is an adjustment, reset it first
+					line.WriteString(name + ":0|g" + tags + "\n")
+				}
+				line.WriteString(name + ":" + strconv.FormatFloat(value, 'f', -1, 64) + "|g")
+			}
+			line.WriteString(tags)
+
+			if packet.Len() > 0 && packet.Len()+1+line.Len() > maxDatagramSize {
+				if err := t.send(ctx, packet.Bytes()); err != nil {
+					return err
+				}
+				packet.Reset()
+			}
+			if packet.Len() > 0 {
+				packet.WriteByte('\n')
+			}
+			packet.Write(line.Bytes())
+		}
+	}
+	if packet.Len() == 0 {
+		return nil
+	}
+	return t.send(ctx, packet.Bytes())
+}
+
+func (t *statsdTransport) send(ctx context.Context, packet []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if _, err := t.conn.Write(packet); err != nil {
+		return withKind(ErrBackendUnavailable, err)
+	}
+	return nil
+}
+
+func (t *statsdTransport) Close() {
+	t.conn.Close()
+}
+
+// tags returns the DogStatsD tag suffix of a line, or "" for plain StatsD.
+func (t *statsdTransport) tags(tags map[string]string) string {
+	if !t.datadog || len(tags) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(tags))
+	for k, v := range tags {
+		pairs = append(pairs, statsdTag(statsdName(k))+":"+statsdTag(v))
+	}
+	sort.Strings(pairs)
+	return "|#" + strings.Join(pairs, ",")
+}
+
+func sortedFields(fields map[string]interface{}) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	statsdNameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "\n", "_")
+	statsdTagReplacer  = strings.NewReplacer(",", "_", "|", "_", "\n", "_")
+)
+
+// statsdName replaces the characters that delimit a StatsD line.
+func statsdName(s string) string {
+	return statsdNameReplacer.Replace(s)
+}
+
+// statsdTag replaces the characters that delimit DogStatsD tags.
+func statsdTag(s string) string {
+	return statsdTagReplacer.Replace(s)
+}
@@ -0,0 +1,85 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+func TestStatsdTransport(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	config, err := (&Config{
+		Measurement:      "go.runtime",
+		StatsdAddress:    conn.LocalAddr().String(),
+		StatsdFormat:     StatsdFormatDatadog,
+		StatsdPrefix:     "app.",
+		StatsdSampleRate: 0.5,
+	}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := newStatsdTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tr.Close()
+	tr.sample = func() float64 { return 0.25 }
+
+	read := func() string {
+		buf := make([]byte, maxDatagramSize)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(buf[:n])
+	}
+
+	tags := map[string]string{"go.os": "linux", "host": "db:5432"}
+	write := func(count int64) {
+		err := tr.Write(context.Background(), []*point.Point{{
+			Measurement: "go.runtime",
+			Tags:        tags,
+			Fields:      map[string]interface{}{"mem.heap.alloc": int64(1024), "mem.gc.count": count, "mem.gc.gogc": int64(-1), "go.godebug": "skipped"},
+			Time:        time.Now(),
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The first counter value is only a baseline
+	write(3)
+	expected := "app.go.runtime.mem.gc.gogc:0|g|#go.os:linux,host:db:5432\n" +
+		"app.go.runtime.mem.gc.gogc:-1|g|#go.os:linux,host:db:5432\n" +
+		"app.go.runtime.mem.heap.alloc:1024|g|#go.os:linux,host:db:5432"
+	if got := read(); got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+
+	// Counters are sent as the increase since the previous point
+	write(5)
+	if got := read(); got[:len("app.go.runtime.mem.gc.count:2|c")] != "app.go.runtime.mem.gc.count:2|c" {
+		t.Errorf("expected the counter increase got\n%s", got)
+	}
+}
+
+func TestStatsdInvalidConfig(t *testing.T) {
+	for _, config := range []*Config{
+		{StatsdAddress: "localhost:8125", StatsdFormat: "graphite"},
+		{StatsdAddress: "localhost:8125", StatsdSampleRate: 2},
+	} {
+		if _, err := config.init(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected ErrInvalidConfig for %+v got %v", config, err)
+		}
+	}
+}
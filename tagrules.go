@@ -0,0 +1,87 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// TagRules rewrite the tags of the points sent to one backend, e.g. to drop
+// high-cardinality tags a backend can't cope with. Strip is applied first, then
+// Rename, then Add.
+type TagRules struct {
+	// Tags to add, overriding tags of the same name.
+	Add map[string]string `json:"add" yaml:"add" mapstructure:"add"`
+	// Tags to rename, from old to new name.
+	Rename map[string]string `json:"rename" yaml:"rename" mapstructure:"rename"`
+	// Tags to remove.
+	Strip []string `json:"strip" yaml:"strip" mapstructure:"strip"`
+}
+
+func (r *TagRules) empty() bool {
+	return r == nil || len(r.Add) == 0 && len(r.Rename) == 0 && len(r.Strip) == 0
+}
+
+// apply returns a rewritten copy of tags.
+func (r *TagRules) apply(tags map[string]string) map[string]string {
+	out := make(map[string]string, len(tags)+len(r.Add))
+	for k, v := range tags {
+		out[k] = v
+	}
+	for _, k := range r.Strip {
+		delete(out, k)
+	}
+	for from, to := range r.Rename {
+		if v, ok := out[from]; ok {
+			delete(out, from)
+			out[to] = v
+		}
+	}
+	for k, v := range r.Add {
+		out[k] = v
+	}
+	return out
+}
+
+// tagRulesTransport applies TagRules to the points before writing them.
+type tagRulesTransport struct {
+	transport
+	rules *TagRules
+}
+
+// withTagRules wraps t to apply rules, if there are any.
+func withTagRules(t transport, rules *TagRules) transport {
+	if rules.empty() {
+		return t
+	}
+	return &tagRulesTransport{transport: t, rules: rules}
+}
+
+// Write writes rewritten copies of points. Rejected copies are reported as the
+// original points, which the writer tells apart from the rest of the batch.
+func (t *tagRulesTransport) Write(ctx context.Context, points []*point.Point) error {
+	rewritten := make([]*point.Point, len(points))
+	originals := make(map[*point.Point]*point.Point, len(points))
+	for i, p := range points {
+		rewritten[i] = &point.Point{
+			Measurement: p.Measurement,
+			Tags:        t.rules.apply(p.Tags),
+			Fields:      p.Fields,
+			Time:        p.Time,
+			Units:       p.Units,
+		}
+		originals[rewritten[i]] = p
+	}
+
+	err := t.transport.Write(ctx, rewritten)
+	var rejected *rejectedPointsError
+	if !errors.As(err, &rejected) {
+		return err
+	}
+	mapped := &rejectedPointsError{rejected: make([]*point.Point, len(rejected.rejected)), reason: rejected.reason, err: rejected.err}
+	for i, p := range rejected.rejected {
+		mapped.rejected[i] = originals[p]
+	}
+	return mapped
+}
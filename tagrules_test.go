@@ -0,0 +1,113 @@
+package runstats
+
+import (
+	"context"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+func TestTagRules(t *testing.T) {
+	tr := &testTransport{}
+	rules := &TagRules{
+		Add:    map[string]string{"dc": "eu-1"},
+		Rename: map[string]string{"env": "environment"},
+		Strip:  []string{"pod.uid"},
+	}
+	tags := map[string]string{"env": "prod", "pod.uid": "1234", "go.os": "linux"}
+	units := map[string]string{"v": "bytes"}
+	points := []*point.Point{{Measurement: "m", Tags: tags, Units: units}}
+
+	if err := withTagRules(tr, rules).Write(context.Background(), points); err != nil {
+		t.Fatal(err)
+	}
+
+	exp := map[string]string{"environment": "prod", "go.os": "linux", "dc": "eu-1"}
+	if !reflect.DeepEqual(tr.points[0].Tags, exp) {
+		t.Errorf("expected %v got %v", exp, tr.points[0].Tags)
+	}
+	if !reflect.DeepEqual(tr.points[0].Units, units) {
+		t.Errorf("expected the units kept got %v", tr.points[0].Units)
+	}
+	if len(tags) != 3 || tags["pod.uid"] != "1234" {
+		t.Errorf("expected the original tags to be left alone got %v", tags)
+	}
+
+	if withTagRules(tr, &TagRules{}) != transport(tr) {
+		t.Error("expected empty rules not to wrap the transport")
+	}
+}
+
+func TestBackendTagRules(t *testing.T) {
+	producer := &testProducer{}
+	rules := &TagRules{Strip: []string{"pod.uid"}}
+	config, err := (&Config{KafkaProducer: producer, KafkaFormat: OutputFormatLine, BackendTags: rules}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := newTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := []*point.Point{{Measurement: "m", Tags: map[string]string{"pod.uid": "1234", "env": "prod"}, Fields: map[string]interface{}{"v": int64(1)}, Time: time.Unix(0, 1)}}
+	if err := tr.Write(context.Background(), points); err != nil {
+		t.Fatal(err)
+	}
+	if len(producer.values) != 1 || producer.values[0] != "m,env=prod v=1i 1" {
+		t.Errorf("expected the tag stripped from the Kafka message got %v", producer.values)
+	}
+
+	w := newWriter(&testTransport{}, defaultBatchSize, time.Hour)
+	sink := &testTransport{}
+	w.fanOut([]SinkConfig{{Sink: &transportSink{transport: sink}, Tags: rules}})
+	w.WritePoint("m", map[string]string{"pod.uid": "1234", "env": "prod"}, map[string]interface{}{"v": 1}, time.Now())
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(sink.points) != 1 || !reflect.DeepEqual(sink.points[0].Tags, map[string]string{"env": "prod"}) {
+		t.Errorf("expected the tag stripped from the fanned out point got %v", sink.points)
+	}
+}
+
+func TestBackendTagRulesRejectedPoints(t *testing.T) {
+	dir, err := ioutil.TempDir("", "runstats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rules := &TagRules{Strip: []string{"pod.uid"}}
+	config, err := (&Config{OutputFile: filepath.Join(dir, "metrics.json"), BackendTags: rules}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr, err := newTransport(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := newWriter(tr, defaultBatchSize, time.Hour)
+	defer w.Close(context.Background())
+	w.retryLimit = 10
+	var written int
+	w.onWritten = func(points []*point.Point) { written += len(points) }
+
+	// JSON can't encode the NaN of the second point
+	for _, v := range []float64{1, math.NaN(), 2} {
+		w.WritePoint("m", map[string]string{"pod.uid": "1234"}, map[string]interface{}{"v": v}, time.Now())
+	}
+	if err := w.Flush(context.Background()); err == nil {
+		t.Fatal("expected the rejected points error")
+	}
+	if written != 2 || w.Dropped() != 1 || len(w.retry) != 0 {
+		t.Errorf("expected 2 points written and the NaN one dropped got %d written %d dropped %v kept", written, w.Dropped(), w.retry)
+	}
+	if errs := w.Errors(); errs[classRejected] != 1 {
+		t.Errorf("expected a rejected error got %v", errs)
+	}
+}
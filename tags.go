@@ -0,0 +1,112 @@
+package runstats
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// TagProvider returns tags evaluated every collection, e.g. the deployment color
+// or the feature flag cohort, see Config.TagProviders.
+type TagProvider func() map[string]string
+
+// staticTags returns the tags attached to every point.
+func (config *Config) staticTags() map[string]string {
+	tags := map[string]string{}
+
+	if !config.DisableBuildTags {
+		info := readBuildInfo()
+		tags["app.version"] = info.Version
+		if info.Revision != "" {
+			tags["app.revision"] = info.Revision
+		}
+	}
+	if config.Environment != "" {
+		tags["env"] = config.Environment
+	}
+	if config.FleetSampleRate < 1 {
+		tags["fleet.sampled"] = strconv.FormatBool(config.fleetSampled)
+	}
+	for k, v := range config.Tags {
+		tags[k] = v
+	}
+
+	return tags
+}
+
+// refreshTags evaluates the TagProviders, later ones taking precedence, and the
+// refreshed identity for the points written until the next collection.
+func (r *RunStats) refreshTags() {
+	if len(r.config.TagProviders) == 0 && r.config.IdentityRefreshInterval <= 0 {
+		return
+	}
+	tags := map[string]string{}
+	if r.config.IdentityRefreshInterval > 0 {
+		tags["instance"] = r.instance()
+	}
+	for _, provider := range r.config.TagProviders {
+		for k, v := range provider() {
+			if k != "" {
+				tags[k] = v
+			}
+		}
+	}
+	r.dynamicTags.Store(tags)
+}
+
+// instance returns the current identity, which is the one resolved at start
+// unless Config.IdentityRefreshInterval is set.
+func (r *RunStats) instance() string {
+	if r.config.IdentityRefreshInterval <= 0 {
+		return r.config.instance
+	}
+	if id, err := r.config.Identity(); err == nil {
+		return id
+	}
+	return r.config.instance
+}
+
+// pointTags merges the static tags, the tags of the TagProviders and tags, the
+// latter taking precedence.
+func (r *RunStats) pointTags(tags map[string]string) map[string]string {
+	dynamic, _ := r.dynamicTags.Load().(map[string]string)
+	r.configMu.RLock()
+	static := r.tags
+	r.configMu.RUnlock()
+
+	merged := make(map[string]string, len(static)+len(dynamic)+len(tags))
+	for k, v := range static {
+		merged[k] = v
+	}
+	for k, v := range dynamic {
+		merged[k] = v
+	}
+	for k, v := range tags {
+		merged[k] = v
+	}
+	return merged
+}
+
+// BucketTag maps value to one of buckets tag values by its hash salted with salt,
+// "00" to "63" for 64 buckets. Use it to tag points with high-cardinality values
+// such as user IDs or URLs: the number of series stays bounded and the raw value
+// isn't written. With buckets <= 0 the hash itself is returned as 16 hex digits,
+// which hides the value but doesn't bound the cardinality.
+func BucketTag(salt, value string, buckets int) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+	h := binary.BigEndian.Uint64(mac.Sum(nil))
+	if buckets <= 0 {
+		return fmt.Sprintf("%016x", h)
+	}
+	width := len(strconv.Itoa(buckets - 1))
+	return fmt.Sprintf("%0*d", width, h%uint64(buckets))
+}
+
+// BucketTag maps value to one of Config.TagBuckets tag values, salted with
+// Config.TagSalt, see the package level BucketTag.
+func (r *RunStats) BucketTag(value string) string {
+	return BucketTag(r.config.TagSalt, value, r.config.TagBuckets)
+}
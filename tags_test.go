@@ -0,0 +1,90 @@
+package runstats
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestBucketTag(t *testing.T) {
+	stats, _ := newTestRunStats(&Config{TagSalt: "secret"})
+
+	seen := map[string]bool{}
+	for i := 0; i < 1000; i++ {
+		tag := stats.BucketTag(fmt.Sprintf("user-%d", i))
+		if len(tag) != 2 || tag > "63" {
+			t.Fatalf("unexpected bucket %q", tag)
+		}
+		seen[tag] = true
+	}
+	if len(seen) < 48 {
+		t.Errorf("expected values spread over the buckets got %d buckets", len(seen))
+	}
+
+	if stats.BucketTag("user-1") != BucketTag("secret", "user-1", 64) {
+		t.Error("expected the same bucket for the same salt")
+	}
+	if BucketTag("secret", "user-1", 0) == BucketTag("other", "user-1", 0) {
+		t.Error("expected the salt to change the hash")
+	}
+	if tag := BucketTag("", "user-1", 0); len(tag) != 16 {
+		t.Errorf("expected a 16 digit hash got %q", tag)
+	}
+}
+
+func TestConfigTags(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{
+		Environment: "staging",
+		Tags:        map[string]string{"service": "checkout", "env": "prod", "go.os": "plan9"},
+	})
+	stats.onNewPoint(stats.collector.OneOff())
+
+	points := tr.Points(stats)
+	if len(points) == 0 {
+		t.Fatal("expected a point")
+	}
+	tags := points[0].Tags
+	if tags["service"] != "checkout" || tags["env"] != "prod" {
+		t.Errorf("expected the config tags to be added got %v", tags)
+	}
+	if tags["go.os"] == "plan9" {
+		t.Error("expected the tags of the point to take precedence")
+	}
+
+	if _, err := (&Config{Tags: map[string]string{"": "x"}}).init(); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("expected an invalid config error for an empty tag key got %v", err)
+	}
+}
+
+func TestTagProviders(t *testing.T) {
+	color := "blue"
+	stats, tr := newTestRunStats(&Config{
+		Tags: map[string]string{"service": "checkout", "deploy.color": "none"},
+		TagProviders: []TagProvider{
+			func() map[string]string { return map[string]string{"deploy.color": color} },
+			func() map[string]string { return map[string]string{"role": "leader", "": "ignored"} },
+		},
+	})
+
+	stats.onNewPoint(stats.collector.OneOff())
+	color = "green"
+	stats.WritePoint("app.orders", nil, map[string]interface{}{"count": 1})
+	stats.onNewPoint(stats.collector.OneOff())
+
+	points := tr.Points(stats)
+	var colors []string
+	for _, p := range points {
+		if p.Tags["service"] != "checkout" || p.Tags["role"] != "leader" {
+			t.Errorf("expected the static and provided tags got %v", p.Tags)
+		}
+		if _, ok := p.Tags[""]; ok {
+			t.Error("expected empty tag keys to be skipped")
+		}
+		if p.Measurement == stats.config.Measurement || p.Measurement == "app.orders" {
+			colors = append(colors, p.Tags["deploy.color"])
+		}
+	}
+	if fmt.Sprint(colors) != "[blue blue green]" {
+		t.Errorf("expected the tags to be evaluated every collection got %v", colors)
+	}
+}
@@ -0,0 +1,135 @@
+package runstats
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+)
+
+const defaultTraceCooldown = time.Minute
+
+// TraceTrigger decides after each collection whether to capture an execution
+// trace. It returns the reason for the capture, or an empty string.
+type TraceTrigger func(fields collector.Fields) string
+
+// tracer dumps the flight recorder to TraceDir when a trigger fires.
+type tracer struct {
+	recorder flightRecorder
+	dir      string
+	cooldown time.Duration
+	triggers []TraceTrigger
+	onTrace  func(path string)
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// flightRecorder keeps the last seconds of execution trace in memory.
+type flightRecorder interface {
+	// dump writes the recorded trace to a new file in dir and returns its path.
+	dump(dir string, name string) (string, error)
+	stop()
+}
+
+// PauseTrigger fires when a GC pause since the previous collection exceeded
+// threshold, not only the last one.
+func PauseTrigger(threshold time.Duration) TraceTrigger {
+	var mu sync.Mutex
+	var lastGC int64
+	return func(fields collector.Fields) string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if fields.NumGC == lastGC {
+			return ""
+		}
+		lastGC = fields.NumGC
+		if pause := time.Duration(fields.PauseMax); pause > threshold {
+			return fmt.Sprintf("gc pause %v > %v", pause, threshold)
+		}
+		return ""
+	}
+}
+
+// SchedLatencyTrigger fires when the p99 scheduler latency since the previous
+// collection exceeded threshold, a sign of CPU starvation. It needs the
+// scheduler stats, see collector.Collector.EnableScheduler.
+func SchedLatencyTrigger(threshold time.Duration) TraceTrigger {
+	return func(fields collector.Fields) string {
+		if fields.SchedLatencyCount == 0 {
+			return ""
+		}
+		if p99 := time.Duration(fields.SchedLatencyP99); p99 > threshold {
+			return fmt.Sprintf("sched latency p99 %v > %v", p99, threshold)
+		}
+		return ""
+	}
+}
+
+func newTracer(config *Config) (*tracer, error) {
+	recorder, err := startFlightRecorder(config.TraceMinAge)
+	if err != nil {
+		return nil, err
+	}
+
+	triggers := config.TraceTriggers
+	if config.TraceSchedLatencyThreshold > 0 {
+		triggers = append([]TraceTrigger{SchedLatencyTrigger(config.TraceSchedLatencyThreshold)}, triggers...)
+	}
+	if config.TracePauseThreshold > 0 {
+		triggers = append([]TraceTrigger{PauseTrigger(config.TracePauseThreshold)}, triggers...)
+	}
+
+	return &tracer{
+		recorder: recorder,
+		dir:      config.TraceDir,
+		cooldown: config.TraceCooldown,
+		triggers: triggers,
+		onTrace:  config.OnTrace,
+	}, nil
+}
+
+// check evaluates the triggers and dumps the trace if one fires, at most once per
+// cooldown. It returns the reason and path of a capture.
+func (t *tracer) check(fields collector.Fields, now time.Time) (reason, path string, err error) {
+	for _, trigger := range t.triggers {
+		if reason = trigger(fields); reason != "" {
+			break
+		}
+	}
+	if reason == "" {
+		return "", "", nil
+	}
+
+	t.mu.Lock()
+	if !t.last.IsZero() && now.Sub(t.last) < t.cooldown {
+		t.mu.Unlock()
+		return "", "", nil
+	}
+	t.last = now
+	t.mu.Unlock()
+
+	path, err = t.recorder.dump(t.dir, fmt.Sprintf("runstats-%s.trace", now.UTC().Format("20060102T150405.000000000")))
+	if err != nil {
+		return reason, "", err
+	}
+	if t.onTrace != nil {
+		t.onTrace(path)
+	}
+	return reason, path, nil
+}
+
+// checkTrace captures a trace if a trigger fires and writes an annotation
+// referencing the trace file.
+func (r *RunStats) checkTrace(fields collector.Fields, now time.Time) {
+	reason, path, err := r.tracer.check(fields, now)
+	if err != nil {
+		r.logError("runstats: failed to capture trace:", err)
+		return
+	}
+	if path != "" {
+		r.Annotate("trace", reason, map[string]string{"event": "trace", "trace": path})
+	}
+}
@@ -0,0 +1,45 @@
+//go:build go1.25
+// +build go1.25
+
+package runstats
+
+import (
+	"os"
+	"path/filepath"
+	"runtime/trace"
+	"time"
+)
+
+type runtimeFlightRecorder struct {
+	fr *trace.FlightRecorder
+}
+
+func startFlightRecorder(minAge time.Duration) (flightRecorder, error) {
+	fr := trace.NewFlightRecorder(trace.FlightRecorderConfig{MinAge: minAge})
+	if err := fr.Start(); err != nil {
+		return nil, err
+	}
+	return &runtimeFlightRecorder{fr: fr}, nil
+}
+
+func (r *runtimeFlightRecorder) dump(dir string, name string) (string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, name)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.fr.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return "", err
+	}
+	return path, f.Close()
+}
+
+func (r *runtimeFlightRecorder) stop() {
+	r.fr.Stop()
+}
@@ -0,0 +1,13 @@
+//go:build !go1.25
+// +build !go1.25
+
+package runstats
+
+import (
+	"fmt"
+	"time"
+)
+
+func startFlightRecorder(minAge time.Duration) (flightRecorder, error) {
+	return nil, fmt.Errorf("%w: the trace flight recorder requires Go 1.25", ErrInvalidConfig)
+}
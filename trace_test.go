@@ -0,0 +1,72 @@
+package runstats
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+)
+
+func TestPauseTrigger(t *testing.T) {
+	trigger := PauseTrigger(time.Millisecond)
+
+	if reason := trigger(collector.Fields{NumGC: 1, PauseNs: int64(10 * time.Millisecond), PauseMax: int64(10 * time.Millisecond)}); reason == "" {
+		t.Error("expected long pause to trigger")
+	}
+	if reason := trigger(collector.Fields{NumGC: 1, PauseNs: int64(10 * time.Millisecond), PauseMax: int64(10 * time.Millisecond)}); reason != "" {
+		t.Error("expected the same GC cycle not to trigger twice")
+	}
+	if reason := trigger(collector.Fields{NumGC: 2, PauseNs: int64(time.Microsecond), PauseMax: int64(time.Microsecond)}); reason != "" {
+		t.Error("expected short pause not to trigger")
+	}
+	if reason := trigger(collector.Fields{NumGC: 4, PauseNs: int64(time.Microsecond), PauseMax: int64(10 * time.Millisecond)}); reason == "" {
+		t.Error("expected a long pause followed by a short one to trigger")
+	}
+}
+
+func TestSchedLatencyTrigger(t *testing.T) {
+	trigger := SchedLatencyTrigger(time.Millisecond)
+
+	if reason := trigger(collector.Fields{SchedLatencyCount: 100, SchedLatencyP99: int64(5 * time.Millisecond)}); reason == "" {
+		t.Error("expected high latency to trigger")
+	}
+	if reason := trigger(collector.Fields{SchedLatencyCount: 100, SchedLatencyP99: int64(time.Microsecond)}); reason != "" {
+		t.Error("expected low latency not to trigger")
+	}
+	if reason := trigger(collector.Fields{}); reason != "" {
+		t.Error("expected an interval without samples not to trigger")
+	}
+}
+
+func TestTracer(t *testing.T) {
+	dir := t.TempDir()
+	config := &Config{
+		TraceDir:      dir,
+		TraceTriggers: []TraceTrigger{func(collector.Fields) string { return "always" }},
+	}
+	config.init()
+
+	tr, err := newTracer(config)
+	if err != nil {
+		if strings.Contains(err.Error(), "requires Go") {
+			t.Skip(err)
+		}
+		t.Fatal(err)
+	}
+	defer tr.recorder.stop()
+
+	now := time.Now()
+	reason, path, err := tr.check(collector.Fields{}, now)
+	if err != nil || reason != "always" {
+		t.Fatalf("expected capture got (%s, %v)", reason, err)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Size() == 0 {
+		t.Errorf("expected non-empty trace file got (%v, %v)", fi, err)
+	}
+
+	if _, path, _ := tr.check(collector.Fields{}, now.Add(time.Second)); path != "" {
+		t.Error("expected no capture within the cooldown")
+	}
+}
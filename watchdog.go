@@ -0,0 +1,149 @@
+package runstats
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/pprof"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// watchdogTick is how often the deadlines of the watched goroutines are checked.
+var watchdogTick = time.Second
+
+// Watchdog watches a goroutine that must check in within a deadline, see
+// RunStats.Watch.
+type Watchdog struct {
+	name     string
+	deadline time.Duration
+	dogs     *watchdogs
+	last     int64 // unix nanoseconds of the last check-in, accessed atomically
+	stalled  bool  // guarded by dogs.mu
+}
+
+// watchdogs holds the watched goroutines of a RunStats.
+type watchdogs struct {
+	mu      sync.Mutex
+	watched map[*Watchdog]struct{}
+	started bool
+}
+
+// Watch starts watching a goroutine, which must call CheckIn on the returned
+// Watchdog at least once per deadline, e.g. every iteration of its loop. While it
+// fails to, a point with an "alarm" field and the "stalled" duration in
+// nanoseconds is written to the WatchdogMeasurement, tagged with the watchdog
+// name. With WatchdogStackDir set, the stacks of all goroutines are also dumped
+// there once per stall, and referenced by an annotation.
+func (r *RunStats) Watch(name string, deadline time.Duration) *Watchdog {
+	w := &Watchdog{name: name, deadline: deadline, dogs: &r.watchdogs, last: time.Now().UnixNano()}
+
+	r.watchdogs.mu.Lock()
+	defer r.watchdogs.mu.Unlock()
+	if r.watchdogs.watched == nil {
+		r.watchdogs.watched = map[*Watchdog]struct{}{}
+	}
+	r.watchdogs.watched[w] = struct{}{}
+	if !r.watchdogs.started {
+		r.watchdogs.started = true
+		go r.runWatchdogs()
+	}
+	return w
+}
+
+// CheckIn reports the watched goroutine alive until the deadline.
+func (w *Watchdog) CheckIn() {
+	atomic.StoreInt64(&w.last, time.Now().UnixNano())
+}
+
+// Stop stops watching the goroutine.
+func (w *Watchdog) Stop() {
+	w.dogs.mu.Lock()
+	defer w.dogs.mu.Unlock()
+	delete(w.dogs.watched, w)
+}
+
+// watchdogStall is a watched goroutine past its deadline.
+type watchdogStall struct {
+	name    string
+	stalled time.Duration
+	// first is set on the first check of the stall.
+	first bool
+}
+
+// check returns the watched goroutines that didn't check in within their deadline.
+func (d *watchdogs) check(now time.Time) []watchdogStall {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var stalls []watchdogStall
+	for w := range d.watched {
+		stalled := now.Sub(time.Unix(0, atomic.LoadInt64(&w.last)))
+		if stalled <= w.deadline {
+			w.stalled = false
+			continue
+		}
+		stalls = append(stalls, watchdogStall{name: w.name, stalled: stalled, first: !w.stalled})
+		w.stalled = true
+	}
+	return stalls
+}
+
+func (r *RunStats) runWatchdogs() {
+	tick := time.NewTicker(watchdogTick)
+	defer tick.Stop()
+	for {
+		select {
+		case <-r.done:
+			return
+		case now := <-tick.C:
+			r.checkWatchdogs(now)
+		}
+	}
+}
+
+// checkWatchdogs writes an alarm point for every stalled goroutine and, on the
+// first check of a stall, dumps the goroutine stacks.
+func (r *RunStats) checkWatchdogs(now time.Time) {
+	for _, s := range r.watchdogs.check(now) {
+		r.writer.WritePoint(r.config.WatchdogMeasurement, r.pointTags(map[string]string{"watchdog": s.name}), map[string]interface{}{
+			"alarm":   true,
+			"stalled": int64(s.stalled),
+		}, now)
+
+		if !s.first {
+			continue
+		}
+		r.log("runstats: watchdog", s.name, "stalled for", s.stalled)
+		if r.config.WatchdogStackDir == "" {
+			continue
+		}
+
+		path, err := dumpStacks(r.config.WatchdogStackDir, fmt.Sprintf("runstats-%s-%s.stacks", s.name, now.UTC().Format("20060102T150405.000000000")))
+		if err != nil {
+			r.logError("runstats: failed to dump goroutine stacks:", err)
+			continue
+		}
+		r.Annotate("watchdog", fmt.Sprintf("%s stalled for %v", s.name, s.stalled), map[string]string{
+			"event":    "watchdog",
+			"watchdog": s.name,
+			"stacks":   path,
+		})
+	}
+}
+
+// dumpStacks writes the stacks of all goroutines to a new file in dir and returns
+// its path.
+func dumpStacks(dir, name string) (string, error) {
+	path := filepath.Join(dir, filepath.Base(name))
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if err := pprof.Lookup("goroutine").WriteTo(f, 2); err != nil {
+		f.Close()
+		return "", err
+	}
+	return path, f.Close()
+}
@@ -0,0 +1,53 @@
+package runstats
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchdog(t *testing.T) {
+	dir := t.TempDir()
+	stats, tr := newTestRunStats(&Config{WatchdogStackDir: dir, DisableMarkers: true})
+
+	w := stats.Watch("worker", time.Minute)
+	defer w.Stop()
+
+	now := time.Now()
+	stats.checkWatchdogs(now)
+	if points := tr.Points(stats); len(points) != 0 {
+		t.Fatalf("expected no alarm before the deadline got %d points", len(points))
+	}
+
+	// Two checks of the same stall dump the stacks once
+	stats.checkWatchdogs(now.Add(2 * time.Minute))
+	stats.checkWatchdogs(now.Add(3 * time.Minute))
+
+	var alarms, annotations int
+	for _, p := range tr.Points(stats) {
+		switch p.Measurement {
+		case stats.config.WatchdogMeasurement:
+			alarms++
+			if p.Tags["watchdog"] != "worker" || p.Fields["alarm"] != true || p.Fields["stalled"].(int64) < int64(2*time.Minute) {
+				t.Errorf("unexpected alarm %v %v", p.Tags, p.Fields)
+			}
+		case stats.config.AnnotationMeasurement:
+			annotations++
+			stacks, err := ioutil.ReadFile(p.Tags["stacks"])
+			if err != nil || !strings.Contains(string(stacks), "TestWatchdog") {
+				t.Errorf("expected the stacks dumped to %q got %v", p.Tags["stacks"], err)
+			}
+		}
+	}
+	if alarms != 2 || annotations != 1 {
+		t.Errorf("expected 2 alarms and 1 annotation got %d and %d", alarms, annotations)
+	}
+
+	// Checking in clears the stall
+	w.CheckIn()
+	stats.checkWatchdogs(time.Now())
+	if points := tr.Points(stats); len(points) != 3 {
+		t.Errorf("expected no alarm after checking in got %d points", len(points))
+	}
+}
@@ -0,0 +1,23 @@
+package runstats
+
+import (
+	"time"
+)
+
+// WriteAt writes a point with the given fields and tags to the configured
+// Measurement, timestamped ts, to backfill values computed after the fact such as
+// a job summary dated at the job's start. The point goes through the same
+// batching, retries and budget as the collected ones, together with the static
+// tags; MaxPointAge still applies, so backfilling further back than it requires
+// leaving it unset.
+func (r *RunStats) WriteAt(ts time.Time, fields map[string]interface{}, tags map[string]string) {
+	r.writer.WritePoint(r.config.Measurement, r.pointTags(tags), fields, ts)
+}
+
+// WritePoint writes a custom point to measurement, timestamped now, so that
+// occasional application points reuse the configured backend, batching and
+// error handling instead of a client of their own. The static tags are attached
+// to the point, tags taking precedence.
+func (r *RunStats) WritePoint(measurement string, tags map[string]string, fields map[string]interface{}) {
+	r.writer.WritePoint(measurement, r.pointTags(tags), fields, time.Now())
+}
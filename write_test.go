@@ -0,0 +1,44 @@
+package runstats
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWriteAt(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{Environment: "test"})
+
+	ts := time.Now().Add(-24 * time.Hour)
+	stats.WriteAt(ts, map[string]interface{}{"duration": 1.5}, map[string]string{"job": "nightly"})
+
+	points := tr.Points(stats)
+	if len(points) != 1 {
+		t.Fatalf("expected 1 point got %d", len(points))
+	}
+	p := points[0]
+	if p.Measurement != stats.config.Measurement || !p.Time.Equal(ts) {
+		t.Errorf("unexpected point %s at %s", p.Measurement, p.Time)
+	}
+	if p.Tags["job"] != "nightly" || p.Tags["env"] != "test" || p.Fields["duration"] != 1.5 {
+		t.Errorf("unexpected tags %v or fields %v", p.Tags, p.Fields)
+	}
+}
+
+func TestWritePoint(t *testing.T) {
+	stats, tr := newTestRunStats(&Config{Environment: "test"})
+
+	before := time.Now()
+	stats.WritePoint("app.orders", map[string]string{"env": "staging"}, map[string]interface{}{"count": 3})
+
+	points := tr.Points(stats)
+	if len(points) != 1 {
+		t.Fatalf("expected 1 point got %d", len(points))
+	}
+	p := points[0]
+	if p.Measurement != "app.orders" || p.Time.Before(before) {
+		t.Errorf("unexpected point %s at %s", p.Measurement, p.Time)
+	}
+	if p.Tags["env"] != "staging" || p.Fields["count"] != 3 {
+		t.Errorf("unexpected tags %v or fields %v", p.Tags, p.Fields)
+	}
+}
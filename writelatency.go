@@ -0,0 +1,82 @@
+package runstats
+
+import (
+	"sync"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// defaultWriteLatencyBuckets are the default buckets of the write latency
+// histogram, from flushed on time to minutes late.
+var defaultWriteLatencyBuckets = []time.Duration{time.Second, 5 * time.Second, 30 * time.Second}
+
+// writeLatency is the histogram of the time from collection to acknowledged
+// write of the points written since the last collection.
+type writeLatency struct {
+	mu      sync.Mutex
+	bounds  []time.Duration
+	buckets []int64
+	count   int64
+	total   time.Duration
+	max     time.Duration
+}
+
+// onWritten records the latency of a batch confirmed by the backend and passes
+// it on to Config.OnWritten.
+func (r *RunStats) onWritten(points []*point.Point) {
+	now := r.now()
+	r.latency.observe(points, now)
+	if r.config.OnWritten != nil {
+		r.config.OnWritten(newWriteAck(points, now))
+	}
+}
+
+func newWriteLatency(bounds []time.Duration) *writeLatency {
+	return &writeLatency{bounds: bounds, buckets: make([]int64, len(bounds))}
+}
+
+// observe records the latency of the points of a batch acknowledged at written.
+func (l *writeLatency) observe(points []*point.Point, written time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, p := range points {
+		d := written.Sub(p.Time)
+		if d < 0 {
+			// Aligned or backdated timestamps may lie ahead
+			d = 0
+		}
+		l.count++
+		l.total += d
+		if d > l.max {
+			l.max = d
+		}
+		for i, b := range l.bounds {
+			if d <= b {
+				l.buckets[i]++
+			}
+		}
+	}
+}
+
+// fields adds the histogram to fields as latency.count, latency.mean,
+// latency.max and a latency.le_<bound> count per bucket, then resets it. It
+// returns false, leaving fields alone, if no point was written.
+func (l *writeLatency) fields(fields map[string]interface{}) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.count == 0 {
+		return false
+	}
+	fields["latency.count"] = l.count
+	fields["latency.mean"] = int64(l.total) / l.count
+	fields["latency.max"] = int64(l.max)
+	for i, b := range l.bounds {
+		fields["latency.le_"+b.String()] = l.buckets[i]
+		l.buckets[i] = 0
+	}
+	l.count, l.total, l.max = 0, 0, 0
+	return true
+}
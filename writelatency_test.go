@@ -0,0 +1,61 @@
+package runstats
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+func TestWriteLatency(t *testing.T) {
+	l := newWriteLatency(defaultWriteLatencyBuckets)
+	now := time.Now()
+	l.observe([]*point.Point{
+		{Time: now.Add(-500 * time.Millisecond)},
+		{Time: now.Add(-2 * time.Second)},
+		{Time: now.Add(-time.Minute)},
+		{Time: now.Add(time.Second)},
+	}, now)
+
+	fields := map[string]interface{}{}
+	if !l.fields(fields) {
+		t.Fatal("expected latency fields")
+	}
+	want := map[string]interface{}{
+		"latency.count":  int64(4),
+		"latency.max":    int64(time.Minute),
+		"latency.le_1s":  int64(2),
+		"latency.le_5s":  int64(3),
+		"latency.le_30s": int64(3),
+	}
+	for k, v := range want {
+		if fields[k] != v {
+			t.Errorf("expected %s=%v got %v", k, v, fields[k])
+		}
+	}
+
+	if l.fields(map[string]interface{}{}) {
+		t.Error("expected the histogram to be reset")
+	}
+}
+
+func TestWriterLatencyPoint(t *testing.T) {
+	stats, tr := newTestRunStats(nil)
+	stats.latency = newWriteLatency(stats.config.WriteLatencyBuckets)
+	stats.writer.onWritten = stats.onWritten
+
+	var acked int
+	stats.config.OnWritten = func(ack WriteAck) { acked += ack.Points }
+	stats.WritePoint("app.orders", nil, map[string]interface{}{"count": 1})
+	tr.Points(stats)
+	if acked != 1 {
+		t.Fatalf("expected OnWritten to be called got %d points", acked)
+	}
+
+	stats.writeWriterStats(time.Now())
+	points := tr.Points(stats)
+	p := points[len(points)-1]
+	if p.Measurement != stats.config.WriterMeasurement || p.Fields["latency.count"] != int64(1) || p.Fields["latency.le_1s"] != int64(1) {
+		t.Errorf("unexpected writer point %v %v", p.Measurement, p.Fields)
+	}
+}
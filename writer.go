@@ -0,0 +1,577 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+const (
+	defaultBatchSize     = 1000
+	defaultFlushInterval = time.Second
+	maxBackoff           = time.Minute
+)
+
+var (
+	// errPointExpired is reported for points dropped for being older than MaxPointAge.
+	errPointExpired = errors.New("runstats: point older than max point age")
+	// errBackoff is reported for points dropped from a full retry buffer while
+	// backing off after a throttled write.
+	errBackoff = errors.New("runstats: backing off after throttled write")
+)
+
+// partialWriteError is returned by transports writing the points of a batch
+// one at a time, when the first written of them were written before err. Only
+// the others are retried, so that the backend doesn't get them twice.
+type partialWriteError struct {
+	written int
+	err     error
+}
+
+func (e *partialWriteError) Error() string { return e.err.Error() }
+func (e *partialWriteError) Unwrap() error { return e.err }
+
+// rejectedPointsError is returned by transports that skipped the points of a
+// batch they couldn't encode, e.g. with NaN fields in JSON or without any field
+// line protocol can represent, and wrote the others. The skipped points are
+// dropped as rejected rather than failing the batch. err, if not nil, is the
+// error writing the others; a partialWriteError in it counts the points written
+// among them.
+type rejectedPointsError struct {
+	rejected []*point.Point
+	reason   error // encoding error of the first rejected point
+	err      error
+}
+
+func (e *rejectedPointsError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return "rejected " + strconv.Itoa(len(e.rejected)) + " points: " + e.reason.Error()
+}
+
+func (e *rejectedPointsError) Unwrap() error {
+	if e.err != nil {
+		return e.err
+	}
+	return e.reason
+}
+
+// with returns err, the error writing the points that could be encoded, along
+// with the rejected points if there are any.
+func (e *rejectedPointsError) with(err error) error {
+	if e == nil {
+		return err
+	}
+	e.err = err
+	return e
+}
+
+// encodeBatch encodes the points with encode, skipping those it fails for. It
+// returns the encodings of the others, in order, and the error to return with
+// the result of writing them, see rejectedPointsError.with.
+func encodeBatch(points []*point.Point, encode func(*point.Point) ([]byte, error)) ([][]byte, *rejectedPointsError) {
+	encoded := make([][]byte, 0, len(points))
+	var rejected *rejectedPointsError
+	for _, p := range points {
+		b, err := encode(p)
+		if err != nil {
+			if rejected == nil {
+				rejected = &rejectedPointsError{reason: err}
+			}
+			rejected.rejected = append(rejected.rejected, p)
+			continue
+		}
+		encoded = append(encoded, b)
+	}
+	return encoded, rejected
+}
+
+// writtenBefore returns the number of points of a batch written before err.
+func writtenBefore(err error) int {
+	var partial *partialWriteError
+	if errors.As(err, &partial) {
+		return partial.written
+	}
+	return 0
+}
+
+// transport writes batches of points to a backend synchronously.
+type transport interface {
+	Write(ctx context.Context, points []*point.Point) error
+	Close()
+}
+
+// newTransport returns the transport for the configured backend: the Sink if
+// set, the Prometheus Pushgateway if PushgatewayURL is set, StatsD if
+// StatsdAddress is set, Graphite if GraphiteAddress is set, Kafka if
+// KafkaProducer is set, NATS if NatsPublisher is set, a socket if SocketURL is
+// set, a file if OutputFile is set, InfluxDB otherwise. It applies the tag rules
+// of the backend.
+func newTransport(config *Config) (transport, error) {
+	if config.Sink != nil {
+		return withTagRules(newSinkTransport(config.Sink), config.BackendTags), nil
+	}
+	if config.PushgatewayURL != "" {
+		return withTagRules(newPushgatewayTransport(config), config.PushgatewayTags), nil
+	}
+	if config.StatsdAddress != "" {
+		t, err := newStatsdTransport(config)
+		if err != nil {
+			return nil, err
+		}
+		return withTagRules(t, config.BackendTags), nil
+	}
+	if config.GraphiteAddress != "" {
+		return withTagRules(newGraphiteTransport(config), config.BackendTags), nil
+	}
+	if config.KafkaProducer != nil {
+		return withTagRules(newKafkaTransport(config), config.BackendTags), nil
+	}
+	if config.NatsPublisher != nil {
+		return withTagRules(newNatsTransport(config), config.BackendTags), nil
+	}
+	if config.SocketURL != "" {
+		t, err := newSocketTransport(config)
+		if err != nil {
+			return nil, err
+		}
+		return withTagRules(t, config.BackendTags), nil
+	}
+	if config.OutputFile != "" {
+		t, err := newFileTransport(config)
+		if err != nil {
+			return nil, err
+		}
+		return withTagRules(t, config.BackendTags), nil
+	}
+
+	t, err := newInfluxTransport(config)
+	if err != nil {
+		return nil, err
+	}
+	return withTagRules(t, config.InfluxTags), nil
+}
+
+// writer buffers points and writes them in batches through a transport, every
+// flushInterval or as soon as batchSize points are pending. It accounts for every
+// point that could not be written.
+type writer struct {
+	transport     transport
+	batchSize     int
+	flushInterval time.Duration
+	onError       func(err error, points int)
+	onWritten     func(points []*point.Point) // optional
+	onRetry       func(err error, points int) // optional, called for failed batches kept for retry
+	budget        *budget                     // optional, applied to every batch
+	prefix        string                      // optional, prepended to every field name
+
+	// retryLimit is the number of points of failed batches kept for the next
+	// flush, 0 drops them right away. Points older than maxAge, if set, are
+	// dropped instead of written.
+	retryLimit int
+	maxAge     time.Duration
+	now        func() time.Time
+	retry      []*point.Point // guarded by flushMu
+
+	// maxRetries, if set, is the number of failed writes after which a point is
+	// dropped rather than kept for retry. attempts counts the failed writes of
+	// the points in retry and is guarded by flushMu.
+	maxRetries int
+	attempts   map[*point.Point]int
+
+	// aggregate, if set, writes only the latest point of every series per flush.
+	// tees are the writers of the further sinks points are fanned out to.
+	aggregate bool
+	tees      []*writer
+
+	// After a throttled write no points are written before backoffUntil. The
+	// backoff doubles with every throttled write, up to maxBackoff, and is reset by
+	// a successful one. Both are guarded by flushMu.
+	backoff      time.Duration
+	backoffUntil time.Time
+
+	mu      sync.Mutex
+	pending []*point.Point
+	closed  bool
+	errors  map[errorClass]int64 // failed writes by class
+
+	flushMu sync.Mutex // serializes writes to the transport
+	dropped int64      // accessed atomically
+
+	full chan struct{}
+	stop chan struct{}
+	done chan struct{}
+
+	// ctx is used by the background flushes and cancelled when the deadline
+	// given to Close expires.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func newWriter(t transport, batchSize int, flushInterval time.Duration) *writer {
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+
+	w := &writer{
+		transport:     t,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		onError:       func(error, int) {},
+		now:           time.Now,
+		full:          make(chan struct{}, 1),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	go w.run()
+	return w
+}
+
+func (w *writer) run() {
+	defer close(w.done)
+
+	tick := time.NewTicker(w.flushInterval)
+	defer tick.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-tick.C:
+		case <-w.full:
+		}
+		w.Flush(w.ctx)
+	}
+}
+
+// WritePoint queues a point. Points written after Close are dropped.
+func (w *writer) WritePoint(measurement string, tags map[string]string, fields map[string]interface{}, ts time.Time) {
+	w.Write(&point.Point{
+		Measurement: measurement,
+		Tags:        tags,
+		Fields:      fields,
+		Time:        ts,
+	})
+}
+
+// Write queues p, like WritePoint.
+func (w *writer) Write(p *point.Point) {
+	if w.prefix != "" {
+		p = prefixFields(w.prefix, p)
+	}
+	for _, tee := range w.tees {
+		tee.Write(p)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		atomic.AddInt64(&w.dropped, 1)
+		return
+	}
+
+	w.pending = append(w.pending, p)
+	if len(w.pending) >= w.batchSize {
+		select {
+		case w.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Flush writes the points of failed batches kept for retry and all pending
+// points in batches. Points of batches failing with a retryable error are kept
+// for the next flush up to retryLimit and dropped otherwise; the first error is
+// returned. While backing off after a throttled write, points are only kept.
+func (w *writer) Flush(ctx context.Context) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	pending := append(w.retry, w.pending...)
+	w.pending, w.retry = nil, nil
+	closed := w.closed
+	w.mu.Unlock()
+
+	pending = w.expire(pending)
+	if w.aggregate {
+		pending = latestPerSeries(pending)
+	}
+
+	var firstErr error
+	for len(pending) > 0 {
+		n := len(pending)
+		if n > w.batchSize {
+			n = w.batchSize
+		}
+
+		if !closed && w.now().Before(w.backoffUntil) {
+			w.retryLater(errBackoff, pending)
+			break
+		}
+
+		if err := ctx.Err(); err != nil {
+			// Out of time, drop everything left
+			n = len(pending)
+			w.drop(err, n)
+			if firstErr == nil {
+				firstErr = err
+			}
+		} else if batch := w.budgeted(pending[:n]); len(batch) > 0 {
+			err := w.transport.Write(ctx, batch)
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			batch, err = w.reject(err, batch, closed)
+			written := len(batch)
+			if err != nil {
+				written = writtenBefore(err)
+			}
+			if written > 0 {
+				w.backoff = 0
+				if w.onWritten != nil {
+					w.onWritten(batch[:written])
+				}
+			}
+			if err != nil {
+				w.failed(err, batch[written:], closed)
+			}
+		}
+		pending = pending[n:]
+	}
+	w.pruneAttempts()
+	return firstErr
+}
+
+// reject drops the points of batch a transport rejected, see
+// rejectedPointsError, and returns the others with the error writing them.
+func (w *writer) reject(err error, batch []*point.Point, closed bool) ([]*point.Point, error) {
+	var rejected *rejectedPointsError
+	if !errors.As(err, &rejected) {
+		return batch, err
+	}
+	w.failed(&rejectedPointsError{rejected: rejected.rejected, reason: rejected.reason}, rejected.rejected, closed)
+
+	skip := make(map[*point.Point]bool, len(rejected.rejected))
+	for _, p := range rejected.rejected {
+		skip[p] = true
+	}
+	rest := make([]*point.Point, 0, len(batch))
+	for _, p := range batch {
+		if !skip[p] {
+			rest = append(rest, p)
+		}
+	}
+	return rest, rejected.err
+}
+
+// failed applies the policy of the class of err to the points of a failed batch:
+// retryable ones are kept for retry unless closed, throttled ones also start a
+// backoff, others are dropped.
+func (w *writer) failed(err error, batch []*point.Point, closed bool) {
+	class := classifyError(err)
+	w.mu.Lock()
+	if w.errors == nil {
+		w.errors = map[errorClass]int64{}
+	}
+	w.errors[class]++
+	w.mu.Unlock()
+
+	if closed || w.retryLimit == 0 || !class.retryable() {
+		w.drop(err, len(batch))
+		return
+	}
+	kept := w.countAttempt(err, batch)
+	if w.onRetry != nil && len(kept) > 0 {
+		w.onRetry(err, len(kept))
+	}
+	w.retryLater(err, kept)
+
+	if class == classThrottled {
+		switch {
+		case w.backoff == 0:
+			w.backoff = w.flushInterval
+		case w.backoff < maxBackoff:
+			w.backoff *= 2
+		}
+		if w.backoff > maxBackoff {
+			w.backoff = maxBackoff
+		}
+		w.backoffUntil = w.now().Add(w.backoff)
+	}
+}
+
+// Errors returns the number of failed writes so far by error class.
+func (w *writer) Errors() map[errorClass]int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	counts := make(map[errorClass]int64, len(errorClasses))
+	for _, class := range errorClasses {
+		counts[class] = w.errors[class]
+	}
+	return counts
+}
+
+// expire drops the points older than maxAge, which would skew rate queries if
+// written long after the fact.
+func (w *writer) expire(points []*point.Point) []*point.Point {
+	if w.maxAge <= 0 {
+		return points
+	}
+
+	cutoff := w.now().Add(-w.maxAge)
+	kept := points[:0]
+	for _, p := range points {
+		if !p.Time.Before(cutoff) {
+			kept = append(kept, p)
+		}
+	}
+	if expired := len(points) - len(kept); expired > 0 {
+		w.drop(errPointExpired, expired)
+	}
+	return kept
+}
+
+// retryLater keeps the points of a failed batch for the next flush. When the
+// retry buffer is full the oldest points are dropped.
+func (w *writer) retryLater(err error, batch []*point.Point) {
+	w.retry = append(w.retry, batch...)
+	if overflow := len(w.retry) - w.retryLimit; overflow > 0 {
+		w.drop(withKind(ErrBufferFull, err), overflow)
+		w.retry = append(w.retry[:0:0], w.retry[overflow:]...)
+	}
+}
+
+// countAttempt counts a failed write of the points of batch and drops those
+// that failed more than maxRetries times. It returns the others.
+func (w *writer) countAttempt(err error, batch []*point.Point) []*point.Point {
+	if w.maxRetries <= 0 {
+		return batch
+	}
+	if w.attempts == nil {
+		w.attempts = map[*point.Point]int{}
+	}
+	kept := make([]*point.Point, 0, len(batch))
+	for _, p := range batch {
+		w.attempts[p]++
+		if w.attempts[p] > w.maxRetries {
+			delete(w.attempts, p)
+			continue
+		}
+		kept = append(kept, p)
+	}
+	if dropped := len(batch) - len(kept); dropped > 0 {
+		w.drop(err, dropped)
+	}
+	return kept
+}
+
+// pruneAttempts forgets the failed writes of the points no longer kept for
+// retry, written or dropped since.
+func (w *writer) pruneAttempts() {
+	if len(w.attempts) <= len(w.retry) {
+		return
+	}
+	attempts := make(map[*point.Point]int, len(w.retry))
+	for _, p := range w.retry {
+		if n, ok := w.attempts[p]; ok {
+			attempts[p] = n
+		}
+	}
+	w.attempts = attempts
+}
+
+// budgeted applies the budget to batch. Points that don't fit are dropped but,
+// being deliberate, not reported as a Flush error.
+func (w *writer) budgeted(batch []*point.Point) []*point.Point {
+	if w.budget == nil {
+		return batch
+	}
+	batch, dropped := w.budget.apply(batch)
+	if dropped > 0 {
+		w.drop(errBudgetExceeded, dropped)
+	}
+	return batch
+}
+
+// prefixFields returns a copy of p with prefix prepended to its field names.
+func prefixFields(prefix string, p *point.Point) *point.Point {
+	prefixed := *p
+	prefixed.Fields = make(map[string]interface{}, len(p.Fields))
+	for k, v := range p.Fields {
+		prefixed.Fields[prefix+k] = v
+	}
+	if p.Units != nil {
+		prefixed.Units = make(map[string]string, len(p.Units))
+		for k, v := range p.Units {
+			prefixed.Units[prefix+k] = v
+		}
+	}
+	return &prefixed
+}
+
+func (w *writer) drop(err error, points int) {
+	atomic.AddInt64(&w.dropped, int64(points))
+	w.onError(err, points)
+}
+
+// Close stops the background flushing, flushes the pending points within ctx and
+// closes the transport. It returns a *DroppedError if points were dropped while
+// flushing.
+func (w *writer) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	// Abort a background flush in progress once ctx is done
+	closed := make(chan struct{})
+	defer close(closed)
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.cancel()
+		case <-closed:
+		}
+	}()
+
+	before := w.Dropped()
+	close(w.stop)
+	<-w.done
+
+	err := w.Flush(ctx)
+	w.transport.Close()
+	w.cancel()
+	if teeErr := w.closeTees(ctx); err == nil {
+		err = teeErr
+	}
+
+	if dropped := w.Dropped() - before; dropped > 0 {
+		return &DroppedError{Dropped: dropped, Err: err}
+	}
+	return nil
+}
+
+// Dropped returns the number of points dropped so far, including those of the
+// sinks fanned out to.
+func (w *writer) Dropped() int64 {
+	dropped := atomic.LoadInt64(&w.dropped)
+	for _, tee := range w.tees {
+		dropped += tee.Dropped()
+	}
+	return dropped
+}
@@ -0,0 +1,352 @@
+package runstats
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nzlov/go-runtime-metrics/collector"
+	"github.com/nzlov/go-runtime-metrics/point"
+)
+
+// testTransport records all written points.
+type testTransport struct {
+	mu     sync.Mutex
+	points []*point.Point
+	writes int
+	closed bool
+
+	// err, if set, is returned by Write.
+	err error
+	// block, if set, makes Write wait for ctx to be done.
+	block bool
+}
+
+func (t *testTransport) Write(ctx context.Context, points []*point.Point) error {
+	if t.block {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.writes++
+	if t.err != nil {
+		return t.err
+	}
+	t.points = append(t.points, points...)
+	return nil
+}
+
+func (t *testTransport) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+}
+
+// Points flushes the writer of stats and returns all points written so far.
+func (t *testTransport) Points(stats *RunStats) []*point.Point {
+	stats.writer.Flush(context.Background())
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*point.Point(nil), t.points...)
+}
+
+// newTestRunStats returns a RunStats writing to a testTransport. Its collector isn't running.
+func newTestRunStats(config *Config) (*RunStats, *testTransport) {
+	if config == nil {
+		config = &Config{}
+	}
+	config, _ = config.init()
+
+	t := &testTransport{}
+	stats := &RunStats{
+		config:    config,
+		writer:    newWriter(t, defaultBatchSize, time.Hour),
+		tags:      config.staticTags(),
+		collector: collector.New(nil),
+		started:   time.Now(),
+		done:      make(chan struct{}),
+		stopped:   make(chan struct{}),
+	}
+	stats.writer.prefix = config.Prefix
+	close(stats.stopped)
+	return stats, t
+}
+
+func TestWriterBatches(t *testing.T) {
+	tr := &testTransport{}
+	w := newWriter(tr, 2, time.Hour)
+	defer w.Close(context.Background())
+
+	for i := 0; i < 5; i++ {
+		w.WritePoint("m", nil, map[string]interface{}{"i": i}, time.Now())
+	}
+	w.Flush(context.Background())
+
+	if len(tr.points) != 5 || tr.writes != 3 {
+		t.Errorf("expected 5 points in 3 writes got %d in %d", len(tr.points), tr.writes)
+	}
+}
+
+func TestWriterDropAccounting(t *testing.T) {
+	tr := &testTransport{err: errors.New("backend unavailable")}
+	w := newWriter(tr, 10, time.Hour)
+
+	for i := 0; i < 3; i++ {
+		w.WritePoint("m", nil, map[string]interface{}{"i": i}, time.Now())
+	}
+	if err := w.Flush(context.Background()); err == nil {
+		t.Error("expected flush error")
+	}
+	if dropped := w.Dropped(); dropped != 3 {
+		t.Errorf("expected 3 dropped points got %d", dropped)
+	}
+
+	// Points that can't be flushed before the deadline are reported by Close
+	tr.err, tr.block = nil, true
+	for i := 0; i < 4; i++ {
+		w.WritePoint("m", nil, map[string]interface{}{"i": i}, time.Now())
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := w.Close(ctx)
+	var dropErr *DroppedError
+	if !errors.As(err, &dropErr) || dropErr.Dropped != 4 || !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected 4 points dropped on deadline got %v", err)
+	}
+	if !tr.closed {
+		t.Error("expected transport to be closed")
+	}
+
+	w.WritePoint("m", nil, map[string]interface{}{"i": 0}, time.Now())
+	if dropped := w.Dropped(); dropped != 8 {
+		t.Errorf("expected writes after close to be dropped got %d", dropped)
+	}
+}
+
+func TestWriterBudget(t *testing.T) {
+	tr := &testTransport{}
+	w := newWriter(tr, defaultBatchSize, time.Hour)
+	defer w.Close(context.Background())
+
+	now := time.Unix(0, 0)
+	fields := func() map[string]interface{} {
+		return map[string]interface{}{"critical": 1, "extra": 2}
+	}
+	full, _ := point.AppendLineProtocol(nil, &point.Point{Measurement: "m", Fields: fields(), Time: now})
+	w.budget = newBudget(len(full)+20, time.Minute, []string{"critical"})
+	w.budget.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		w.WritePoint("m", nil, fields(), now)
+	}
+	w.Flush(context.Background())
+
+	// The first point fits, the second is reduced to its critical field and the
+	// third doesn't fit at all
+	if len(tr.points) != 2 || len(tr.points[0].Fields) != 2 || len(tr.points[1].Fields) != 1 {
+		t.Fatalf("unexpected points %v", tr.points)
+	}
+	if dropped := w.Dropped(); dropped != 1 {
+		t.Errorf("expected 1 dropped point got %d", dropped)
+	}
+	if dropped := w.budget.droppedFields; dropped != 3 {
+		t.Errorf("expected 3 dropped fields got %d", dropped)
+	}
+
+	// A new window resets the budget
+	now = now.Add(time.Minute)
+	w.WritePoint("m", nil, fields(), now)
+	w.Flush(context.Background())
+	if len(tr.points) != 3 || len(tr.points[2].Fields) != 2 {
+		t.Errorf("expected full point in the next window got %v", tr.points[2:])
+	}
+
+	// A reduced point keeps the units of its critical field
+	now = now.Add(time.Minute)
+	p := &point.Point{Measurement: "m", Fields: fields(), Time: time.Unix(0, 0), Units: map[string]string{"critical": "bytes", "extra": "ns"}}
+	kept, _ := w.budget.apply([]*point.Point{p, p})
+	if len(kept) != 2 || !reflect.DeepEqual(kept[1].Units, map[string]string{"critical": "bytes"}) {
+		t.Errorf("expected the reduced point to keep the critical unit got %v", kept)
+	}
+}
+
+func TestWriterRetryAndExpiry(t *testing.T) {
+	tr := &testTransport{err: errors.New("backend unavailable")}
+	w := newWriter(tr, defaultBatchSize, time.Hour)
+	defer w.Close(context.Background())
+	var dropErr error
+	w.onError = func(err error, points int) { dropErr = err }
+	w.retryLimit = 3
+	w.maxAge = time.Minute
+	now := time.Now()
+	w.now = func() time.Time { return now }
+
+	for i := 0; i < 4; i++ {
+		w.WritePoint("m", nil, map[string]interface{}{"i": i}, now.Add(time.Duration(i)*time.Second))
+	}
+	w.Flush(context.Background())
+	if dropped := w.Dropped(); dropped != 1 || len(w.retry) != 3 {
+		t.Fatalf("expected the oldest point dropped and 3 kept got %d dropped %d kept", dropped, len(w.retry))
+	}
+	if !errors.Is(dropErr, ErrBufferFull) || !errors.Is(dropErr, tr.err) {
+		t.Errorf("expected ErrBufferFull wrapping the write error got %v", dropErr)
+	}
+
+	// Recovered, but some points are too old by now
+	tr.err = nil
+	now = now.Add(time.Minute + 1500*time.Millisecond)
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.points) != 2 || w.Dropped() != 2 {
+		t.Errorf("expected 2 points written and 1 more expired got %d written %d dropped", len(tr.points), w.Dropped())
+	}
+}
+
+func TestWriterMaxRetries(t *testing.T) {
+	tr := &testTransport{err: errors.New("backend unavailable")}
+	w := newWriter(tr, defaultBatchSize, time.Hour)
+	defer w.Close(context.Background())
+	w.retryLimit = 10
+	w.maxRetries = 2
+
+	w.WritePoint("m", nil, map[string]interface{}{"i": 0}, time.Now())
+	w.Flush(context.Background())
+	w.WritePoint("m", nil, map[string]interface{}{"i": 1}, time.Now())
+	w.Flush(context.Background())
+	if w.Dropped() != 0 || len(w.retry) != 2 {
+		t.Fatalf("expected both points kept got %d dropped %d kept", w.Dropped(), len(w.retry))
+	}
+
+	// The first point failed a third time
+	w.Flush(context.Background())
+	if w.Dropped() != 1 || len(w.retry) != 1 || len(w.attempts) != 1 {
+		t.Fatalf("expected the first point dropped got %d dropped %d kept %d counted", w.Dropped(), len(w.retry), len(w.attempts))
+	}
+
+	tr.err = nil
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.points) != 1 || len(w.attempts) != 0 {
+		t.Errorf("expected the second point written and forgotten got %d written %d counted", len(tr.points), len(w.attempts))
+	}
+}
+
+func TestWriterRejectedPoints(t *testing.T) {
+	producer := &testProducer{failAfter: 2}
+	config, _ := (&Config{KafkaProducer: producer}).init()
+	w := newWriter(newKafkaTransport(config), defaultBatchSize, time.Hour)
+	defer w.Close(context.Background())
+	w.retryLimit = 10
+	var written int
+	w.onWritten = func(points []*point.Point) { written += len(points) }
+
+	// JSON can't encode the NaN of the second point, the fourth one fails to be produced
+	for _, v := range []float64{1, math.NaN(), 2, 3} {
+		w.WritePoint("m", nil, map[string]interface{}{"v": v}, time.Now())
+	}
+	if err := w.Flush(context.Background()); err == nil {
+		t.Fatal("expected the write error")
+	}
+	if len(producer.values) != 2 || written != 2 || w.Dropped() != 1 || len(w.retry) != 1 || w.retry[0].Fields["v"] != 3.0 {
+		t.Errorf("expected 2 points written, the NaN one dropped and the last kept got %d written %d dropped %v kept", written, w.Dropped(), w.retry)
+	}
+	if errs := w.Errors(); errs[classRejected] != 1 || errs[classUnavailable] != 1 {
+		t.Errorf("expected a rejected and an unavailable error got %v", errs)
+	}
+}
+
+func TestConfigBatching(t *testing.T) {
+	config, err := (&Config{}).init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.BatchSize != defaultBatchSize || config.flushInterval() != defaultFlushInterval {
+		t.Errorf("unexpected defaults %d %v", config.BatchSize, config.flushInterval())
+	}
+
+	for _, c := range []*Config{{BatchSize: -1}, {FlushInterval: -time.Second}, {MaxRetries: -1}} {
+		if _, err := c.init(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("expected ErrInvalidConfig for %+v got %v", c, err)
+		}
+	}
+
+	stats, tr := newTestRunStats(nil)
+	stats.writer.WritePoint("m", nil, map[string]interface{}{"i": 0}, time.Now())
+	if err := stats.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(tr.points) != 1 {
+		t.Errorf("expected the point written by Flush got %d", len(tr.points))
+	}
+}
+
+func TestWriteAck(t *testing.T) {
+	var acks []WriteAck
+	stats, _ := newTestRunStats(&Config{
+		OnWritten: func(ack WriteAck) { acks = append(acks, ack) },
+	})
+	stats.writer.onWritten = func(points []*point.Point) {
+		stats.config.OnWritten(newWriteAck(points, time.Now()))
+	}
+
+	start := time.Now().Add(-time.Second)
+	stats.writer.WritePoint("m", nil, map[string]interface{}{"i": 1}, start.Add(time.Millisecond))
+	stats.writer.WritePoint("m", nil, map[string]interface{}{"i": 0}, start)
+	stats.writer.Flush(context.Background())
+
+	if len(acks) != 1 || acks[0].Points != 2 || len(acks[0].Timestamps) != 2 {
+		t.Fatalf("expected a single ack for 2 points got %+v", acks)
+	}
+	if !acks[0].Oldest.Equal(start) || !acks[0].Newest.Equal(start.Add(time.Millisecond)) || acks[0].Written.Sub(acks[0].Oldest) < time.Second {
+		t.Errorf("unexpected ack %+v", acks[0])
+	}
+}
+
+func TestWriterErrorPolicies(t *testing.T) {
+	tr := &testTransport{err: &statusError{http.StatusUnauthorized, errors.New("unauthorized")}}
+	w := newWriter(tr, defaultBatchSize, time.Hour)
+	defer w.Close(context.Background())
+	w.retryLimit = 10
+	now := time.Now()
+	w.now = func() time.Time { return now }
+
+	// Auth failures aren't retried
+	w.WritePoint("m", nil, map[string]interface{}{"i": 0}, now)
+	w.Flush(context.Background())
+	if w.Dropped() != 1 || len(w.retry) != 0 {
+		t.Fatalf("expected the point dropped got %d dropped %d kept", w.Dropped(), len(w.retry))
+	}
+
+	// Throttled writes are kept and back off
+	tr.err = withKind(ErrBackendUnavailable, &statusError{http.StatusTooManyRequests, errors.New("slow down")})
+	w.WritePoint("m", nil, map[string]interface{}{"i": 1}, now)
+	w.Flush(context.Background())
+	w.WritePoint("m", nil, map[string]interface{}{"i": 2}, now)
+	w.Flush(context.Background())
+	if tr.writes != 2 || len(w.retry) != 2 {
+		t.Fatalf("expected no write while backing off got %d writes %d kept", tr.writes, len(w.retry))
+	}
+
+	tr.err = nil
+	now = now.Add(w.flushInterval)
+	w.Flush(context.Background())
+	if len(tr.points) != 2 || w.backoff != 0 {
+		t.Errorf("expected the kept points written after the backoff got %d", len(tr.points))
+	}
+
+	errs := w.Errors()
+	if errs[classAuth] != 1 || errs[classThrottled] != 1 || errs[classUnavailable] != 0 {
+		t.Errorf("unexpected error counts %v", errs)
+	}
+}